@@ -0,0 +1,100 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/wrble/flock"
+	"github.com/wrble/flock/document"
+	"golang.org/x/net/context"
+)
+
+var setFields string
+
+// deleteByQueryCmd represents the deletebyquery command
+var deleteByQueryCmd = &cobra.Command{
+	Use:   "deletebyquery [index path] [query]",
+	Short: "deletes the documents matching a query",
+	Long:  `The deletebyquery command will delete the documents of the index matching a query, in batches.`,
+	Annotations: map[string]string{
+		canMutateBleveIndex: "true",
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return fmt.Errorf("must specify query")
+		}
+
+		status, err := idx.DeleteByQuery(byQueryContext(), buildQuery(args))
+		if err != nil {
+			return fmt.Errorf("error deleting documents: %v", err)
+		}
+		fmt.Printf("matched %d, deleted %d, conflicts %d in %v\n", status.Matched, status.Deleted, status.Conflicts, status.Took)
+		return nil
+	},
+}
+
+// updateByQueryCmd represents the updatebyquery command
+var updateByQueryCmd = &cobra.Command{
+	Use:   "updatebyquery [index path] [query]",
+	Short: "updates fields of the documents matching a query",
+	Long:  `The updatebyquery command will replace the fields given as a JSON object in the documents of the index matching a query, in batches.`,
+	Annotations: map[string]string{
+		canMutateBleveIndex: "true",
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return fmt.Errorf("must specify query")
+		}
+		var fields map[string]interface{}
+		err := json.Unmarshal([]byte(setFields), &fields)
+		if err != nil {
+			return fmt.Errorf("error parsing fields: %v", err)
+		}
+		if len(fields) == 0 {
+			return fmt.Errorf("must specify fields to set")
+		}
+
+		status, err := idx.UpdateByQuery(byQueryContext(), buildQuery(args), flock.UpdaterFunc(func(doc *document.Document) (map[string]interface{}, error) {
+			return fields, nil
+		}))
+		if err != nil {
+			return fmt.Errorf("error updating documents: %v", err)
+		}
+		fmt.Printf("matched %d, updated %d, conflicts %d in %v\n", status.Matched, status.Updated, status.Conflicts, status.Took)
+		return nil
+	},
+}
+
+// byQueryContext returns a context printing the progress of each batch
+func byQueryContext() context.Context {
+	return flock.WithByQueryProgress(context.Background(), func(status flock.ByQueryStatus) {
+		fmt.Printf("batch %d (%d docs)...\n", status.Batches, status.Matched)
+	})
+}
+
+func init() {
+	RootCmd.AddCommand(deleteByQueryCmd)
+	RootCmd.AddCommand(updateByQueryCmd)
+
+	for _, cmd := range []*cobra.Command{deleteByQueryCmd, updateByQueryCmd} {
+		cmd.Flags().IntVar(&flock.ByQueryBatchSize, "batch", flock.ByQueryBatchSize, "Batch size for writing, default 100.")
+		cmd.Flags().StringVarP(&qtype, "type", "t", "query_string", "Type of query to run, defaults to 'query_string'")
+		cmd.Flags().StringVarP(&qfield, "field", "f", "", "Restrict query to field, by default no restriction, not applicable to query_string queries.")
+	}
+	updateByQueryCmd.Flags().StringVar(&setFields, "set", "", "Fields to set, as a JSON object.")
+}
@@ -24,7 +24,7 @@ import (
 var countCmd = &cobra.Command{
 	Use:   "count [index path]",
 	Short: "counts the number documents in the index",
-	Long:  `The count command will count the number of documents in the index.`,
+	Long:  `The count command will print the number of documents in the index, as kept by the index.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		count, err := idx.DocCount()
 		if err != nil {
@@ -0,0 +1,100 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/wrble/flock/registry"
+)
+
+// legacyRowMigrator is implemented by stores which changed their
+// layout and can copy rows written with the previous one
+type legacyRowMigrator interface {
+	MigrateLegacyRows(progress func(table string, rows int)) error
+}
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate [index path]",
+	Short: "migrates the stored rows of an index to the current layout",
+	Long:  `The migrate command upgrades the storage schema and copies rows written with an older layout, again if opening the index already copied them.`,
+	Annotations: map[string]string{
+		canMutateBleveIndex: "true",
+	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// override RootCmd version, only the store is opened
+		if len(args) < 1 {
+			return fmt.Errorf("must specify path to index")
+		}
+		return nil
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		metaBytes, err := ioutil.ReadFile(filepath.Join(args[0], "index_meta.json"))
+		if err != nil {
+			return fmt.Errorf("error reading index metadata: %v", err)
+		}
+		var meta struct {
+			Storage string                 `json:"storage"`
+			Config  map[string]interface{} `json:"config"`
+		}
+		err = json.Unmarshal(metaBytes, &meta)
+		if err != nil {
+			return fmt.Errorf("error parsing index metadata: %v", err)
+		}
+		if meta.Config == nil {
+			meta.Config = map[string]interface{}{}
+		}
+		meta.Config["path"] = filepath.Join(args[0], "store")
+
+		storeConstructor := registry.KVStoreConstructorByName(meta.Storage)
+		if storeConstructor == nil {
+			return fmt.Errorf("unknown storage type: %s", meta.Storage)
+		}
+		// opening the store upgrades its schema and copies the
+		// legacy rows of a first open
+		kvstore, err := storeConstructor(nil, meta.Config)
+		if err != nil {
+			return fmt.Errorf("error opening store: %v", err)
+		}
+		defer func() {
+			_ = kvstore.Close()
+		}()
+
+		migrator, ok := kvstore.(legacyRowMigrator)
+		if !ok {
+			fmt.Printf("storage type %s has no rows to migrate\n", meta.Storage)
+			return nil
+		}
+		err = migrator.MigrateLegacyRows(func(table string, rows int) {
+			fmt.Printf("table %s: copied %d rows\n", table, rows)
+		})
+		if err != nil {
+			return fmt.Errorf("error migrating rows: %v", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(migrateCmd)
+}
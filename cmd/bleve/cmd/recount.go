@@ -0,0 +1,61 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// docCountReconciler is implemented by indexes keeping a document
+// counter which can be recounted
+type docCountReconciler interface {
+	ReconcileDocCount() (stored, counted uint64, err error)
+}
+
+// recountCmd represents the recount command
+var recountCmd = &cobra.Command{
+	Use:   "recount [index path]",
+	Short: "recounts the documents in the index",
+	Long:  `The recount command will count the documents of the index one by one and correct the stored document count.`,
+	Annotations: map[string]string{
+		canMutateBleveIndex: "true",
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		i, _, err := idx.Advanced()
+		if err != nil {
+			return fmt.Errorf("error getting index: %v", err)
+		}
+		reconciler, ok := i.(docCountReconciler)
+		if !ok {
+			return fmt.Errorf("index does not keep a document count")
+		}
+		stored, counted, err := reconciler.ReconcileDocCount()
+		if err != nil {
+			return fmt.Errorf("error recounting docs in index: %v", err)
+		}
+		if stored != counted {
+			fmt.Printf("corrected count from %d to %d\n", stored, counted)
+		} else {
+			fmt.Printf("%d\n", counted)
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(recountCmd)
+}
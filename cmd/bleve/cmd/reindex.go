@@ -0,0 +1,103 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/wrble/flock"
+)
+
+var reindexBatchSize, reindexConcurrency int
+var reindexCheckpoint string
+
+// reindexCmd represents the reindex command
+var reindexCmd = &cobra.Command{
+	Use:   "reindex [index path] [destination index path]",
+	Short: "reindexes the documents into another index",
+	Long:  `The reindex command will index the documents of the index again into the destination index, created with the mapping given unless it exists.  An interrupted reindex run again resumes where it stopped.`,
+	Annotations: map[string]string{
+		canMutateBleveIndex: "true",
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return fmt.Errorf("must specify path to destination index")
+		}
+
+		dst, err := openOrCreateIndex(args[1])
+		if err != nil {
+			return err
+		}
+		defer func() {
+			cerr := dst.Close()
+			if cerr != nil {
+				fmt.Printf("error closing destination index: %v\n", cerr)
+			}
+		}()
+
+		opts := &flock.ReindexOptions{
+			BatchSize:   reindexBatchSize,
+			Concurrency: reindexConcurrency,
+			Progress: func(status flock.ReindexStatus) {
+				fmt.Printf("batch %d (%d docs)...\n", status.Batches, status.Indexed)
+			},
+		}
+		if reindexCheckpoint != "" {
+			opts.Checkpoint = []byte(reindexCheckpoint)
+		}
+		status, err := flock.Reindex(idx, dst, opts)
+		if err != nil {
+			return fmt.Errorf("error reindexing: %v", err)
+		}
+		if status.Resumed != "" {
+			fmt.Printf("resumed after '%s'\n", status.Resumed)
+		}
+		fmt.Printf("read %d, indexed %d, skipped %d in %v\n", status.Read, status.Indexed, status.Skipped, status.Took)
+		return nil
+	},
+}
+
+// openOrCreateIndex opens the index at the path, or creates it with the
+// mapping, index and store types of the flags when there is none
+func openOrCreateIndex(path string) (flock.Index, error) {
+	rv, err := flock.Open(path)
+	if err != flock.ErrorIndexPathDoesNotExist {
+		if err != nil {
+			return nil, fmt.Errorf("error opening destination index: %v", err)
+		}
+		return rv, nil
+	}
+	mapping, err := buildMapping()
+	if err != nil {
+		return nil, fmt.Errorf("error building mapping: %v", err)
+	}
+	rv, err = flock.NewUsing(path, mapping, indexType, storeType, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating destination index: %v", err)
+	}
+	return rv, nil
+}
+
+func init() {
+	RootCmd.AddCommand(reindexCmd)
+
+	reindexCmd.Flags().StringVarP(&mappingPath, "mapping", "m", "", "Path to a file containing a JSON representation of the mapping of the destination index to create.")
+	reindexCmd.Flags().StringVarP(&storeType, "store", "s", flock.Config.DefaultKVStore, "The bleve storage type of the destination index to create.")
+	reindexCmd.Flags().StringVarP(&indexType, "index", "i", flock.Config.DefaultIndexType, "The bleve index type of the destination index to create.")
+	reindexCmd.Flags().IntVar(&reindexBatchSize, "batch", flock.ReindexBatchSize, "Batch size for writing, default 100.")
+	reindexCmd.Flags().IntVar(&reindexConcurrency, "concurrency", 1, "Number of batches written at the same time, default 1.")
+	reindexCmd.Flags().StringVar(&reindexCheckpoint, "checkpoint", "_reindex", "Internal key of the destination index the progress is kept under to resume, empty for none.")
+}
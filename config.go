@@ -21,7 +21,7 @@ import (
 	"time"
 
 	"github.com/wrble/flock/index"
-	"github.com/wrble/flock/index/store/cassandra"
+	"github.com/wrble/flock/index/store/memory"
 	"github.com/wrble/flock/index/upsidedown"
 	"github.com/wrble/flock/registry"
 	"github.com/wrble/flock/search/highlight/highlighter/html"
@@ -66,7 +66,7 @@ func init() {
 	// default kv store
 	Config.DefaultKVStore = ""
 
-	Config.DefaultMemKVStore = cassandra.Name
+	Config.DefaultMemKVStore = memory.Name
 
 	// default index
 	Config.DefaultIndexType = upsidedown.Name
@@ -30,6 +30,13 @@ import (
 	_ "github.com/wrble/flock/search/highlight/highlighter/html"
 	_ "github.com/wrble/flock/search/highlight/highlighter/simple"
 
+	// similarities
+	_ "github.com/wrble/flock/search/similarity/bm25"
+	_ "github.com/wrble/flock/search/similarity/classic"
+	_ "github.com/wrble/flock/search/similarity/dfr"
+	_ "github.com/wrble/flock/search/similarity/lmdirichlet"
+	_ "github.com/wrble/flock/search/similarity/lmjelinekmercer"
+
 	// char filters
 	_ "github.com/wrble/flock/analysis/char/html"
 	_ "github.com/wrble/flock/analysis/char/regexp"
@@ -64,6 +71,10 @@ import (
 	_ "github.com/wrble/flock/analysis/tokenizer/web"
 	_ "github.com/wrble/flock/analysis/tokenizer/whitespace"
 
+	// kv stores
+	_ "github.com/wrble/flock/index/store/boltdb"
+	_ "github.com/wrble/flock/index/store/memory"
+
 	// date time parsers
 	_ "github.com/wrble/flock/analysis/datetime/flexible"
 	_ "github.com/wrble/flock/analysis/datetime/optional"
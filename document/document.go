@@ -14,12 +14,36 @@
 
 package document
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type Document struct {
 	ID              string  `json:"id"`
 	Fields          []Field `json:"fields"`
 	CompositeFields []*CompositeField
+	// Boost multiplies the score of every field of the document, 0 is
+	// no boost
+	Boost float64 `json:"boost,omitempty"`
+	// FieldBoosts multiply the score of the named fields on top of Boost
+	FieldBoosts map[string]float64 `json:"field_boosts,omitempty"`
+	// Version is the version of the document read from the index.  A
+	// document written with a version other than 0 is only written if
+	// the index still holds that version.
+	Version uint64 `json:"version,omitempty"`
+	// Expires is when the document expires, searches no longer find it
+	// from then on until it is removed from the index.  The zero time
+	// never expires.
+	Expires time.Time `json:"expires"`
+	// Nested are the documents indexed apart from this one for the
+	// sub-objects of its nested properties, at any depth.  They are
+	// written and deleted along with it.
+	Nested []*Document `json:"nested,omitempty"`
+	// Source is the JSON the document was mapped from, kept by the
+	// index when the mapping stores sources
+	Source json.RawMessage `json:"source,omitempty"`
 }
 
 func NewDocument(id string) *Document {
@@ -30,6 +54,22 @@ func NewDocument(id string) *Document {
 	}
 }
 
+// MarshalJSON leaves the expiry out of documents which do not expire,
+// omitempty has no effect on a time.Time
+func (d *Document) MarshalJSON() ([]byte, error) {
+	type document Document
+	rv := struct {
+		*document
+		Expires *time.Time `json:"expires,omitempty"`
+	}{
+		document: (*document)(d),
+	}
+	if !d.Expires.IsZero() {
+		rv.Expires = &d.Expires
+	}
+	return json.Marshal(rv)
+}
+
 func (d *Document) AddField(f Field) *Document {
 	switch f := f.(type) {
 	case *CompositeField:
@@ -40,6 +80,67 @@ func (d *Document) AddField(f Field) *Document {
 	return d
 }
 
+// AddNested adds a nested document, its id is made with NestedID
+func (d *Document) AddNested(nested *Document) *Document {
+	d.Nested = append(d.Nested, nested)
+	return d
+}
+
+// SetFieldBoost sets the index time boost of the named field, boosts
+// which are not positive are ignored
+func (d *Document) SetFieldBoost(name string, boost float64) *Document {
+	if boost > 0 {
+		if d.FieldBoosts == nil {
+			d.FieldBoosts = make(map[string]float64)
+		}
+		d.FieldBoosts[name] = boost
+	}
+	return d
+}
+
+// FieldBoost returns the index time boost of the named field, the
+// document boost times the field boost, 1 when neither is set
+func (d *Document) FieldBoost(name string) float64 {
+	rv := 1.0
+	if d.Boost > 0 {
+		rv = d.Boost
+	}
+	if boost, ok := d.FieldBoosts[name]; ok && boost > 0 {
+		rv *= boost
+	}
+	return rv
+}
+
+// ReplaceFields replaces the fields of the document named like the
+// fields of partial with them, the composite fields are kept.  The
+// boosts set on partial replace those of the document, the source of
+// partial is merged into its source, if it has one.
+func (d *Document) ReplaceFields(partial *Document) *Document {
+	replaced := make(map[string]bool, len(partial.Fields))
+	for _, field := range partial.Fields {
+		replaced[field.Name()] = true
+	}
+	fields := make([]Field, 0, len(d.Fields)+len(partial.Fields))
+	for _, field := range d.Fields {
+		if !replaced[field.Name()] {
+			fields = append(fields, field)
+		}
+	}
+	d.Fields = append(fields, partial.Fields...)
+	if partial.Boost > 0 {
+		d.Boost = partial.Boost
+	}
+	for name, boost := range partial.FieldBoosts {
+		d.SetFieldBoost(name, boost)
+	}
+	if len(d.Source) > 0 && len(partial.Source) > 0 {
+		if source, err := MergeSource(d.Source, partial.Source); err == nil {
+			d.Source = source
+		}
+	}
+	return d
+}
+
 func (d *Document) GoString() string {
 	fields := ""
 	for i, field := range d.Fields {
@@ -15,7 +15,11 @@
 package document
 
 import (
+	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDocumentNumPlainTextBytes(t *testing.T) {
@@ -71,3 +75,108 @@ func TestDocumentNumPlainTextBytes(t *testing.T) {
 		}
 	}
 }
+
+func TestDocumentFieldBoost(t *testing.T) {
+	doc := NewDocument("a")
+	if doc.FieldBoost("name") != 1.0 {
+		t.Errorf("expected no boost, got %f", doc.FieldBoost("name"))
+	}
+	doc.SetFieldBoost("name", 3.0).SetFieldBoost("desc", -1.0)
+	if doc.FieldBoost("name") != 3.0 {
+		t.Errorf("expected field boost 3, got %f", doc.FieldBoost("name"))
+	}
+	if doc.FieldBoost("desc") != 1.0 {
+		t.Errorf("expected a negative field boost to be ignored, got %f", doc.FieldBoost("desc"))
+	}
+	doc.Boost = 2.0
+	if doc.FieldBoost("name") != 6.0 {
+		t.Errorf("expected document and field boost 6, got %f", doc.FieldBoost("name"))
+	}
+	if doc.FieldBoost("desc") != 2.0 {
+		t.Errorf("expected document boost 2, got %f", doc.FieldBoost("desc"))
+	}
+}
+
+func TestDocumentReplaceFields(t *testing.T) {
+	doc := NewDocument("a")
+	doc.AddField(NewTextField("name", []uint64{}, []byte("a")))
+	doc.AddField(NewTextField("desc", []uint64{}, []byte("b")))
+	doc.AddField(NewCompositeField("_all", true, nil, nil))
+	doc.Boost = 2.0
+
+	partial := NewDocument("a")
+	partial.AddField(NewTextField("name", []uint64{}, []byte("c")))
+	partial.AddField(NewTextField("tags", []uint64{}, []byte("d")))
+	partial.SetFieldBoost("tags", 3.0)
+	doc.ReplaceFields(partial)
+
+	values := make(map[string]string)
+	for _, field := range doc.Fields {
+		values[field.Name()] = string(field.Value())
+	}
+	expected := map[string]string{"name": "c", "desc": "b", "tags": "d"}
+	if len(doc.Fields) != len(expected) || !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected fields %v, got %v", expected, values)
+	}
+	if len(doc.CompositeFields) != 1 {
+		t.Errorf("expected the composite field to be kept")
+	}
+	if doc.FieldBoost("tags") != 6.0 {
+		t.Errorf("expected document and field boost 6, got %f", doc.FieldBoost("tags"))
+	}
+}
+
+func TestDocumentExpiresJSON(t *testing.T) {
+	doc := NewDocument("a")
+	buf, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(buf), "expires") {
+		t.Errorf("expected no expiry, got %s", buf)
+	}
+
+	doc.Expires = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	buf, err = json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(buf), `"id":"a"`) || !strings.Contains(string(buf), `"expires":"2030-01-01T00:00:00Z"`) {
+		t.Errorf("expected the id and the expiry, got %s", buf)
+	}
+}
+
+func TestNestedID(t *testing.T) {
+	id := NestedID("a", "comments", 3)
+	if !IsNestedID(id) {
+		t.Errorf("expected %q to be a nested id", id)
+	}
+	if IsNestedID("a") {
+		t.Errorf("expected a not to be a nested id")
+	}
+	parentID, path, offset, ok := ParseNestedID(NestedID(id, "comments.replies", 1))
+	if !ok || parentID != id || path != "comments.replies" || offset != 1 {
+		t.Errorf("expected reply 1 of %q, got %q %q %d", id, parentID, path, offset)
+	}
+	_, _, _, ok = ParseNestedID("a")
+	if ok {
+		t.Errorf("expected a not to parse as a nested id")
+	}
+}
+
+func TestMergeSource(t *testing.T) {
+	source := json.RawMessage(`{"name":"a","tags":["x","y"],"address":{"city":"b","zip":12345678901234567}}`)
+	merged, err := MergeSource(source, json.RawMessage(`{"tags":["z"],"address":{"city":"c"},"age":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"address":{"city":"c","zip":12345678901234567},"age":3,"name":"a","tags":["z"]}`
+	if string(merged) != expected {
+		t.Errorf("expected %s, got %s", expected, merged)
+	}
+
+	_, err = MergeSource(json.RawMessage(`[1]`), json.RawMessage(`{"a":1}`))
+	if err == nil {
+		t.Errorf("expected an error merging into a source which is not an object")
+	}
+}
@@ -0,0 +1,41 @@
+package document
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NestedSeparator separates the id of a nested document from the path
+// and offset it was found at in its parent, the ids of top level
+// documents must not contain it.  Nested documents sort right after
+// their parent.
+const NestedSeparator = '\x00'
+
+// NestedID returns the id of the nested document found at offset of
+// the path in the parent document
+func NestedID(parentID, path string, offset int) string {
+	return parentID + string(NestedSeparator) + path + string(NestedSeparator) + strconv.Itoa(offset)
+}
+
+// ParseNestedID returns the id of the parent, the path and the offset
+// of a nested document id, ok is false for other ids
+func ParseNestedID(id string) (parentID, path string, offset int, ok bool) {
+	i := strings.LastIndexByte(id, NestedSeparator)
+	if i < 0 {
+		return "", "", 0, false
+	}
+	j := strings.LastIndexByte(id[:i], NestedSeparator)
+	if j < 0 {
+		return "", "", 0, false
+	}
+	offset, err := strconv.Atoi(id[i+1:])
+	if err != nil {
+		return "", "", 0, false
+	}
+	return id[:j], id[j+1 : i], offset, true
+}
+
+// IsNestedID returns whether the id is that of a nested document
+func IsNestedID(id string) bool {
+	return strings.IndexByte(id, NestedSeparator) >= 0
+}
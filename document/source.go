@@ -0,0 +1,55 @@
+package document
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// DecodeSource decodes a source, numbers are kept as they are written
+func DecodeSource(source json.RawMessage) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(source))
+	decoder.UseNumber()
+	var rv interface{}
+	err := decoder.Decode(&rv)
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// MergeSource returns the source with the properties of fields, a JSON
+// object, replacing those of the same name.  Objects are merged
+// property by property, any other value is replaced whole.
+func MergeSource(source, fields json.RawMessage) (json.RawMessage, error) {
+	data, err := DecodeSource(source)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("source is not an object")
+	}
+	update, err := DecodeSource(fields)
+	if err != nil {
+		return nil, err
+	}
+	updateObject, ok := update.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("fields are not an object")
+	}
+	mergeProperties(object, updateObject)
+	return json.Marshal(object)
+}
+
+func mergeProperties(object, update map[string]interface{}) {
+	for k, v := range update {
+		if vObject, ok := v.(map[string]interface{}); ok {
+			if existing, ok := object[k].(map[string]interface{}); ok {
+				mergeProperties(existing, vObject)
+				continue
+			}
+		}
+		object[k] = v
+	}
+}
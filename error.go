@@ -27,6 +27,8 @@ const (
 	ErrorUnknownIndexType
 	ErrorEmptyID
 	ErrorIndexReadInconsistency
+	ErrorIndexSchemaTooNew
+	ErrorDocumentNotFound
 )
 
 // Error represents a more strongly typed bleve error for detecting
@@ -49,4 +51,6 @@ var errorMessages = map[Error]string{
 	ErrorUnknownIndexType:       "unknown index type",
 	ErrorEmptyID:                "document ID cannot be empty",
 	ErrorIndexReadInconsistency: "index read inconsistency detected",
+	ErrorIndexSchemaTooNew:      "cannot open index, storage schema is newer than this version supports",
+	ErrorDocumentNotFound:       "document not found",
 }
@@ -0,0 +1,172 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/wrble/flock"
+	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/search/query"
+)
+
+// DeleteByQueryHandler can handle requests deleting the documents
+// matching a query sent over HTTP, the body holds the query:
+//
+//	{"query": {...}}
+type DeleteByQueryHandler struct {
+	defaultIndexName string
+	IndexNameLookup  varLookupFunc
+}
+
+func NewDeleteByQueryHandler(defaultIndexName string) *DeleteByQueryHandler {
+	return &DeleteByQueryHandler{
+		defaultIndexName: defaultIndexName,
+	}
+}
+
+func (h *DeleteByQueryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	index, body, ok := byQueryRequest(w, req, h.IndexNameLookup, h.defaultIndexName)
+	if !ok {
+		return
+	}
+
+	// the request context is cancelled when the client goes away
+	status, err := index.DeleteByQuery(req.Context(), body.Query)
+	byQueryResponse(w, req, "deleting", status, err)
+}
+
+// UpdateByQueryHandler can handle requests replacing fields of the
+// documents matching a query sent over HTTP, the body holds the query
+// and the fields to replace in each of them:
+//
+//	{"query": {...}, "fields": {...}}
+type UpdateByQueryHandler struct {
+	defaultIndexName string
+	IndexNameLookup  varLookupFunc
+}
+
+func NewUpdateByQueryHandler(defaultIndexName string) *UpdateByQueryHandler {
+	return &UpdateByQueryHandler{
+		defaultIndexName: defaultIndexName,
+	}
+}
+
+func (h *UpdateByQueryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	index, body, ok := byQueryRequest(w, req, h.IndexNameLookup, h.defaultIndexName)
+	if !ok {
+		return
+	}
+	if len(body.Fields) == 0 {
+		showError(w, req, "fields cannot be empty", 400)
+		return
+	}
+
+	status, err := index.UpdateByQuery(req.Context(), body.Query, flock.UpdaterFunc(func(doc *document.Document) (map[string]interface{}, error) {
+		return body.Fields, nil
+	}))
+	byQueryResponse(w, req, "updating", status, err)
+}
+
+type byQueryBody struct {
+	Query  query.Query
+	Fields map[string]interface{}
+}
+
+func (b *byQueryBody) UnmarshalJSON(input []byte) error {
+	var temp struct {
+		Query  json.RawMessage        `json:"query"`
+		Fields map[string]interface{} `json:"fields"`
+	}
+	err := json.Unmarshal(input, &temp)
+	if err != nil {
+		return err
+	}
+	if temp.Query == nil {
+		return fmt.Errorf("query cannot be empty")
+	}
+	b.Query, err = query.ParseQuery(temp.Query)
+	if err != nil {
+		return err
+	}
+	b.Fields = temp.Fields
+	return nil
+}
+
+// byQueryRequest finds the index and parses the body of a by query
+// request, it reports the error and returns false when it cannot
+func byQueryRequest(w http.ResponseWriter, req *http.Request, indexNameLookup varLookupFunc, defaultIndexName string) (flock.Index, *byQueryBody, bool) {
+
+	// find the index to operate on
+	var indexName string
+	if indexNameLookup != nil {
+		indexName = indexNameLookup(req)
+	}
+	if indexName == "" {
+		indexName = defaultIndexName
+	}
+	index := IndexByName(indexName)
+	if index == nil {
+		showError(w, req, fmt.Sprintf("no such index '%s'", indexName), 404)
+		return nil, nil, false
+	}
+
+	// read the request body
+	requestBody, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		showError(w, req, fmt.Sprintf("error reading request body: %v", err), 400)
+		return nil, nil, false
+	}
+
+	logger.Printf("request body: %s", requestBody)
+
+	// parse the request
+	var body byQueryBody
+	err = json.Unmarshal(requestBody, &body)
+	if err != nil {
+		showError(w, req, fmt.Sprintf("error parsing query: %v", err), 400)
+		return nil, nil, false
+	}
+
+	// validate the query
+	if qv, ok := body.Query.(query.ValidatableQuery); ok {
+		err = qv.Validate()
+		if err != nil {
+			showError(w, req, fmt.Sprintf("error validating query: %v", err), 400)
+			return nil, nil, false
+		}
+	}
+
+	return index, &body, true
+}
+
+func byQueryResponse(w http.ResponseWriter, req *http.Request, op string, status *flock.ByQueryStatus, err error) {
+	if err != nil {
+		showError(w, req, fmt.Sprintf("error %s documents: %v", op, err), writeErrorStatus(err))
+		return
+	}
+
+	rv := struct {
+		Status string `json:"status"`
+		*flock.ByQueryStatus
+	}{
+		Status:        "ok",
+		ByQueryStatus: status,
+	}
+	mustEncode(w, rv)
+}
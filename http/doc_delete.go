@@ -57,9 +57,15 @@ func (h *DocDeleteHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err := index.Delete(docID)
+	version, err := requestVersion(req)
 	if err != nil {
-		showError(w, req, fmt.Sprintf("error deleting document '%s': %v", docID, err), 500)
+		showError(w, req, fmt.Sprintf("error parsing version: %v", err), 400)
+		return
+	}
+
+	err = index.DeleteWithVersion(docID, version)
+	if err != nil {
+		showError(w, req, fmt.Sprintf("error deleting document '%s': %v", docID, err), writeErrorStatus(err))
 		return
 	}
 
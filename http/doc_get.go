@@ -15,13 +15,20 @@
 package http
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/wrble/flock"
 	"github.com/wrble/flock/document"
 )
 
+// DocGetHandler can handle requests for a document, its source is
+// returned when the mapping stores sources, filtered by the
+// comma-separated paths of the _source_includes and _source_excludes
+// parameters, _source=false leaves it out.
 type DocGetHandler struct {
 	defaultIndexName string
 	IndexNameLookup  varLookupFunc
@@ -70,11 +77,29 @@ func (h *DocGetHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 
 	rv := struct {
-		ID     string                 `json:"id"`
-		Fields map[string]interface{} `json:"fields"`
+		ID      string                 `json:"id"`
+		Version uint64                 `json:"version,omitempty"`
+		Expires string                 `json:"expires,omitempty"`
+		Fields  map[string]interface{} `json:"fields"`
+		Source  json.RawMessage        `json:"_source,omitempty"`
 	}{
-		ID:     docID,
-		Fields: map[string]interface{}{},
+		ID:      docID,
+		Version: doc.Version,
+		Fields:  map[string]interface{}{},
+	}
+	if !doc.Expires.IsZero() {
+		rv.Expires = doc.Expires.Format(time.RFC3339Nano)
+	}
+	if len(doc.Source) > 0 && req.FormValue("_source") != "false" {
+		filter := &flock.SourceFilter{
+			Includes: splitPaths(req.FormValue("_source_includes")),
+			Excludes: splitPaths(req.FormValue("_source_excludes")),
+		}
+		rv.Source, err = filter.Filter(doc.Source)
+		if err != nil {
+			showError(w, req, fmt.Sprintf("error filtering source of document '%s': %v", docID, err), 500)
+			return
+		}
 	}
 	for _, field := range doc.Fields {
 		var newval interface{}
@@ -110,3 +135,11 @@ func (h *DocGetHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	mustEncode(w, rv)
 }
+
+// splitPaths splits a comma-separated list of paths
+func splitPaths(paths string) []string {
+	if paths == "" {
+		return nil
+	}
+	return strings.Split(paths, ",")
+}
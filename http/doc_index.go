@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+
+	"github.com/wrble/flock"
 )
 
 type DocIndexHandler struct {
@@ -59,25 +61,51 @@ func (h *DocIndexHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// read the request body
-	requestBody, err := ioutil.ReadAll(req.Body)
+	version, err := requestVersion(req)
 	if err != nil {
-		showError(w, req, fmt.Sprintf("error reading request body: %v", err), 400)
+		showError(w, req, fmt.Sprintf("error parsing version: %v", err), 400)
 		return
 	}
 
-	// parse request body as json
-	var doc interface{}
-	err = json.Unmarshal(requestBody, &doc)
+	// read the request body
+	requestBody, err := ioutil.ReadAll(req.Body)
 	if err != nil {
-		showError(w, req, fmt.Sprintf("error parsing request body as JSON: %v", err), 400)
+		showError(w, req, fmt.Sprintf("error reading request body: %v", err), 400)
 		return
 	}
 
-	err = index.Index(docID, doc)
-	if err != nil {
-		showError(w, req, fmt.Sprintf("error indexing document '%s': %v", docID, err), 500)
-		return
+	if req.Method == "PATCH" {
+		// the body holds the fields to replace
+		var fields map[string]interface{}
+		err = json.Unmarshal(requestBody, &fields)
+		if err != nil {
+			showError(w, req, fmt.Sprintf("error parsing request body as JSON object: %v", err), 400)
+			return
+		}
+
+		err = index.UpdateFieldsWithVersion(docID, fields, version)
+		if err == flock.ErrorDocumentNotFound {
+			showError(w, req, fmt.Sprintf("no such document '%s'", docID), 404)
+			return
+		}
+		if err != nil {
+			showError(w, req, fmt.Sprintf("error updating document '%s': %v", docID, err), writeErrorStatus(err))
+			return
+		}
+	} else {
+		// parse request body as json
+		var doc interface{}
+		err = json.Unmarshal(requestBody, &doc)
+		if err != nil {
+			showError(w, req, fmt.Sprintf("error parsing request body as JSON: %v", err), 400)
+			return
+		}
+
+		err = index.IndexWithVersion(docID, doc, version)
+		if err != nil {
+			showError(w, req, fmt.Sprintf("error indexing document '%s': %v", docID, err), writeErrorStatus(err))
+			return
+		}
 	}
 
 	rv := struct {
@@ -73,6 +73,12 @@ func TestHandlers(t *testing.T) {
 	docDeleteHandler.IndexNameLookup = indexNameLookup
 	docDeleteHandler.DocIDLookup = docIDLookup
 
+	deleteByQueryHandler := NewDeleteByQueryHandler("")
+	deleteByQueryHandler.IndexNameLookup = indexNameLookup
+
+	updateByQueryHandler := NewUpdateByQueryHandler("")
+	updateByQueryHandler.IndexNameLookup = indexNameLookup
+
 	searchHandler := NewSearchHandler("")
 	searchHandler.IndexNameLookup = indexNameLookup
 
@@ -316,6 +322,165 @@ func TestHandlers(t *testing.T) {
 			Status:       http.StatusBadRequest,
 			ResponseBody: []byte(`document id cannot be empty`),
 		},
+		{
+			Desc:         "create index storing sources",
+			Handler:      createIndexHandler,
+			Path:         "/create",
+			Method:       "PUT",
+			Params:       url.Values{"indexName": []string{"tis"}},
+			Body:         []byte(`{"store_source":true}`),
+			Status:       http.StatusOK,
+			ResponseBody: []byte(`{"status":"ok"}`),
+		},
+		{
+			Desc:    "index doc with source",
+			Handler: docIndexHandler,
+			Path:    "/tis/a",
+			Method:  "PUT",
+			Params: url.Values{
+				"indexName": []string{"tis"},
+				"docID":     []string{"a"},
+			},
+			Body:         []byte(`{"name":"a","address":{"city":"b","zip":"c"}}`),
+			Status:       http.StatusOK,
+			ResponseBody: []byte(`{"status":"ok"}`),
+		},
+		{
+			Desc:    "doc get source",
+			Handler: docGetHandler,
+			Path:    "/tis/a",
+			Method:  "GET",
+			Params: url.Values{
+				"indexName":        []string{"tis"},
+				"docID":            []string{"a"},
+				"_source_includes": []string{"address"},
+				"_source_excludes": []string{"address.zip"},
+			},
+			Status: http.StatusOK,
+			ResponseMatch: map[string]bool{
+				`"_source":{"address":{"city":"b"}}`: true,
+			},
+		},
+		{
+			Desc:    "doc get without source",
+			Handler: docGetHandler,
+			Path:    "/tis/a",
+			Method:  "GET",
+			Params: url.Values{
+				"indexName": []string{"tis"},
+				"docID":     []string{"a"},
+				"_source":   []string{"false"},
+			},
+			Status: http.StatusOK,
+			ResponseMatch: map[string]bool{
+				`"_source"`: false,
+			},
+		},
+		{
+			Desc:    "search with source",
+			Handler: searchHandler,
+			Path:    "/tis/_search",
+			Method:  "POST",
+			Params: url.Values{
+				"indexName": []string{"tis"},
+			},
+			Body:   []byte(`{"query":{"match":"b"},"_source":["address.city"]}`),
+			Status: http.StatusOK,
+			ResponseMatch: map[string]bool{
+				`"_source":{"address":{"city":"b"}}`: true,
+			},
+		},
+		{
+			Desc:    "patch doc",
+			Handler: docIndexHandler,
+			Path:    "/ti1/a",
+			Method:  "PATCH",
+			Params: url.Values{
+				"indexName": []string{"ti1"},
+				"docID":     []string{"a"},
+			},
+			Body:         []byte(`{"name":"aa"}`),
+			Status:       http.StatusOK,
+			ResponseBody: []byte(`{"status":"ok"}`),
+		},
+		{
+			Desc:    "doc get patched",
+			Handler: docGetHandler,
+			Path:    "/ti1/a",
+			Method:  "GET",
+			Params: url.Values{
+				"indexName": []string{"ti1"},
+				"docID":     []string{"a"},
+			},
+			Status: http.StatusOK,
+			ResponseMatch: map[string]bool{
+				`"id":"a"`:      true,
+				`"version":2`:   true,
+				`"body":"test"`: true,
+				`"name":"aa"`:   true,
+				`"name":"a"`:    false,
+			},
+		},
+		{
+			Desc:    "patch doc stale version",
+			Handler: docIndexHandler,
+			Path:    "/ti1/a",
+			Method:  "PATCH",
+			Params: url.Values{
+				"indexName": []string{"ti1"},
+				"docID":     []string{"a"},
+				"version":   []string{"1"},
+			},
+			Body:   []byte(`{"name":"ab"}`),
+			Status: http.StatusConflict,
+			ResponseMatch: map[string]bool{
+				`version conflict`: true,
+			},
+		},
+		{
+			Desc:    "patch doc invalid version",
+			Handler: docIndexHandler,
+			Path:    "/ti1/a",
+			Method:  "PATCH",
+			Params: url.Values{
+				"indexName": []string{"ti1"},
+				"docID":     []string{"a"},
+				"version":   []string{"x"},
+			},
+			Body:   []byte(`{"name":"ab"}`),
+			Status: http.StatusBadRequest,
+			ResponseMatch: map[string]bool{
+				`error parsing version`: true,
+			},
+		},
+		{
+			Desc:    "patch doc not indexed",
+			Handler: docIndexHandler,
+			Path:    "/ti1/x",
+			Method:  "PATCH",
+			Params: url.Values{
+				"indexName": []string{"ti1"},
+				"docID":     []string{"x"},
+			},
+			Body:         []byte(`{"name":"x"}`),
+			Status:       http.StatusNotFound,
+			ResponseBody: []byte(`no such document 'x'`),
+		},
+		{
+			Desc:    "patch doc not an object",
+			Handler: docIndexHandler,
+			Path:    "/ti1/a",
+			Method:  "PATCH",
+			Params: url.Values{
+				"indexName": []string{"ti1"},
+				"docID":     []string{"a"},
+			},
+			Body:   []byte(`["aa"]`),
+			Status: http.StatusBadRequest,
+			ResponseMatch: map[string]bool{
+				`error parsing request body as JSON object`: true,
+			},
+		},
 		{
 			Desc:    "index another doc",
 			Handler: docIndexHandler,
@@ -375,6 +540,89 @@ func TestHandlers(t *testing.T) {
 			Status:       http.StatusBadRequest,
 			ResponseBody: []byte(`document id cannot be empty`),
 		},
+		{
+			Desc:    "index doc to delete by query",
+			Handler: docIndexHandler,
+			Path:    "/ti1/c",
+			Method:  "PUT",
+			Params: url.Values{
+				"indexName": []string{"ti1"},
+				"docID":     []string{"c"},
+			},
+			Body:         []byte(`{"name":"c","body":"gone"}`),
+			Status:       http.StatusOK,
+			ResponseBody: []byte(`{"status":"ok"}`),
+		},
+		{
+			Desc:    "update by query",
+			Handler: updateByQueryHandler,
+			Path:    "/ti1/_update_by_query",
+			Method:  "POST",
+			Params: url.Values{
+				"indexName": []string{"ti1"},
+			},
+			Body:   []byte(`{"query":{"field":"body","term":"gone"},"fields":{"name":"cc"}}`),
+			Status: http.StatusOK,
+			ResponseMatch: map[string]bool{
+				`"status":"ok"`: true,
+				`"matched":1`:   true,
+				`"updated":1`:   true,
+			},
+		},
+		{
+			Desc:    "update by query missing fields",
+			Handler: updateByQueryHandler,
+			Path:    "/ti1/_update_by_query",
+			Method:  "POST",
+			Params: url.Values{
+				"indexName": []string{"ti1"},
+			},
+			Body:         []byte(`{"query":{"field":"body","term":"gone"}}`),
+			Status:       http.StatusBadRequest,
+			ResponseBody: []byte(`fields cannot be empty`),
+		},
+		{
+			Desc:    "delete by query",
+			Handler: deleteByQueryHandler,
+			Path:    "/ti1/_delete_by_query",
+			Method:  "POST",
+			Params: url.Values{
+				"indexName": []string{"ti1"},
+			},
+			Body:   []byte(`{"query":{"field":"name","term":"cc"}}`),
+			Status: http.StatusOK,
+			ResponseMatch: map[string]bool{
+				`"status":"ok"`: true,
+				`"matched":1`:   true,
+				`"deleted":1`:   true,
+			},
+		},
+		{
+			Desc:    "delete by query invalid index",
+			Handler: deleteByQueryHandler,
+			Path:    "/tix/_delete_by_query",
+			Method:  "POST",
+			Params: url.Values{
+				"indexName": []string{"tix"},
+			},
+			Body:         []byte(`{"query":{"field":"name","term":"cc"}}`),
+			Status:       http.StatusNotFound,
+			ResponseBody: []byte(`no such index 'tix'`),
+		},
+		{
+			Desc:    "delete by query missing query",
+			Handler: deleteByQueryHandler,
+			Path:    "/ti1/_delete_by_query",
+			Method:  "POST",
+			Params: url.Values{
+				"indexName": []string{"ti1"},
+			},
+			Body:   []byte(`{}`),
+			Status: http.StatusBadRequest,
+			ResponseMatch: map[string]bool{
+				`query cannot be empty`: true,
+			},
+		},
 		{
 			Desc:    "doc get",
 			Handler: docGetHandler,
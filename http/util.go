@@ -20,8 +20,29 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strconv"
+
+	"github.com/wrble/flock/index"
 )
 
+// requestVersion returns the version of the document the request
+// expects from the version parameter, 0 when there is none
+func requestVersion(req *http.Request) (uint64, error) {
+	version := req.FormValue("version")
+	if version == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(version, 10, 64)
+}
+
+// writeErrorStatus returns the status of an error writing a document
+func writeErrorStatus(err error) int {
+	if _, ok := index.AsVersionConflict(err); ok {
+		return http.StatusConflict
+	}
+	return 500
+}
+
 func showError(w http.ResponseWriter, r *http.Request,
 	msg string, code int) {
 	logger.Printf("Reporting error %v/%v", code, msg)
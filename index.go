@@ -16,11 +16,13 @@ package flock
 
 import (
 	"os"
+	"time"
 
 	"github.com/wrble/flock/document"
 	"github.com/wrble/flock/index"
 	"github.com/wrble/flock/index/store"
 	"github.com/wrble/flock/mapping"
+	"github.com/wrble/flock/search/query"
 	"golang.org/x/net/context"
 )
 
@@ -39,6 +41,15 @@ type Batch struct {
 // batch.  NOTE: the bleve Index is not updated
 // until the batch is executed.
 func (b *Batch) Index(id string, data interface{}) error {
+	return b.IndexWithVersion(id, data, 0)
+}
+
+// IndexWithVersion adds the specified index operation
+// to the batch, the batch fails if the index does not
+// hold the version of the document, 0 accepts any
+// version.  NOTE: the bleve Index is not updated until
+// the batch is executed.
+func (b *Batch) IndexWithVersion(id string, data interface{}, version uint64) error {
 	if id == "" {
 		return ErrorEmptyID
 	}
@@ -47,6 +58,29 @@ func (b *Batch) Index(id string, data interface{}) error {
 	if err != nil {
 		return err
 	}
+	doc.Version = version
+	b.internal.Update(doc)
+	return nil
+}
+
+// IndexWithTTL adds the specified index operation to
+// the batch, the document expires once ttl has passed
+// whatever the TTL field of the mapping holds.  Searches
+// no longer find it from then on and it is removed from
+// the index soon after.  NOTE: the bleve Index is not
+// updated until the batch is executed.
+func (b *Batch) IndexWithTTL(id string, data interface{}, ttl time.Duration) error {
+	if id == "" {
+		return ErrorEmptyID
+	}
+	doc := document.NewDocument(id)
+	err := b.index.Mapping().MapDocument(doc, data)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		doc.Expires = time.Now().Add(ttl)
+	}
 	b.internal.Update(doc)
 	return nil
 }
@@ -62,6 +96,32 @@ func (b *Batch) IndexAdvanced(doc *document.Document) (err error) {
 	return nil
 }
 
+// UpdateFields adds the specified partial update
+// operation to the batch, see Index.UpdateFields.
+// NOTE: the bleve Index is not updated until the
+// batch is executed.
+func (b *Batch) UpdateFields(id string, fields map[string]interface{}) error {
+	return b.UpdateFieldsWithVersion(id, fields, 0)
+}
+
+// UpdateFieldsWithVersion adds the specified partial
+// update operation to the batch, the batch fails if the
+// index does not hold the version of the document.
+// NOTE: the bleve Index is not updated until the batch
+// is executed.
+func (b *Batch) UpdateFieldsWithVersion(id string, fields map[string]interface{}, version uint64) error {
+	if id == "" {
+		return ErrorEmptyID
+	}
+	doc, err := mapFields(b.index.Mapping(), id, fields)
+	if err != nil {
+		return err
+	}
+	doc.Version = version
+	b.internal.UpdateFields(doc)
+	return nil
+}
+
 // Delete adds the specified delete operation to the
 // batch.  NOTE: the bleve Index is not updated until
 // the batch is executed.
@@ -71,6 +131,16 @@ func (b *Batch) Delete(id string) {
 	}
 }
 
+// DeleteWithVersion adds the specified delete operation
+// to the batch, the batch fails if the index does not
+// hold the version of the document.  NOTE: the bleve
+// Index is not updated until the batch is executed.
+func (b *Batch) DeleteWithVersion(id string, version uint64) {
+	if id != "" {
+		b.internal.DeleteVersion(id, version)
+	}
+}
+
 // SetInternal adds the specified set internal
 // operation to the batch. NOTE: the bleve Index is
 // not updated until the batch is executed.
@@ -88,7 +158,7 @@ func (b *Batch) DeleteInternal(key []byte) {
 // Size returns the total number of operations inside the batch
 // including normal index operations and internal operations.
 func (b *Batch) Size() int {
-	return len(b.internal.IndexOps) + len(b.internal.InternalOps)
+	return len(b.internal.IndexOps) + len(b.internal.UpdateFieldsOps) + len(b.internal.InternalOps)
 }
 
 // String prints a user friendly string representation of what
@@ -173,13 +243,39 @@ type Index interface {
 	// requests. See Index interface documentation for details about mapping
 	// rules.
 	Index(id string, data interface{}) error
+	// UpdateFields replaces the fields of an indexed document named like
+	// the fields mapped from fields and keeps its other fields, only the
+	// fields replaced are analyzed.  The boost field of the mapping sets
+	// the boost of the document.  The _all field is not updated, unless
+	// the mapping stores sources: the fields are then merged into the
+	// source of the document, which is mapped again whole.
+	UpdateFields(id string, fields map[string]interface{}) error
 	Delete(id string) error
 
+	// IndexWithVersion, UpdateFieldsWithVersion and DeleteWithVersion
+	// only write when the index holds the version of the document, as
+	// returned by Document() and in the hits of Search(), 0 accepts any
+	// version.  Every write raises the version of the document.  When
+	// the version differs, or another writer changes the document at
+	// the same time, an *index.VersionConflictError is returned and
+	// nothing is written.
+	IndexWithVersion(id string, data interface{}, version uint64) error
+	UpdateFieldsWithVersion(id string, fields map[string]interface{}, version uint64) error
+	DeleteWithVersion(id string, version uint64) error
+
+	// DeleteByQuery and UpdateByQuery delete or update the documents
+	// matching the query in batches, see WithByQueryProgress to follow
+	// them.  They stop when the context is done, the batches written by
+	// then are kept.
+	DeleteByQuery(ctx context.Context, q query.Query) (*ByQueryStatus, error)
+	UpdateByQuery(ctx context.Context, q query.Query, updater Updater) (*ByQueryStatus, error)
+
 	NewBatch() *Batch
 	Batch(b *Batch) error
 
 	// Document returns specified document or nil if the document is not
-	// indexed or stored.
+	// indexed or stored.  It holds the source of the document when the
+	// mapping stores sources.
 	Document(id string) (*document.Document, error)
 	// DocCount returns the number of documents in the index.
 	DocCount() (uint64, error)
@@ -25,11 +25,59 @@ import (
 
 var ErrorUnknownStorageType = fmt.Errorf("unknown storage type")
 
+// ErrorScoreOrderUnsupported is returned by ScoreOrderedTermFieldReader
+// when the underlying store does not keep score ordered postings
+var ErrorScoreOrderUnsupported = fmt.Errorf("score ordered term field readers are not supported by this store")
+
+// ErrorDocumentNotFound is returned when updating the fields of a
+// document which is not indexed
+var ErrorDocumentNotFound = fmt.Errorf("document not found")
+
+// VersionConflictError is returned when a document is written expecting
+// a version the index no longer holds, or when another writer changed
+// the document while it was written.  Nothing of the write is applied.
+type VersionConflictError struct {
+	ID       string
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict on document '%s': expected version %d, found %d", e.ID, e.Expected, e.Actual)
+}
+
+// ReleaseError is returned when a write failed and the back index rows
+// it claimed could not all be restored.  Err is why the write failed,
+// Release why the rows were not restored.
+type ReleaseError struct {
+	Err     error
+	Release error
+}
+
+func (e *ReleaseError) Error() string {
+	return fmt.Sprintf("%v; releasing claimed back index rows: %v", e.Err, e.Release)
+}
+
+// AsVersionConflict returns the version conflict err is, or failed a
+// write whose claims were not released
+func AsVersionConflict(err error) (*VersionConflictError, bool) {
+	switch err := err.(type) {
+	case *VersionConflictError:
+		return err, true
+	case *ReleaseError:
+		return AsVersionConflict(err.Err)
+	}
+	return nil, false
+}
+
 type Index interface {
 	Open() error
 	Close() error
 
 	Update(doc *document.Document) error
+	// UpdateFields replaces the fields of an indexed document named
+	// like the fields of doc, keeping the others
+	UpdateFields(doc *document.Document) error
 	Delete(id string) error
 	Batch(batch *Batch) error
 
@@ -120,8 +168,14 @@ type TermFieldDoc struct {
 	Term    string
 	ID      IndexInternalID
 	Freq    float32
-	Score   float32
+	Score   float32 // pre score, the term score of a field of any length must not decrease as it grows
 	Vectors []*TermFieldVector
+	// FieldLength is the number of tokens of the field in this
+	// document, 0 when it is unknown
+	FieldLength uint32
+	// Boost is the index time boost of the field in this document, 0
+	// when it has none
+	Boost float32
 }
 
 // Reset allows an already allocated TermFieldDoc to be reused
@@ -135,6 +189,60 @@ func (tfd *TermFieldDoc) Reset() *TermFieldDoc {
 	return tfd
 }
 
+// FieldNamesField is the field indexes record the names of the fields
+// of every document in, one term per field, along with the paths of the
+// objects and nested documents holding them
+const FieldNamesField = "_field_names"
+
+// DocCounter is implemented by indexes which keep a durable count of
+// their documents, reading it is cheap and reflects the writes of
+// every process sharing the store.
+type DocCounter interface {
+	DocCount() (uint64, error)
+}
+
+// FieldLengthReader is implemented by index readers which keep the
+// length of the fields of every document.
+type FieldLengthReader interface {
+	// FieldLengths returns the total length of a field and the number
+	// of documents it was recorded for, their ratio is the average
+	// field length
+	FieldLengths(field string) (total, docs uint64, err error)
+}
+
+// FieldBoostReader is implemented by index readers which keep the
+// index time boosts of the fields of every document.
+type FieldBoostReader interface {
+	// MaxFieldBoost returns a bound of the boosts of a field, at least
+	// 1.  It only grows, documents deleted do not lower it.
+	MaxFieldBoost(field string) (float64, error)
+}
+
+// DocumentVersionReader is implemented by index readers which keep the
+// version of every document.
+type DocumentVersionReader interface {
+	// DocumentVersion returns the version of the document, 0 when it is
+	// not indexed
+	DocumentVersion(id string) (uint64, error)
+}
+
+// ScoreOrderedIndexReader is implemented by index readers which can
+// enumerate the documents containing a term best first.
+type ScoreOrderedIndexReader interface {
+	// ScoreOrderedTermFieldReader returns a TermFieldReader whose Next
+	// returns documents by descending TermFieldDoc.Score, documents
+	// with the same score in byte lexicographic order.  Advance is not
+	// supported.  ErrorScoreOrderUnsupported is returned when the index
+	// can not provide this order.
+	ScoreOrderedTermFieldReader(term []byte, field string, includeFreq, includeNorm, includeTermVectors bool) (TermFieldReader, error)
+}
+
+// DocIDIndexReader is implemented by index readers which can enumerate
+// the ids of every document, in order.
+type DocIDIndexReader interface {
+	DocIDReaderAll() (DocIDReader, error)
+}
+
 // TermFieldReader is the interface exposing the enumeration of documents
 // containing a given term in a given field. Documents are returned in byte
 // lexicographic order over their identifiers.
@@ -179,23 +287,67 @@ type DocIDReader interface {
 }
 
 type Batch struct {
-	IndexOps    map[string]*document.Document
+	IndexOps map[string]*document.Document
+	// UpdateFieldsOps are partial documents, see Index.UpdateFields,
+	// of documents the IndexOps do not touch
+	UpdateFieldsOps map[string]*document.Document
+	// Versions are the versions the index must hold for the documents
+	// of the ops, see document.Document.Version
+	Versions    map[string]uint64
 	InternalOps map[string][]byte
 }
 
 func NewBatch() *Batch {
 	return &Batch{
-		IndexOps:    make(map[string]*document.Document),
-		InternalOps: make(map[string][]byte),
+		IndexOps:        make(map[string]*document.Document),
+		UpdateFieldsOps: make(map[string]*document.Document),
+		Versions:        make(map[string]uint64),
+		InternalOps:     make(map[string][]byte),
 	}
 }
 
 func (b *Batch) Update(doc *document.Document) {
 	b.IndexOps[doc.ID] = doc
+	delete(b.UpdateFieldsOps, doc.ID)
+	b.expectVersion(doc.ID, doc.Version)
+}
+
+// expectVersion records the version the index must hold for the
+// document, 0 keeps the one an earlier op of the batch expects
+func (b *Batch) expectVersion(id string, version uint64) {
+	if version > 0 {
+		b.Versions[id] = version
+	}
+}
+
+// UpdateFields replaces the fields of the document named like the
+// fields of doc.  A document the batch indexes gets them right away,
+// the update of a document the batch deletes is dropped.
+func (b *Batch) UpdateFields(doc *document.Document) {
+	if indexed, ok := b.IndexOps[doc.ID]; ok {
+		if indexed != nil {
+			indexed.ReplaceFields(doc)
+		}
+		return
+	}
+	b.expectVersion(doc.ID, doc.Version)
+	if updated, ok := b.UpdateFieldsOps[doc.ID]; ok {
+		updated.ReplaceFields(doc)
+		return
+	}
+	b.UpdateFieldsOps[doc.ID] = doc
 }
 
 func (b *Batch) Delete(id string) {
 	b.IndexOps[id] = nil
+	delete(b.UpdateFieldsOps, id)
+}
+
+// DeleteVersion deletes the document only if the index holds the
+// version
+func (b *Batch) DeleteVersion(id string, version uint64) {
+	b.Delete(id)
+	b.expectVersion(id, version)
 }
 
 func (b *Batch) SetInternal(key, val []byte) {
@@ -207,7 +359,7 @@ func (b *Batch) DeleteInternal(key []byte) {
 }
 
 func (b *Batch) String() string {
-	rv := fmt.Sprintf("Batch (%d ops, %d internal ops)\n", len(b.IndexOps), len(b.InternalOps))
+	rv := fmt.Sprintf("Batch (%d ops, %d internal ops)\n", len(b.IndexOps)+len(b.UpdateFieldsOps), len(b.InternalOps))
 	for k, v := range b.IndexOps {
 		if v != nil {
 			rv += fmt.Sprintf("\tINDEX - '%s'\n", k)
@@ -215,6 +367,9 @@ func (b *Batch) String() string {
 			rv += fmt.Sprintf("\tDELETE - '%s'\n", k)
 		}
 	}
+	for k := range b.UpdateFieldsOps {
+		rv += fmt.Sprintf("\tUPDATE FIELDS - '%s'\n", k)
+	}
 	for k, v := range b.InternalOps {
 		if v != nil {
 			rv += fmt.Sprintf("\tSET INTERNAL - '%s'\n", k)
@@ -227,5 +382,7 @@ func (b *Batch) String() string {
 
 func (b *Batch) Reset() {
 	b.IndexOps = make(map[string]*document.Document)
+	b.UpdateFieldsOps = make(map[string]*document.Document)
+	b.Versions = make(map[string]uint64)
 	b.InternalOps = make(map[string][]byte)
 }
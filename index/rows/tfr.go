@@ -28,6 +28,11 @@ type TermFrequencyRow struct {
 	Score   float32 // a "pre" score of this document if this term was searched for, allows truncation of term frequency iteration
 	Vectors []*TermVector
 	Field   uint16
+	// FieldLength is the number of tokens of the field in this document
+	FieldLength uint32
+	// Boost is the index time boost of the field in this document, 0
+	// is no boost
+	Boost float32
 }
 
 func (v *TermFrequencyRow) Table() string {
@@ -139,7 +144,7 @@ func (tfr *TermFrequencyRow) TFVectorsValueTo(buf []byte) (int, error) {
 }
 
 func (tfr *TermFrequencyRow) String() string {
-	return fmt.Sprintf("Term: `%s` Field: %d DocId: `%s` Frequency: %d Score: %f Vectors: %v", string(tfr.Term), tfr.Field, string(tfr.Doc), tfr.Freq, tfr.Score, tfr.Vectors)
+	return fmt.Sprintf("Term: `%s` Field: %d DocId: `%s` Frequency: %f Score: %f Length: %d Boost: %f Vectors: %v", string(tfr.Term), tfr.Field, string(tfr.Doc), tfr.Freq, tfr.Score, tfr.FieldLength, tfr.Boost, tfr.Vectors)
 }
 
 func InitTermFrequencyRow(tfr *TermFrequencyRow, term []byte, field uint16, docID []byte, freq float32, score float32) *TermFrequencyRow {
@@ -179,6 +184,14 @@ func NewTermFrequencyRowFromMap(m map[string]interface{}) (*TermFrequencyRow, er
 	}
 	r.Freq = m["freq"].(float32)
 	r.Score = m["score"].(float32)
+	// rows written before field lengths were kept have none
+	if length, ok := m["length"].(int); ok {
+		r.FieldLength = uint32(length)
+	}
+	// and rows written before boosts were kept none either
+	if boost, ok := m["boost"].(float32); ok {
+		r.Boost = boost
+	}
 	err = r.parseTFVectorsV(m["vectors"].([]byte), true)
 	return r, err
 }
@@ -197,19 +210,21 @@ func (tfr *TermFrequencyRow) parseK(key []byte) error {
 	if keyLen < 3 {
 		return fmt.Errorf("invalid term frequency key, no valid field")
 	}
-	tfr.Field = binary.LittleEndian.Uint16(key[1:3])
+	// the key is laid out as TermFrequencyRowKeyTo writes it, there is
+	// no row type prefix
+	tfr.Field = binary.LittleEndian.Uint16(key[0:2])
 
-	termEndPos := bytes.IndexByte(key[3:], ByteSeparator)
+	termEndPos := bytes.IndexByte(key[2:], ByteSeparator)
 	if termEndPos < 0 {
 		return fmt.Errorf("invalid term frequency key, no byte separator terminating term")
 	}
-	tfr.Term = key[3 : 3+termEndPos]
+	tfr.Term = key[2 : 2+termEndPos]
 
-	docLen := keyLen - (3 + termEndPos + 1)
+	docLen := keyLen - (2 + termEndPos + 1)
 	if docLen < 1 {
 		return fmt.Errorf("invalid term frequency key, empty docid")
 	}
-	tfr.Doc = key[3+termEndPos+1:]
+	tfr.Doc = key[2+termEndPos+1:]
 
 	return nil
 }
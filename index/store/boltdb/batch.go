@@ -0,0 +1,106 @@
+package boltdb
+
+import (
+	"errors"
+
+	"github.com/boltdb/bolt"
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/index/rows"
+)
+
+type opKind int
+
+const (
+	opSet opKind = iota
+	opDelete
+	opIncrement
+)
+
+type op struct {
+	kind   opKind
+	table  string
+	key    []byte
+	val    []byte
+	amount int64
+}
+
+type Batch struct {
+	store *Store
+	ops   []*op
+}
+
+func (b *Batch) Set(table string, row interface{}) error {
+	indexRow, ok := row.(index.IndexRow)
+	if !ok {
+		return errors.New("Invalid main row type.")
+	}
+	var val []byte
+	if table == TermFrequencyTable {
+		termRow, ok := row.(*rows.TermFrequencyRow)
+		if !ok {
+			return errors.New("Invalid row type.")
+		}
+		val = encodeTermFrequency(termRow.Freq, termRow.Score, termRow.FieldLength, termRow.Boost, termRow.TFVectorsValue())
+	} else {
+		val = copyBytes(indexRow.Value())
+	}
+	b.ops = append(b.ops, &op{kind: opSet, table: table, key: copyBytes(indexRow.Key()), val: val})
+	return nil
+}
+
+func (b *Batch) Delete(table string, key []byte) error {
+	b.ops = append(b.ops, &op{kind: opDelete, table: table, key: copyBytes(key)})
+	return nil
+}
+
+func (b *Batch) Increment(table string, key []byte, amount int64) error {
+	b.ops = append(b.ops, &op{kind: opIncrement, table: table, key: copyBytes(key), amount: amount})
+	return nil
+}
+
+// apply runs the batch inside a single read-write transaction
+func (b *Batch) apply(tx *bolt.Tx) error {
+	for _, o := range b.ops {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(o.table))
+		if err != nil {
+			return err
+		}
+		switch o.kind {
+		case opSet:
+			err = bucket.Put(o.key, o.val)
+		case opDelete:
+			err = bucket.Delete(o.key)
+		case opIncrement:
+			var counter int64
+			if existing := bucket.Get(o.key); existing != nil {
+				counter, err = decodeCounter(existing)
+				if err != nil {
+					return err
+				}
+			}
+			err = bucket.Put(o.key, encodeCounter(counter+o.amount))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Batch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+func (b *Batch) Close() error {
+	b.ops = nil
+	return nil
+}
+
+func copyBytes(in []byte) []byte {
+	if in == nil {
+		return nil
+	}
+	rv := make([]byte, len(in))
+	copy(rv, in)
+	return rv
+}
@@ -0,0 +1,128 @@
+package boltdb
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+type Iterator struct {
+	cursor *bolt.Cursor
+
+	start  []byte
+	end    []byte
+	prefix []byte
+
+	key   []byte
+	val   []byte
+	valid bool
+}
+
+func newIterator(bucket *bolt.Bucket, start, end, prefix []byte) *Iterator {
+	rv := &Iterator{
+		start:  start,
+		end:    end,
+		prefix: prefix,
+	}
+	if bucket != nil {
+		rv.cursor = bucket.Cursor()
+		rv.Seek(start)
+	}
+	return rv
+}
+
+func (i *Iterator) update(key, val []byte) {
+	i.key, i.val = key, val
+	i.valid = key != nil
+	if i.valid && len(i.end) > 0 && bytes.Compare(key, i.end) >= 0 {
+		i.valid = false
+	}
+	if i.valid && i.prefix != nil && !bytes.HasPrefix(key, i.prefix) {
+		i.valid = false
+	}
+}
+
+func (i *Iterator) Seek(key []byte) {
+	if i.cursor == nil {
+		return
+	}
+	if bytes.Compare(key, i.start) < 0 {
+		key = i.start
+	}
+	if len(key) == 0 {
+		i.update(i.cursor.First())
+		return
+	}
+	i.update(i.cursor.Seek(key))
+}
+
+func (i *Iterator) Next() {
+	if i.cursor == nil {
+		return
+	}
+	i.update(i.cursor.Next())
+}
+
+func (i *Iterator) Current() ([]byte, []byte, bool) {
+	if !i.valid {
+		return nil, nil, false
+	}
+	return i.key, i.val, true
+}
+
+func (i *Iterator) Key() []byte {
+	if !i.valid {
+		return nil
+	}
+	return i.key
+}
+
+func (i *Iterator) Value() []byte {
+	if !i.valid {
+		return nil
+	}
+	return i.val
+}
+
+func (i *Iterator) Valid() bool {
+	return i.valid
+}
+
+func (i *Iterator) Close() error {
+	i.cursor = nil
+	i.valid = false
+	return nil
+}
+
+// TypedKVIterator walks the same rows as Iterator but decodes
+// the typed term frequency columns, a row that fails to decode
+// stops the iteration and its error is returned by Close
+type TypedKVIterator struct {
+	*Iterator
+
+	err error
+}
+
+func (i *TypedKVIterator) Current() ([]byte, map[string]interface{}, bool) {
+	if !i.valid {
+		return nil, nil, false
+	}
+	val, err := decodeTermFrequency(i.key, i.val)
+	if err != nil {
+		i.err = fmt.Errorf("decoding term frequency row %q: %v", i.key, err)
+		i.valid = false
+		return nil, nil, false
+	}
+	return i.key, val, true
+}
+
+func (i *TypedKVIterator) Value() map[string]interface{} {
+	_, val, _ := i.Current()
+	return val
+}
+
+func (i *TypedKVIterator) Close() error {
+	_ = i.Iterator.Close()
+	return i.err
+}
@@ -0,0 +1,67 @@
+package boltdb
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/wrble/flock/index/store"
+)
+
+// Reader holds a read-only bolt transaction, it observes the store as
+// it was when the reader was opened
+type Reader struct {
+	store *Store
+	tx    *bolt.Tx
+}
+
+func (r *Reader) bucket(table string) *bolt.Bucket {
+	return r.tx.Bucket([]byte(table))
+}
+
+func (r *Reader) Get(table string, key []byte) ([]byte, error) {
+	bucket := r.bucket(table)
+	if bucket == nil {
+		return nil, nil
+	}
+	return copyBytes(bucket.Get(key)), nil
+}
+
+func (r *Reader) GetCounter(table string, key []byte) (int64, error) {
+	bucket := r.bucket(table)
+	if bucket == nil {
+		return -1, nil
+	}
+	value := bucket.Get(key)
+	if value == nil {
+		return -1, nil
+	}
+	return decodeCounter(value)
+}
+
+func (r *Reader) DocCount() (uint64, error) {
+	bucket := r.bucket("b")
+	if bucket == nil {
+		return 0, nil
+	}
+	return uint64(bucket.Stats().KeyN), nil
+}
+
+func (r *Reader) MultiGet(table string, keys [][]byte) ([][]byte, error) {
+	return store.MultiGet(r, table, keys)
+}
+
+func (r *Reader) PrefixIterator(table string, prefix []byte) store.KVIterator {
+	return newIterator(r.bucket(table), prefix, nil, prefix)
+}
+
+func (r *Reader) TypedPrefixIterator(table string, prefix []byte) store.TypedKVIterator {
+	return &TypedKVIterator{
+		Iterator: newIterator(r.bucket(table), prefix, nil, prefix),
+	}
+}
+
+func (r *Reader) RangeIterator(table string, start, end []byte) store.KVIterator {
+	return newIterator(r.bucket(table), start, end, nil)
+}
+
+func (r *Reader) Close() error {
+	return r.tx.Rollback()
+}
@@ -0,0 +1,121 @@
+package boltdb
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/boltdb/bolt"
+	"github.com/wrble/flock/index/store"
+	"github.com/wrble/flock/registry"
+)
+
+const (
+	Name = "boltdb"
+)
+
+// Tables are created up front, every other table used by a batch
+// is created on demand inside the same transaction
+var Tables = []string{
+	"b",
+	"d",
+	"f",
+	"i",
+	"n",
+	"s",
+	"t",
+	"v",
+	"x",
+}
+
+// CounterTable holds the counters maintained through KVBatch.Increment
+const CounterTable = "d"
+
+// TermFrequencyTable holds typed term frequency rows
+const TermFrequencyTable = "t"
+
+func init() {
+	registry.RegisterKVStore(Name, New)
+}
+
+// Store is a single node, on-disk KVStore backed by a bolt database.
+// Each logical table is kept in its own bucket, every batch is
+// committed in a single bolt transaction so it is applied atomically
+// and survives a crash once ExecuteBatch returns.
+type Store struct {
+	mo store.MergeOperator
+
+	path string
+	db   *bolt.DB
+}
+
+func New(mo store.MergeOperator, config map[string]interface{}) (store.KVStore, error) {
+	path, ok := config["path"].(string)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("must specify: path")
+	}
+
+	createIfMissing, _ := config["create_if_missing"].(bool)
+	errorIfExists, _ := config["error_if_exists"].(bool)
+	readOnly, _ := config["read_only"].(bool)
+	noSync, _ := config["nosync"].(bool)
+
+	_, err := os.Stat(path)
+	if err == nil && errorIfExists {
+		return nil, fmt.Errorf("store already exists at path: %s", path)
+	}
+	if os.IsNotExist(err) && !createIfMissing {
+		return nil, fmt.Errorf("store does not exist at path: %s", path)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{
+		ReadOnly: readOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.NoSync = noSync
+
+	if !readOnly {
+		err = db.Update(func(tx *bolt.Tx) error {
+			for _, table := range Tables {
+				_, err := tx.CreateBucketIfNotExists([]byte(table))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	rv := Store{
+		mo:   mo,
+		path: path,
+		db:   db,
+	}
+	return &rv, nil
+}
+
+func (bs *Store) Close() error {
+	return bs.db.Close()
+}
+
+func (bs *Store) Reader() (store.KVReader, error) {
+	tx, err := bs.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		store: bs,
+		tx:    tx,
+	}, nil
+}
+
+func (bs *Store) Writer() (store.KVWriter, error) {
+	return &Writer{
+		store: bs,
+	}, nil
+}
@@ -0,0 +1,190 @@
+package boltdb
+
+import (
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/wrble/flock/index/rows"
+	"github.com/wrble/flock/index/store"
+	"github.com/wrble/flock/index/store/test"
+)
+
+func open(t *testing.T, mo store.MergeOperator) store.KVStore {
+	rv, err := New(mo, map[string]interface{}{
+		"path":              "test",
+		"create_if_missing": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rv
+}
+
+func cleanup(t *testing.T, s store.KVStore) {
+	err := s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.RemoveAll("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBoltDBKVCrud(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+	test.CommonTestKVCrud(t, s)
+}
+
+func TestBoltDBReaderIsolation(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+	test.CommonTestReaderIsolation(t, s)
+}
+
+func TestBoltDBReaderOwnsGetBytes(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+	test.CommonTestReaderOwnsGetBytes(t, s)
+}
+
+func TestBoltDBPrefixIterator(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+	test.CommonTestPrefixIterator(t, s)
+}
+
+func TestBoltDBPrefixIteratorSeek(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+	test.CommonTestPrefixIteratorSeek(t, s)
+}
+
+func TestBoltDBRangeIterator(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+	test.CommonTestRangeIterator(t, s)
+}
+
+func TestBoltDBMerge(t *testing.T) {
+	s := open(t, &test.TestMergeCounter{})
+	defer cleanup(t, s)
+	test.CommonTestMerge(t, s)
+}
+
+func TestBoltDBCreateOptions(t *testing.T) {
+	_, err := New(nil, map[string]interface{}{
+		"path": "test",
+	})
+	if err == nil {
+		t.Fatal("expected error opening missing store without create_if_missing")
+	}
+
+	s := open(t, nil)
+	defer cleanup(t, s)
+
+	_, err = New(nil, map[string]interface{}{
+		"path":              "test",
+		"create_if_missing": true,
+		"error_if_exists":   true,
+	})
+	if err == nil {
+		t.Fatal("expected error opening existing store with error_if_exists")
+	}
+}
+
+func TestBoltDBTypedRowsAndCountersSurviveReopen(t *testing.T) {
+	s := open(t, nil)
+
+	writer, err := s.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := writer.NewBatch()
+	tfr := rows.NewTermFrequencyRow([]byte("beer"), 1, []byte("a"), 0.5, 0.25)
+	if err := batch.Set(TermFrequencyTable, tfr); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Increment(CounterTable, tfr.DictionaryRowKey(), 3); err != nil {
+		t.Fatal(err)
+	}
+	err = writer.ExecuteBatch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = New(nil, map[string]interface{}{
+		"path": "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(t, s)
+
+	reader, err := s.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	count, err := reader.GetCounter(CounterTable, tfr.DictionaryRowKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("expected counter 3, got %d", count)
+	}
+
+	iter := reader.TypedPrefixIterator(TermFrequencyTable, tfr.ScanPrefixForFieldTerm())
+	_, val, valid := iter.Current()
+	if !valid {
+		t.Fatal("expected a valid term frequency row")
+	}
+	if val["freq"].(float32) != 0.5 || val["score"].(float32) != 0.25 {
+		t.Errorf("expected freq 0.5 and score 0.25, got %v", val)
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBoltDBTypedIteratorDecodeError(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+
+	tfr := rows.NewTermFrequencyRow([]byte("beer"), 1, []byte("a"), 0.5, 0.25)
+	err := s.(*Store).db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(TermFrequencyTable)).Put(tfr.Key(), []byte{0x1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := s.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	iter := reader.TypedPrefixIterator(TermFrequencyTable, tfr.ScanPrefixForFieldTerm())
+	if _, _, valid := iter.Current(); valid {
+		t.Fatal("expected the truncated row to stop the iteration")
+	}
+	if err := iter.Close(); err == nil {
+		t.Fatal("expected the decode error from Close")
+	}
+}
@@ -0,0 +1,53 @@
+package boltdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// term frequency rows are stored as freq (4 bytes), score (4 bytes),
+// field length (4 bytes), boost (4 bytes) followed by the encoded term
+// vectors
+
+const termFrequencyHeaderSize = 16
+
+func encodeTermFrequency(freq, score float32, length uint32, boost float32, vectors []byte) []byte {
+	buf := make([]byte, termFrequencyHeaderSize+len(vectors))
+	binary.BigEndian.PutUint32(buf[0:4], math.Float32bits(freq))
+	binary.BigEndian.PutUint32(buf[4:8], math.Float32bits(score))
+	binary.BigEndian.PutUint32(buf[8:12], length)
+	binary.BigEndian.PutUint32(buf[12:16], math.Float32bits(boost))
+	copy(buf[termFrequencyHeaderSize:], vectors)
+	return buf
+}
+
+func decodeTermFrequency(key, value []byte) (map[string]interface{}, error) {
+	if len(value) < termFrequencyHeaderSize {
+		return nil, fmt.Errorf("invalid term frequency value, length %d", len(value))
+	}
+	return map[string]interface{}{
+		"key":     key,
+		"freq":    math.Float32frombits(binary.BigEndian.Uint32(value[0:4])),
+		"score":   math.Float32frombits(binary.BigEndian.Uint32(value[4:8])),
+		"length":  int(binary.BigEndian.Uint32(value[8:12])),
+		"boost":   math.Float32frombits(binary.BigEndian.Uint32(value[12:16])),
+		"vectors": value[termFrequencyHeaderSize:],
+	}, nil
+}
+
+// counters are encoded the same way a DictionaryRow value is encoded
+
+func encodeCounter(counter int64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	used := binary.PutUvarint(buf, uint64(counter))
+	return buf[:used]
+}
+
+func decodeCounter(value []byte) (int64, error) {
+	counter, nread := binary.Uvarint(value)
+	if nread <= 0 {
+		return 0, fmt.Errorf("invalid counter value, nread: %d", nread)
+	}
+	return int64(counter), nil
+}
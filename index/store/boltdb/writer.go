@@ -0,0 +1,45 @@
+package boltdb
+
+import (
+	"fmt"
+
+	"github.com/wrble/flock/index/store"
+)
+
+type Writer struct {
+	store *Store
+}
+
+func (w *Writer) NewBatch() store.KVBatch {
+	return &Batch{
+		store: w.store,
+	}
+}
+
+func (w *Writer) NewBatchEx(options store.KVBatchOptions) (store.KVBatch, error) {
+	rv := &Batch{
+		store: w.store,
+		ops:   make([]*op, 0, options.NumSets+options.NumDeletes+options.NumMerges),
+	}
+	return rv, nil
+}
+
+func (w *Writer) ExecuteBatch(b store.KVBatch) error {
+	batch, ok := b.(*Batch)
+	if !ok {
+		return fmt.Errorf("wrong type of batch")
+	}
+	if batch.store != w.store {
+		return fmt.Errorf("batch belongs to a different store")
+	}
+	err := w.store.db.Update(batch.apply)
+	if err != nil {
+		return err
+	}
+	batch.Reset()
+	return nil
+}
+
+func (w *Writer) Close() error {
+	return nil
+}
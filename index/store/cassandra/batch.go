@@ -2,6 +2,9 @@ package cassandra
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gocql/gocql"
 	"github.com/pkg/errors"
@@ -10,17 +13,148 @@ import (
 	"github.com/wrble/flock/index/store"
 )
 
+// DefaultBatchSizeBytes keeps every batch under the default
+// batch_size_warn_threshold_in_kb (5KB) of Cassandra
+const DefaultBatchSizeBytes = 4 * 1024
+
+// DefaultBatchWorkers is the number of partitions written concurrently
+const DefaultBatchWorkers = 4
+
+const BATCH_TYPE = gocql.UnloggedBatch
+
+// rough per statement overhead, on top of the key and values
+const statementOverhead = 16
+
+type statement struct {
+	table string
+	key   []byte
+	cql   string
+	args  []interface{}
+	size  int
+}
+
+// partition groups the statements that share the same Cassandra
+// partition (table, type and bucket), so every batch stays
+// single-partition
+type partition struct {
+	mapped     string
+	table      string
+	counter    bool
+	statements []*statement
+}
+
+// posting is a term frequency row written or deleted by the batch,
+// kept to maintain ScorePostingsTable
+type posting struct {
+	bucket  []byte
+	key     []byte
+	deleted bool
+	freq    float32
+	score   float32
+	length  int
+	boost   float32
+	vectors []byte
+	// ttl is the TTL in seconds the posting is written with, 0 for none
+	ttl int
+}
+
 type Batch struct {
-	store        *Store
-	merge        *EmulatedMerge
-	batch        *gocql.Batch
-	counterBatch *gocql.Batch
+	store      *Store
+	merge      *EmulatedMerge
+	partitions map[string]*partition
+	order      []*partition
+
+	postings []*posting
+	// scored is set once the ScorePostingsTable statements of postings
+	// were added, a retried Execute must not read the old scores again
+	scored bool
+
+	// writeChunk writes the statements of one single-partition batch
+	writeChunk func(batchType gocql.BatchType, chunk []*statement) error
 }
 
-const MAX_BATCH_STATEMENTS = 1 // try and estimate what's under 5K
-const BATCH_TYPE = gocql.UnloggedBatch
+// RowError records a single row that could not be written
+type RowError struct {
+	Table string
+	Key   []byte
+	Err   error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("table %s key %q: %v", e.Table, e.Key, e.Err)
+}
+
+// BatchError is returned by ExecuteBatch when some of the rows
+// of the batch could not be written
+type BatchError struct {
+	Rows []*RowError
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, 0, len(e.Rows))
+	for i, row := range e.Rows {
+		if i >= 10 {
+			msgs = append(msgs, fmt.Sprintf("and %d more", len(e.Rows)-i))
+			break
+		}
+		msgs = append(msgs, row.Error())
+	}
+	return fmt.Sprintf("%d rows failed: %s", len(e.Rows), strings.Join(msgs, "; "))
+}
+
+var errSkipped = errors.New("not executed, earlier batch in partition failed")
+
+func newBatch(s *Store) *Batch {
+	rv := &Batch{
+		store: s,
+	}
+	rv.writeChunk = rv.write
+	rv.Reset()
+	return rv
+}
+
+func (b *Batch) add(mapped, table string, bucket []byte, counter bool, stmt *statement) {
+	partitionKey := b.store.partition(table)
+	key := mapped + "/" + partitionKey + "/" + string(bucket)
+	p, ok := b.partitions[key]
+	if !ok {
+		p = &partition{
+			mapped:  mapped,
+			table:   table,
+			counter: counter,
+		}
+		b.partitions[key] = p
+		b.order = append(b.order, p)
+	}
+	stmt.table = table
+	stmt.size += len(stmt.key) + len(partitionKey) + len(bucket) + statementOverhead
+	p.statements = append(p.statements, stmt)
+}
 
 func (b *Batch) Set(table string, row interface{}) error {
+	return b.set(table, row, 0)
+}
+
+// SetWithTTL writes the row with a Cassandra TTL, rounded up to whole
+// seconds, so it is removed once it expires
+func (b *Batch) SetWithTTL(table string, row interface{}, ttl time.Duration) error {
+	seconds := int((ttl + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return b.set(table, row, seconds)
+}
+
+// using returns the USING TTL clause and argument of a write expiring
+// after ttl seconds, nothing when it does not expire
+func using(ttl int, args []interface{}) (string, []interface{}) {
+	if ttl <= 0 {
+		return ``, args
+	}
+	return ` USING TTL ?`, append(args, ttl)
+}
+
+func (b *Batch) set(table string, row interface{}, ttl int) error {
 	indexRow, ok := row.(index.IndexRow)
 	if !ok {
 		return errors.New("Invalid main row type.")
@@ -29,65 +163,297 @@ func (b *Batch) Set(table string, row interface{}) error {
 	if b.store.debug {
 		fmt.Println("INSERT TABLE:", mapped, table, string(indexRow.Key()), string(indexRow.Value()))
 	}
-	if mapped == "t" {
+	key := append([]byte(nil), indexRow.Key()...)
+	bucket := b.store.bucket(table, key)
+	if table == "t" {
 		termRow, ok := row.(*rows.TermFrequencyRow)
 		if !ok {
 			return errors.New("Invalid row type.")
 		}
-		b.batch.Query(`INSERT INTO `+mapped+` (type, key, freq, score, vectors) VALUES (?, ?, ?, ?, ?)`, table, termRow.Key(), termRow.Freq, termRow.Score, termRow.TFVectorsValue())
+		b.setPosting(bucket, key, termRow.Freq, termRow.Score, int(termRow.FieldLength), termRow.Boost, termRow.TFVectorsValue(), ttl)
 	} else {
-		b.batch.Query(`INSERT INTO `+mapped+` (type, key, value) VALUES (?, ?, ?)`, table, indexRow.Key(), indexRow.Value())
+		value := append([]byte(nil), indexRow.Value()...)
+		clause, args := using(ttl, []interface{}{b.store.partition(table), bucket, key, value})
+		b.add(mapped, table, bucket, false, &statement{
+			key:  key,
+			cql:  `INSERT INTO ` + mapped + ` (type, bucket, key, value) VALUES (?, ?, ?, ?)` + clause,
+			args: args,
+			size: len(value),
+		})
 	}
-	return WrapError(b.CheckExecute(), "SET")
+	return nil
 }
 
 func (b *Batch) Delete(table string, key []byte) error {
+	mapped := TableMapping(table)
 	if b.store.debug {
-		fmt.Println("DELETE TABLE:", TableMapping(table), table, string(key))
+		fmt.Println("DELETE TABLE:", mapped, table, string(key))
 	}
-	b.batch.Query(`DELETE FROM `+TableMapping(table)+` WHERE type = ? AND key = ?`, table, key)
-	return WrapError(b.CheckExecute(), "DELETE")
+	key = append([]byte(nil), key...)
+	bucket := b.store.bucket(table, key)
+	b.add(mapped, table, bucket, false, &statement{
+		key:  key,
+		cql:  `DELETE FROM ` + mapped + ` WHERE type = ? AND bucket = ? AND key = ?`,
+		args: []interface{}{b.store.partition(table), bucket, key},
+	})
+	if table == "t" {
+		b.postings = append(b.postings, &posting{bucket: bucket, key: key, deleted: true})
+	}
+	return nil
+}
+
+// setPosting writes a term frequency row, expiring after ttl seconds
+// when ttl is positive
+func (b *Batch) setPosting(bucket, key []byte, freq, score float32, length int, boost float32, vectors []byte, ttl int) {
+	clause, args := using(ttl, []interface{}{b.store.partition("t"), bucket, key, freq, score, length, boost, vectors})
+	b.add(PostingsTable, "t", bucket, false, &statement{
+		key:  key,
+		cql:  `INSERT INTO ` + PostingsTable + ` (type, bucket, key, freq, score, length, boost, vectors) VALUES (?, ?, ?, ?, ?, ?, ?, ?)` + clause,
+		args: args,
+		size: len(vectors) + 16,
+	})
+	b.postings = append(b.postings, &posting{bucket: bucket, key: key, freq: freq, score: score, length: length, boost: boost, vectors: vectors, ttl: ttl})
+}
+
+// scorePostings adds the ScorePostingsTable statements mirroring the
+// postings of the batch.  The score is part of the primary key there,
+// so the row under the score currently stored in PostingsTable is
+// deleted when the posting is deleted or its score changes.
+func (b *Batch) scorePostings() error {
+	// the final state of every key, grouped by bucket
+	final := make(map[string]map[string]*posting)
+	var buckets []string
+	for _, p := range b.postings {
+		keys, ok := final[string(p.bucket)]
+		if !ok {
+			keys = make(map[string]*posting)
+			final[string(p.bucket)] = keys
+			buckets = append(buckets, string(p.bucket))
+		}
+		keys[string(p.key)] = p
+	}
+
+	partitionKey := b.store.partition("t")
+	for _, bucket := range buckets {
+		keys := final[bucket]
+		current, err := b.storedScores([]byte(bucket), keys)
+		if err != nil {
+			return err
+		}
+		for _, p := range keys {
+			score, stored := current[string(p.key)]
+			if stored && (p.deleted || score != p.score) {
+				b.add(ScorePostingsTable, "t", p.bucket, false, &statement{
+					key:  p.key,
+					cql:  `DELETE FROM ` + ScorePostingsTable + ` WHERE type = ? AND bucket = ? AND score = ? AND key = ?`,
+					args: []interface{}{partitionKey, p.bucket, score, p.key},
+					size: 4,
+				})
+			}
+			if !p.deleted {
+				// the copy ordered by score expires with the posting
+				clause, args := using(p.ttl, []interface{}{partitionKey, p.bucket, p.score, p.key, p.freq, p.length, p.boost, p.vectors})
+				b.add(ScorePostingsTable, "t", p.bucket, false, &statement{
+					key:  p.key,
+					cql:  `INSERT INTO ` + ScorePostingsTable + ` (type, bucket, score, key, freq, length, boost, vectors) VALUES (?, ?, ?, ?, ?, ?, ?, ?)` + clause,
+					args: args,
+					size: len(p.vectors) + 16,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// storedScoreKeys is the number of keys looked up per query
+const storedScoreKeys = 100
+
+// storedScores returns the score PostingsTable holds for the keys
+func (b *Batch) storedScores(bucket []byte, keys map[string]*posting) (map[string]float32, error) {
+	rv := make(map[string]float32, len(keys))
+	lookup := make([][]byte, 0, storedScoreKeys)
+	flush := func() error {
+		iter := b.store.read(`SELECT key, score FROM `+PostingsTable+` WHERE type = ? AND bucket = ? AND key IN ?`, b.store.partition("t"), bucket, lookup).Iter()
+		var key []byte
+		var score float32
+		for iter.Scan(&key, &score) {
+			rv[string(key)] = score
+		}
+		lookup = lookup[:0]
+		return WrapError(iter.Close(), "read posting scores")
+	}
+	for _, p := range keys {
+		lookup = append(lookup, p.key)
+		if len(lookup) == storedScoreKeys {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(lookup) > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	return rv, nil
 }
 
 func (b *Batch) Increment(table string, key []byte, amount int64) error {
 	if b.store.debug {
 		fmt.Println("INCREMENT TABLE:", TableMapping(table), table, string(key))
 	}
-	b.counterBatch.Query(`UPDATE d SET value = value + ? WHERE type = ? AND key = ?;`, amount, table, key)
-	return WrapError(b.CheckExecute(), "INCREMENT")
+	key = append([]byte(nil), key...)
+	b.add("d", table, nil, true, &statement{
+		key:  key,
+		cql:  `UPDATE d SET value = value + ? WHERE type = ? AND key = ?;`,
+		args: []interface{}{amount, b.store.partition(table), key},
+		size: 8,
+	})
+	return nil
 }
 
-// Check if we need to execute the partial batch due to size
-func (b *Batch) CheckExecute() error {
-	//fmt.Println("BATCH SIZE:", b.batch.Size())
-	if b.batch.Size() >= MAX_BATCH_STATEMENTS || b.counterBatch.Size() >= MAX_BATCH_STATEMENTS {
-		return b.Execute()
+// chunks splits the statements of a partition into batches which fit
+// the byte budget.  A key is never written twice in the same batch,
+// as all statements of a batch share the same timestamp and would
+// otherwise lose their ordering.
+func (p *partition) chunks(budget int) [][]*statement {
+	var rv [][]*statement
+	var current []*statement
+	currentSize := 0
+	seen := make(map[string]struct{})
+	for _, stmt := range p.statements {
+		_, dup := seen[string(stmt.key)]
+		if len(current) > 0 && (dup || currentSize+stmt.size > budget) {
+			rv = append(rv, current)
+			current = nil
+			currentSize = 0
+			seen = make(map[string]struct{})
+		}
+		current = append(current, stmt)
+		currentSize += stmt.size
+		seen[string(stmt.key)] = struct{}{}
+	}
+	if len(current) > 0 {
+		rv = append(rv, current)
+	}
+	return rv
+}
+
+func (b *Batch) write(batchType gocql.BatchType, chunk []*statement) error {
+	if len(chunk) == 1 {
+		return b.store.write(chunk[0].cql, chunk[0].args...).Exec()
+	}
+	batch := b.store.Session.NewBatch(batchType)
+	batch.SetConsistency(b.store.writeConsistency)
+	for _, stmt := range chunk {
+		batch.Query(stmt.cql, stmt.args...)
+	}
+	return b.store.Session.ExecuteBatch(batch)
+}
+
+// execute writes the partition one batch at a time, stopping at the
+// first failure, and returns the rows which were not written.  Only
+// those rows are left in the partition, so a retry does not apply the
+// written counter increments twice.
+func (b *Batch) execute(p *partition) []*RowError {
+	var failed []*RowError
+	batchType := BATCH_TYPE
+	if p.counter {
+		batchType = gocql.CounterBatch
+	}
+	chunks := p.chunks(b.store.batchSizeBytes)
+	for i, chunk := range chunks {
+		err := b.writeChunk(batchType, chunk)
+		if err != nil {
+			p.statements = nil
+			for j, rest := range chunks[i:] {
+				rowErr := err
+				if j > 0 {
+					rowErr = errSkipped
+				}
+				for _, stmt := range rest {
+					failed = append(failed, &RowError{Table: stmt.table, Key: stmt.key, Err: rowErr})
+				}
+				p.statements = append(p.statements, rest...)
+			}
+			return failed
+		}
 	}
+	p.statements = nil
 	return nil
 }
 
 func (b *Batch) Execute() error {
-	err := b.store.Session.ExecuteBatch(b.batch)
-	if err != nil {
-		return err
+	if !b.scored {
+		// the scores must be read before the postings are overwritten
+		if err := b.scorePostings(); err != nil {
+			return err
+		}
+		b.scored = true
+	}
+
+	workers := b.store.batchWorkers
+	if workers > len(b.order) {
+		workers = len(b.order)
+	}
+
+	jobs := make(chan *partition, len(b.order))
+	for _, p := range b.order {
+		jobs <- p
 	}
-	err = b.store.Session.ExecuteBatch(b.counterBatch)
-	if err != nil {
-		return err
+	close(jobs)
+
+	var m sync.Mutex
+	var failed []*RowError
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				if rowErrs := b.execute(p); len(rowErrs) > 0 {
+					m.Lock()
+					failed = append(failed, rowErrs...)
+					m.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		// drop the partitions which were written, a retried Execute
+		// only writes the rows of the BatchError
+		pending := b.order[:0]
+		for _, p := range b.order {
+			if len(p.statements) > 0 {
+				pending = append(pending, p)
+			}
+		}
+		b.order = pending
+		for key, p := range b.partitions {
+			if len(p.statements) == 0 {
+				delete(b.partitions, key)
+			}
+		}
+		return &BatchError{Rows: failed}
 	}
 	b.Reset()
 	return nil
 }
 
 func (b *Batch) Reset() {
-	b.batch = b.store.Session.NewBatch(BATCH_TYPE)
-	b.counterBatch = b.store.Session.NewBatch(gocql.CounterBatch)
+	b.partitions = make(map[string]*partition)
+	b.order = nil
+	b.postings = nil
+	b.scored = false
 	b.merge = NewEmulatedMerge(b.store.mo)
 }
 
 func (b *Batch) Close() error {
-	b.batch = nil
-	b.batch = nil
+	b.partitions = nil
+	b.order = nil
+	b.postings = nil
 	b.merge = nil
 	return nil
 }
@@ -0,0 +1,174 @@
+package cassandra
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+func TestBatchPartitionsAndChunks(t *testing.T) {
+	b := newBatch(&Store{batchSizeBytes: 100})
+	for _, key := range []string{"a", "b", "c"} {
+		b.add(RowsTable, "b", bucketID(0), false, &statement{key: []byte(key), size: 20})
+	}
+	b.add(RowsTable, "s", bucketID(0), false, &statement{key: []byte("a"), size: 20})
+	b.add("d", "d", nil, true, &statement{key: []byte("a"), size: 8})
+
+	if len(b.order) != 3 {
+		t.Fatalf("expected 3 partitions, got %d", len(b.order))
+	}
+	if !b.order[2].counter {
+		t.Errorf("expected counter partition to be flagged")
+	}
+
+	// each statement is 20 + 1 + 1 + 2 + 16 = 40 bytes, two fit in 100
+	chunks := b.order[0].chunks(b.store.batchSizeBytes)
+	if len(chunks) != 2 || len(chunks[0]) != 2 || len(chunks[1]) != 1 {
+		t.Errorf("expected chunks of 2 and 1 statements, got %v", chunks)
+	}
+
+	// a single oversized statement still gets its own batch
+	chunks = b.order[0].chunks(1)
+	if len(chunks) != 3 {
+		t.Errorf("expected 3 chunks, got %d", len(chunks))
+	}
+}
+
+func TestBatchChunksSplitOnRepeatedKey(t *testing.T) {
+	b := newBatch(&Store{batchSizeBytes: DefaultBatchSizeBytes})
+	b.add(RowsTable, "i", bucketID(0), false, &statement{key: []byte("k")})
+	b.add(RowsTable, "i", bucketID(0), false, &statement{key: []byte("other")})
+	b.add(RowsTable, "i", bucketID(0), false, &statement{key: []byte("k")})
+
+	chunks := b.order[0].chunks(b.store.batchSizeBytes)
+	if len(chunks) != 2 {
+		t.Fatalf("expected repeated key to start a new batch, got %d chunks", len(chunks))
+	}
+	if string(chunks[1][0].key) != "k" {
+		t.Errorf("expected second batch to start with the repeated key, got %s", chunks[1][0].key)
+	}
+}
+
+func TestBatchErrorReportsRows(t *testing.T) {
+	err := &BatchError{Rows: []*RowError{
+		{Table: "t", Key: []byte("k1"), Err: errSkipped},
+	}}
+	expected := `1 rows failed: table t key "k1": ` + errSkipped.Error()
+	if err.Error() != expected {
+		t.Errorf("expected %s, got %s", expected, err.Error())
+	}
+}
+
+func TestBatchNamespacePartitions(t *testing.T) {
+	legacy := newBatch(&Store{buckets: DefaultBuckets})
+	err := legacy.Delete("b", []byte("doc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partition := legacy.order[0].statements[0].args[0]; partition != "b" {
+		t.Errorf("expected partition b without namespace, got %v", partition)
+	}
+
+	b := newBatch(&Store{namespace: "indexes/one", buckets: DefaultBuckets})
+	err = b.Delete("b", []byte("doc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Increment("d", []byte("term"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partition := b.order[0].statements[0].args[0]; partition != "indexes/one/b" {
+		t.Errorf("expected partition indexes/one/b, got %v", partition)
+	}
+	if partition := b.order[1].statements[0].args[1]; partition != "indexes/one/d" {
+		t.Errorf("expected partition indexes/one/d, got %v", partition)
+	}
+}
+
+func TestBatchRetryAppliesCountersOnce(t *testing.T) {
+	b := newBatch(&Store{namespace: "indexes/one", buckets: DefaultBuckets, batchSizeBytes: DefaultBatchSizeBytes, batchWorkers: DefaultBatchWorkers})
+	if err := b.Increment("d", []byte("term"), 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set("s", &testRow{key: []byte("doc\xff\x00\x00"), value: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var m sync.Mutex
+	counters := make(map[string]int64)
+	fail := true
+	b.writeChunk = func(batchType gocql.BatchType, chunk []*statement) error {
+		m.Lock()
+		defer m.Unlock()
+		if chunk[0].table == "s" && fail {
+			return errors.New("timeout")
+		}
+		for _, stmt := range chunk {
+			if stmt.table == "d" {
+				counters[string(stmt.key)] += stmt.args[0].(int64)
+			}
+		}
+		return nil
+	}
+
+	err := b.Execute()
+	batchErr, ok := err.(*BatchError)
+	if !ok || len(batchErr.Rows) != 1 || batchErr.Rows[0].Table != "s" {
+		t.Fatalf("expected the stored field row to fail, got %v", err)
+	}
+	if len(b.order) != 1 || b.order[0].table != "s" {
+		t.Fatalf("expected only the failed partition to be left, got %d partitions", len(b.order))
+	}
+
+	fail = false
+	if err := b.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if counters["term"] != 2 {
+		t.Errorf("expected the counter to be incremented once by 2, got %d", counters["term"])
+	}
+}
+
+type testRow struct {
+	key   []byte
+	value []byte
+}
+
+func (r *testRow) Table() string                   { return "s" }
+func (r *testRow) Key() []byte                     { return r.key }
+func (r *testRow) ValueSize() int                  { return len(r.value) }
+func (r *testRow) ValueTo(buf []byte) (int, error) { return copy(buf, r.value), nil }
+func (r *testRow) Value() []byte                   { return r.value }
+
+func TestBatchSetWithTTL(t *testing.T) {
+	b := newBatch(&Store{buckets: DefaultBuckets})
+	err := b.SetWithTTL("s", &testRow{key: []byte("doc\xff\x00\x00"), value: []byte("v")}, 1500*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Set("s", &testRow{key: []byte("other\xff\x00\x00"), value: []byte("v")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expiring, kept *statement
+	for _, p := range b.order {
+		for _, stmt := range p.statements {
+			if string(stmt.key) == "doc\xff\x00\x00" {
+				expiring = stmt
+			} else {
+				kept = stmt
+			}
+		}
+	}
+	if !strings.HasSuffix(expiring.cql, "USING TTL ?") || expiring.args[len(expiring.args)-1] != 2 {
+		t.Errorf("expected a TTL of 2 seconds, got %s %v", expiring.cql, expiring.args)
+	}
+	if strings.Contains(kept.cql, "TTL") || len(kept.args) != 4 {
+		t.Errorf("expected no TTL, got %s %v", kept.cql, kept.args)
+	}
+}
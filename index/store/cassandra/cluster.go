@@ -0,0 +1,163 @@
+package cassandra
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+)
+
+// DefaultConsistency is used for reads and writes unless the config
+// sets "consistency", "read_consistency" or "write_consistency"
+const DefaultConsistency = gocql.Quorum
+
+// newClusterConfig builds the gocql cluster settings from the store
+// config.  Recognised keys, all optional except hosts and keyspace:
+//
+//	hosts, keyspace
+//	timeout, connect_timeout     duration string or milliseconds
+//	num_conns, protocol_version
+//	host_policy                  round_robin, dc_aware or token_aware
+//	local_dc                     required by dc_aware, used by token_aware
+//	retry_policy                 simple or exponential
+//	num_retries, retry_min, retry_max
+//	username, password
+//	tls_ca_path, tls_cert_path, tls_key_path, tls_host_verification
+func newClusterConfig(config map[string]interface{}) (*gocql.ClusterConfig, error) {
+	hosts, err := configStrings(config, "hosts")
+	if err != nil || len(hosts) == 0 {
+		return nil, errors.New("Must specify 'hosts' param as []string")
+	}
+	keyspace, err := configString(config, "keyspace", "")
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := gocql.NewCluster(hosts...)
+	cluster.Keyspace = keyspace
+	cluster.Consistency = DefaultConsistency
+
+	if cluster.Timeout, err = configDuration(config, "timeout", cluster.Timeout); err != nil {
+		return nil, err
+	}
+	if cluster.ConnectTimeout, err = configDuration(config, "connect_timeout", cluster.ConnectTimeout); err != nil {
+		return nil, err
+	}
+	if cluster.NumConns, err = configInt(config, "num_conns", cluster.NumConns); err != nil {
+		return nil, err
+	}
+	if cluster.ProtoVersion, err = configInt(config, "protocol_version", cluster.ProtoVersion); err != nil {
+		return nil, err
+	}
+
+	if err = setHostPolicy(cluster, config); err != nil {
+		return nil, err
+	}
+	if err = setRetryPolicy(cluster, config); err != nil {
+		return nil, err
+	}
+
+	username, err := configString(config, "username", "")
+	if err != nil {
+		return nil, err
+	}
+	if username != "" {
+		password, err := configString(config, "password", "")
+		if err != nil {
+			return nil, err
+		}
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: username,
+			Password: password,
+		}
+	}
+
+	return cluster, setSslOptions(cluster, config)
+}
+
+func setHostPolicy(cluster *gocql.ClusterConfig, config map[string]interface{}) error {
+	policy, err := configString(config, "host_policy", "")
+	if err != nil {
+		return err
+	}
+	localDC, err := configString(config, "local_dc", "")
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(policy) {
+	case "", "round_robin":
+		if localDC != "" {
+			cluster.PoolConfig.HostSelectionPolicy = gocql.DCAwareRoundRobinPolicy(localDC)
+		}
+	case "dc_aware":
+		if localDC == "" {
+			return errors.New("host_policy dc_aware requires 'local_dc'")
+		}
+		cluster.PoolConfig.HostSelectionPolicy = gocql.DCAwareRoundRobinPolicy(localDC)
+	case "token_aware":
+		fallback := gocql.RoundRobinHostPolicy()
+		if localDC != "" {
+			fallback = gocql.DCAwareRoundRobinPolicy(localDC)
+		}
+		cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(fallback)
+	default:
+		return fmt.Errorf("unknown host_policy: %s", policy)
+	}
+	return nil
+}
+
+func setRetryPolicy(cluster *gocql.ClusterConfig, config map[string]interface{}) error {
+	policy, err := configString(config, "retry_policy", "")
+	if err != nil {
+		return err
+	}
+	retries, err := configInt(config, "num_retries", 3)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(policy) {
+	case "":
+		if _, ok := config["num_retries"]; ok {
+			cluster.RetryPolicy = &gocql.SimpleRetryPolicy{NumRetries: retries}
+		}
+	case "simple":
+		cluster.RetryPolicy = &gocql.SimpleRetryPolicy{NumRetries: retries}
+	case "exponential":
+		rp := &gocql.ExponentialBackoffRetryPolicy{NumRetries: retries}
+		if rp.Min, err = configDuration(config, "retry_min", 0); err != nil {
+			return err
+		}
+		if rp.Max, err = configDuration(config, "retry_max", 0); err != nil {
+			return err
+		}
+		cluster.RetryPolicy = rp
+	default:
+		return fmt.Errorf("unknown retry_policy: %s", policy)
+	}
+	return nil
+}
+
+func setSslOptions(cluster *gocql.ClusterConfig, config map[string]interface{}) error {
+	opts := &gocql.SslOptions{}
+	var err error
+	if opts.CaPath, err = configString(config, "tls_ca_path", ""); err != nil {
+		return err
+	}
+	if opts.CertPath, err = configString(config, "tls_cert_path", ""); err != nil {
+		return err
+	}
+	if opts.KeyPath, err = configString(config, "tls_key_path", ""); err != nil {
+		return err
+	}
+	if opts.EnableHostVerification, err = configBool(config, "tls_host_verification", false); err != nil {
+		return err
+	}
+	if (opts.CertPath == "") != (opts.KeyPath == "") {
+		return errors.New("'tls_cert_path' and 'tls_key_path' must be set together")
+	}
+	if opts.CaPath != "" || opts.CertPath != "" || opts.EnableHostVerification {
+		cluster.SslOpts = opts
+	}
+	return nil
+}
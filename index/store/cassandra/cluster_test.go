@@ -0,0 +1,125 @@
+package cassandra
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+func TestNewClusterConfigFromIndexMeta(t *testing.T) {
+	// round trip through json, as the config is read back from index_meta.json
+	metaBytes := []byte(`{
+		"hosts": ["10.0.0.1", "10.0.0.2"],
+		"keyspace": "flock",
+		"timeout": "2s",
+		"connect_timeout": 1500,
+		"num_conns": 4,
+		"protocol_version": 4,
+		"host_policy": "token_aware",
+		"local_dc": "dc1",
+		"retry_policy": "exponential",
+		"num_retries": 5,
+		"retry_min": "10ms",
+		"retry_max": "1s",
+		"username": "flock",
+		"password": "secret",
+		"tls_ca_path": "/etc/ssl/ca.pem",
+		"tls_host_verification": true
+	}`)
+	var config map[string]interface{}
+	err := json.Unmarshal(metaBytes, &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cluster, err := newClusterConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cluster.Hosts) != 2 || cluster.Hosts[1] != "10.0.0.2" {
+		t.Errorf("unexpected hosts: %v", cluster.Hosts)
+	}
+	if cluster.Keyspace != "flock" {
+		t.Errorf("expected keyspace flock, got %s", cluster.Keyspace)
+	}
+	if cluster.Timeout != 2*time.Second {
+		t.Errorf("expected timeout 2s, got %v", cluster.Timeout)
+	}
+	if cluster.ConnectTimeout != 1500*time.Millisecond {
+		t.Errorf("expected connect timeout 1.5s, got %v", cluster.ConnectTimeout)
+	}
+	if cluster.NumConns != 4 {
+		t.Errorf("expected 4 conns, got %d", cluster.NumConns)
+	}
+	if cluster.ProtoVersion != 4 {
+		t.Errorf("expected protocol version 4, got %d", cluster.ProtoVersion)
+	}
+	if cluster.PoolConfig.HostSelectionPolicy == nil {
+		t.Errorf("expected a host selection policy")
+	}
+	rp, ok := cluster.RetryPolicy.(*gocql.ExponentialBackoffRetryPolicy)
+	if !ok {
+		t.Fatalf("expected exponential retry policy, got %T", cluster.RetryPolicy)
+	}
+	if rp.NumRetries != 5 || rp.Min != 10*time.Millisecond || rp.Max != time.Second {
+		t.Errorf("unexpected retry policy: %+v", rp)
+	}
+	auth, ok := cluster.Authenticator.(gocql.PasswordAuthenticator)
+	if !ok || auth.Username != "flock" || auth.Password != "secret" {
+		t.Errorf("unexpected authenticator: %#v", cluster.Authenticator)
+	}
+	if cluster.SslOpts == nil || cluster.SslOpts.CaPath != "/etc/ssl/ca.pem" || !cluster.SslOpts.EnableHostVerification {
+		t.Errorf("unexpected ssl options: %+v", cluster.SslOpts)
+	}
+}
+
+func TestNewClusterConfigErrors(t *testing.T) {
+	tests := []map[string]interface{}{
+		{"keyspace": "flock"},
+		{"hosts": []string{"127.0.0.1"}, "keyspace": "flock", "host_policy": "nearest"},
+		{"hosts": []string{"127.0.0.1"}, "keyspace": "flock", "host_policy": "dc_aware"},
+		{"hosts": []string{"127.0.0.1"}, "keyspace": "flock", "retry_policy": "forever"},
+		{"hosts": []string{"127.0.0.1"}, "keyspace": "flock", "timeout": "soon"},
+		{"hosts": []string{"127.0.0.1"}, "keyspace": "flock", "tls_cert_path": "/etc/ssl/cert.pem"},
+	}
+	for _, config := range tests {
+		_, err := newClusterConfig(config)
+		if err == nil {
+			t.Errorf("expected error for config %v", config)
+		}
+	}
+}
+
+func TestConfigConsistency(t *testing.T) {
+	config := map[string]interface{}{
+		"read_consistency":  "local_one",
+		"write_consistency": "LOCAL_QUORUM",
+	}
+	read, err := configConsistency(config, "read_consistency", DefaultConsistency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != gocql.LocalOne {
+		t.Errorf("expected LOCAL_ONE, got %v", read)
+	}
+	write, err := configConsistency(config, "write_consistency", DefaultConsistency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if write != gocql.LocalQuorum {
+		t.Errorf("expected LOCAL_QUORUM, got %v", write)
+	}
+	def, err := configConsistency(config, "consistency", DefaultConsistency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def != gocql.Quorum {
+		t.Errorf("expected QUORUM, got %v", def)
+	}
+	_, err = configConsistency(map[string]interface{}{"consistency": "most"}, "consistency", DefaultConsistency)
+	if err == nil {
+		t.Errorf("expected error for unknown consistency")
+	}
+}
@@ -0,0 +1,122 @@
+package cassandra
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// configInt reads an integer setting from the store config, values
+// that went through index_meta.json come back as float64
+func configInt(config map[string]interface{}, key string, def int) (int, error) {
+	value, ok := config[key]
+	if !ok || value == nil {
+		return def, nil
+	}
+	switch value := value.(type) {
+	case int:
+		return value, nil
+	case int32:
+		return int(value), nil
+	case int64:
+		return int(value), nil
+	case float64:
+		return int(value), nil
+	case string:
+		rv, err := strconv.Atoi(value)
+		if err != nil {
+			return def, fmt.Errorf("invalid value for '%s': %v", key, err)
+		}
+		return rv, nil
+	}
+	return def, fmt.Errorf("invalid value for '%s': %v", key, value)
+}
+
+func configString(config map[string]interface{}, key string, def string) (string, error) {
+	value, ok := config[key]
+	if !ok || value == nil {
+		return def, nil
+	}
+	rv, ok := value.(string)
+	if !ok {
+		return def, fmt.Errorf("invalid value for '%s': %v", key, value)
+	}
+	return rv, nil
+}
+
+func configBool(config map[string]interface{}, key string, def bool) (bool, error) {
+	value, ok := config[key]
+	if !ok || value == nil {
+		return def, nil
+	}
+	switch value := value.(type) {
+	case bool:
+		return value, nil
+	case string:
+		rv, err := strconv.ParseBool(value)
+		if err != nil {
+			return def, fmt.Errorf("invalid value for '%s': %v", key, err)
+		}
+		return rv, nil
+	}
+	return def, fmt.Errorf("invalid value for '%s': %v", key, value)
+}
+
+// configDuration accepts a duration string such as "500ms", or a
+// number which is taken as milliseconds
+func configDuration(config map[string]interface{}, key string, def time.Duration) (time.Duration, error) {
+	value, ok := config[key]
+	if !ok || value == nil {
+		return def, nil
+	}
+	if s, ok := value.(string); ok {
+		rv, err := time.ParseDuration(s)
+		if err != nil {
+			return def, fmt.Errorf("invalid value for '%s': %v", key, err)
+		}
+		return rv, nil
+	}
+	ms, err := configInt(config, key, 0)
+	if err != nil {
+		return def, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// configStrings reads a list of strings, values that went through
+// index_meta.json come back as []interface{}
+func configStrings(config map[string]interface{}, key string) ([]string, error) {
+	switch value := config[key].(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return value, nil
+	case string:
+		return []string{value}, nil
+	case []interface{}:
+		rv := make([]string, 0, len(value))
+		for _, v := range value {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid value for '%s': %v", key, value)
+			}
+			rv = append(rv, s)
+		}
+		return rv, nil
+	}
+	return nil, fmt.Errorf("invalid value for '%s': %v", key, config[key])
+}
+
+func configConsistency(config map[string]interface{}, key string, def gocql.Consistency) (gocql.Consistency, error) {
+	value, err := configString(config, key, "")
+	if err != nil || value == "" {
+		return def, err
+	}
+	rv, err := gocql.ParseConsistencyWrapper(value)
+	if err != nil {
+		return def, fmt.Errorf("invalid value for '%s': %v", key, err)
+	}
+	return rv, nil
+}
@@ -2,48 +2,172 @@ package cassandra
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 
 	"github.com/gocql/gocql"
 )
 
-type Iterator struct {
-	store    *Store
+// DefaultPageSize is the number of rows fetched per page by iterators
+const DefaultPageSize = 1000
+
+// DefaultSeekScanRows is how many rows Seek steps over on the current
+// page before it re-issues the query starting at the target key
+const DefaultSeekScanRows = 32
+
+// prefixEnd returns the smallest key greater than every key with the
+// given prefix, or nil if there is no such key
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// encodeCounter returns a counter in the uvarint encoding the other
+// stores use for the values of CounterTable
+func encodeCounter(counter int64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	used := binary.PutUvarint(buf, uint64(counter))
+	return buf[:used]
+}
+
+// rowQuery describes the bounded slice of a partition an iterator
+// walks, start is inclusive and end exclusive, a nil bound is open.
+// Partitions of ScorePostingsTable are walked whole, in score order,
+// CounterTable has a single partition per table and no bucket.
+type rowQuery struct {
+	store   *Store
+	columns string
+	table   string
+	mapped  string
+	bucket  []byte
+	start   []byte
+	end     []byte
+	prefix  []byte
+
 	iterator *gocql.Iter
+	err      error
+}
+
+// open (re)issues the query starting at from, closing the current page
+func (q *rowQuery) open(from []byte) {
+	q.close()
+	mapped := q.mapped
+	if mapped == "" {
+		mapped = TableMapping(q.table)
+	}
+	cql := `SELECT ` + q.columns + ` FROM ` + mapped + ` WHERE type = ?`
+	args := []interface{}{q.store.partition(q.table)}
+	if q.table != CounterTable {
+		cql += ` AND bucket = ?`
+		args = append(args, q.bucket)
+	}
+	if len(from) > 0 {
+		cql += ` AND key >= ?`
+		args = append(args, from)
+	}
+	if q.end != nil {
+		cql += ` AND key < ?`
+		args = append(args, q.end)
+	}
+	if q.store.debug {
+		fmt.Println("QUERY", cql, string(from), string(q.end))
+	}
+	q.iterator = q.store.read(cql, args...).PageSize(q.store.pageSize).Iter()
+}
+
+// inPrefix guards prefix scans whose upper bound could not be computed
+func (q *rowQuery) inPrefix(key []byte) bool {
+	return q.prefix == nil || bytes.HasPrefix(key, q.prefix)
+}
+
+func (q *rowQuery) close() {
+	if q.iterator != nil {
+		if err := q.iterator.Close(); err != nil && q.err == nil {
+			q.err = err
+		}
+		q.iterator = nil
+	}
+}
+
+// seek positions the iterator at the first key >= key.  Short hops are
+// served from the current page, anything further away is re-queried.
+func (q *rowQuery) seek(key []byte, current func() ([]byte, bool), next func()) {
+	if bytes.Compare(key, q.start) < 0 {
+		key = q.start
+	}
+	currentKey, valid := current()
+	if !valid || bytes.Compare(currentKey, key) > 0 {
+		q.open(key)
+		next()
+		return
+	}
+	for steps := 0; valid && bytes.Compare(currentKey, key) < 0; steps++ {
+		if steps >= q.store.seekScanRows || q.iterator.WillSwitchPage() {
+			if q.store.debug {
+				fmt.Println("SEEK REQUERY", string(key))
+			}
+			q.open(key)
+			next()
+			return
+		}
+		next()
+		currentKey, valid = current()
+	}
+}
 
-	startKey []byte
+type Iterator struct {
+	rowQuery
 
 	currentKey   []byte
 	currentValue []byte
 	currentValid bool
 }
 
+func newIterator(s *Store, table string, bucket, start, end, prefix []byte) *Iterator {
+	rv := &Iterator{
+		rowQuery: rowQuery{
+			store:   s,
+			columns: `value, key`,
+			table:   table,
+			bucket:  bucket,
+			start:   start,
+			end:     end,
+			prefix:  prefix,
+		},
+	}
+	rv.open(start)
+	rv.Next()
+	return rv
+}
+
+func (ldi *Iterator) current() ([]byte, bool) {
+	return ldi.currentKey, ldi.currentValid
+}
+
 func (ldi *Iterator) Seek(key []byte) {
 	if ldi.store.debug {
 		fmt.Println("SEEK", string(key))
 	}
-	for {
-		if ldi.store.debug && !ldi.Valid() {
-			fmt.Println("SEEK INVALID: ", string(key))
-			return
-		}
-		if ldi.store.debug {
-			fmt.Println("SEEK EQ", string(key), string(ldi.currentKey))
-		}
-		if bytes.Compare(ldi.currentKey, key) >= 0 {
-			if ldi.store.debug {
-				fmt.Println("SEEK DONE: ", string(key))
-			}
-			return
-		}
-		ldi.Next()
-	}
+	ldi.seek(key, ldi.current, ldi.Next)
 }
 
 func (ldi *Iterator) Next() {
-	ldi.currentValid = ldi.iterator.Scan(&ldi.currentValue, &ldi.currentKey)
-	if !bytes.HasPrefix(ldi.currentKey, ldi.startKey) {
+	if ldi.iterator == nil {
 		ldi.currentValid = false
+		return
+	}
+	if ldi.table == CounterTable {
+		var counter int64
+		ldi.currentValid = ldi.iterator.Scan(&counter, &ldi.currentKey) && ldi.inPrefix(ldi.currentKey)
+		ldi.currentValue = encodeCounter(counter)
+	} else {
+		ldi.currentValid = ldi.iterator.Scan(&ldi.currentValue, &ldi.currentKey) && ldi.inPrefix(ldi.currentKey)
 	}
 	if ldi.store.debug {
 		fmt.Println("NEXT", ldi.currentValid, string(ldi.currentKey))
@@ -73,50 +197,82 @@ func (ldi *Iterator) Valid() bool {
 }
 
 func (ldi *Iterator) Close() error {
-	return nil
+	ldi.close()
+	ldi.currentValid = false
+	return ldi.err
 }
 
 type TypedKVIterator struct {
-	store    *Store
-	iterator *gocql.Iter
-
-	startKey []byte
+	rowQuery
 
 	currentKey   []byte
 	currentValue map[string]interface{}
 	currentValid bool
 }
 
+func newTypedKVIterator(s *Store, table string, bucket, start, end, prefix []byte) *TypedKVIterator {
+	rv := &TypedKVIterator{
+		rowQuery: rowQuery{
+			store:   s,
+			columns: `key, freq, score, length, boost, vectors`,
+			table:   table,
+			bucket:  bucket,
+			start:   start,
+			end:     end,
+			prefix:  prefix,
+		},
+	}
+	rv.open(start)
+	rv.Next()
+	return rv
+}
+
+// newScoreIterator walks a postings partition by descending score
+func newScoreIterator(s *Store, bucket []byte) *TypedKVIterator {
+	rv := &TypedKVIterator{
+		rowQuery: rowQuery{
+			store:   s,
+			columns: `key, freq, score, length, boost, vectors`,
+			table:   "t",
+			mapped:  ScorePostingsTable,
+			bucket:  bucket,
+		},
+	}
+	rv.open(nil)
+	rv.Next()
+	return rv
+}
+
+func (ldi *TypedKVIterator) current() ([]byte, bool) {
+	return ldi.currentKey, ldi.currentValid
+}
+
+func (ldi *TypedKVIterator) score() float32 {
+	score, _ := ldi.currentValue["score"].(float32)
+	return score
+}
+
+// Seek is not supported in score order, the iterator is left in place
 func (ldi *TypedKVIterator) Seek(key []byte) {
 	if ldi.store.debug {
 		fmt.Println("TYPED SEEK", string(key))
 	}
-	for {
-		if ldi.store.debug && !ldi.Valid() {
-			fmt.Println("TYPED SEEK INVALID: ", string(key))
-			return
-		}
-		if ldi.store.debug {
-			fmt.Println("TYPED SEEK EQ", string(key), string(ldi.currentKey))
-		}
-		if bytes.Compare(ldi.currentKey, key) >= 0 {
-			if ldi.store.debug {
-				fmt.Println("TYPED SEEK DONE: ", string(key))
-			}
-			return
-		}
-		ldi.Next()
+	if ldi.mapped == ScorePostingsTable {
+		return
 	}
+	ldi.seek(key, ldi.current, ldi.Next)
 }
 
 func (ldi *TypedKVIterator) Next() {
+	if ldi.iterator == nil {
+		ldi.currentValid = false
+		return
+	}
 	ldi.currentValue = make(map[string]interface{})
 	ldi.currentValid = ldi.iterator.MapScan(ldi.currentValue)
 	if ldi.currentValid {
 		ldi.currentKey = ldi.currentValue["key"].([]byte)
-	}
-	if ldi.currentValid && !bytes.HasPrefix(ldi.currentKey, ldi.startKey) {
-		ldi.currentValid = false
+		ldi.currentValid = ldi.inPrefix(ldi.currentKey)
 	}
 	if ldi.store.debug {
 		fmt.Println("TYPED NEXT", ldi.currentValid, string(ldi.currentKey))
@@ -146,5 +302,167 @@ func (ldi *TypedKVIterator) Valid() bool {
 }
 
 func (ldi *TypedKVIterator) Close() error {
-	return nil
+	ldi.close()
+	ldi.currentValid = false
+	return ldi.err
+}
+
+// cursor is the part of Iterator and TypedKVIterator merge relies on
+type cursor interface {
+	current() ([]byte, bool)
+	Seek(key []byte)
+	Next()
+	Close() error
+}
+
+// merge walks the buckets of a table in key order, a key lives in a
+// single bucket so there are no duplicates to skip
+type merge struct {
+	cursors []cursor
+	pos     int
+
+	// before reports whether the row of cursor i comes before the row
+	// of cursor j, nil orders by key
+	before func(i, j int) bool
+}
+
+func (m *merge) pick() {
+	m.pos = -1
+	for i, c := range m.cursors {
+		if _, valid := c.current(); valid && (m.pos < 0 || m.less(i, m.pos)) {
+			m.pos = i
+		}
+	}
+}
+
+func (m *merge) less(i, j int) bool {
+	if m.before != nil {
+		return m.before(i, j)
+	}
+	a, _ := m.cursors[i].current()
+	b, _ := m.cursors[j].current()
+	return bytes.Compare(a, b) < 0
+}
+
+func (m *merge) Seek(key []byte) {
+	for _, c := range m.cursors {
+		c.Seek(key)
+	}
+	m.pick()
+}
+
+func (m *merge) Next() {
+	if m.pos >= 0 {
+		m.cursors[m.pos].Next()
+		m.pick()
+	}
+}
+
+func (m *merge) Valid() bool {
+	return m.pos >= 0
+}
+
+func (m *merge) Close() error {
+	var rv error
+	for _, c := range m.cursors {
+		if err := c.Close(); err != nil && rv == nil {
+			rv = err
+		}
+	}
+	m.pos = -1
+	return rv
+}
+
+type MergeIterator struct {
+	merge
+	iterators []*Iterator
+}
+
+func newMergeIterator(iterators []*Iterator) *MergeIterator {
+	rv := &MergeIterator{
+		iterators: iterators,
+	}
+	for _, it := range iterators {
+		rv.cursors = append(rv.cursors, it)
+	}
+	rv.pick()
+	return rv
+}
+
+func (mi *MergeIterator) Current() ([]byte, []byte, bool) {
+	if mi.pos < 0 {
+		return nil, nil, false
+	}
+	return mi.iterators[mi.pos].Current()
+}
+
+func (mi *MergeIterator) Key() []byte {
+	if mi.pos < 0 {
+		return nil
+	}
+	return mi.iterators[mi.pos].Key()
+}
+
+func (mi *MergeIterator) Value() []byte {
+	if mi.pos < 0 {
+		return nil
+	}
+	return mi.iterators[mi.pos].Value()
+}
+
+type TypedMergeIterator struct {
+	merge
+	iterators []*TypedKVIterator
+}
+
+func newTypedMergeIterator(iterators []*TypedKVIterator) *TypedMergeIterator {
+	rv := &TypedMergeIterator{
+		iterators: iterators,
+	}
+	for _, it := range iterators {
+		rv.cursors = append(rv.cursors, it)
+	}
+	rv.pick()
+	return rv
+}
+
+// newScoreMergeIterator walks the buckets of a term by descending
+// score, then ascending key
+func newScoreMergeIterator(iterators []*TypedKVIterator) *TypedMergeIterator {
+	rv := &TypedMergeIterator{
+		iterators: iterators,
+	}
+	rv.before = func(i, j int) bool {
+		a, b := iterators[i], iterators[j]
+		if a.score() != b.score() {
+			return a.score() > b.score()
+		}
+		return bytes.Compare(a.currentKey, b.currentKey) < 0
+	}
+	for _, it := range iterators {
+		rv.cursors = append(rv.cursors, it)
+	}
+	rv.pick()
+	return rv
+}
+
+func (mi *TypedMergeIterator) Current() ([]byte, map[string]interface{}, bool) {
+	if mi.pos < 0 {
+		return nil, nil, false
+	}
+	return mi.iterators[mi.pos].Current()
+}
+
+func (mi *TypedMergeIterator) Key() []byte {
+	if mi.pos < 0 {
+		return nil
+	}
+	return mi.iterators[mi.pos].Key()
+}
+
+func (mi *TypedMergeIterator) Value() map[string]interface{} {
+	if mi.pos < 0 {
+		return nil
+	}
+	return mi.iterators[mi.pos].Value()
 }
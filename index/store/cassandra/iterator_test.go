@@ -0,0 +1,33 @@
+package cassandra
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix []byte
+		end    []byte
+	}{
+		{nil, nil},
+		{[]byte{}, nil},
+		{[]byte("a"), []byte("b")},
+		{[]byte("ab"), []byte("ac")},
+		{[]byte{'a', 0xff}, []byte("b")},
+		{[]byte{'a', 0xfe, 0xff, 0xff}, []byte{'a', 0xff}},
+		{[]byte{0xff, 0xff}, nil},
+	}
+	for _, test := range tests {
+		end := prefixEnd(test.prefix)
+		if !bytes.Equal(end, test.end) || (end == nil) != (test.end == nil) {
+			t.Errorf("prefix %x: expected end %x, got %x", test.prefix, test.end, end)
+		}
+	}
+
+	prefix := []byte("abc")
+	prefixEnd(prefix)
+	if string(prefix) != "abc" {
+		t.Errorf("prefixEnd modified its argument: %q", prefix)
+	}
+}
@@ -0,0 +1,212 @@
+package cassandra
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/gocql/gocql"
+)
+
+// legacyCopyRows is the number of rows copied per batch execution
+const legacyCopyRows = 1000
+
+// LegacyRowsKey is the key of the version table (v) under which an
+// index records that its rows written before schema version 2 were
+// copied, the schema migrations are shared by every index of the
+// keyspace but the rows are not
+var LegacyRowsKey = []byte("cassandra_legacy_rows")
+
+// MigrateLegacyRows copies the rows of this index written before
+// schema version 2, when every table lived in a single partition of t
+// or idx, into the bucketed tables, then indexes the postings written
+// before schema version 3 by score.  The score of those postings was
+// the field norm, it is replaced by the frequency best first term
+// searches expect.  Rows are upserted so an
+// interrupted copy can be run again, the legacy rows are left in place.
+// Counters in d did not move and are not touched.  progress, if not
+// nil, is called with the running row count of each table.  New runs
+// it once per index, see copyLegacyRowsOnce.
+func (cdbs *Store) MigrateLegacyRows(progress func(table string, rows int)) error {
+	for _, table := range Tables {
+		if table == "d" {
+			continue
+		}
+		err := cdbs.copyLegacyTable(table, progress)
+		if err != nil {
+			return err
+		}
+	}
+	err := cdbs.copyScorePostings(progress)
+	if err != nil {
+		return err
+	}
+	return cdbs.markLegacyRowsCopied()
+}
+
+// copyLegacyRowsOnce copies the legacy rows of this index the first
+// time it is opened by this version, otherwise it would read the empty
+// bucketed tables.  An index without legacy rows is only marked.
+func (cdbs *Store) copyLegacyRowsOnce() error {
+	var value []byte
+	err := cdbs.read(`SELECT value FROM `+TableMapping("v")+` WHERE type = ? AND bucket = ? AND key = ?`, cdbs.partition("v"), cdbs.bucket("v", LegacyRowsKey), LegacyRowsKey).Scan(&value)
+	if err == nil {
+		return nil
+	}
+	if err != gocql.ErrNotFound {
+		return WrapError(err, "read legacy rows marker")
+	}
+	legacy, err := cdbs.hasLegacyRows()
+	if err != nil {
+		return err
+	}
+	if legacy {
+		return cdbs.MigrateLegacyRows(nil)
+	}
+	return cdbs.markLegacyRowsCopied()
+}
+
+// hasLegacyRows reports whether this index has back index or term
+// frequency rows in the tables used before schema version 2
+func (cdbs *Store) hasLegacyRows() (bool, error) {
+	for _, table := range []string{"b", "t"} {
+		var key []byte
+		err := cdbs.read(`SELECT key FROM `+legacyTableMapping(table)+` WHERE type = ? LIMIT 1`, cdbs.partition(table)).Scan(&key)
+		if err == gocql.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			if isUnconfiguredTable(err) {
+				return false, nil
+			}
+			return false, WrapError(err, "read legacy table "+table)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (cdbs *Store) markLegacyRowsCopied() error {
+	value := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(value, uint64(SchemaVersion))
+	return WrapError(cdbs.write(`INSERT INTO `+TableMapping("v")+` (type, bucket, key, value) VALUES (?, ?, ?, ?)`, cdbs.partition("v"), cdbs.bucket("v", LegacyRowsKey), LegacyRowsKey, value[:n]).Exec(), "write legacy rows marker")
+}
+
+func (cdbs *Store) copyLegacyTable(table string, progress func(table string, rows int)) error {
+	columns := `key, value`
+	if table == "t" {
+		columns = `key, freq, score, vectors`
+	}
+	iter := cdbs.read(`SELECT `+columns+` FROM `+legacyTableMapping(table)+` WHERE type = ?`, cdbs.partition(table)).PageSize(cdbs.pageSize).Iter()
+
+	mapped := TableMapping(table)
+	batch := newBatch(cdbs)
+	count := 0
+	for {
+		row := make(map[string]interface{})
+		if !iter.MapScan(row) {
+			break
+		}
+		key, _ := row["key"].([]byte)
+		if table == "v" && bytes.Equal(key, SchemaVersionKey) {
+			continue
+		}
+		bucket := cdbs.bucket(table, key)
+		if table == "t" {
+			vectors, _ := row["vectors"].([]byte)
+			freq, _ := row["freq"].(float32)
+			// the legacy table predates field lengths and boosts, its
+			// score was the field norm
+			batch.setPosting(bucket, key, freq, freq, 0, 0, vectors, 0)
+		} else {
+			value, _ := row["value"].([]byte)
+			batch.add(mapped, table, bucket, false, &statement{
+				key:  key,
+				cql:  `INSERT INTO ` + mapped + ` (type, bucket, key, value) VALUES (?, ?, ?, ?)`,
+				args: []interface{}{cdbs.partition(table), bucket, key, value},
+				size: len(value),
+			})
+		}
+		count++
+		if count%legacyCopyRows == 0 {
+			if err := batch.Execute(); err != nil {
+				_ = iter.Close()
+				return err
+			}
+			if progress != nil {
+				progress(table, count)
+			}
+		}
+	}
+	if err := iter.Close(); err != nil {
+		if isUnconfiguredTable(err) {
+			// nothing was ever written with the legacy layout
+			return nil
+		}
+		return WrapError(err, "read legacy table "+table)
+	}
+	if err := batch.Execute(); err != nil {
+		return err
+	}
+	if progress != nil {
+		progress(table, count)
+	}
+	return nil
+}
+
+// copyScorePostings rewrites every posting of this index whose score
+// is not its frequency, which also moves it in ScorePostingsTable, the
+// postings partitions are found through the dictionary
+func (cdbs *Store) copyScorePostings(progress func(table string, rows int)) error {
+	table := ScorePostingsTable
+	iter := cdbs.read(`SELECT key FROM d WHERE type = ?`, cdbs.partition("d")).PageSize(cdbs.pageSize).Iter()
+	batch := newBatch(cdbs)
+	count := 0
+	var term []byte
+	for iter.Scan(&term) {
+		buckets, err := cdbs.scanBuckets("t", append(term, keySeparator))
+		if err != nil {
+			_ = iter.Close()
+			return err
+		}
+		for _, bucket := range buckets {
+			postings := newTypedKVIterator(cdbs, "t", bucket, nil, nil, nil)
+			for ; postings.Valid(); postings.Next() {
+				row := postings.Value()
+				freq, _ := row["freq"].(float32)
+				if score, _ := row["score"].(float32); score == freq {
+					continue
+				}
+				key := append([]byte(nil), postings.Key()...)
+				vectors, _ := row["vectors"].([]byte)
+				length, _ := row["length"].(int)
+				boost, _ := row["boost"].(float32)
+				batch.setPosting(bucket, key, freq, freq, length, boost, vectors, 0)
+				count++
+				if count%legacyCopyRows == 0 {
+					if err = batch.Execute(); err != nil {
+						_ = postings.Close()
+						_ = iter.Close()
+						return err
+					}
+					if progress != nil {
+						progress(table, count)
+					}
+				}
+			}
+			if err = postings.Close(); err != nil {
+				_ = iter.Close()
+				return WrapError(err, "read postings")
+			}
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return WrapError(err, "read table d")
+	}
+	if err := batch.Execute(); err != nil {
+		return err
+	}
+	if progress != nil {
+		progress(table, count)
+	}
+	return nil
+}
@@ -5,7 +5,6 @@ import (
 
 	"encoding/hex"
 
-	"github.com/gocql/gocql"
 	"github.com/wrble/flock/index/store"
 )
 
@@ -18,7 +17,14 @@ func (r *Reader) Get(table string, key []byte) ([]byte, error) {
 	if r.store.debug {
 		fmt.Println("GET: "+table, key)
 	}
-	err := r.store.Session.Query(`SELECT value FROM `+TableMapping(table)+` WHERE type = ? AND key = ?`, table, key).Scan(&value)
+	if table == CounterTable {
+		counter, err := r.GetCounter(table, key)
+		if err != nil || counter < 0 {
+			return nil, err
+		}
+		return encodeCounter(counter), nil
+	}
+	err := r.store.read(`SELECT value FROM `+TableMapping(table)+` WHERE type = ? AND bucket = ? AND key = ?`, r.store.partition(table), r.store.bucket(table, key), key).Scan(&value)
 	if err != nil {
 		if err.Error() == "not found" {
 			return nil, nil
@@ -36,7 +42,7 @@ func (r *Reader) GetCounter(table string, key []byte) (int64, error) {
 	if r.store.debug {
 		fmt.Println("GET COUNTER: "+table, key)
 	}
-	err := r.store.Session.Query(`SELECT value FROM d WHERE type = ? AND key = ?`, table, key).Scan(&value)
+	err := r.store.read(`SELECT value FROM d WHERE type = ? AND key = ?`, r.store.partition(table), key).Scan(&value)
 	if err != nil {
 		if r.store.debug {
 			fmt.Println("GET COUNTER ERROR: " + err.Error())
@@ -52,10 +58,22 @@ func (r *Reader) GetCounter(table string, key []byte) (int64, error) {
 	return value, err
 }
 
+// DocCount counts the back index rows page by page, a single count(*)
+// over a large partition times out.  The index keeps its own counter,
+// this exact count is only needed to seed or reconcile it.
 func (r *Reader) DocCount() (uint64, error) {
-	c := uint64(0)
-	err := r.store.Session.Query(`SELECT count(*) FROM `+SharedTable+` WHERE type = ?`, "b").Scan(&c)
-	return c, err
+	rv := uint64(0)
+	for _, bucket := range r.store.allBuckets() {
+		iter := r.store.read(`SELECT key FROM `+TableMapping("b")+` WHERE type = ? AND bucket = ?`, r.store.partition("b"), bucket).PageSize(r.store.pageSize).Iter()
+		var key []byte
+		for iter.Scan(&key) {
+			rv++
+		}
+		if err := iter.Close(); err != nil {
+			return 0, WrapError(err, "count back index rows")
+		}
+	}
+	return rv, nil
 }
 
 func (r *Reader) MultiGet(table string, keys [][]byte) ([][]byte, error) {
@@ -66,56 +84,78 @@ func (r *Reader) MultiGet(table string, keys [][]byte) ([][]byte, error) {
 }
 
 func (r *Reader) PrefixIterator(table string, prefix []byte) store.KVIterator {
-	var iter *gocql.Iter
 	if r.store.debug {
 		fmt.Println("PrefixIterator", TableMapping(table), table, string(prefix))
 	}
-	iter = r.store.Session.Query(`SELECT value, key FROM `+TableMapping(table)+` WHERE type = ? AND key >= ?`, table, prefix).Iter()
-	rv := &Iterator{
-		store:    r.store,
-		iterator: iter,
-		startKey: prefix,
-	}
-	rv.Next()
-	return rv
+	buckets, err := r.store.scanBuckets(table, prefix)
+	return r.iterator(table, buckets, err, prefix, prefixEnd(prefix), prefix)
 }
 
 func (r *Reader) TypedPrefixIterator(table string, prefix []byte) store.TypedKVIterator {
-	var iter *gocql.Iter
-	mapped := TableMapping(table)
 	if r.store.debug {
-		fmt.Println("TypedPrefixIterator", mapped, table, string(prefix), hex.EncodeToString(prefix))
+		fmt.Println("TypedPrefixIterator", TableMapping(table), table, string(prefix), hex.EncodeToString(prefix))
 	}
-	iter = r.store.Session.Query(`SELECT key, freq, score, vectors FROM `+mapped+` WHERE type = ? AND key >= ?`, table, prefix).Iter()
-	rv := &TypedKVIterator{
-		store:        r.store,
-		iterator:     iter,
-		startKey:     prefix,
-		currentValue: make(map[string]interface{}),
+	buckets, err := r.store.scanBuckets(table, prefix)
+	if err != nil {
+		return &TypedKVIterator{rowQuery: rowQuery{store: r.store, err: err}}
 	}
-	rv.Next()
-	return rv
+	if len(buckets) == 1 {
+		return newTypedKVIterator(r.store, table, buckets[0], prefix, prefixEnd(prefix), prefix)
+	}
+	iterators := make([]*TypedKVIterator, len(buckets))
+	for i, bucket := range buckets {
+		iterators[i] = newTypedKVIterator(r.store, table, bucket, prefix, prefixEnd(prefix), prefix)
+	}
+	return newTypedMergeIterator(iterators)
+}
+
+// ScoreOrderedIterator walks the postings of a term by descending
+// score, prefix must cover a complete field and term
+func (r *Reader) ScoreOrderedIterator(table string, prefix []byte) store.TypedKVIterator {
+	if r.store.debug {
+		fmt.Println("ScoreOrderedIterator", table, string(prefix))
+	}
+	if table != "t" {
+		return &TypedKVIterator{rowQuery: rowQuery{store: r.store, err: fmt.Errorf("table %s is not ordered by score", table)}}
+	}
+	buckets, err := r.store.scanBuckets(table, prefix)
+	if err != nil {
+		return &TypedKVIterator{rowQuery: rowQuery{store: r.store, err: err}}
+	}
+	if len(buckets) == 1 {
+		return newScoreIterator(r.store, buckets[0])
+	}
+	iterators := make([]*TypedKVIterator, len(buckets))
+	for i, bucket := range buckets {
+		iterators[i] = newScoreIterator(r.store, bucket)
+	}
+	return newScoreMergeIterator(iterators)
 }
 
 func (r *Reader) RangeIterator(table string, start, end []byte) store.KVIterator {
 	if r.store.debug {
 		fmt.Println("RangeIterator", table, string(start), string(end))
 	}
-	var iter *gocql.Iter
-	tableName := TableMapping(table)
-	if len(start) == 0 && len(end) == 0 {
-		iter = r.store.Session.Query(`SELECT value, key FROM `+tableName+` WHERE type = ?`, table).Iter()
-	} else if end != nil && len(end) > 0 {
-		iter = r.store.Session.Query(`SELECT value, key FROM `+tableName+` WHERE type = ? AND key >= ? AND key < ?`, table, start, end).Iter()
-	} else {
-		iter = r.store.Session.Query(`SELECT value, key FROM `+tableName+` WHERE type = ? AND key >= ?`, table, start).Iter()
-	}
-	rv := Iterator{
-		store:    r.store,
-		iterator: iter,
-	}
-	rv.Next()
-	return &rv
+	if len(end) == 0 {
+		end = nil
+	}
+	buckets, err := r.store.rangeBuckets(table, start)
+	return r.iterator(table, buckets, err, start, end, nil)
+}
+
+// iterator opens one iterator per bucket, merged when there are more
+func (r *Reader) iterator(table string, buckets [][]byte, err error, start, end, prefix []byte) store.KVIterator {
+	if err != nil {
+		return &Iterator{rowQuery: rowQuery{store: r.store, err: err}}
+	}
+	if len(buckets) == 1 {
+		return newIterator(r.store, table, buckets[0], start, end, prefix)
+	}
+	iterators := make([]*Iterator, len(buckets))
+	for i, bucket := range buckets {
+		iterators[i] = newIterator(r.store, table, bucket, start, end, prefix)
+	}
+	return newMergeIterator(iterators)
 }
 
 func (r *Reader) Close() error {
@@ -0,0 +1,204 @@
+package cassandra
+
+import (
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+	"github.com/wrble/flock/index/store"
+)
+
+// SchemaVersion is the table layout this code reads and writes, it is
+// the version of the last entry in migrations
+const SchemaVersion = 5
+
+// SchemaVersionKey is the key of the version table (v) under which
+// the schema version is recorded
+var SchemaVersionKey = []byte("cassandra_schema")
+
+// DefaultReplication is used when creating a keyspace without a
+// "replication" setting in the config
+var DefaultReplication = map[string]interface{}{
+	"class":              "SimpleStrategy",
+	"replication_factor": 1,
+}
+
+type migration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+// migrations are applied in order, every statement must be idempotent
+// as a migration interrupted half way is run again on the next open,
+// adding a column which already exists is ignored
+var migrations = []migration{
+	{
+		version:     1,
+		description: "counter, term frequency and shared tables",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS d (type text, key blob, value counter, PRIMARY KEY(type, key))`,
+			`CREATE TABLE IF NOT EXISTS t (type text, key blob, freq float, score float, vectors blob, PRIMARY KEY((type), key))`,
+			`CREATE TABLE IF NOT EXISTS ` + SharedTable + ` (type text, key blob, value blob, PRIMARY KEY(type, key))`,
+		},
+	},
+	{
+		// rows written before are copied by New when an index is
+		// first opened, see Store.MigrateLegacyRows
+		version:     2,
+		description: "postings and rows partitioned by bucket",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS ` + PostingsTable + ` (type text, bucket blob, key blob, freq float, score float, vectors blob, PRIMARY KEY((type, bucket), key))`,
+			`CREATE TABLE IF NOT EXISTS ` + RowsTable + ` (type text, bucket blob, key blob, value blob, PRIMARY KEY((type, bucket), key))`,
+		},
+	},
+	{
+		// postings written before are indexed with Store.MigrateLegacyRows
+		version:     3,
+		description: "postings clustered by score",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS ` + ScorePostingsTable + ` (type text, bucket blob, score float, key blob, freq float, vectors blob, PRIMARY KEY((type, bucket), score, key)) WITH CLUSTERING ORDER BY (score DESC, key ASC)`,
+		},
+	},
+	{
+		// postings written before have no length, it reads as 0
+		version:     4,
+		description: "field length of postings",
+		statements: []string{
+			`ALTER TABLE ` + PostingsTable + ` ADD length int`,
+			`ALTER TABLE ` + ScorePostingsTable + ` ADD length int`,
+		},
+	},
+	{
+		// postings written before have no boost, it reads as 0 which
+		// is no boost
+		version:     5,
+		description: "index time boost of postings",
+		statements: []string{
+			`ALTER TABLE ` + PostingsTable + ` ADD boost float`,
+			`ALTER TABLE ` + ScorePostingsTable + ` ADD boost float`,
+		},
+	},
+}
+
+var keyspaceName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,47}$`)
+
+// CreateKeyspace creates the keyspace of the cluster config if it does
+// not exist yet, using the given replication options
+func CreateKeyspace(cluster *gocql.ClusterConfig, replication map[string]interface{}) error {
+	if !keyspaceName.MatchString(cluster.Keyspace) {
+		return fmt.Errorf("invalid keyspace name: %q", cluster.Keyspace)
+	}
+	if len(replication) == 0 {
+		replication = DefaultReplication
+	}
+	options, err := replicationOptions(replication)
+	if err != nil {
+		return err
+	}
+
+	// the keyspace may not exist yet, so connect without it
+	system := *cluster
+	system.Keyspace = ""
+	session, err := system.CreateSession()
+	if err != nil {
+		return WrapError(err, "create keyspace")
+	}
+	defer session.Close()
+	return WrapError(session.Query(`CREATE KEYSPACE IF NOT EXISTS `+cluster.Keyspace+` WITH replication = `+options).Exec(), "create keyspace")
+}
+
+// replicationOptions renders the replication options as a CQL map
+func replicationOptions(replication map[string]interface{}) (string, error) {
+	keys := make([]string, 0, len(replication))
+	for k := range replication {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		var v string
+		switch value := replication[k].(type) {
+		case string:
+			v = value
+		case int:
+			v = strconv.Itoa(value)
+		case float64:
+			v = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			return "", fmt.Errorf("invalid replication option '%s': %v", k, value)
+		}
+		pairs = append(pairs, cqlString(k)+": "+cqlString(v))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}", nil
+}
+
+func cqlString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// ReadSchemaVersion returns the recorded schema version, 0 when the
+// tables predate versioning or do not exist yet
+func ReadSchemaVersion(session *gocql.Session) (int, error) {
+	var value []byte
+	err := session.Query(`SELECT value FROM `+SharedTable+` WHERE type = ? AND key = ?`, "v", SchemaVersionKey).Scan(&value)
+	if err == gocql.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		if isUnconfiguredTable(err) {
+			return 0, nil
+		}
+		return 0, WrapError(err, "read schema version")
+	}
+	version, n := binary.Uvarint(value)
+	if n <= 0 {
+		return 0, errors.New("read schema version: invalid value")
+	}
+	return int(version), nil
+}
+
+func writeSchemaVersion(session *gocql.Session, version int) error {
+	value := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(value, uint64(version))
+	return WrapError(session.Query(`INSERT INTO `+SharedTable+` (type, key, value) VALUES (?, ?, ?)`, "v", SchemaVersionKey, value[:n]).Exec(), "write schema version")
+}
+
+// Migrate brings the tables of the session keyspace up to
+// SchemaVersion, creating them when missing.  It refuses to touch a
+// schema written by a newer version.
+func Migrate(session *gocql.Session) error {
+	version, err := ReadSchemaVersion(session)
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return &store.SchemaVersionError{
+			Store:     Name,
+			Stored:    version,
+			Supported: SchemaVersion,
+		}
+	}
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		for _, stmt := range m.statements {
+			err = session.Query(stmt).Exec()
+			if err != nil && !isExistingColumn(err) {
+				return WrapError(err, fmt.Sprintf("schema migration %d (%s)", m.version, m.description))
+			}
+		}
+		err = writeSchemaVersion(session, m.version)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package cassandra
+
+import "testing"
+
+func TestMigrationsOrdered(t *testing.T) {
+	last := 0
+	for _, m := range migrations {
+		if m.version != last+1 {
+			t.Errorf("expected migration %d, got %d", last+1, m.version)
+		}
+		last = m.version
+	}
+	if last != SchemaVersion {
+		t.Errorf("expected last migration to be SchemaVersion %d, got %d", SchemaVersion, last)
+	}
+}
+
+func TestReplicationOptions(t *testing.T) {
+	options, err := replicationOptions(DefaultReplication)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{'class': 'SimpleStrategy', 'replication_factor': '1'}`
+	if options != expected {
+		t.Errorf("expected %s, got %s", expected, options)
+	}
+
+	// as read back from index_meta.json
+	options, err = replicationOptions(map[string]interface{}{
+		"class": "NetworkTopologyStrategy",
+		"dc1":   float64(3),
+		"dc'2":  float64(2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `{'class': 'NetworkTopologyStrategy', 'dc''2': '2', 'dc1': '3'}`
+	if options != expected {
+		t.Errorf("expected %s, got %s", expected, options)
+	}
+
+	_, err = replicationOptions(map[string]interface{}{"class": true})
+	if err == nil {
+		t.Errorf("expected error for invalid option")
+	}
+}
@@ -0,0 +1,134 @@
+package cassandra
+
+import (
+	"bytes"
+	"hash/fnv"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultBuckets is the number of partitions the back index (b) and
+// stored (s) rows of an index are hashed over
+const DefaultBuckets = 16
+
+// DefaultTermBuckets is the number of partitions the postings of each
+// term are spread over, raise it for corpora with very frequent terms
+const DefaultTermBuckets = 1
+
+// keySeparator ends the term of term frequency keys and the document
+// id of stored keys
+const keySeparator = 0xff
+
+var errIncompleteTerm = errors.New("term frequency scans must cover a complete field and term")
+
+func bucketID(n int) []byte {
+	return []byte{byte(n >> 8), byte(n)}
+}
+
+func hashBucket(data []byte, buckets int) []byte {
+	h := fnv.New32a()
+	_, _ = h.Write(data)
+	return bucketID(int(h.Sum32() % uint32(buckets)))
+}
+
+// termPartition returns the field, term and separator prefix of a term
+// frequency key, ok is false if the key does not contain a whole term
+func termPartition(key []byte) (term []byte, ok bool) {
+	if len(key) < 3 {
+		return nil, false
+	}
+	i := bytes.IndexByte(key[2:], keySeparator)
+	if i < 0 {
+		return nil, false
+	}
+	return key[:2+i+1], true
+}
+
+// docPartition returns the document id of a stored key
+func docPartition(key []byte) (doc []byte, ok bool) {
+	i := bytes.IndexByte(key, keySeparator)
+	if i < 0 {
+		return nil, false
+	}
+	return key[:i], true
+}
+
+// bucket returns the bucket, the second part of the partition key,
+// a row is written to.  Postings are partitioned by field and term,
+// optionally split further by document, the back index rows, nested
+// ones included, and stored rows are hashed by document, and the small
+// tables use a single bucket.  CounterTable has no bucket column.
+func (cdbs *Store) bucket(table string, key []byte) []byte {
+	switch table {
+	case CounterTable:
+		return nil
+	case "t":
+		term, ok := termPartition(key)
+		if !ok {
+			return bucketID(0)
+		}
+		rv := append([]byte(nil), term...)
+		if cdbs.termBuckets > 1 {
+			rv = append(rv, hashBucket(key[len(term):], cdbs.termBuckets)...)
+		}
+		return rv
+	case "b", "n":
+		return hashBucket(key, cdbs.buckets)
+	case "s":
+		doc, ok := docPartition(key)
+		if !ok {
+			doc = key
+		}
+		return hashBucket(doc, cdbs.buckets)
+	}
+	return bucketID(0)
+}
+
+// scanBuckets returns every bucket that may hold keys with the prefix
+func (cdbs *Store) scanBuckets(table string, prefix []byte) ([][]byte, error) {
+	switch table {
+	case CounterTable:
+		return [][]byte{nil}, nil
+	case "t":
+		term, ok := termPartition(prefix)
+		if !ok {
+			return nil, errIncompleteTerm
+		}
+		if cdbs.termBuckets <= 1 {
+			return [][]byte{term}, nil
+		}
+		rv := make([][]byte, cdbs.termBuckets)
+		for i := range rv {
+			rv[i] = append(append([]byte(nil), term...), bucketID(i)...)
+		}
+		return rv, nil
+	case "s":
+		if doc, ok := docPartition(prefix); ok {
+			return [][]byte{hashBucket(doc, cdbs.buckets)}, nil
+		}
+		return cdbs.allBuckets(), nil
+	case "b", "n":
+		return cdbs.allBuckets(), nil
+	}
+	return [][]byte{bucketID(0)}, nil
+}
+
+// rangeBuckets returns every bucket that may hold keys >= start, a
+// term frequency range must not extend past the term of start
+func (cdbs *Store) rangeBuckets(table string, start []byte) ([][]byte, error) {
+	switch table {
+	case CounterTable, "t":
+		return cdbs.scanBuckets(table, start)
+	case "b", "n", "s":
+		return cdbs.allBuckets(), nil
+	}
+	return [][]byte{bucketID(0)}, nil
+}
+
+func (cdbs *Store) allBuckets() [][]byte {
+	rv := make([][]byte, cdbs.buckets)
+	for i := range rv {
+		rv[i] = bucketID(i)
+	}
+	return rv
+}
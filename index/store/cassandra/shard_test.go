@@ -0,0 +1,167 @@
+package cassandra
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/wrble/flock/index/rows"
+)
+
+func TestShardTermBuckets(t *testing.T) {
+	s := &Store{buckets: DefaultBuckets, termBuckets: 1}
+	term := rows.NewTermFrequencyRow([]byte("beer"), 1, []byte("doc1"), 1, 0).Key()
+	other := rows.NewTermFrequencyRow([]byte("beer"), 1, []byte("doc2"), 1, 0).Key()
+	prefix := term[:len(term)-len("doc1")]
+
+	if !bytes.Equal(s.bucket("t", term), prefix) || !bytes.Equal(s.bucket("t", other), prefix) {
+		t.Errorf("expected postings of a term to share the partition %x", prefix)
+	}
+	buckets, err := s.scanBuckets("t", prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 1 || !bytes.Equal(buckets[0], prefix) {
+		t.Errorf("expected a single bucket %x, got %x", prefix, buckets)
+	}
+
+	_, err = s.scanBuckets("t", prefix[:2])
+	if err != errIncompleteTerm {
+		t.Errorf("expected errIncompleteTerm for a field only prefix, got %v", err)
+	}
+
+	s.termBuckets = 4
+	buckets, err = s.scanBuckets("t", prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 4 {
+		t.Fatalf("expected 4 sub-buckets, got %d", len(buckets))
+	}
+	bucket := s.bucket("t", term)
+	found := false
+	for _, b := range buckets {
+		if !bytes.HasPrefix(b, prefix) {
+			t.Errorf("expected sub-bucket %x to start with the term", b)
+		}
+		found = found || bytes.Equal(b, bucket)
+	}
+	if !found {
+		t.Errorf("expected bucket %x of the row to be scanned", bucket)
+	}
+}
+
+func TestShardDocBuckets(t *testing.T) {
+	s := &Store{buckets: DefaultBuckets, termBuckets: 1}
+	storedKey := append([]byte("doc1"), keySeparator, 1, 0)
+	if !bytes.Equal(s.bucket("s", storedKey), s.bucket("b", []byte("doc1"))) {
+		t.Errorf("expected stored rows to be bucketed by document")
+	}
+	buckets, err := s.scanBuckets("s", []byte{'d', 'o', 'c', '1', keySeparator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 1 || !bytes.Equal(buckets[0], s.bucket("s", storedKey)) {
+		t.Errorf("expected the stored row scan of a document to hit its bucket, got %x", buckets)
+	}
+	buckets, err = s.rangeBuckets("b", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != DefaultBuckets {
+		t.Errorf("expected back index ranges to scan %d buckets, got %d", DefaultBuckets, len(buckets))
+	}
+	buckets, err = s.scanBuckets("f", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 1 || !bytes.Equal(buckets[0], s.bucket("f", []byte("field"))) {
+		t.Errorf("expected a single bucket for small tables, got %x", buckets)
+	}
+}
+
+func TestShardCounterBuckets(t *testing.T) {
+	s := &Store{buckets: DefaultBuckets, termBuckets: 4}
+	if s.bucket(CounterTable, []byte("key")) != nil {
+		t.Errorf("expected no bucket for the counter table")
+	}
+	for _, start := range [][]byte{nil, []byte("key")} {
+		buckets, err := s.rangeBuckets(CounterTable, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(buckets) != 1 || buckets[0] != nil {
+			t.Errorf("expected a single unbucketed scan of the counter table, got %x", buckets)
+		}
+	}
+}
+
+type sliceCursor struct {
+	keys [][]byte
+	pos  int
+}
+
+func (c *sliceCursor) current() ([]byte, bool) {
+	if c.pos < len(c.keys) {
+		return c.keys[c.pos], true
+	}
+	return nil, false
+}
+
+func (c *sliceCursor) Seek(key []byte) {
+	for c.pos = 0; c.pos < len(c.keys) && bytes.Compare(c.keys[c.pos], key) < 0; c.pos++ {
+	}
+}
+
+func (c *sliceCursor) Next() {
+	c.pos++
+}
+
+func (c *sliceCursor) Close() error {
+	return nil
+}
+
+func TestMergeKeyOrder(t *testing.T) {
+	m := &merge{cursors: []cursor{
+		&sliceCursor{keys: [][]byte{[]byte("a"), []byte("d"), []byte("e")}},
+		&sliceCursor{},
+		&sliceCursor{keys: [][]byte{[]byte("b"), []byte("c"), []byte("f")}},
+	}}
+	m.pick()
+
+	var got []string
+	for m.Valid() {
+		key, _ := m.cursors[m.pos].current()
+		got = append(got, string(key))
+		m.Next()
+	}
+	if len(got) != 6 || got[0] != "a" || got[2] != "c" || got[5] != "f" {
+		t.Errorf("expected keys a to f in order, got %v", got)
+	}
+
+	m.Seek([]byte("cc"))
+	key, _ := m.cursors[m.pos].current()
+	if string(key) != "d" {
+		t.Errorf("expected seek to land on d, got %s", key)
+	}
+}
+
+func TestScoreMergeOrder(t *testing.T) {
+	row := func(key string, score float32) *TypedKVIterator {
+		return &TypedKVIterator{
+			rowQuery:     rowQuery{store: &Store{}},
+			currentKey:   []byte(key),
+			currentValue: map[string]interface{}{"key": []byte(key), "score": score},
+			currentValid: true,
+		}
+	}
+	mi := newScoreMergeIterator([]*TypedKVIterator{row("a", 0.5), row("b", 0.9), row("c", 0.5)})
+
+	var got []string
+	for mi.Valid() {
+		got = append(got, string(mi.Key()))
+		mi.Next()
+	}
+	if len(got) != 3 || got[0] != "b" || got[1] != "a" || got[2] != "c" {
+		t.Errorf("expected b, a, c, got %v", got)
+	}
+}
@@ -19,13 +19,33 @@ var Tables = []string{
 	"d",
 	"i",
 	"f",
+	"n",
 	"s",
 	"t",
 	"v",
+	"x",
 }
 
+// CounterTable holds the counters maintained through KVBatch.Increment,
+// the dictionary among them.  Its rows are neither bucketed nor blobs.
+const CounterTable = "d"
+
+// SharedTable held every table but d and t before schema version 2,
+// it still holds the schema version
 const SharedTable = "idx"
 
+// PostingsTable holds the term frequency rows (t), partitioned by
+// field and term
+const PostingsTable = "postings"
+
+// ScorePostingsTable holds a copy of the term frequency rows (t) in
+// the partitions of PostingsTable, clustered by descending score
+const ScorePostingsTable = "postings_by_score"
+
+// RowsTable holds the remaining tables but d, partitioned by hashed
+// buckets where the table is large
+const RowsTable = "kv"
+
 func init() {
 	registry.RegisterKVStore(Name, New)
 }
@@ -37,6 +57,18 @@ type Store struct {
 
 	cluster *gocql.ClusterConfig
 
+	batchSizeBytes int
+	batchWorkers   int
+	pageSize       int
+	seekScanRows   int
+
+	readConsistency  gocql.Consistency
+	writeConsistency gocql.Consistency
+
+	namespace   string
+	buckets     int
+	termBuckets int
+
 	debug bool
 }
 
@@ -47,62 +79,116 @@ func New(mo store.MergeOperator, config map[string]interface{}) (store.KVStore,
 	if err := validate("keyspace", config); err != nil {
 		return nil, err
 	}
-	hosts, ok := config["hosts"].([]string)
-	if !ok {
-		interfaceHosts, ok := config["hosts"].([]interface{})
-		if ok {
-			hosts = []string{}
-			for _, h := range interfaceHosts {
-				hosts = append(hosts, h.(string))
-			}
-		} else {
-			return nil, errors.New("Must specify 'hosts' param as []string")
+	batchSizeBytes, err := configInt(config, "batch_size_bytes", DefaultBatchSizeBytes)
+	if err != nil {
+		return nil, err
+	}
+	batchWorkers, err := configInt(config, "batch_workers", DefaultBatchWorkers)
+	if err != nil {
+		return nil, err
+	}
+	if batchWorkers < 1 {
+		batchWorkers = 1
+	}
+	pageSize, err := configInt(config, "page_size", DefaultPageSize)
+	if err != nil {
+		return nil, err
+	}
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	seekScanRows, err := configInt(config, "seek_scan_rows", DefaultSeekScanRows)
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := configString(config, "namespace", "")
+	if err != nil {
+		return nil, err
+	}
+	buckets, err := configInt(config, "buckets", DefaultBuckets)
+	if err != nil {
+		return nil, err
+	}
+	termBuckets, err := configInt(config, "term_buckets", DefaultTermBuckets)
+	if err != nil {
+		return nil, err
+	}
+	if buckets < 1 || buckets > 1<<16 || termBuckets < 1 || termBuckets > 1<<16 {
+		return nil, errors.New("'buckets' and 'term_buckets' must be between 1 and 65536")
+	}
+	consistency, err := configConsistency(config, "consistency", DefaultConsistency)
+	if err != nil {
+		return nil, err
+	}
+	readConsistency, err := configConsistency(config, "read_consistency", consistency)
+	if err != nil {
+		return nil, err
+	}
+	writeConsistency, err := configConsistency(config, "write_consistency", consistency)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := newClusterConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	cluster.Consistency = readConsistency
+	createIfMissing, err := configBool(config, "create_if_missing", false)
+	if err != nil {
+		return nil, err
+	}
+	if createIfMissing {
+		replication, _ := config["replication"].(map[string]interface{})
+		err = CreateKeyspace(cluster, replication)
+		if err != nil {
+			return nil, err
 		}
 	}
-	cluster := gocql.NewCluster(hosts...)
-	cluster.Keyspace = config["keyspace"].(string)
-	cluster.Consistency = gocql.Quorum
 	session, err := cluster.CreateSession()
 	if err != nil {
 		return nil, err
 	}
-
-	st := Store{
-		mo:      mo,
-		cluster: cluster,
-		Session: session,
-		debug:   false,
+	err = Migrate(session)
+	if err != nil {
+		session.Close()
+		return nil, err
 	}
-	return &st, nil
-}
 
-func CreateTables(session *gocql.Session) error {
-	var err error
-	err = session.Query(`CREATE TABLE d (type text, key blob, value counter, PRIMARY KEY(type, key))`).Exec()
-	if err != nil {
-		return err
+	st := &Store{
+		mo:             mo,
+		cluster:        cluster,
+		Session:        session,
+		batchSizeBytes: batchSizeBytes,
+		batchWorkers:   batchWorkers,
+		pageSize:       pageSize,
+		seekScanRows:   seekScanRows,
+		debug:          false,
+
+		readConsistency:  readConsistency,
+		writeConsistency: writeConsistency,
+
+		namespace:   namespace,
+		buckets:     buckets,
+		termBuckets: termBuckets,
 	}
-	err = session.Query(`CREATE TABLE t (type text, key blob, freq float, score float, vectors blob, PRIMARY KEY((type), key));`).Exec()
-	//err = session.Query(`CREATE TABLE t (type text, key blob, freq float, score float, vectors blob, PRIMARY KEY((type), score, key)) WITH CLUSTERING ORDER BY (score DESC);`).Exec()
+	err = st.copyLegacyRowsOnce()
 	if err != nil {
-		return err
+		session.Close()
+		return nil, err
 	}
-	//err = session.Query(`CREATE INDEX t_score ON t (score);`).Exec()
-	//if err != nil {
-	//	return err
-	//}
-	return WrapError(session.Query(`CREATE TABLE `+SharedTable+` (type text, key blob, value blob, PRIMARY KEY(type, key))`).Exec(), "Create Tables")
+	return st, nil
+}
 
-	//for _, tableName := range Tables {
-	//	err := session.Query(`CREATE TABLE ` + tableName + ` (key blob, value blob, PRIMARY KEY(key, value))`).Exec()
-	//	if err != nil {
-	//		return err
-	//	}
-	//}
-	//return nil
+// CreateTables creates any missing table and runs pending migrations,
+// see Migrate
+func CreateTables(session *gocql.Session) error {
+	return Migrate(session)
 }
 
-func DropTables(session *gocql.Session) error {
+// DropKeyspaceTables drops every table of the session keyspace, which
+// wipes all indexes sharing it, not only the one of a store.  Use
+// Store.DropNamespace to delete a single index.
+func DropKeyspaceTables(session *gocql.Session) error {
 	var err error
 	err = session.Query(`DROP TABLE d`).Exec()
 	if err != nil && !strings.Contains(err.Error(), "unconfigured table") {
@@ -112,9 +198,11 @@ func DropTables(session *gocql.Session) error {
 	if err != nil && !strings.Contains(err.Error(), "unconfigured table") {
 		return WrapError(err, "drop table t")
 	}
-	err = session.Query(`DROP TABLE ` + SharedTable).Exec()
-	if err != nil && !strings.Contains(err.Error(), "unconfigured table") {
-		return WrapError(err, "drop table "+SharedTable)
+	for _, table := range []string{SharedTable, PostingsTable, ScorePostingsTable, RowsTable} {
+		err = session.Query(`DROP TABLE ` + table).Exec()
+		if err != nil && !strings.Contains(err.Error(), "unconfigured table") {
+			return WrapError(err, "drop table "+table)
+		}
 	}
 	return nil
 
@@ -128,13 +216,100 @@ func DropTables(session *gocql.Session) error {
 	//return nil
 }
 
+// DropNamespace deletes every row of the index, leaving the tables and
+// the other indexes of the keyspace in place.  Cassandra counters can
+// not be reliably incremented again once deleted, so a dropped
+// namespace should not be reused for a new index.
+func (cdbs *Store) DropNamespace() error {
+	// the postings partitions are found through the dictionary
+	iter := cdbs.read(`SELECT key FROM d WHERE type = ?`, cdbs.partition("d")).PageSize(cdbs.pageSize).Iter()
+	var key []byte
+	for iter.Scan(&key) {
+		buckets, err := cdbs.scanBuckets("t", append(key, keySeparator))
+		if err != nil {
+			_ = iter.Close()
+			return err
+		}
+		for _, bucket := range buckets {
+			for _, mapped := range []string{PostingsTable, ScorePostingsTable} {
+				err = cdbs.write(`DELETE FROM `+mapped+` WHERE type = ? AND bucket = ?`, cdbs.partition("t"), bucket).Exec()
+				if err != nil {
+					_ = iter.Close()
+					return WrapError(err, "drop namespace "+cdbs.namespace+" table t")
+				}
+			}
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return WrapError(err, "drop namespace "+cdbs.namespace+" table d")
+	}
+
+	for _, table := range Tables {
+		if table == "d" || table == "t" {
+			continue
+		}
+		buckets, err := cdbs.rangeBuckets(table, nil)
+		if err != nil {
+			return err
+		}
+		for _, bucket := range buckets {
+			err = cdbs.write(`DELETE FROM `+RowsTable+` WHERE type = ? AND bucket = ?`, cdbs.partition(table), bucket).Exec()
+			if err != nil {
+				return WrapError(err, "drop namespace "+cdbs.namespace+" table "+table)
+			}
+		}
+	}
+	// counters are kept in d under the partition of their table
+	for _, table := range Tables {
+		err := cdbs.write(`DELETE FROM d WHERE type = ?`, cdbs.partition(table)).Exec()
+		if err != nil {
+			return WrapError(err, "drop namespace "+cdbs.namespace+" counters of table "+table)
+		}
+	}
+	// rows left in place by MigrateLegacyRows would be copied again
+	// on the next open, the shared version partition also holds the
+	// schema version
+	for _, table := range Tables {
+		if table == "d" || table == "v" {
+			continue
+		}
+		err := cdbs.write(`DELETE FROM `+legacyTableMapping(table)+` WHERE type = ?`, cdbs.partition(table)).Exec()
+		if err != nil && !isUnconfiguredTable(err) {
+			return WrapError(err, "drop namespace "+cdbs.namespace+" legacy table "+table)
+		}
+	}
+	return nil
+}
+
+// TableMapping returns the Cassandra table holding the rows of a
+// table, see Store.partition and Store.bucket for the partition key
 func TableMapping(t string) string {
+	switch t {
+	case "d":
+		return t
+	case "t":
+		return PostingsTable
+	}
+	return RowsTable
+}
+
+// legacyTableMapping is TableMapping before schema version 2, when all
+// rows of a table shared a single partition
+func legacyTableMapping(t string) string {
 	if t == "d" || t == "t" {
 		return t
 	}
 	return SharedTable
 }
 
+func isUnconfiguredTable(err error) bool {
+	return strings.Contains(err.Error(), "unconfigured table")
+}
+
+func isExistingColumn(err error) bool {
+	return strings.Contains(err.Error(), "conflicts with an existing column")
+}
+
 func WrapError(err error, context string) error {
 	if err != nil {
 		return errors.New(context + ": " + err.Error())
@@ -145,11 +320,32 @@ func WrapError(err error, context string) error {
 func validate(key string, config map[string]interface{}) error {
 	value := config[key]
 	if value == nil {
-		return fmt.Errorf("must specify: " + key)
+		return fmt.Errorf("must specify: %s", key)
 	}
 	return nil
 }
 
+// partition returns the partition key (the type column) of a table.
+// The namespace prefix keeps indexes sharing a keyspace apart, table
+// names never contain the separator so no two pairs collide.  The
+// empty namespace maps tables as they were before namespaces existed.
+func (cdbs *Store) partition(table string) string {
+	if cdbs.namespace == "" {
+		return table
+	}
+	return cdbs.namespace + "/" + table
+}
+
+// read prepares a query at the read consistency level
+func (cdbs *Store) read(cql string, values ...interface{}) *gocql.Query {
+	return cdbs.Session.Query(cql, values...).Consistency(cdbs.readConsistency)
+}
+
+// write prepares a query at the write consistency level
+func (cdbs *Store) write(cql string, values ...interface{}) *gocql.Query {
+	return cdbs.Session.Query(cql, values...).Consistency(cdbs.writeConsistency)
+}
+
 func (cdbs *Store) Close() error {
 	cdbs.Session.Close()
 	return nil
@@ -1,7 +1,9 @@
 package cassandra
 
 import (
+	"encoding/binary"
 	"os"
+	"reflect"
 	"testing"
 
 	"strings"
@@ -19,7 +21,7 @@ func open(t *testing.T, mo store.MergeOperator) store.KVStore {
 		t.Fatal(err)
 	}
 	st := rv.(*Store)
-	err = DropTables(st.Session)
+	err = DropKeyspaceTables(st.Session)
 	if err != nil && !strings.Contains(err.Error(), "unconfigured table") {
 		t.Fatal(err)
 	}
@@ -94,3 +96,163 @@ func TestCassandraMerge(t *testing.T) {
 	defer cleanup(t, s)
 	test.CommonTestMerge(t, s)
 }
+
+func TestCassandraCounterIterator(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+
+	writer, err := s.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := writer.NewBatch()
+	counts := map[string]int64{"a": 1, "b1": 2, "b2": 300, "c": 4}
+	for key, count := range counts {
+		if err := batch.Increment(CounterTable, []byte(key), count); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = writer.ExecuteBatch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := s.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	walk := func(iter store.KVIterator) []string {
+		var keys []string
+		for iter.Valid() {
+			count, nread := binary.Uvarint(iter.Value())
+			if nread <= 0 || int64(count) != counts[string(iter.Key())] {
+				t.Errorf("expected counter %d for %s, got %d", counts[string(iter.Key())], iter.Key(), count)
+			}
+			keys = append(keys, string(iter.Key()))
+			iter.Next()
+		}
+		if err := iter.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+
+	got := walk(reader.RangeIterator(CounterTable, []byte("b"), []byte("c")))
+	if !reflect.DeepEqual(got, []string{"b1", "b2"}) {
+		t.Errorf("expected range b-c to be [b1 b2], got %v", got)
+	}
+	got = walk(reader.PrefixIterator(CounterTable, []byte("b")))
+	if !reflect.DeepEqual(got, []string{"b1", "b2"}) {
+		t.Errorf("expected prefix b to be [b1 b2], got %v", got)
+	}
+	got = walk(reader.RangeIterator(CounterTable, nil, nil))
+	if !reflect.DeepEqual(got, []string{"a", "b1", "b2", "c"}) {
+		t.Errorf("expected all counters, got %v", got)
+	}
+}
+
+func TestCassandraCopiesLegacyRowsOnOpen(t *testing.T) {
+	s := open(t, nil)
+	st := s.(*Store)
+	err := st.Session.Query(`INSERT INTO `+SharedTable+` (type, key, value) VALUES (?, ?, ?)`, "b", []byte("doc1"), []byte("back")).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Session.Query(`DELETE FROM `+RowsTable+` WHERE type = ? AND bucket = ? AND key = ?`, "v", st.bucket("v", LegacyRowsKey), LegacyRowsKey).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = New(nil, map[string]interface{}{
+		"keyspace": "example",
+		"hosts":    []string{"127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(t, s)
+
+	reader, err := s.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	value, err := reader.Get("b", []byte("doc1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "back" {
+		t.Errorf("expected the legacy back index row to be copied, got %q", value)
+	}
+}
+
+func TestCassandraDropNamespace(t *testing.T) {
+	s := open(t, nil)
+	err := s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = New(nil, map[string]interface{}{
+		"keyspace":  "example",
+		"hosts":     []string{"127.0.0.1"},
+		"namespace": "dropped",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(t, s)
+
+	writer, err := s.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := writer.NewBatch()
+	for _, table := range []string{"b", "s", "x"} {
+		err = batch.Set(table, &testRow{key: []byte("doc\xff\x00\x00"), value: []byte("v")})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = writer.ExecuteBatch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.(*Store).DropNamespace()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := s.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, table := range []string{"b", "s", "x"} {
+		iter := reader.RangeIterator(table, nil, nil)
+		if iter.Valid() {
+			t.Errorf("expected no rows left in table %s, found %q", table, iter.Key())
+		}
+		if err := iter.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
 	"github.com/wrble/flock/index/store"
 )
 
@@ -12,13 +13,7 @@ type Writer struct {
 }
 
 func (w *Writer) NewBatch() store.KVBatch {
-	rv := Batch{
-		store:        w.store,
-		merge:        NewEmulatedMerge(w.store.mo),
-		batch:        w.store.Session.NewBatch(BATCH_TYPE),
-		counterBatch: w.store.Session.NewBatch(gocql.CounterBatch),
-	}
-	return &rv
+	return newBatch(w.store)
 }
 
 func (w *Writer) NewBatchEx(options store.KVBatchOptions) (store.KVBatch, error) {
@@ -33,6 +28,34 @@ func (w *Writer) ExecuteBatch(b store.KVBatch) error {
 	return batch.Execute()
 }
 
+// CompareAndSet is a lightweight transaction on the row of the key, it
+// is only supported on the tables mapped to RowsTable
+func (w *Writer) CompareAndSet(table string, key, old, value []byte) (bool, error) {
+	mapped := TableMapping(table)
+	if mapped != RowsTable {
+		return false, errors.Errorf("compare and set is not supported on table %s", table)
+	}
+	partitionKey := w.store.partition(table)
+	bucket := w.store.bucket(table, key)
+	var query *gocql.Query
+	switch {
+	case old == nil && value == nil:
+		return false, errors.New("compare and set needs an old value or a value")
+	case old == nil:
+		query = w.store.write(`INSERT INTO `+mapped+` (type, bucket, key, value) VALUES (?, ?, ?, ?) IF NOT EXISTS`, partitionKey, bucket, key, value)
+	case value == nil:
+		query = w.store.write(`DELETE FROM `+mapped+` WHERE type = ? AND bucket = ? AND key = ? IF value = ?`, partitionKey, bucket, key, old)
+	default:
+		query = w.store.write(`UPDATE `+mapped+` SET value = ? WHERE type = ? AND bucket = ? AND key = ? IF value = ?`, value, partitionKey, bucket, key, old)
+	}
+	// the current row is returned when it was not applied
+	applied, err := query.MapScanCAS(make(map[string]interface{}))
+	if err != nil {
+		return false, WrapError(err, "compare and set "+table)
+	}
+	return applied, nil
+}
+
 func (w *Writer) Close() error {
 	return nil
 }
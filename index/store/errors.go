@@ -0,0 +1,15 @@
+package store
+
+import "fmt"
+
+// SchemaVersionError is returned by a KVStore constructor when the
+// stored layout was written by a newer version than the running code
+type SchemaVersionError struct {
+	Store     string
+	Stored    int
+	Supported int
+}
+
+func (e *SchemaVersionError) Error() string {
+	return fmt.Sprintf("%s schema version %d is newer than the supported version %d, upgrade before opening this index", e.Store, e.Stored, e.Supported)
+}
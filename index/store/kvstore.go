@@ -16,6 +16,7 @@ package store
 
 import (
 	"encoding/json"
+	"time"
 )
 
 // KVStore is an abstraction for working with KV stores.  Note that
@@ -70,13 +71,26 @@ type KVReader interface {
 	// visit all K/V pairs >= start AND < end
 	RangeIterator(table string, start, end []byte) KVIterator
 
-	// Fetch the count of documents in the index
+	// DocCount counts the back index rows (b), this may scan the
+	// whole table, indexes keep a counter and only use it to seed or
+	// reconcile that counter
 	DocCount() (uint64, error)
 
 	// Close closes the iterator
 	Close() error
 }
 
+// KVScoreOrderedReader is implemented by readers which also keep the
+// term frequency rows (t) ordered by their pre score.
+type KVScoreOrderedReader interface {
+
+	// ScoreOrderedIterator returns a TypedKVIterator visiting the term
+	// frequency rows with the provided prefix, which must cover a whole
+	// field and term, by descending score then ascending key.
+	// Seek is not supported by the returned iterator.
+	ScoreOrderedIterator(table string, prefix []byte) TypedKVIterator
+}
+
 // KVIterator is an abstraction around key iteration
 type KVIterator interface {
 
@@ -162,6 +176,16 @@ type KVWriter interface {
 	Close() error
 }
 
+// KVCompareAndSet is an optional interface of KVWriters whose store
+// may be written by several processes at once
+type KVCompareAndSet interface {
+	// CompareAndSet atomically sets the value of the key only if its
+	// current value is old, a nil old requires the key not to exist and
+	// a nil value deletes the key.  It reports whether the value was
+	// set.  The keys it sets must not be written by batches.
+	CompareAndSet(table string, key, old, value []byte) (bool, error)
+}
+
 // KVBatchOptions provides the KVWriter.NewBatchEx() method with batch
 // preparation and preallocation information.
 type KVBatchOptions struct {
@@ -205,6 +229,14 @@ type KVBatch interface {
 	Close() error
 }
 
+// KVExpiringBatch is an optional interface of KVBatches whose store
+// removes rows once they expire
+type KVExpiringBatch interface {
+	// SetWithTTL updates the key like Set, the store removes the row
+	// once ttl has passed unless it is written again before
+	SetWithTTL(table string, row interface{}, ttl time.Duration) error
+}
+
 // KVStoreStats is an optional interface that KVStores can implement
 // if they're able to report any useful stats
 type KVStoreStats interface {
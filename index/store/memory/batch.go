@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"errors"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/index/rows"
+)
+
+type opKind int
+
+const (
+	opSet opKind = iota
+	opDelete
+	opIncrement
+)
+
+type op struct {
+	kind opKind
+	item *item
+}
+
+type Batch struct {
+	store *Store
+	ops   map[string][]*op
+}
+
+func newBatch(s *Store) *Batch {
+	return &Batch{
+		store: s,
+		ops:   make(map[string][]*op),
+	}
+}
+
+func (b *Batch) Set(table string, row interface{}) error {
+	indexRow, ok := row.(index.IndexRow)
+	if !ok {
+		return errors.New("Invalid main row type.")
+	}
+	it := &item{
+		key: copyBytes(indexRow.Key()),
+		val: copyBytes(indexRow.Value()),
+	}
+	if termRow, ok := row.(*rows.TermFrequencyRow); ok {
+		it.freq = termRow.Freq
+		it.score = termRow.Score
+		it.length = int(termRow.FieldLength)
+		it.boost = termRow.Boost
+		it.vectors = termRow.TFVectorsValue()
+	}
+	b.ops[table] = append(b.ops[table], &op{kind: opSet, item: it})
+	return nil
+}
+
+func (b *Batch) Delete(table string, key []byte) error {
+	b.ops[table] = append(b.ops[table], &op{kind: opDelete, item: &item{key: copyBytes(key)}})
+	return nil
+}
+
+func (b *Batch) Increment(table string, key []byte, amount int64) error {
+	b.ops[table] = append(b.ops[table], &op{kind: opIncrement, item: &item{key: copyBytes(key), counter: amount}})
+	return nil
+}
+
+func (b *Batch) Reset() {
+	b.ops = make(map[string][]*op)
+}
+
+func (b *Batch) Close() error {
+	b.ops = nil
+	return nil
+}
+
+func copyBytes(in []byte) []byte {
+	if in == nil {
+		return nil
+	}
+	rv := make([]byte, len(in))
+	copy(rv, in)
+	return rv
+}
@@ -0,0 +1,183 @@
+package memory
+
+import (
+	"bytes"
+	"sort"
+)
+
+type Iterator struct {
+	table *table
+
+	start  []byte
+	end    []byte
+	prefix []byte
+
+	pos int
+}
+
+func newIterator(t *table, start, end, prefix []byte) *Iterator {
+	rv := &Iterator{
+		table:  t,
+		start:  start,
+		end:    end,
+		prefix: prefix,
+	}
+	rv.pos = t.find(start)
+	return rv
+}
+
+func (i *Iterator) Seek(key []byte) {
+	if bytes.Compare(key, i.start) < 0 {
+		key = i.start
+	}
+	i.pos = i.table.find(key)
+}
+
+func (i *Iterator) Next() {
+	i.pos++
+}
+
+func (i *Iterator) current() *item {
+	if i.table == nil || i.pos >= len(i.table.items) {
+		return nil
+	}
+	it := i.table.items[i.pos]
+	if i.end != nil && len(i.end) > 0 && bytes.Compare(it.key, i.end) >= 0 {
+		return nil
+	}
+	if i.prefix != nil && !bytes.HasPrefix(it.key, i.prefix) {
+		return nil
+	}
+	return it
+}
+
+func (i *Iterator) Current() ([]byte, []byte, bool) {
+	it := i.current()
+	if it == nil {
+		return nil, nil, false
+	}
+	return it.key, it.val, true
+}
+
+func (i *Iterator) Key() []byte {
+	it := i.current()
+	if it == nil {
+		return nil
+	}
+	return it.key
+}
+
+func (i *Iterator) Value() []byte {
+	it := i.current()
+	if it == nil {
+		return nil
+	}
+	return it.val
+}
+
+func (i *Iterator) Valid() bool {
+	return i.current() != nil
+}
+
+func (i *Iterator) Close() error {
+	i.table = nil
+	return nil
+}
+
+// TypedKVIterator walks the same rows as Iterator but exposes the
+// typed term frequency columns
+type TypedKVIterator struct {
+	*Iterator
+}
+
+func (i *TypedKVIterator) Current() ([]byte, map[string]interface{}, bool) {
+	it := i.current()
+	if it == nil {
+		return nil, nil, false
+	}
+	return it.key, it.typed(), true
+}
+
+func (i *TypedKVIterator) Value() map[string]interface{} {
+	it := i.current()
+	if it == nil {
+		return nil
+	}
+	return it.typed()
+}
+
+// ScoreIterator walks term frequency rows by descending score, then
+// ascending key
+type ScoreIterator struct {
+	items []*item
+	pos   int
+}
+
+func newScoreIterator(t *table, prefix []byte) *ScoreIterator {
+	rv := &ScoreIterator{}
+	if t == nil {
+		return rv
+	}
+	for i := t.find(prefix); i < len(t.items) && bytes.HasPrefix(t.items[i].key, prefix); i++ {
+		rv.items = append(rv.items, t.items[i])
+	}
+	// the rows were collected in key order, a stable sort keeps it
+	// among equal scores
+	sort.Stable(byScore(rv.items))
+	return rv
+}
+
+type byScore []*item
+
+func (s byScore) Len() int           { return len(s) }
+func (s byScore) Less(i, j int) bool { return s[i].score > s[j].score }
+func (s byScore) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// Seek is not supported on score ordered rows, it leaves the iterator
+// where it is
+func (i *ScoreIterator) Seek(key []byte) {
+}
+
+func (i *ScoreIterator) Next() {
+	i.pos++
+}
+
+func (i *ScoreIterator) current() *item {
+	if i.pos >= len(i.items) {
+		return nil
+	}
+	return i.items[i.pos]
+}
+
+func (i *ScoreIterator) Current() ([]byte, map[string]interface{}, bool) {
+	it := i.current()
+	if it == nil {
+		return nil, nil, false
+	}
+	return it.key, it.typed(), true
+}
+
+func (i *ScoreIterator) Key() []byte {
+	it := i.current()
+	if it == nil {
+		return nil
+	}
+	return it.key
+}
+
+func (i *ScoreIterator) Value() map[string]interface{} {
+	it := i.current()
+	if it == nil {
+		return nil
+	}
+	return it.typed()
+}
+
+func (i *ScoreIterator) Valid() bool {
+	return i.current() != nil
+}
+
+func (i *ScoreIterator) Close() error {
+	i.items = nil
+	return nil
+}
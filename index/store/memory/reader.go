@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"github.com/wrble/flock/index/store"
+)
+
+type Reader struct {
+	store  *Store
+	tables map[string]*table
+}
+
+func (r *Reader) Get(table string, key []byte) ([]byte, error) {
+	it := r.tables[table].get(key)
+	if it == nil {
+		return nil, nil
+	}
+	return copyBytes(it.val), nil
+}
+
+func (r *Reader) GetCounter(table string, key []byte) (int64, error) {
+	it := r.tables[table].get(key)
+	if it == nil {
+		return -1, nil
+	}
+	return it.counter, nil
+}
+
+func (r *Reader) DocCount() (uint64, error) {
+	return uint64(r.tables["b"].len()), nil
+}
+
+func (r *Reader) MultiGet(table string, keys [][]byte) ([][]byte, error) {
+	return store.MultiGet(r, table, keys)
+}
+
+func (r *Reader) PrefixIterator(table string, prefix []byte) store.KVIterator {
+	return newIterator(r.tables[table], prefix, nil, prefix)
+}
+
+func (r *Reader) TypedPrefixIterator(table string, prefix []byte) store.TypedKVIterator {
+	return &TypedKVIterator{
+		Iterator: newIterator(r.tables[table], prefix, nil, prefix),
+	}
+}
+
+func (r *Reader) ScoreOrderedIterator(table string, prefix []byte) store.TypedKVIterator {
+	return newScoreIterator(r.tables[table], prefix)
+}
+
+func (r *Reader) RangeIterator(table string, start, end []byte) store.KVIterator {
+	return newIterator(r.tables[table], start, end, nil)
+}
+
+func (r *Reader) Close() error {
+	r.tables = nil
+	return nil
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/wrble/flock/index/store"
+	"github.com/wrble/flock/registry"
+)
+
+const (
+	Name = "memory"
+)
+
+func init() {
+	registry.RegisterKVStore(Name, New)
+}
+
+// Store is an in-memory, ordered KVStore.  Each logical table is kept
+// as an immutable sorted slice, batches build a new version of every
+// table they touch, so readers see a consistent snapshot of the store
+// as it was when they were opened.
+type Store struct {
+	mo store.MergeOperator
+
+	m      sync.RWMutex
+	tables map[string]*table
+}
+
+func New(mo store.MergeOperator, config map[string]interface{}) (store.KVStore, error) {
+	rv := Store{
+		mo:     mo,
+		tables: make(map[string]*table),
+	}
+	return &rv, nil
+}
+
+func (s *Store) Close() error {
+	s.m.Lock()
+	s.tables = make(map[string]*table)
+	s.m.Unlock()
+	return nil
+}
+
+func (s *Store) Reader() (store.KVReader, error) {
+	s.m.RLock()
+	snapshot := make(map[string]*table, len(s.tables))
+	for name, t := range s.tables {
+		snapshot[name] = t
+	}
+	s.m.RUnlock()
+	return &Reader{
+		store:  s,
+		tables: snapshot,
+	}, nil
+}
+
+func (s *Store) Writer() (store.KVWriter, error) {
+	return &Writer{
+		store: s,
+	}, nil
+}
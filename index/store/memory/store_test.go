@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wrble/flock/index/rows"
+	"github.com/wrble/flock/index/store"
+	"github.com/wrble/flock/index/store/test"
+)
+
+func open(t *testing.T, mo store.MergeOperator) store.KVStore {
+	rv, err := New(mo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rv
+}
+
+func cleanup(t *testing.T, s store.KVStore) {
+	err := s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryKVCrud(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+	test.CommonTestKVCrud(t, s)
+}
+
+func TestMemoryReaderIsolation(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+	test.CommonTestReaderIsolation(t, s)
+}
+
+func TestMemoryReaderOwnsGetBytes(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+	test.CommonTestReaderOwnsGetBytes(t, s)
+}
+
+func TestMemoryPrefixIterator(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+	test.CommonTestPrefixIterator(t, s)
+}
+
+func TestMemoryPrefixIteratorSeek(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+	test.CommonTestPrefixIteratorSeek(t, s)
+}
+
+func TestMemoryRangeIterator(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+	test.CommonTestRangeIterator(t, s)
+}
+
+func TestMemoryMerge(t *testing.T) {
+	s := open(t, &test.TestMergeCounter{})
+	defer cleanup(t, s)
+	test.CommonTestMerge(t, s)
+}
+
+func TestMemoryTypedPrefixIterator(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+
+	writer, err := s.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := writer.NewBatch()
+	for _, doc := range []string{"a", "b", "c"} {
+		tfr := rows.NewTermFrequencyRow([]byte("beer"), 1, []byte(doc), 0.5, 0.25)
+		if err := batch.Set("t", tfr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := rows.NewTermFrequencyRow([]byte("wine"), 1, []byte("a"), 1, 1)
+	if err := batch.Set("t", other); err != nil {
+		t.Fatal(err)
+	}
+	err = writer.ExecuteBatch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := s.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	prefix := rows.NewTermFrequencyRow([]byte("beer"), 1, nil, 0, 0).ScanPrefixForFieldTerm()
+	iter := reader.TypedPrefixIterator("t", prefix)
+	count := 0
+	for ; iter.Valid(); iter.Next() {
+		val := iter.Value()
+		if val["freq"].(float32) != 0.5 {
+			t.Errorf("expected freq 0.5, got %v", val["freq"])
+		}
+		if val["score"].(float32) != 0.25 {
+			t.Errorf("expected score 0.25, got %v", val["score"])
+		}
+		if _, ok := val["vectors"].([]byte); !ok {
+			t.Errorf("expected vectors []byte, got %T", val["vectors"])
+		}
+		count++
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 postings, got %d", count)
+	}
+}
+
+func TestMemoryScoreOrderedIterator(t *testing.T) {
+	s := open(t, nil)
+	defer cleanup(t, s)
+
+	writer, err := s.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := writer.NewBatch()
+	scores := map[string]float32{"a": 0.25, "b": 0.75, "c": 0.25, "d": 0.5}
+	for doc, score := range scores {
+		tfr := rows.NewTermFrequencyRow([]byte("beer"), 1, []byte(doc), score, score)
+		if err := batch.Set("t", tfr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := rows.NewTermFrequencyRow([]byte("wine"), 1, []byte("a"), 1, 1)
+	if err := batch.Set("t", other); err != nil {
+		t.Fatal(err)
+	}
+	err = writer.ExecuteBatch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := s.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	prefix := rows.NewTermFrequencyRow([]byte("beer"), 1, nil, 0, 0).ScanPrefixForFieldTerm()
+	iter := reader.(store.KVScoreOrderedReader).ScoreOrderedIterator("t", prefix)
+	var docs []string
+	for ; iter.Valid(); iter.Next() {
+		docs = append(docs, string(iter.Key()[len(prefix):]))
+	}
+	if err := iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"b", "d", "a", "c"}
+	if !reflect.DeepEqual(docs, expected) {
+		t.Errorf("expected %v, got %v", expected, docs)
+	}
+}
@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+// item is a single row of a table, the typed columns mirror the
+// term frequency table of the cassandra store
+type item struct {
+	key     []byte
+	val     []byte
+	freq    float32
+	score   float32
+	length  int
+	boost   float32
+	vectors []byte
+	counter int64
+}
+
+func (i *item) typed() map[string]interface{} {
+	return map[string]interface{}{
+		"key":     i.key,
+		"freq":    i.freq,
+		"score":   i.score,
+		"length":  i.length,
+		"boost":   i.boost,
+		"vectors": i.vectors,
+	}
+}
+
+// counterValue encodes a counter the same way a DictionaryRow value is encoded
+func counterValue(counter int64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	used := binary.PutUvarint(buf, uint64(counter))
+	return buf[:used]
+}
+
+// table is immutable once published to the store
+type table struct {
+	items []*item
+}
+
+// find returns the position of the first item >= key
+func (t *table) find(key []byte) int {
+	if t == nil {
+		return 0
+	}
+	return sort.Search(len(t.items), func(i int) bool {
+		return bytes.Compare(t.items[i].key, key) >= 0
+	})
+}
+
+func (t *table) get(key []byte) *item {
+	if t == nil {
+		return nil
+	}
+	i := t.find(key)
+	if i < len(t.items) && bytes.Equal(t.items[i].key, key) {
+		return t.items[i]
+	}
+	return nil
+}
+
+func (t *table) len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.items)
+}
+
+// apply returns a new version of the table with the operations applied
+// in order, the receiver is left untouched
+func (t *table) apply(ops []*op) *table {
+	// collapse the operations into the final state of each key,
+	// a nil item marks a deletion
+	pending := make(map[string]*item, len(ops))
+	for _, o := range ops {
+		switch o.kind {
+		case opSet:
+			pending[string(o.item.key)] = o.item
+		case opDelete:
+			pending[string(o.item.key)] = nil
+		case opIncrement:
+			base, ok := pending[string(o.item.key)]
+			if !ok {
+				base = t.get(o.item.key)
+			}
+			var counter int64
+			if base != nil {
+				counter = base.counter
+			}
+			counter += o.item.counter
+			pending[string(o.item.key)] = &item{
+				key:     o.item.key,
+				val:     counterValue(counter),
+				counter: counter,
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(pending))
+	for k := range pending {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rv := &table{
+		items: make([]*item, 0, t.len()+len(keys)),
+	}
+	var existing []*item
+	if t != nil {
+		existing = t.items
+	}
+	i, j := 0, 0
+	for i < len(existing) || j < len(keys) {
+		var cmp int
+		if i >= len(existing) {
+			cmp = 1
+		} else if j >= len(keys) {
+			cmp = -1
+		} else {
+			cmp = bytes.Compare(existing[i].key, []byte(keys[j]))
+		}
+		if cmp < 0 {
+			rv.items = append(rv.items, existing[i])
+			i++
+			continue
+		}
+		if cmp == 0 {
+			// replaced or deleted
+			i++
+		}
+		if next := pending[keys[j]]; next != nil {
+			rv.items = append(rv.items, next)
+		}
+		j++
+	}
+	return rv
+}
@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"fmt"
+
+	"github.com/wrble/flock/index/store"
+)
+
+type Writer struct {
+	store *Store
+}
+
+func (w *Writer) NewBatch() store.KVBatch {
+	return newBatch(w.store)
+}
+
+func (w *Writer) NewBatchEx(options store.KVBatchOptions) (store.KVBatch, error) {
+	return w.NewBatch(), nil
+}
+
+// ExecuteBatch publishes a new version of every table touched by the
+// batch while holding the store lock, so the batch is applied atomically
+func (w *Writer) ExecuteBatch(b store.KVBatch) error {
+	batch, ok := b.(*Batch)
+	if !ok {
+		return fmt.Errorf("wrong type of batch")
+	}
+	if batch.store != w.store {
+		return fmt.Errorf("batch belongs to a different store")
+	}
+
+	w.store.m.Lock()
+	defer w.store.m.Unlock()
+	for name, ops := range batch.ops {
+		w.store.tables[name] = w.store.tables[name].apply(ops)
+	}
+	batch.Reset()
+	return nil
+}
+
+func (w *Writer) Close() error {
+	return nil
+}
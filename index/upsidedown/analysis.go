@@ -21,6 +21,12 @@ import (
 )
 
 func (udc *UpsideDownCouch) Analyze(d *document.Document) *index.AnalysisResult {
+	return udc.analyze(d, nil)
+}
+
+// analyze analyzes the document, the names of its fields are recorded
+// along with the names recorded before, see index.FieldNamesField
+func (udc *UpsideDownCouch) analyze(d *document.Document, recordedFieldNames []string) *index.AnalysisResult {
 	rv := &index.AnalysisResult{
 		DocID: d.ID,
 		Rows:  make([]index.IndexRow, 0, 100),
@@ -34,6 +40,7 @@ func (udc *UpsideDownCouch) Analyze(d *document.Document) *index.AnalysisResult
 	// information we collate as we merge fields with same name
 	fieldTermFreqs := make(map[uint16]analysis.TokenFrequencies)
 	fieldLengths := make(map[uint16]int)
+	fieldBoosts := make(map[uint16]float32)
 	fieldIncludeTermVectors := make(map[uint16]bool)
 	fieldNames := make(map[uint16]string)
 
@@ -54,6 +61,7 @@ func (udc *UpsideDownCouch) Analyze(d *document.Document) *index.AnalysisResult
 				fieldTermFreqs[fieldIndex] = existingFreqs
 			}
 			fieldLengths[fieldIndex] += fieldLength
+			fieldBoosts[fieldIndex] = float32(d.FieldBoost(field.Name()))
 			fieldIncludeTermVectors[fieldIndex] = field.Options().IncludeTermVectors()
 		}
 
@@ -70,6 +78,10 @@ func (udc *UpsideDownCouch) Analyze(d *document.Document) *index.AnalysisResult
 		analyzeField(field, true)
 	}
 
+	if len(d.Source) > 0 {
+		rv.Rows, backIndexStoredEntries = udc.storeSource(docIDBytes, d.Source, rv.Rows, backIndexStoredEntries)
+	}
+
 	if len(d.CompositeFields) > 0 {
 		for fieldIndex, tokenFreqs := range fieldTermFreqs {
 			// see if any of the composite fields need this
@@ -83,6 +95,18 @@ func (udc *UpsideDownCouch) Analyze(d *document.Document) *index.AnalysisResult
 		}
 	}
 
+	// record the names of the fields present, after the composite
+	// fields so they do not take them in
+	if names := documentFieldNames(d, recordedFieldNames); len(names) > 0 {
+		fieldIndex, newFieldRow := udc.fieldIndexOrNewRow(index.FieldNamesField)
+		if newFieldRow != nil {
+			rv.Rows = append(rv.Rows, newFieldRow)
+		}
+		fieldTermFreqs[fieldIndex] = fieldNamesTokenFreqs(names)
+		fieldLengths[fieldIndex] = len(names)
+		fieldBoosts[fieldIndex] = 1
+	}
+
 	rowsCapNeeded := len(rv.Rows) + 1
 	for _, tokenFreqs := range fieldTermFreqs {
 		rowsCapNeeded += len(tokenFreqs)
@@ -96,14 +120,25 @@ func (udc *UpsideDownCouch) Analyze(d *document.Document) *index.AnalysisResult
 	// once for each indexed field (unique name)
 	for fieldIndex, tokenFreqs := range fieldTermFreqs {
 		fieldLength := fieldLengths[fieldIndex]
+		boost := fieldBoosts[fieldIndex]
 		includeTermVectors := fieldIncludeTermVectors[fieldIndex]
 
 		// encode this field
-		rv.Rows, backIndexTermsEntries = udc.indexField(docIDBytes, includeTermVectors, fieldIndex, fieldLength, tokenFreqs, rv.Rows, backIndexTermsEntries)
+		rv.Rows, backIndexTermsEntries = udc.indexField(docIDBytes, includeTermVectors, fieldIndex, fieldLength, boost, tokenFreqs, rv.Rows, backIndexTermsEntries)
 	}
 
 	// build the back index row
 	backIndexRow := NewBackIndexRow(docIDBytes, backIndexTermsEntries, backIndexStoredEntries)
+	if d.Boost > 0 {
+		backIndexRow.boost = float32(d.Boost)
+	}
+	if !d.Expires.IsZero() {
+		backIndexRow.expires = d.Expires.UnixNano()
+		rv.Rows = append(rv.Rows, NewExpiryRow(docIDBytes, backIndexRow.expires))
+	}
+	for _, nested := range d.Nested {
+		backIndexRow.nested = append(backIndexRow.nested, nested.ID)
+	}
 	rv.Rows = append(rv.Rows, backIndexRow)
 
 	return rv
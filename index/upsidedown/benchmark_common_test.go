@@ -49,7 +49,7 @@ func DestroyTest() error {
 	if err != nil {
 		return err
 	}
-	err = cassandra.DropTables(store.(*cassandra.Store).Session)
+	err = cassandra.DropKeyspaceTables(store.(*cassandra.Store).Session)
 	if err != nil {
 		return err
 	}
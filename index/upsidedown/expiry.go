@@ -0,0 +1,344 @@
+package upsidedown
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/index/rows"
+	"github.com/wrble/flock/index/store"
+)
+
+// DefaultReapInterval is how often the documents which expired are
+// removed from the index, the "reap_interval" of the store config, a
+// duration or a number of seconds, changes it.  An interval which is
+// not positive disables the reaper.
+const DefaultReapInterval = time.Minute
+
+// reapBatchSize is the number of expired documents removed per batch
+const reapBatchSize = 100
+
+// reapInterval returns the interval of the reaper set in the config
+func reapInterval(config map[string]interface{}) (time.Duration, error) {
+	switch v := config["reap_interval"].(type) {
+	case nil:
+		return DefaultReapInterval, nil
+	case string:
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second)), nil
+		}
+		return time.ParseDuration(v)
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	}
+	return 0, fmt.Errorf("reap_interval must be a duration, got %v", config["reap_interval"])
+}
+
+// startReaper removes the documents which expired every interval until
+// the index is closed
+func (udc *UpsideDownCouch) startReaper(interval time.Duration) {
+	udc.reapStop = make(chan struct{})
+	udc.reapDone.Add(1)
+	go func() {
+		defer udc.reapDone.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-udc.reapStop:
+				return
+			case now := <-ticker.C:
+				_, err := udc.ReapExpired(now)
+				if err != nil {
+					atomic.AddUint64(&udc.stats.errors, 1)
+				}
+			}
+		}
+	}()
+}
+
+// stopReaper stops the reaper and waits for the removal under way
+func (udc *UpsideDownCouch) stopReaper() {
+	if udc.reapStop != nil {
+		close(udc.reapStop)
+		udc.reapDone.Wait()
+		udc.reapStop = nil
+	}
+}
+
+// ReapExpired removes the documents which expired at now from the
+// index like deletes do, and returns how many it removed.  A document
+// written again since it was found expired is kept.
+func (udc *UpsideDownCouch) ReapExpired(now time.Time) (int, error) {
+	reaped := 0
+	for {
+		batch, stale, found, err := udc.expiredBatch(now)
+		if err != nil {
+			return reaped, err
+		}
+		err = udc.deleteStaleExpiries(stale)
+		if err != nil {
+			return reaped, err
+		}
+		for len(batch.IndexOps) > 0 {
+			err = udc.Batch(batch)
+			conflict, ok := index.AsVersionConflict(err)
+			if !ok {
+				break
+			}
+			// written again since, it no longer expires now
+			delete(batch.IndexOps, conflict.ID)
+			delete(batch.Versions, conflict.ID)
+		}
+		if err != nil {
+			return reaped, err
+		}
+		reaped += len(batch.IndexOps)
+		atomic.AddUint64(&udc.stats.reaped, uint64(len(batch.IndexOps)))
+		if found < reapBatchSize {
+			// pick up the documents other processes wrote or reaped
+			return reaped, udc.refreshExpired(time.Now())
+		}
+	}
+}
+
+// expiredBatch returns the batch deleting up to reapBatchSize documents
+// which expired at now, the expiry rows of documents which no longer
+// expire then and the number of expiry rows read
+func (udc *UpsideDownCouch) expiredBatch(now time.Time) (batch *index.Batch, stale []*ExpiryRow, found int, err error) {
+	batch = index.NewBatch()
+	kvreader, err := udc.store.Reader()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer func() {
+		if cerr := kvreader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	var expired []*ExpiryRow
+	it := kvreader.RangeIterator("x", NewExpiryRow(nil, 0).Key(), expiryRowEnd(now.UnixNano()))
+	for key, _, valid := it.Current(); valid && len(expired) < reapBatchSize; key, _, valid = it.Current() {
+		var row *ExpiryRow
+		row, err = NewExpiryRowK(append([]byte(nil), key...))
+		if err != nil {
+			_ = it.Close()
+			return nil, nil, 0, err
+		}
+		expired = append(expired, row)
+		it.Next()
+	}
+	err = it.Close()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	for _, row := range expired {
+		var backIndexRow *BackIndexRow
+		backIndexRow, err = backIndexRowForDoc(kvreader, row.doc)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if backIndexRow == nil || backIndexRow.expires != row.expires {
+			stale = append(stale, row)
+			continue
+		}
+		batch.DeleteVersion(string(row.doc), backIndexRow.version)
+	}
+	return batch, stale, len(expired), nil
+}
+
+// deleteStaleExpiries deletes expiry rows left behind by documents
+// which were deleted or given another expiry, there are no counters to
+// move for them
+func (udc *UpsideDownCouch) deleteStaleExpiries(stale []*ExpiryRow) (err error) {
+	if len(stale) == 0 {
+		return nil
+	}
+	udc.writeMutex.Lock()
+	defer udc.writeMutex.Unlock()
+
+	var kvwriter store.KVWriter
+	kvwriter, err = udc.store.Writer()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := kvwriter.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	wb := kvwriter.NewBatch()
+	defer func() {
+		_ = wb.Close()
+	}()
+	for _, row := range stale {
+		err = wb.Delete(row.Table(), row.Key())
+		if err != nil {
+			return
+		}
+	}
+	return kvwriter.ExecuteBatch(wb)
+}
+
+// expiredSet returns the documents which expired at now and were not
+// removed yet, searches skip them.  Only the expiry rows reached since
+// the set was last read are scanned, batches keep it up to date with
+// the expiry rows they write and the reaper reads it again from all
+// the rows.
+func (udc *UpsideDownCouch) expiredSet(now time.Time) (map[string]struct{}, error) {
+	udc.expiredMutex.Lock()
+	defer udc.expiredMutex.Unlock()
+
+	until := now.UnixNano()
+	if until <= udc.expiredUntil {
+		return udc.expired, nil
+	}
+	found, err := udc.readExpired(udc.expiredUntil+1, until)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) > 0 {
+		expired := make(map[string]struct{}, len(udc.expired)+len(found))
+		for id := range udc.expired {
+			expired[id] = struct{}{}
+		}
+		for id := range found {
+			expired[id] = struct{}{}
+		}
+		udc.expired = expired
+	}
+	udc.expiredUntil = until
+	return udc.expired, nil
+}
+
+// refreshExpired reads the expired set again from all the expiry rows
+func (udc *UpsideDownCouch) refreshExpired(now time.Time) error {
+	udc.expiredMutex.Lock()
+	defer udc.expiredMutex.Unlock()
+
+	until := now.UnixNano()
+	expired, err := udc.readExpired(0, until)
+	if err != nil {
+		return err
+	}
+	udc.expired = expired
+	udc.expiredUntil = until
+	return nil
+}
+
+// updateExpired applies the expiry rows a batch wrote and deleted to
+// the expired set, added rows only count when they expire before the
+// set was last read
+func (udc *UpsideDownCouch) updateExpired(added, deleted []*ExpiryRow) {
+	if len(added) == 0 && len(deleted) == 0 {
+		return
+	}
+	udc.expiredMutex.Lock()
+	defer udc.expiredMutex.Unlock()
+
+	var expired map[string]struct{}
+	change := func() {
+		if expired == nil {
+			expired = make(map[string]struct{}, len(udc.expired))
+			for id := range udc.expired {
+				expired[id] = struct{}{}
+			}
+		}
+	}
+	for _, row := range deleted {
+		if _, ok := udc.expired[string(row.doc)]; ok {
+			change()
+			delete(expired, string(row.doc))
+		}
+	}
+	for _, row := range added {
+		if row.expires <= udc.expiredUntil {
+			change()
+			expired[string(row.doc)] = struct{}{}
+		}
+	}
+	if expired != nil {
+		udc.expired = expired
+	}
+}
+
+func (udc *UpsideDownCouch) readExpired(from, until int64) (rv map[string]struct{}, err error) {
+	kvreader, err := udc.store.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := kvreader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	return expiredDocs(kvreader, from, until)
+}
+
+// expiredDocs returns the documents whose expiry rows expire from
+// and until, both included
+func expiredDocs(kvreader store.KVReader, from, until int64) (rv map[string]struct{}, err error) {
+	it := kvreader.RangeIterator("x", NewExpiryRow(nil, from).Key(), expiryRowEnd(until))
+	defer func() {
+		if cerr := it.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	for key, _, valid := it.Current(); valid; key, _, valid = it.Current() {
+		var row *ExpiryRow
+		row, err = NewExpiryRowK(key)
+		if err != nil {
+			return nil, err
+		}
+		if rv == nil {
+			rv = make(map[string]struct{})
+		}
+		rv[string(row.doc)] = struct{}{}
+		it.Next()
+	}
+	return rv, nil
+}
+
+// documentExpiries returns the expiry of the documents whose back
+// index rows are among the rows, by document id
+func documentExpiries(rowsAll ...[][]UpsideDownCouchRow) map[string]int64 {
+	rv := make(map[string]int64)
+	for _, all := range rowsAll {
+		for _, docRows := range all {
+			for _, row := range docRows {
+				if row, ok := row.(*BackIndexRow); ok && row.expires != 0 {
+					rv[string(row.doc)] = row.expires
+				}
+			}
+		}
+	}
+	return rv
+}
+
+// rowTTL returns how long the store may keep a row of a document which
+// expires.  Only the postings and stored fields expire with their
+// document, the counters are moved by the back index row and the
+// expiry row leads the reaper to it, both are kept until reaped.
+func rowTTL(row UpsideDownCouchRow, expiries map[string]int64, now time.Time) (time.Duration, bool) {
+	var doc []byte
+	switch row := row.(type) {
+	case *rows.TermFrequencyRow:
+		doc = row.Doc
+	case *StoredRow:
+		doc = row.doc
+	default:
+		return 0, false
+	}
+	expires, ok := expiries[string(doc)]
+	if !ok {
+		return 0, false
+	}
+	return time.Unix(0, expires).Sub(now), true
+}
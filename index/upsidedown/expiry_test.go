@@ -0,0 +1,392 @@
+package upsidedown
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/index/rows"
+	"github.com/wrble/flock/index/store"
+	"github.com/wrble/flock/index/store/memory"
+	"github.com/wrble/flock/registry"
+)
+
+const expiringMemoryName = "expiring_memory_test"
+
+func init() {
+	registry.RegisterKVStore(expiringMemoryName, func(mo store.MergeOperator, config map[string]interface{}) (store.KVStore, error) {
+		s, err := memory.New(mo, config)
+		if err != nil {
+			return nil, err
+		}
+		return &expiringStore{KVStore: s, ttls: make(map[string]map[string]time.Duration)}, nil
+	})
+}
+
+// expiringStore is a memory store whose batches take TTLs like the
+// stores which expire rows, it records the TTLs by table and key
+type expiringStore struct {
+	store.KVStore
+	ttls map[string]map[string]time.Duration
+}
+
+func (s *expiringStore) Writer() (store.KVWriter, error) {
+	w, err := s.KVStore.Writer()
+	if err != nil {
+		return nil, err
+	}
+	return &expiringWriter{KVWriter: w, store: s}, nil
+}
+
+type expiringWriter struct {
+	store.KVWriter
+	store *expiringStore
+}
+
+func (w *expiringWriter) NewBatch() store.KVBatch {
+	return &expiringBatch{KVBatch: w.KVWriter.NewBatch(), store: w.store}
+}
+
+func (w *expiringWriter) NewBatchEx(options store.KVBatchOptions) (store.KVBatch, error) {
+	return w.NewBatch(), nil
+}
+
+func (w *expiringWriter) ExecuteBatch(batch store.KVBatch) error {
+	return w.KVWriter.ExecuteBatch(batch.(*expiringBatch).KVBatch)
+}
+
+type expiringBatch struct {
+	store.KVBatch
+	store *expiringStore
+}
+
+func (b *expiringBatch) SetWithTTL(table string, row interface{}, ttl time.Duration) error {
+	if b.store.ttls[table] == nil {
+		b.store.ttls[table] = make(map[string]time.Duration)
+	}
+	b.store.ttls[table][string(row.(index.IndexRow).Key())] = ttl
+	return b.Set(table, row)
+}
+
+func expiryTestDoc(id, name string, expires time.Time) *document.Document {
+	doc := document.NewDocument(id)
+	doc.AddField(document.NewTextFieldWithIndexingOptions("name", []uint64{}, []byte(name), document.IndexField|document.StoreField))
+	doc.Expires = expires
+	return doc
+}
+
+func openExpiryTestIndex(t *testing.T, storeName string) *UpsideDownCouch {
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx, err := NewUpsideDownCouch(storeName, map[string]interface{}{"reap_interval": "0"}, analysisQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Open()
+	if err != nil {
+		t.Fatalf("error opening index: %v", err)
+	}
+	return idx.(*UpsideDownCouch)
+}
+
+// expectFound checks the documents a search for the term finds
+func expectFound(t *testing.T, idx *UpsideDownCouch, term string, expected ...string) {
+	indexReader, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := indexReader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	reader, err := indexReader.TermFieldReader([]byte(term), "name", true, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for tfd, err := reader.Next(nil); tfd != nil || err != nil; tfd, err = reader.Next(nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		found = append(found, string(tfd.ID))
+	}
+	err = reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("expected %v to be found for %s, got %v", expected, term, found)
+	}
+	for i := range found {
+		if found[i] != expected[i] {
+			t.Errorf("expected %v to be found for %s, got %v", expected, term, found)
+		}
+	}
+	for _, id := range expected {
+		doc, err := indexReader.Document(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc == nil {
+			t.Errorf("expected document %s", id)
+		}
+	}
+}
+
+func countRows(t *testing.T, idx *UpsideDownCouch, table string) int {
+	reader, err := idx.store.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	it := reader.PrefixIterator(table, []byte{})
+	defer func() {
+		_ = it.Close()
+	}()
+	count := 0
+	for _, _, valid := it.Current(); valid; _, _, valid = it.Current() {
+		count++
+		it.Next()
+	}
+	return count
+}
+
+func TestIndexExpiry(t *testing.T) {
+	idx := openExpiryTestIndex(t, memory.Name)
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	now := time.Now()
+	for _, doc := range []*document.Document{
+		expiryTestDoc("a", "x", now.Add(-time.Second)),
+		expiryTestDoc("b", "x", now.Add(time.Hour)),
+		expiryTestDoc("c", "x", time.Time{}),
+	} {
+		err := idx.Update(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the expired document is no longer found
+	expectFound(t, idx, "x", "b", "c")
+	indexReader, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := indexReader.Document("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc != nil {
+		t.Errorf("expected the expired document not to be found")
+	}
+	count, err := indexReader.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 documents, got %d", count)
+	}
+	doc, err = indexReader.Document("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Expires.UnixNano() != now.Add(time.Hour).UnixNano() {
+		t.Errorf("expected document b to expire at %v, got %v", now.Add(time.Hour), doc.Expires)
+	}
+	docIDs, err := indexReader.(*IndexReader).DocIDReaderOnly([]string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := docIDs.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(id) != "b" {
+		t.Errorf("expected document b, got %s", id)
+	}
+	err = docIDs.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = indexReader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := countRows(t, idx, "x"); rows != 2 {
+		t.Errorf("expected 2 expiry rows, got %d", rows)
+	}
+
+	// the reaper removes the rows and moves the counters like deletes
+	reaped, err := idx.ReapExpired(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reaped != 1 {
+		t.Errorf("expected 1 document reaped, got %d", reaped)
+	}
+	docCount, err := idx.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if docCount != 2 {
+		t.Errorf("expected 2 documents, got %d", docCount)
+	}
+	if rows := countRows(t, idx, "b"); rows != 2 {
+		t.Errorf("expected 2 back index rows, got %d", rows)
+	}
+	if rows := countRows(t, idx, "x"); rows != 1 {
+		t.Errorf("expected 1 expiry row, got %d", rows)
+	}
+	kvreader, err := idx.store.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fieldIndex, _ := idx.fieldCache.FieldNamed("name", false)
+	dictRow := rows.NewDictionaryRow([]byte("x"), uint16(fieldIndex), 0)
+	termCount, err := kvreader.GetCounter(dictRow.Table(), dictRow.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if termCount != 2 {
+		t.Errorf("expected the term in 2 documents, got %d", termCount)
+	}
+	err = kvreader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a new expiry replaces the expiry row, a partial update keeps it
+	err = idx.Update(expiryTestDoc("b", "x", now.Add(2*time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.UpdateFields(expiryTestDoc("b", "y", time.Time{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := countRows(t, idx, "x"); rows != 1 {
+		t.Errorf("expected 1 expiry row, got %d", rows)
+	}
+	reaped, err = idx.ReapExpired(now.Add(90 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reaped != 0 {
+		t.Errorf("expected nothing reaped, got %d", reaped)
+	}
+	expectFound(t, idx, "y", "b")
+
+	// indexed without an expiry, it no longer expires
+	err = idx.Update(expiryTestDoc("b", "x", time.Time{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := countRows(t, idx, "x"); rows != 0 {
+		t.Errorf("expected no expiry row, got %d", rows)
+	}
+	reaped, err = idx.ReapExpired(now.Add(3 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reaped != 0 {
+		t.Errorf("expected nothing reaped, got %d", reaped)
+	}
+	expectFound(t, idx, "x", "b", "c")
+}
+
+func TestIndexExpiredSetFollowsBatches(t *testing.T) {
+	idx := openExpiryTestIndex(t, memory.Name)
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	now := time.Now()
+	for _, doc := range []*document.Document{
+		expiryTestDoc("a", "x", now.Add(-time.Second)),
+		expiryTestDoc("b", "x", time.Time{}),
+	} {
+		err := idx.Update(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectFound(t, idx, "x", "b")
+
+	// the cached set drops the document once its expiry row goes
+	err := idx.Update(expiryTestDoc("a", "x", time.Time{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFound(t, idx, "x", "a", "b")
+
+	// and adds it back when it is written with an expiry already past
+	err = idx.Update(expiryTestDoc("a", "x", now.Add(-time.Second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFound(t, idx, "x", "b")
+
+	// the reaper reads the set again from the expiry rows
+	_, err = idx.ReapExpired(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.expired) != 0 {
+		t.Errorf("expected no expired documents left, got %v", idx.expired)
+	}
+	expectFound(t, idx, "x", "b")
+}
+
+func TestIndexExpiryStoreTTL(t *testing.T) {
+	idx := openExpiryTestIndex(t, expiringMemoryName)
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	expiring := idx.store.(*expiringStore)
+
+	err := idx.Update(expiryTestDoc("a", "x", time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Update(expiryTestDoc("b", "x", time.Time{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the postings and stored fields of the expiring document are
+	// left to expire to the store, the postings of its term and of the
+	// name of its field
+	expected := map[string]int{"t": 2, "s": 1}
+	for table, ttls := range expiring.ttls {
+		if _, ok := expected[table]; !ok {
+			t.Errorf("expected no TTL on table %s, got %v", table, ttls)
+		}
+		if len(ttls) != expected[table] {
+			t.Errorf("expected %d rows of table %s with a TTL, got %d", expected[table], table, len(ttls))
+		}
+		for key, ttl := range ttls {
+			if ttl <= 59*time.Minute || ttl > time.Hour {
+				t.Errorf("expected a TTL of an hour on %s %q, got %v", table, key, ttl)
+			}
+		}
+	}
+	if len(expiring.ttls) != 2 {
+		t.Errorf("expected TTLs on the postings and stored fields, got %v", expiring.ttls)
+	}
+}
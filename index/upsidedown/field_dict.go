@@ -61,7 +61,7 @@ func (r *UpsideDownCouchFieldDict) Next() (*index.DictEntry, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unexpected error parsing dictionary row key: %v", err)
 	}
-	err = r.dictRow.ParseDictionaryK(val)
+	err = r.dictRow.ParseDictionaryV(val)
 	if err != nil {
 		return nil, fmt.Errorf("unexpected error parsing dictionary row val: %v", err)
 	}
@@ -0,0 +1,77 @@
+package upsidedown
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/wrble/flock/analysis"
+	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/index"
+)
+
+// pathSeparator separates the names of the objects in a field name
+const pathSeparator = "."
+
+// documentFieldNames returns the names of the fields of the document, the
+// paths of the objects holding them and the paths of its nested
+// documents, along with the names already recorded for it, sorted
+func documentFieldNames(d *document.Document, recorded []string) []string {
+	names := make(map[string]struct{}, len(d.Fields)+len(recorded))
+	addPath := func(name string) {
+		for {
+			names[name] = struct{}{}
+			i := strings.LastIndex(name, pathSeparator)
+			if i < 0 {
+				return
+			}
+			name = name[:i]
+		}
+	}
+	for _, name := range recorded {
+		names[name] = struct{}{}
+	}
+	for _, field := range d.Fields {
+		addPath(field.Name())
+	}
+	for _, nested := range d.Nested {
+		if _, path, _, ok := document.ParseNestedID(nested.ID); ok {
+			addPath(path)
+		}
+	}
+
+	rv := make([]string, 0, len(names))
+	for name := range names {
+		rv = append(rv, name)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+// fieldNamesTokenFreqs returns the names as the terms of the
+// index.FieldNamesField field
+func fieldNamesTokenFreqs(names []string) analysis.TokenFrequencies {
+	tokens := make(analysis.TokenStream, len(names))
+	for i, name := range names {
+		tokens[i] = &analysis.Token{
+			Term:     []byte(name),
+			Position: i + 1,
+			Type:     analysis.Single,
+		}
+	}
+	return analysis.TokenFrequency(tokens, nil, false)
+}
+
+// recordedFieldNames returns the names recorded for the document
+// backIndexRow belongs to
+func (udc *UpsideDownCouch) recordedFieldNames(backIndexRow *BackIndexRow) []string {
+	fieldIndex, ok := udc.fieldCache.FieldNamed(index.FieldNamesField, false)
+	if !ok {
+		return nil
+	}
+	for _, entry := range backIndexRow.termsEntries {
+		if entry.GetField() == uint32(fieldIndex) {
+			return entry.Terms
+		}
+	}
+	return nil
+}
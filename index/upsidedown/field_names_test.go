@@ -0,0 +1,109 @@
+package upsidedown
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/index/store/memory"
+)
+
+// expectFieldNames checks the documents recorded to have each field
+func expectFieldNames(t *testing.T, idx *UpsideDownCouch, expected map[string][]string) {
+	indexReader, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := indexReader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for name, ids := range expected {
+		reader, err := indexReader.TermFieldReader([]byte(name), index.FieldNamesField, false, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found []string
+		for tfd, err := reader.Next(nil); tfd != nil || err != nil; tfd, err = reader.Next(nil) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			found = append(found, string(tfd.ID))
+		}
+		err = reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != len(ids) {
+			t.Errorf("expected %v to have %s, got %v", ids, name, found)
+			continue
+		}
+		for i := range found {
+			if found[i] != ids[i] {
+				t.Errorf("expected %v to have %s, got %v", ids, name, found)
+			}
+		}
+	}
+}
+
+func TestIndexFieldNames(t *testing.T) {
+	idx := openExpiryTestIndex(t, memory.Name)
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	doc := expiryTestDoc("a", "x", time.Time{})
+	doc.AddField(document.NewTextField("address.city", []uint64{}, []byte("paris")))
+	err := idx.Update(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Update(expiryTestDoc("b", "x", time.Time{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFieldNames(t, idx, map[string][]string{
+		"name":         {"a", "b"},
+		"address":      {"a"},
+		"address.city": {"a"},
+		"email":        nil,
+	})
+
+	// a partial update records its fields along with the others
+	partial := document.NewDocument("b")
+	partial.AddField(document.NewTextField("email", []uint64{}, []byte("b@example.com")))
+	err = idx.UpdateFields(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFieldNames(t, idx, map[string][]string{
+		"name":  {"a", "b"},
+		"email": {"b"},
+	})
+
+	// indexed again whole, only its fields are left
+	err = idx.Update(expiryTestDoc("a", "x", time.Time{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFieldNames(t, idx, map[string][]string{
+		"name":         {"a", "b"},
+		"address":      nil,
+		"address.city": nil,
+	})
+
+	// deleted, it has none
+	err = idx.Delete("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFieldNames(t, idx, map[string][]string{
+		"name": {"b"},
+	})
+}
@@ -15,6 +15,8 @@
 package upsidedown
 
 import (
+	"time"
+
 	"github.com/wrble/flock/document"
 	"github.com/wrble/flock/index"
 	"github.com/wrble/flock/index/store"
@@ -24,6 +26,18 @@ type IndexReader struct {
 	index    *UpsideDownCouch
 	kvreader store.KVReader
 	docCount uint64
+	// expired are the documents which expired when the reader was
+	// opened and were not reaped yet
+	expired map[string]struct{}
+}
+
+// isExpired reports whether the document expired, searches skip it
+func (i *IndexReader) isExpired(id []byte) bool {
+	if len(i.expired) == 0 {
+		return false
+	}
+	_, ok := i.expired[string(id)]
+	return ok
 }
 
 func (i *IndexReader) TermFieldReader(term []byte, fieldName string, includeFreq, includeNorm, includeTermVectors bool) (index.TermFieldReader, error) {
@@ -34,6 +48,65 @@ func (i *IndexReader) TermFieldReader(term []byte, fieldName string, includeFreq
 	return newUpsideDownCouchTermFieldReader(i, []byte{ByteSeparator}, ^uint16(0), includeFreq, includeNorm, includeTermVectors)
 }
 
+// ScoreOrderedTermFieldReader enumerates the documents containing the
+// term best first, when the store keeps its postings ordered by score
+func (i *IndexReader) ScoreOrderedTermFieldReader(term []byte, fieldName string, includeFreq, includeNorm, includeTermVectors bool) (index.TermFieldReader, error) {
+	if _, ok := i.kvreader.(store.KVScoreOrderedReader); !ok {
+		return nil, index.ErrorScoreOrderUnsupported
+	}
+	fieldIndex, fieldExists := i.index.fieldCache.FieldNamed(fieldName, false)
+	if fieldExists {
+		return newUpsideDownCouchScoreOrderedTermFieldReader(i, term, uint16(fieldIndex), includeFreq, includeNorm, includeTermVectors)
+	}
+	return newUpsideDownCouchScoreOrderedTermFieldReader(i, []byte{ByteSeparator}, ^uint16(0), includeFreq, includeNorm, includeTermVectors)
+}
+
+// FieldLengths returns the total length of the field and the number of
+// documents it was recorded for, documents indexed before field lengths
+// were kept are not included
+func (i *IndexReader) FieldLengths(fieldName string) (total, docs uint64, err error) {
+	fieldIndex, fieldExists := i.index.fieldCache.FieldNamed(fieldName, false)
+	if !fieldExists {
+		return 0, 0, nil
+	}
+	var count int64
+	count, err = i.kvreader.GetCounter(VersionTable, fieldCounterKey(FieldLengthKeyPrefix, uint16(fieldIndex)))
+	if err != nil || count <= 0 {
+		return 0, 0, err
+	}
+	total = uint64(count)
+	count, err = i.kvreader.GetCounter(VersionTable, fieldCounterKey(FieldDocsKeyPrefix, uint16(fieldIndex)))
+	if err != nil || count <= 0 {
+		return 0, 0, err
+	}
+	return total, uint64(count), nil
+}
+
+// MaxFieldBoost returns the highest boost the field was indexed with,
+// at least 1
+func (i *IndexReader) MaxFieldBoost(fieldName string) (float64, error) {
+	fieldIndex, fieldExists := i.index.fieldCache.FieldNamed(fieldName, false)
+	if !fieldExists {
+		return 1, nil
+	}
+	boost, err := fieldBoost(i.kvreader, uint16(fieldIndex))
+	return float64(boost), err
+}
+
+// DocumentVersion returns the version of the document, 0 when it is
+// not indexed
+func (i *IndexReader) DocumentVersion(id string) (uint64, error) {
+	backIndexRow, err := backIndexRowForDoc(i.kvreader, []byte(id))
+	if err != nil {
+		return 0, err
+	}
+	return documentVersion(backIndexRow), nil
+}
+
+func (i *IndexReader) DocIDReaderAll() (index.DocIDReader, error) {
+	return newUpsideDownCouchDocIDReader(i)
+}
+
 func (i *IndexReader) DocIDReaderOnly(ids []string) (index.DocIDReader, error) {
 	return newUpsideDownCouchDocIDReaderOnly(i, ids)
 }
@@ -45,10 +118,14 @@ func (i *IndexReader) Document(id string) (doc *document.Document, err error) {
 	if err != nil {
 		return
 	}
-	if backIndexRow == nil {
+	if backIndexRow == nil || i.isExpired([]byte(id)) {
 		return
 	}
 	doc = document.NewDocument(id)
+	doc.Version = backIndexRow.version
+	if backIndexRow.expires != 0 {
+		doc.Expires = time.Unix(0, backIndexRow.expires)
+	}
 	storedRow := NewStoredRow([]byte(id), 0, []uint64{}, 'x', nil)
 	storedRowScanPrefix := storedRow.ScanPrefixForDoc()
 	it := i.kvreader.PrefixIterator("s", storedRowScanPrefix)
@@ -67,7 +144,13 @@ func (i *IndexReader) Document(id string) (doc *document.Document, err error) {
 			doc = nil
 			return
 		}
-		if row != nil {
+		if row != nil && row.typ == sourceFieldType {
+			doc.Source, err = decodeSource(row)
+			if err != nil {
+				doc = nil
+				return
+			}
+		} else if row != nil {
 			fieldName := i.index.fieldCache.FieldIndexed(row.field)
 			field := decodeFieldType(row.typ, fieldName, row.arrayPositions, row.value)
 			if field != nil {
@@ -0,0 +1,51 @@
+package upsidedown
+
+import (
+	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/index/store"
+)
+
+// withNested returns the index ops with the nested documents of the
+// documents indexed added, they expire along with their document
+func withNested(ops map[string]*document.Document) map[string]*document.Document {
+	rv := ops
+	for _, doc := range ops {
+		if doc == nil || len(doc.Nested) == 0 {
+			continue
+		}
+		if len(rv) == len(ops) {
+			rv = make(map[string]*document.Document, len(ops))
+			for id, doc := range ops {
+				rv[id] = doc
+			}
+		}
+		for _, nested := range doc.Nested {
+			nested.Expires = doc.Expires
+			rv[nested.ID] = nested
+		}
+	}
+	return rv
+}
+
+// staleNestedRows reads the back index rows of the nested documents
+// the document of the row had, but for those the ops write or delete
+func staleNestedRows(kvreader store.KVReader, backIndexRow *BackIndexRow, ops map[string]*document.Document) ([]*BackIndexRow, error) {
+	if backIndexRow == nil {
+		return nil, nil
+	}
+	var rv []*BackIndexRow
+	for _, id := range backIndexRow.nested {
+		if _, ok := ops[id]; ok {
+			continue
+		}
+		row, err := backIndexRowForDoc(kvreader, index.IndexInternalID(id))
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			rv = append(rv, row)
+		}
+	}
+	return rv, nil
+}
@@ -16,6 +16,7 @@ package upsidedown
 
 import (
 	"bytes"
+	"fmt"
 	"sort"
 	"sync/atomic"
 
@@ -34,9 +35,21 @@ type UpsideDownCouchTermFieldReader struct {
 	keyBuf             []byte
 	field              uint16
 	includeTermVectors bool
+	scoreOrdered       bool
 }
 
 func newUpsideDownCouchTermFieldReader(indexReader *IndexReader, term []byte, field uint16, includeFreq, includeNorm, includeTermVectors bool) (*UpsideDownCouchTermFieldReader, error) {
+	return newTermFieldReader(indexReader, term, field, includeTermVectors, false)
+}
+
+// newUpsideDownCouchScoreOrderedTermFieldReader returns a reader
+// walking the postings by descending pre score, the kvreader of
+// indexReader must be a store.KVScoreOrderedReader
+func newUpsideDownCouchScoreOrderedTermFieldReader(indexReader *IndexReader, term []byte, field uint16, includeFreq, includeNorm, includeTermVectors bool) (*UpsideDownCouchTermFieldReader, error) {
+	return newTermFieldReader(indexReader, term, field, includeTermVectors, true)
+}
+
+func newTermFieldReader(indexReader *IndexReader, term []byte, field uint16, includeTermVectors, scoreOrdered bool) (*UpsideDownCouchTermFieldReader, error) {
 	bufNeeded := rows.TermFrequencyRowKeySize(term, nil)
 	if bufNeeded < rows.DictionaryRowKeySize(term) {
 		bufNeeded = rows.DictionaryRowKeySize(term)
@@ -54,6 +67,7 @@ func newUpsideDownCouchTermFieldReader(indexReader *IndexReader, term []byte, fi
 			term:               term,
 			field:              field,
 			includeTermVectors: includeTermVectors,
+			scoreOrdered:       scoreOrdered,
 		}
 		rv.tfrNext = &rv.tfrPrealloc
 		return rv, nil
@@ -61,7 +75,12 @@ func newUpsideDownCouchTermFieldReader(indexReader *IndexReader, term []byte, fi
 
 	buf := make([]byte, bufNeeded)
 	bufUsed := rows.TermFrequencyRowKeyTo(buf, field, term, nil)
-	it := indexReader.kvreader.TypedPrefixIterator("t", buf[:bufUsed])
+	var it store.TypedKVIterator
+	if scoreOrdered {
+		it = indexReader.kvreader.(store.KVScoreOrderedReader).ScoreOrderedIterator("t", buf[:bufUsed])
+	} else {
+		it = indexReader.kvreader.TypedPrefixIterator("t", buf[:bufUsed])
+	}
 
 	atomic.AddUint64(&indexReader.index.stats.termSearchersStarted, uint64(1))
 	return &UpsideDownCouchTermFieldReader{
@@ -71,6 +90,7 @@ func newUpsideDownCouchTermFieldReader(indexReader *IndexReader, term []byte, fi
 		term:               term,
 		field:              field,
 		includeTermVectors: includeTermVectors,
+		scoreOrdered:       scoreOrdered,
 	}, nil
 }
 
@@ -89,11 +109,16 @@ func (r *UpsideDownCouchTermFieldReader) Next(preAlloced *index.TermFieldDoc) (*
 			r.tfrNext = &r.tfrPrealloc
 		}
 		_, val, valid := r.iterator.Current()
-		if valid {
+		for valid {
 			currentRow, err := rows.NewTermFrequencyRowFromMap(val)
 			if err != nil {
 				return nil, err
 			}
+			if r.indexReader.isExpired(currentRow.Doc) {
+				r.iterator.Next()
+				_, val, valid = r.iterator.Current()
+				continue
+			}
 			rv := preAlloced
 			if rv == nil {
 				rv = &index.TermFieldDoc{}
@@ -101,6 +126,8 @@ func (r *UpsideDownCouchTermFieldReader) Next(preAlloced *index.TermFieldDoc) (*
 			rv.ID = append(rv.ID, currentRow.Doc...)
 			rv.Freq = currentRow.Freq
 			rv.Score = currentRow.Score
+			rv.FieldLength = currentRow.FieldLength
+			rv.Boost = currentRow.Boost
 			if currentRow.Vectors != nil {
 				rv.Vectors = r.indexReader.index.termFieldVectorsFromTermVectors(currentRow.Vectors)
 			}
@@ -111,6 +138,9 @@ func (r *UpsideDownCouchTermFieldReader) Next(preAlloced *index.TermFieldDoc) (*
 }
 
 func (r *UpsideDownCouchTermFieldReader) Advance(docID index.IndexInternalID, preAlloced *index.TermFieldDoc) (rv *index.TermFieldDoc, err error) {
+	if r.scoreOrdered {
+		return nil, fmt.Errorf("advance is not supported on score ordered term field readers")
+	}
 	if r.iterator != nil {
 		if r.tfrNext == nil {
 			r.tfrNext = &rows.TermFrequencyRow{}
@@ -118,18 +148,25 @@ func (r *UpsideDownCouchTermFieldReader) Advance(docID index.IndexInternalID, pr
 		tfr := rows.InitTermFrequencyRow(r.tfrNext, r.term, r.field, docID, 0, 0)
 		r.iterator.Seek(tfr.Key())
 		_, val, valid := r.iterator.Current()
-		if valid {
+		for valid {
 			currentRow, err := rows.NewTermFrequencyRowFromMap(val)
 			if err != nil {
 				return nil, err
 			}
+			if r.indexReader.isExpired(currentRow.Doc) {
+				r.iterator.Next()
+				_, val, valid = r.iterator.Current()
+				continue
+			}
 			rv = preAlloced
 			if rv == nil {
 				rv = &index.TermFieldDoc{}
 			}
-			rv.ID = append(rv.ID, tfr.Doc...)
+			rv.ID = append(rv.ID, currentRow.Doc...)
 			rv.Freq = currentRow.Freq
 			rv.Score = currentRow.Score
+			rv.FieldLength = currentRow.FieldLength
+			rv.Boost = currentRow.Boost
 			if currentRow.Vectors != nil {
 				rv.Vectors = r.indexReader.index.termFieldVectorsFromTermVectors(currentRow.Vectors)
 			}
@@ -157,6 +194,17 @@ type UpsideDownCouchDocIDReader struct {
 	onlyMode    bool
 }
 
+func newUpsideDownCouchDocIDReader(indexReader *IndexReader) (*UpsideDownCouchDocIDReader, error) {
+	bisr := NewBackIndexRow([]byte{0x0}, nil, nil)
+	bier := NewBackIndexRow([]byte{0xff}, nil, nil)
+	// only the top level documents, nested ones are kept in "n"
+	it := indexReader.kvreader.RangeIterator("b", bisr.Key(), bier.Key())
+	return &UpsideDownCouchDocIDReader{
+		indexReader: indexReader,
+		iterator:    it,
+	}, nil
+}
+
 func newUpsideDownCouchDocIDReaderOnly(indexReader *IndexReader, ids []string) (*UpsideDownCouchDocIDReader, error) {
 	// we don't actually own the list of ids, so if before we sort we must copy
 	idsCopy := make([]string, len(ids))
@@ -173,7 +221,8 @@ func newUpsideDownCouchDocIDReaderOnly(indexReader *IndexReader, ids []string) (
 	}
 	bisr := NewBackIndexRow(startBytes, nil, nil)
 	bier := NewBackIndexRow(endBytes, nil, nil)
-	it := indexReader.kvreader.RangeIterator(bisr.Table(), bisr.Key(), bier.Key())
+	// only the top level documents, nested ones are kept in "n"
+	it := indexReader.kvreader.RangeIterator("b", bisr.Key(), bier.Key())
 
 	return &UpsideDownCouchDocIDReader{
 		indexReader: indexReader,
@@ -184,6 +233,15 @@ func newUpsideDownCouchDocIDReaderOnly(indexReader *IndexReader, ids []string) (
 }
 
 func (r *UpsideDownCouchDocIDReader) Next() (index.IndexInternalID, error) {
+	for {
+		rv, err := r.next()
+		if err != nil || rv == nil || !r.indexReader.isExpired(rv) {
+			return rv, err
+		}
+	}
+}
+
+func (r *UpsideDownCouchDocIDReader) next() (index.IndexInternalID, error) {
 	key, val, valid := r.iterator.Current()
 
 	if r.onlyMode {
@@ -229,6 +287,14 @@ func (r *UpsideDownCouchDocIDReader) Next() (index.IndexInternalID, error) {
 }
 
 func (r *UpsideDownCouchDocIDReader) Advance(docID index.IndexInternalID) (index.IndexInternalID, error) {
+	rv, err := r.advance(docID)
+	if err != nil || rv == nil || !r.indexReader.isExpired(rv) {
+		return rv, err
+	}
+	return r.Next()
+}
+
+func (r *UpsideDownCouchDocIDReader) advance(docID index.IndexInternalID) (index.IndexInternalID, error) {
 
 	if r.onlyMode {
 		r.onlyPos = sort.SearchStrings(r.only, string(docID))
@@ -253,6 +253,59 @@ func TestIndexDocIdReader(t *testing.T) {
 			t.Error(err)
 		}
 	}()
+
+	// first get all doc ids
+	reader, err := indexReader.(index.DocIDIndexReader).DocIDReaderAll()
+	if err != nil {
+		t.Errorf("Error accessing doc id reader: %v", err)
+	}
+	defer func() {
+		err := reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	id, err := reader.Next()
+	count := uint64(0)
+	for id != nil {
+		count++
+		id, err = reader.Next()
+	}
+	if err != nil {
+		t.Error(err)
+	}
+	if count != expectedCount {
+		t.Errorf("expected %d, got %d", expectedCount, count)
+	}
+
+	// try it again, but jump to the second doc this time
+	reader2, err := indexReader.(index.DocIDIndexReader).DocIDReaderAll()
+	if err != nil {
+		t.Errorf("Error accessing doc id reader: %v", err)
+	}
+	defer func() {
+		err := reader2.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	id, err = reader2.Advance(index.IndexInternalID("2"))
+	if err != nil {
+		t.Error(err)
+	}
+	if !id.Equals(index.IndexInternalID("2")) {
+		t.Errorf("expected to find id '2', got '%s'", id)
+	}
+
+	id, err = reader2.Advance(index.IndexInternalID("3"))
+	if err != nil {
+		t.Error(err)
+	}
+	if id != nil {
+		t.Errorf("expected to find id '', got '%s'", id)
+	}
 }
 
 func TestCrashBadBackIndexRow(t *testing.T) {
@@ -19,8 +19,10 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/wrble/flock/document"
 	"github.com/wrble/flock/index/rows"
 )
 
@@ -190,16 +192,90 @@ func NewFieldRowKV(key, value []byte) (*FieldRow, error) {
 	return &rv, nil
 }
 
+// FIELD BOOST
+
+// FieldBoostRow is the highest index time boost of a field
+type FieldBoostRow struct {
+	field uint16
+	boost float32
+}
+
+func (v *FieldBoostRow) Table() string {
+	return VersionTable
+}
+
+func (f *FieldBoostRow) Key() []byte {
+	return fieldCounterKey(FieldBoostKeyPrefix, f.field)
+}
+
+func (f *FieldBoostRow) Value() []byte {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, math.Float32bits(f.boost))
+	return buf
+}
+
+func (f *FieldBoostRow) ValueSize() int {
+	return 4
+}
+
+func (f *FieldBoostRow) ValueTo(buf []byte) (int, error) {
+	binary.LittleEndian.PutUint32(buf, math.Float32bits(f.boost))
+	return 4, nil
+}
+
+func (f *FieldBoostRow) String() string {
+	return fmt.Sprintf("Field: %d Boost: %f", f.field, f.boost)
+}
+
+func NewFieldBoostRow(field uint16, boost float32) *FieldBoostRow {
+	return &FieldBoostRow{
+		field: field,
+		boost: boost,
+	}
+}
+
+func NewFieldBoostRowKV(key, value []byte) (*FieldBoostRow, error) {
+	if len(key) != len(FieldBoostKeyPrefix)+2 || len(value) != 4 {
+		return nil, fmt.Errorf("invalid field boost row, key % x value % x", key, value)
+	}
+	return &FieldBoostRow{
+		field: binary.LittleEndian.Uint16(key[len(FieldBoostKeyPrefix):]),
+		boost: math.Float32frombits(binary.LittleEndian.Uint32(value)),
+	}, nil
+}
+
 type BackIndexRow struct {
 	doc           []byte
 	termsEntries  []*BackIndexTermsEntry
 	storedEntries []*BackIndexStoreEntry
-}
-
+	// boost is the boost of the document, 0 when it has none
+	boost float32
+	// version counts the writes of the document, 0 when it was indexed
+	// before versions were kept
+	version uint64
+	// expires is when the document expires in unix nanoseconds, 0 when
+	// it does not
+	expires int64
+	// nested are the ids of the nested documents of the document
+	nested []string
+	// stored is the value the row was read from, nil for a new row
+	stored []byte
+}
+
+// Table returns "b", or "n" for nested documents so the back index
+// rows of the top level documents can be counted and listed alone
 func (v *BackIndexRow) Table() string {
+	if v.isNested() {
+		return "n"
+	}
 	return "b"
 }
 
+// isNested returns whether the row is that of a nested document
+func (br *BackIndexRow) isNested() bool {
+	return bytes.IndexByte(br.doc, document.NestedSeparator) >= 0
+}
+
 func (br *BackIndexRow) AllTermKeys() [][]byte {
 	if br == nil {
 		return nil
@@ -237,23 +313,55 @@ func (br *BackIndexRow) Value() []byte {
 }
 
 func (br *BackIndexRow) ValueSize() int {
-	birv := &BackIndexRowValue{
-		TermsEntries:  br.termsEntries,
-		StoredEntries: br.storedEntries,
-	}
-	return birv.Size()
+	return br.value().Size()
 }
 
 func (br *BackIndexRow) ValueTo(buf []byte) (int, error) {
+	return br.value().MarshalTo(buf)
+}
+
+func (br *BackIndexRow) value() *BackIndexRowValue {
 	birv := &BackIndexRowValue{
 		TermsEntries:  br.termsEntries,
 		StoredEntries: br.storedEntries,
 	}
-	return birv.MarshalTo(buf)
+	if br.boost > 0 {
+		birv.Boost = proto.Float32(br.boost)
+	}
+	if br.version > 0 {
+		birv.Version = proto.Uint64(br.version)
+	}
+	if br.expires != 0 {
+		birv.Expires = proto.Int64(br.expires)
+	}
+	birv.Nested = br.nested
+	return birv
+}
+
+// docBoost returns the boost of the document, 1 when it has none
+func (br *BackIndexRow) docBoost() float32 {
+	if br.boost > 0 {
+		return br.boost
+	}
+	return 1
 }
 
 func (br *BackIndexRow) String() string {
-	return fmt.Sprintf("Backindex DocId: `%s` Terms Entries: %v, Stored Entries: %v", string(br.doc), br.termsEntries, br.storedEntries)
+	return fmt.Sprintf("Backindex DocId: `%s` Terms Entries: %v, Stored Entries: %v, Boost: %f, Version: %d, Expires: %d, Nested: %q", string(br.doc), br.termsEntries, br.storedEntries, br.boost, br.version, br.expires, br.nested)
+}
+
+// addFieldLengths adds the field lengths recorded in the row to the
+// deltas, sign is 1 when the row is written and -1 when it is removed
+func (br *BackIndexRow) addFieldLengths(sign int64, lengths, docs map[uint16]int64) {
+	for _, termsEntry := range br.termsEntries {
+		if termsEntry.FieldLength == nil {
+			// indexed before field lengths were kept
+			continue
+		}
+		field := uint16(termsEntry.GetField())
+		lengths[field] += sign * int64(termsEntry.GetFieldLength())
+		docs[field] += sign
+	}
 }
 
 func NewBackIndexRow(docID []byte, entries []*BackIndexTermsEntry, storedFields []*BackIndexStoreEntry) *BackIndexRow {
@@ -286,10 +394,74 @@ func NewBackIndexRowKV(key, value []byte) (*BackIndexRow, error) {
 	}
 	rv.termsEntries = birv.TermsEntries
 	rv.storedEntries = birv.StoredEntries
+	rv.boost = birv.GetBoost()
+	rv.version = birv.GetVersion()
+	rv.expires = birv.GetExpires()
+	rv.nested = birv.GetNested()
+	rv.stored = value
 
 	return &rv, nil
 }
 
+// EXPIRY
+
+// ExpiryRow marks a document which expires, its key starts with the
+// expiry in big endian unix nanoseconds so the rows are ordered by it
+type ExpiryRow struct {
+	expires int64
+	doc     []byte
+}
+
+func (e *ExpiryRow) Table() string {
+	return "x"
+}
+
+func (e *ExpiryRow) Key() []byte {
+	buf := make([]byte, 8+len(e.doc))
+	binary.BigEndian.PutUint64(buf, uint64(e.expires))
+	copy(buf[8:], e.doc)
+	return buf
+}
+
+func (e *ExpiryRow) Value() []byte {
+	return []byte{}
+}
+
+func (e *ExpiryRow) ValueSize() int {
+	return 0
+}
+
+func (e *ExpiryRow) ValueTo(buf []byte) (int, error) {
+	return 0, nil
+}
+
+func (e *ExpiryRow) String() string {
+	return fmt.Sprintf("Expiry DocId: `%s` Expires: %d", string(e.doc), e.expires)
+}
+
+func NewExpiryRow(docID []byte, expires int64) *ExpiryRow {
+	return &ExpiryRow{
+		expires: expires,
+		doc:     docID,
+	}
+}
+
+func NewExpiryRowK(key []byte) (*ExpiryRow, error) {
+	if len(key) < 9 {
+		return nil, fmt.Errorf("invalid expiry row key % x", key)
+	}
+	return &ExpiryRow{
+		expires: int64(binary.BigEndian.Uint64(key)),
+		doc:     key[8:],
+	}, nil
+}
+
+// expiryRowEnd returns the key every expiry row of the documents which
+// expired at or before expires sorts before
+func expiryRowEnd(expires int64) []byte {
+	return NewExpiryRow(nil, expires+1).Key()
+}
+
 // STORED
 
 type StoredRow struct {
@@ -91,6 +91,21 @@ func TestRows(t *testing.T) {
 			[]byte{'b', 'b', 'u', 'd', 'w', 'e', 'i', 's', 'e', 'r'},
 			[]byte{10, 8, 8, 0, 18, 4, 'b', 'e', 'e', 'r', 10, 8, 8, 1, 18, 4, 'b', 'e', 'a', 't', 18, 2, 8, 3, 18, 2, 8, 4, 18, 2, 8, 5},
 		},
+		{
+			&BackIndexRow{doc: []byte("budweiser"), termsEntries: []*BackIndexTermsEntry{{Field: proto.Uint32(0), Terms: []string{"beer"}}}, version: 300},
+			[]byte{'b', 'b', 'u', 'd', 'w', 'e', 'i', 's', 'e', 'r'},
+			[]byte{10, 8, 8, 0, 18, 4, 'b', 'e', 'e', 'r', 32, 172, 2},
+		},
+		{
+			&BackIndexRow{doc: []byte("budweiser"), termsEntries: []*BackIndexTermsEntry{{Field: proto.Uint32(0), Terms: []string{"beer"}}}, expires: 300},
+			[]byte{'b', 'b', 'u', 'd', 'w', 'e', 'i', 's', 'e', 'r'},
+			[]byte{10, 8, 8, 0, 18, 4, 'b', 'e', 'e', 'r', 40, 172, 2},
+		},
+		{
+			NewExpiryRow([]byte("budweiser"), 300),
+			[]byte{'x', 0, 0, 0, 0, 0, 0, 1, 44, 'b', 'u', 'd', 'w', 'e', 'i', 's', 'e', 'r'},
+			[]byte{},
+		},
 		{
 			NewStoredRow([]byte("budweiser"), 0, []uint64{}, byte('t'), []byte("an american beer")),
 			[]byte{'s', 'b', 'u', 'd', 'w', 'e', 'i', 's', 'e', 'r', ByteSeparator, 0, 0},
@@ -185,7 +200,7 @@ func BenchmarkTermFrequencyRowEncode(b *testing.B) {
 }
 
 func BenchmarkTermFrequencyRowDecode(b *testing.B) {
-	k := []byte{'t', 0, 0, 'b', 'e', 'e', 'r', ByteSeparator, 'b', 'u', 'd', 'w', 'e', 'i', 's', 'e', 'r'}
+	k := []byte{0, 0, 'b', 'e', 'e', 'r', ByteSeparator, 'b', 'u', 'd', 'w', 'e', 'i', 's', 'e', 'r'}
 	v := []byte{3, 195, 235, 163, 130, 4, 0, 1, 3, 11, 0, 0, 2, 23, 31, 0, 0, 3, 43, 51, 0}
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
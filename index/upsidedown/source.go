@@ -0,0 +1,73 @@
+package upsidedown
+
+import (
+	"encoding/json"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/index/store"
+)
+
+// sourceFieldName is the field the source of a document is stored in,
+// its stored row is of type sourceFieldType and holds the source
+// compressed with snappy
+const sourceFieldName = "_source"
+
+const sourceFieldType = 'j'
+
+func (udc *UpsideDownCouch) storeSource(docID []byte, source json.RawMessage, rows []index.IndexRow, backIndexStoredEntries []*BackIndexStoreEntry) ([]index.IndexRow, []*BackIndexStoreEntry) {
+	fieldIndex, newFieldRow := udc.fieldIndexOrNewRow(sourceFieldName)
+	if newFieldRow != nil {
+		rows = append(rows, newFieldRow)
+	}
+	storedRow := NewStoredRow(docID, fieldIndex, []uint64{}, sourceFieldType, snappy.Encode(nil, source))
+
+	// record the back index entry
+	backIndexStoredEntry := BackIndexStoreEntry{Field: proto.Uint32(uint32(fieldIndex)), ArrayPositions: []uint64{}}
+
+	return append(rows, storedRow), append(backIndexStoredEntries, &backIndexStoredEntry)
+}
+
+func decodeSource(row *StoredRow) (json.RawMessage, error) {
+	return snappy.Decode(nil, row.value)
+}
+
+// storedSource reads the source of the document backIndexRow belongs
+// to, nil if it keeps none
+func (udc *UpsideDownCouch) storedSource(kvreader store.KVReader, backIndexRow *BackIndexRow) (json.RawMessage, error) {
+	fieldIndex, ok := udc.fieldCache.FieldNamed(sourceFieldName, false)
+	if !ok {
+		return nil, nil
+	}
+	for _, entry := range backIndexRow.storedEntries {
+		if entry.GetField() != uint32(fieldIndex) || len(entry.ArrayPositions) > 0 {
+			continue
+		}
+		key := NewStoredRow(backIndexRow.doc, fieldIndex, []uint64{}, sourceFieldType, nil).Key()
+		value, err := kvreader.Get("s", key)
+		if err != nil || len(value) == 0 {
+			return nil, err
+		}
+		row, err := NewStoredRowKV(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if row.typ == sourceFieldType {
+			return decodeSource(row)
+		}
+	}
+	return nil, nil
+}
+
+// mergedSource returns the source of the document backIndexRow belongs
+// to with the source of a partial update merged in, nil if it keeps no
+// source
+func (udc *UpsideDownCouch) mergedSource(kvreader store.KVReader, backIndexRow *BackIndexRow, partial json.RawMessage) (json.RawMessage, error) {
+	source, err := udc.storedSource(kvreader, backIndexRow)
+	if err != nil || source == nil {
+		return nil, err
+	}
+	return document.MergeSource(source, partial)
+}
@@ -27,6 +27,7 @@ type indexStat struct {
 	termSearchersStarted              uint64
 	termSearchersFinished             uint64
 	numPlainTextBytesIndexed          uint64
+	reaped                            uint64
 	i                                 *UpsideDownCouch
 }
 
@@ -41,6 +42,7 @@ func (i *indexStat) statsMap() map[string]interface{} {
 	m["term_searchers_started"] = atomic.LoadUint64(&i.termSearchersStarted)
 	m["term_searchers_finished"] = atomic.LoadUint64(&i.termSearchersFinished)
 	m["num_plain_text_bytes_indexed"] = atomic.LoadUint64(&i.numPlainTextBytesIndexed)
+	m["reaped"] = atomic.LoadUint64(&i.reaped)
 
 	if o, ok := i.i.store.(store.KVStoreStats); ok {
 		m["kv"] = o.StatsMap()
@@ -0,0 +1,266 @@
+package upsidedown
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/index/rows"
+	"github.com/wrble/flock/index/store"
+)
+
+// UpdateFields replaces the fields of an indexed document named like
+// the fields of doc, the other fields are kept as they are.  Only the
+// fields of doc are analyzed.  A boost set on doc replaces the boost of
+// the document, the postings of the fields kept are then rewritten
+// with it, nothing else of them changes.  Composite fields are not
+// updated, they keep the terms of the fields as last indexed whole, and
+// the document keeps its expiry and nested documents, which cannot be
+// updated partially.  The source of doc is merged into the source of
+// the document, if it keeps one.
+func (udc *UpsideDownCouch) UpdateFields(doc *document.Document) (err error) {
+	udc.writeMutex.Lock()
+	defer udc.writeMutex.Unlock()
+
+	// open a reader for the backindex and the postings kept
+	var kvreader store.KVReader
+	kvreader, err = udc.store.Reader()
+	if err != nil {
+		return
+	}
+
+	var backIndexRow *BackIndexRow
+	backIndexRow, err = backIndexRowForDoc(kvreader, index.IndexInternalID(doc.ID))
+	if err != nil {
+		_ = kvreader.Close()
+		atomic.AddUint64(&udc.stats.errors, 1)
+		return
+	}
+	// a document deleted since it was read is a version conflict
+	err = checkVersion(doc.ID, backIndexRow, doc.Version)
+	if err != nil {
+		_ = kvreader.Close()
+		atomic.AddUint64(&udc.stats.errors, 1)
+		return
+	}
+	if backIndexRow == nil {
+		_ = kvreader.Close()
+		return index.ErrorDocumentNotFound
+	}
+
+	analysisStart := time.Now()
+	addRows, updateRows, deleteRows, err := udc.mergeFields(kvreader, backIndexRow, doc)
+	atomic.AddUint64(&udc.stats.analysisTime, uint64(time.Since(analysisStart)))
+	if err != nil {
+		_ = kvreader.Close()
+		atomic.AddUint64(&udc.stats.errors, 1)
+		return
+	}
+
+	err = kvreader.Close()
+	if err != nil {
+		return
+	}
+
+	// start a writer for this update
+	indexStart := time.Now()
+	var kvwriter store.KVWriter
+	kvwriter, err = udc.store.Writer()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := kvwriter.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	err = udc.batchRows(kvwriter, [][]UpsideDownCouchRow{addRows}, [][]UpsideDownCouchRow{updateRows}, [][]UpsideDownCouchRow{deleteRows}, []*BackIndexRow{backIndexRow})
+	atomic.AddUint64(&udc.stats.indexTime, uint64(time.Since(indexStart)))
+	if err == nil {
+		atomic.AddUint64(&udc.stats.updates, 1)
+		atomic.AddUint64(&udc.stats.numPlainTextBytesIndexed, doc.NumPlainTextBytes())
+	} else {
+		atomic.AddUint64(&udc.stats.errors, 1)
+	}
+	return
+}
+
+// batchUpdateFields appends the rows of the partial updates of the
+// batch, every document updated must be indexed
+func (udc *UpsideDownCouch) batchUpdateFields(batch *index.Batch, addRowsAll, updateRowsAll, deleteRowsAll *[][]UpsideDownCouchRow, replacedBackIndexRows *[]*BackIndexRow) (err error) {
+	var kvreader store.KVReader
+	kvreader, err = udc.store.Reader()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := kvreader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	for docID, doc := range batch.UpdateFieldsOps {
+		if _, ok := batch.IndexOps[docID]; ok {
+			// the batch replaces or deletes the whole document
+			continue
+		}
+		var backIndexRow *BackIndexRow
+		backIndexRow, err = backIndexRowForDoc(kvreader, index.IndexInternalID(docID))
+		if err != nil {
+			return
+		}
+		err = checkVersion(docID, backIndexRow, batch.Versions[docID])
+		if err != nil {
+			return
+		}
+		if backIndexRow == nil {
+			return index.ErrorDocumentNotFound
+		}
+		addRows, updateRows, deleteRows, err := udc.mergeFields(kvreader, backIndexRow, doc)
+		if err != nil {
+			return err
+		}
+		if len(addRows) > 0 {
+			*addRowsAll = append(*addRowsAll, addRows)
+		}
+		if len(updateRows) > 0 {
+			*updateRowsAll = append(*updateRowsAll, updateRows)
+		}
+		if len(deleteRows) > 0 {
+			*deleteRowsAll = append(*deleteRowsAll, deleteRows)
+		}
+		*replacedBackIndexRows = append(*replacedBackIndexRows, backIndexRow)
+	}
+	return nil
+}
+
+// mergeFields analyzes the fields of partial and returns the rows
+// replacing the fields of the same name of the document backIndexRow
+// belongs to, the back index row updated is one of the update rows
+func (udc *UpsideDownCouch) mergeFields(kvreader store.KVReader, backIndexRow *BackIndexRow, partial *document.Document) (addRows []UpsideDownCouchRow, updateRows []UpsideDownCouchRow, deleteRows []UpsideDownCouchRow, err error) {
+	if len(partial.Nested) > 0 {
+		return nil, nil, nil, fmt.Errorf("nested documents of '%s' cannot be updated partially", partial.ID)
+	}
+
+	oldBoost := backIndexRow.docBoost()
+	newBoost := oldBoost
+	if partial.Boost > 0 {
+		newBoost = float32(partial.Boost)
+	}
+
+	// the fields are analyzed with the boost of the document
+	doc := *partial
+	doc.Boost = float64(newBoost)
+	doc.CompositeFields = nil
+	doc.Expires = time.Time{}
+	// the fields are merged into the source of the document, if it
+	// keeps one
+	doc.Source = nil
+	if len(partial.Source) > 0 {
+		doc.Source, err = udc.mergedSource(kvreader, backIndexRow, partial.Source)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	// the names of the fields are recorded again with the new ones
+	result := udc.analyze(&doc, udc.recordedFieldNames(backIndexRow))
+
+	changed := make(map[uint32]bool, len(doc.Fields)+2)
+	for _, field := range doc.Fields {
+		fieldIndex, _ := udc.fieldCache.FieldNamed(field.Name(), false)
+		changed[uint32(fieldIndex)] = true
+	}
+	if len(doc.Source) > 0 {
+		fieldIndex, _ := udc.fieldCache.FieldNamed(sourceFieldName, false)
+		changed[uint32(fieldIndex)] = true
+	}
+	if fieldIndex, ok := udc.fieldCache.FieldNamed(index.FieldNamesField, false); ok {
+		changed[uint32(fieldIndex)] = true
+	}
+
+	// split the entries of the back index between the fields replaced
+	// and the fields kept
+	replaced := NewBackIndexRow(backIndexRow.doc, nil, nil)
+	merged := NewBackIndexRow(backIndexRow.doc, nil, nil)
+	merged.boost = backIndexRow.boost
+	merged.version = backIndexRow.version + 1
+	merged.expires = backIndexRow.expires
+	merged.nested = backIndexRow.nested
+	if partial.Boost > 0 {
+		merged.boost = newBoost
+	}
+	for _, entry := range backIndexRow.termsEntries {
+		if changed[entry.GetField()] {
+			replaced.termsEntries = append(replaced.termsEntries, entry)
+		} else {
+			merged.termsEntries = append(merged.termsEntries, entry)
+		}
+	}
+	for _, entry := range backIndexRow.storedEntries {
+		if changed[entry.GetField()] {
+			replaced.storedEntries = append(replaced.storedEntries, entry)
+		} else {
+			merged.storedEntries = append(merged.storedEntries, entry)
+		}
+	}
+	kept := merged.termsEntries
+
+	indexRows := make([]index.IndexRow, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		if row, ok := row.(*BackIndexRow); ok {
+			merged.termsEntries = append(merged.termsEntries, row.termsEntries...)
+			merged.storedEntries = append(merged.storedEntries, row.storedEntries...)
+			continue
+		}
+		indexRows = append(indexRows, row)
+	}
+	indexRows = append(indexRows, merged)
+
+	addRows, updateRows, deleteRows = udc.mergeOldAndNew(replaced, indexRows)
+
+	if newBoost != oldBoost {
+		// only the boost of the postings kept changes
+		for _, entry := range kept {
+			for _, term := range entry.Terms {
+				var termFreqRow *rows.TermFrequencyRow
+				termFreqRow, err = termFrequencyRowForDoc(kvreader, []byte(term), uint16(entry.GetField()), backIndexRow.doc)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				if termFreqRow == nil {
+					continue
+				}
+				boost := float32(1)
+				if termFreqRow.Boost > 0 {
+					boost = termFreqRow.Boost
+				}
+				termFreqRow.Boost = boost / oldBoost * newBoost
+				updateRows = append(updateRows, termFreqRow)
+			}
+		}
+	}
+
+	return addRows, updateRows, deleteRows, nil
+}
+
+// termFrequencyRowForDoc returns the term frequency row of a document
+// for the term in the field, nil if there is none
+func termFrequencyRowForDoc(kvreader store.KVReader, term []byte, field uint16, docID []byte) (*rows.TermFrequencyRow, error) {
+	key := rows.NewTermFrequencyRow(term, field, docID, 0, 0).Key()
+	it := kvreader.TypedPrefixIterator("t", key)
+	defer func() {
+		_ = it.Close()
+	}()
+	// the prefix also matches the documents whose ID docID prefixes
+	for ; it.Valid(); it.Next() {
+		k, val, _ := it.Current()
+		if bytes.Equal(k, key) {
+			return rows.NewTermFrequencyRowFromMap(val)
+		}
+	}
+	return nil, nil
+}
@@ -17,9 +17,9 @@
 package upsidedown
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"math"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -46,6 +46,28 @@ const RowBufferSize = 4 * 1024
 var VersionTable = "v"
 var StaticKey = []byte("static")
 
+// DocCountKey is the counter of the version table holding the number of
+// documents, it moves with the back index rows of every batch
+var DocCountKey = []byte("doc_count")
+
+// FieldLengthKeyPrefix and FieldDocsKeyPrefix followed by the field
+// index are the counters of the version table holding the total length
+// of a field and the number of documents it was recorded for
+var FieldLengthKeyPrefix = []byte("field_length")
+var FieldDocsKeyPrefix = []byte("field_docs")
+
+// FieldBoostKeyPrefix followed by the field index is the FieldBoostRow
+// of the version table, it is only raised so it bounds the boosts of
+// the documents indexed since
+var FieldBoostKeyPrefix = []byte("field_boost")
+
+func fieldCounterKey(prefix []byte, field uint16) []byte {
+	buf := make([]byte, len(prefix)+2)
+	copy(buf, prefix)
+	binary.LittleEndian.PutUint16(buf[len(prefix):], field)
+	return buf
+}
+
 const Version uint8 = 7
 
 var IncompatibleVersion = fmt.Errorf("incompatible version, %d is supported", Version)
@@ -65,6 +87,16 @@ type UpsideDownCouch struct {
 	docCount uint64
 
 	writeMutex sync.Mutex
+
+	// reapStop stops the reaper of the expired documents
+	reapStop chan struct{}
+	reapDone sync.WaitGroup
+
+	// expired caches the documents which expired up to expiredUntil,
+	// readers share the set so it is replaced rather than changed
+	expiredMutex sync.Mutex
+	expired      map[string]struct{}
+	expiredUntil int64
 }
 
 type docBackIndexRow struct {
@@ -91,7 +123,7 @@ func (udc *UpsideDownCouch) init(kvwriter store.KVWriter) (err error) {
 		{NewVersionRow(udc.version)},
 	}
 
-	err = udc.batchRows(kvwriter, nil, rowsAll, nil)
+	err = udc.batchRows(kvwriter, nil, rowsAll, nil, nil)
 	return
 }
 
@@ -133,40 +165,109 @@ func (udc *UpsideDownCouch) loadSchema(kvreader store.KVReader) (err error) {
 	return
 }
 
-func (udc *UpsideDownCouch) batchRows(writer store.KVWriter, addRowsAll [][]UpsideDownCouchRow, updateRowsAll [][]UpsideDownCouchRow, deleteRowsAll [][]UpsideDownCouchRow) (err error) {
+// batchRows writes the rows, replacedBackIndexRows are the back index
+// rows as stored before the update rows overwrite them
+func (udc *UpsideDownCouch) batchRows(writer store.KVWriter, addRowsAll [][]UpsideDownCouchRow, updateRowsAll [][]UpsideDownCouchRow, deleteRowsAll [][]UpsideDownCouchRow, replacedBackIndexRows []*BackIndexRow) (err error) {
 	addNum := 0
 	updateNum := 0
 	deleteNum := 0
 
 	dictionaryDeltas := make(map[string]int64)
+	docDelta := int64(0)
+	fieldLengthDeltas := make(map[uint16]int64)
+	fieldDocsDeltas := make(map[uint16]int64)
+	fieldBoosts := make(map[uint16]float32)
+	var addedExpiries, deletedExpiries []*ExpiryRow
 
 	for _, addRows := range addRowsAll {
 		for _, row := range addRows {
-			tfr, ok := row.(*rows.TermFrequencyRow)
-			if ok {
-				dictionaryDeltas[string(tfr.DictionaryRowKey())] += 1
+			switch row := row.(type) {
+			case *rows.TermFrequencyRow:
+				dictionaryDeltas[string(row.DictionaryRowKey())] += 1
+				if row.Boost > fieldBoosts[row.Field] {
+					fieldBoosts[row.Field] = row.Boost
+				}
+			case *ExpiryRow:
+				addedExpiries = append(addedExpiries, row)
+			case *BackIndexRow:
+				// only new documents add their back index row
+				if !row.isNested() {
+					docDelta++
+				}
+				row.addFieldLengths(1, fieldLengthDeltas, fieldDocsDeltas)
 			}
 		}
 		addNum += len(addRows)
 	}
 
 	for _, updateRows := range updateRowsAll {
+		for _, row := range updateRows {
+			switch row := row.(type) {
+			case *rows.TermFrequencyRow:
+				if row.Boost > fieldBoosts[row.Field] {
+					fieldBoosts[row.Field] = row.Boost
+				}
+			case *ExpiryRow:
+				addedExpiries = append(addedExpiries, row)
+			case *BackIndexRow:
+				row.addFieldLengths(1, fieldLengthDeltas, fieldDocsDeltas)
+			}
+		}
 		updateNum += len(updateRows)
 	}
 
+	fieldBoostRows, err := udc.fieldBoostRows(writer, fieldBoosts)
+	if err != nil {
+		return err
+	}
+	for _, row := range replacedBackIndexRows {
+		row.addFieldLengths(-1, fieldLengthDeltas, fieldDocsDeltas)
+	}
+
 	for _, deleteRows := range deleteRowsAll {
 		for _, row := range deleteRows {
-			tfr, ok := row.(*rows.TermFrequencyRow)
-			if ok {
+			switch row := row.(type) {
+			case *rows.TermFrequencyRow:
 				// need to decrement counter
-				dictionaryDeltas[string(tfr.DictionaryRowKey())] -= 1
+				dictionaryDeltas[string(row.DictionaryRowKey())] -= 1
+			case *ExpiryRow:
+				deletedExpiries = append(deletedExpiries, row)
+			case *BackIndexRow:
+				if !row.isNested() {
+					docDelta--
+				}
+				row.addFieldLengths(-1, fieldLengthDeltas, fieldDocsDeltas)
 			}
 		}
 		deleteNum += len(deleteRows)
 	}
 
+	// when processes share the store, the back index rows are written
+	// first and only over the rows the documents were read from
+	casWriter, shared := writer.(store.KVCompareAndSet)
+	if shared {
+		claims := backIndexClaims(addRowsAll, updateRowsAll, deleteRowsAll, replacedBackIndexRows)
+		err = udc.claimBackIndexRows(casWriter, claims)
+		if err != nil {
+			return err
+		}
+		// the claims are released when the rest of the rows are not
+		// written
+		defer func() {
+			if err != nil {
+				if rerr := releaseBackIndexClaims(casWriter, claims); rerr != nil {
+					err = &index.ReleaseError{Err: err, Release: rerr}
+				}
+			}
+		}()
+	}
+	claimed := func(row UpsideDownCouchRow) bool {
+		_, ok := row.(*BackIndexRow)
+		return shared && ok
+	}
+
 	wb, err := writer.NewBatchEx(store.KVBatchOptions{
-		NumSets:    addNum + updateNum,
+		NumSets:    addNum + updateNum + len(fieldBoostRows),
 		NumDeletes: deleteNum,
 	})
 	if err != nil {
@@ -176,10 +277,30 @@ func (udc *UpsideDownCouch) batchRows(writer store.KVWriter, addRowsAll [][]Upsi
 		_ = wb.Close()
 	}()
 
+	// the rows of expiring documents the store can expire are written
+	// with a TTL as well
+	expiringBatch, expiring := wb.(store.KVExpiringBatch)
+	var expiries map[string]int64
+	if expiring {
+		expiries = documentExpiries(addRowsAll, updateRowsAll)
+	}
+	now := time.Now()
+	set := func(row UpsideDownCouchRow) error {
+		if expiring {
+			if ttl, ok := rowTTL(row, expiries, now); ok {
+				return expiringBatch.SetWithTTL(row.Table(), row, ttl)
+			}
+		}
+		return wb.Set(row.Table(), row)
+	}
+
 	// fill the batch
 	for _, addRows := range addRowsAll {
 		for _, row := range addRows {
-			err = wb.Set(row.Table(), row)
+			if claimed(row) {
+				continue
+			}
+			err = set(row)
 			if err != nil {
 				return err
 			}
@@ -188,15 +309,28 @@ func (udc *UpsideDownCouch) batchRows(writer store.KVWriter, addRowsAll [][]Upsi
 
 	for _, updateRows := range updateRowsAll {
 		for _, row := range updateRows {
-			err = wb.Set(row.Table(), row)
+			if claimed(row) {
+				continue
+			}
+			err = set(row)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
+	for _, row := range fieldBoostRows {
+		err = wb.Set(row.Table(), row)
+		if err != nil {
+			return err
+		}
+	}
+
 	for _, deleteRows := range deleteRowsAll {
 		for _, row := range deleteRows {
+			if claimed(row) {
+				continue
+			}
 			err = wb.Delete(row.Table(), row.Key())
 			if err != nil {
 				return err
@@ -211,8 +345,133 @@ func (udc *UpsideDownCouch) batchRows(writer store.KVWriter, addRowsAll [][]Upsi
 		}
 	}
 
+	if docDelta != 0 {
+		err = wb.Increment(VersionTable, DocCountKey, docDelta)
+		if err != nil {
+			return err
+		}
+	}
+
+	for field, delta := range fieldLengthDeltas {
+		if delta != 0 {
+			err = wb.Increment(VersionTable, fieldCounterKey(FieldLengthKeyPrefix, field), delta)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for field, delta := range fieldDocsDeltas {
+		if delta != 0 {
+			err = wb.Increment(VersionTable, fieldCounterKey(FieldDocsKeyPrefix, field), delta)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// write out the batch
-	return writer.ExecuteBatch(wb)
+	err = writer.ExecuteBatch(wb)
+	if err == nil {
+		udc.updateExpired(addedExpiries, deletedExpiries)
+	}
+	return err
+}
+
+// fieldBoostRows returns the rows raising the highest boost of the
+// fields to the boosts given where they exceed it, boosts up to 1 need
+// none.  When processes share the store the boosts are raised right
+// away with compare and set instead and no rows are returned, a bound
+// raised for rows which are then not written is merely loose.
+func (udc *UpsideDownCouch) fieldBoostRows(writer store.KVWriter, boosts map[uint16]float32) (rv []UpsideDownCouchRow, err error) {
+	for field, boost := range boosts {
+		if boost <= 1 {
+			delete(boosts, field)
+		}
+	}
+	if len(boosts) == 0 {
+		return nil, nil
+	}
+
+	if casWriter, shared := writer.(store.KVCompareAndSet); shared {
+		for field, boost := range boosts {
+			err = udc.raiseFieldBoost(casWriter, field, boost)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+
+	kvreader, err := udc.store.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := kvreader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	for field, boost := range boosts {
+		current, err := fieldBoost(kvreader, field)
+		if err != nil {
+			return nil, err
+		}
+		if boost > current {
+			rv = append(rv, NewFieldBoostRow(field, boost))
+		}
+	}
+	return rv, nil
+}
+
+// raiseFieldBoost raises the highest boost of the field to boost unless
+// it is higher already, retrying while other writers change it
+func (udc *UpsideDownCouch) raiseFieldBoost(writer store.KVCompareAndSet, field uint16, boost float32) error {
+	row := NewFieldBoostRow(field, boost)
+	for {
+		kvreader, err := udc.store.Reader()
+		if err != nil {
+			return err
+		}
+		old, err := kvreader.Get(row.Table(), row.Key())
+		if cerr := kvreader.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		if old != nil {
+			current, err := NewFieldBoostRowKV(row.Key(), old)
+			if err != nil {
+				return err
+			}
+			if current.boost >= boost {
+				return nil
+			}
+		}
+		set, err := writer.CompareAndSet(row.Table(), row.Key(), old, row.Value())
+		if err != nil || set {
+			return err
+		}
+	}
+}
+
+// fieldBoost returns the highest boost of the field, 1 when it was
+// never boosted above
+func fieldBoost(kvreader store.KVReader, field uint16) (float32, error) {
+	val, err := kvreader.Get(VersionTable, fieldCounterKey(FieldBoostKeyPrefix, field))
+	if err != nil || val == nil {
+		return 1, err
+	}
+	row, err := NewFieldBoostRowKV(fieldCounterKey(FieldBoostKeyPrefix, field), val)
+	if err != nil {
+		return 1, err
+	}
+	if row.boost < 1 {
+		return 1, nil
+	}
+	return row.boost, nil
 }
 
 func (udc *UpsideDownCouch) Open() (err error) {
@@ -255,9 +514,24 @@ func (udc *UpsideDownCouch) Open() (err error) {
 		}
 
 		// set doc count
-		udc.m.Lock()
-		udc.docCount, err = udc.countDocs(kvreader)
-		udc.m.Unlock()
+		var count int64
+		count, err = kvreader.GetCounter(VersionTable, DocCountKey)
+		if err != nil {
+			_ = kvreader.Close()
+			return
+		}
+		if count < 0 {
+			// written before the count was kept, start it from a scan
+			err = udc.seedDocCount(kvreader)
+			if err != nil {
+				_ = kvreader.Close()
+				return
+			}
+		} else {
+			udc.m.Lock()
+			udc.docCount = uint64(count)
+			udc.m.Unlock()
+		}
 
 		err = kvreader.Close()
 	} else {
@@ -281,7 +555,15 @@ func (udc *UpsideDownCouch) Open() (err error) {
 		// init the index
 		err = udc.init(kvwriter)
 	}
+	if err != nil {
+		return
+	}
 
+	var interval time.Duration
+	interval, err = reapInterval(udc.storeConfig)
+	if err == nil && interval > 0 {
+		udc.startReaper(interval)
+	}
 	return
 }
 
@@ -289,11 +571,119 @@ func (udc *UpsideDownCouch) countDocs(kvreader store.KVReader) (count uint64, er
 	return kvreader.DocCount()
 }
 
+// seedDocCount starts the document counter of an index which predates
+// it from the number of back index rows
+func (udc *UpsideDownCouch) seedDocCount(kvreader store.KVReader) error {
+	count, err := udc.countDocs(kvreader)
+	if err != nil {
+		return err
+	}
+	err = udc.incrementDocCount(int64(count))
+	if err != nil {
+		return err
+	}
+	udc.m.Lock()
+	udc.docCount = count
+	udc.m.Unlock()
+	return nil
+}
+
+func (udc *UpsideDownCouch) incrementDocCount(delta int64) (err error) {
+	var kvwriter store.KVWriter
+	kvwriter, err = udc.store.Writer()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := kvwriter.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	wb := kvwriter.NewBatch()
+	defer func() {
+		_ = wb.Close()
+	}()
+	err = wb.Increment(VersionTable, DocCountKey, delta)
+	if err != nil {
+		return
+	}
+	return kvwriter.ExecuteBatch(wb)
+}
+
+// DocCount returns the document counter as stored, including the
+// writes of other processes sharing the store
+func (udc *UpsideDownCouch) DocCount() (uint64, error) {
+	kvreader, err := udc.store.Reader()
+	if err != nil {
+		return 0, err
+	}
+	count, err := kvreader.GetCounter(VersionTable, DocCountKey)
+	if cerr := kvreader.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if count < 0 {
+		return 0, nil
+	}
+	return uint64(count), nil
+}
+
+// ReconcileDocCount counts the back index rows and corrects the
+// document counter, which can drift when a batch fails part way.  It
+// returns the count as stored before and as counted.
+func (udc *UpsideDownCouch) ReconcileDocCount() (stored, counted uint64, err error) {
+	udc.writeMutex.Lock()
+	defer udc.writeMutex.Unlock()
+
+	var kvreader store.KVReader
+	kvreader, err = udc.store.Reader()
+	if err != nil {
+		return
+	}
+	var count int64
+	count, err = kvreader.GetCounter(VersionTable, DocCountKey)
+	if err == nil {
+		counted, err = udc.countDocs(kvreader)
+	}
+	if cerr := kvreader.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	if count > 0 {
+		stored = uint64(count)
+	}
+
+	// a missing counter is created by the increment
+	if count < 0 || stored != counted {
+		err = udc.incrementDocCount(int64(counted) - int64(stored))
+		if err != nil {
+			return
+		}
+	}
+	udc.m.Lock()
+	udc.docCount = counted
+	udc.m.Unlock()
+	return
+}
+
 func (udc *UpsideDownCouch) Close() error {
+	udc.stopReaper()
 	return udc.store.Close()
 }
 
 func (udc *UpsideDownCouch) Update(doc *document.Document) (err error) {
+	if len(doc.Nested) > 0 {
+		// the nested documents are written in the same batch
+		batch := index.NewBatch()
+		batch.Update(doc)
+		return udc.Batch(batch)
+	}
+
 	// do analysis before acquiring write lock
 	analysisStart := time.Now()
 	numPlainTextBytes := doc.NumPlainTextBytes()
@@ -328,11 +718,27 @@ func (udc *UpsideDownCouch) Update(doc *document.Document) (err error) {
 		return
 	}
 
+	// the nested documents it had are deleted
+	var nestedRows []*BackIndexRow
+	nestedRows, err = staleNestedRows(kvreader, backIndexRow, nil)
+	if err != nil {
+		_ = kvreader.Close()
+		atomic.AddUint64(&udc.stats.errors, 1)
+		return
+	}
+
 	err = kvreader.Close()
 	if err != nil {
 		return
 	}
 
+	err = checkVersion(doc.ID, backIndexRow, doc.Version)
+	if err != nil {
+		atomic.AddUint64(&udc.stats.errors, 1)
+		return
+	}
+	nextVersion(backIndexRow, result.Rows)
+
 	// start a writer for this update
 	indexStart := time.Now()
 	var kvwriter store.KVWriter
@@ -361,9 +767,17 @@ func (udc *UpsideDownCouch) Update(doc *document.Document) (err error) {
 	if len(deleteRows) > 0 {
 		deleteRowsAll = append(deleteRowsAll, deleteRows)
 	}
+	for _, row := range nestedRows {
+		deleteRowsAll = append(deleteRowsAll, udc.deleteSingle(string(row.doc), row, nil))
+	}
+
+	var replacedBackIndexRows []*BackIndexRow
+	if backIndexRow != nil {
+		replacedBackIndexRows = append(replacedBackIndexRows, backIndexRow)
+	}
 
-	err = udc.batchRows(kvwriter, addRowsAll, updateRowsAll, deleteRowsAll)
-	if err == nil && backIndexRow == nil {
+	err = udc.batchRows(kvwriter, addRowsAll, updateRowsAll, deleteRowsAll, replacedBackIndexRows)
+	if err == nil && backIndexRow == nil && !document.IsNestedID(doc.ID) {
 		udc.m.Lock()
 		udc.docCount++
 		udc.m.Unlock()
@@ -410,6 +824,7 @@ func (udc *UpsideDownCouch) mergeOldAndNew(backIndexRow *BackIndexRow, indexRows
 		}
 	}
 
+	expiryKept := false
 	for _, row := range indexRows {
 		switch row := row.(type) {
 		case *rows.TermFrequencyRow:
@@ -432,11 +847,21 @@ func (udc *UpsideDownCouch) mergeOldAndNew(backIndexRow *BackIndexRow, indexRows
 				}
 			}
 			addRows = append(addRows, row)
+		case *ExpiryRow:
+			if row.expires == backIndexRow.expires {
+				expiryKept = true
+			}
+			updateRows = append(updateRows, row)
 		default:
 			updateRows = append(updateRows, row)
 		}
 	}
 
+	// the expiry row of the old expiry goes when the expiry changes
+	if backIndexRow.expires != 0 && !expiryKept {
+		deleteRows = append(deleteRows, NewExpiryRow(backIndexRow.doc, backIndexRow.expires))
+	}
+
 	// any of the existing rows that weren't updated need to be deleted
 	for existingTermKey := range existingTermKeys {
 		termFreqRow, err := rows.NewTermFrequencyRowK([]byte(existingTermKey))
@@ -485,9 +910,7 @@ func encodeFieldType(f document.Field) byte {
 	return fieldType
 }
 
-func (udc *UpsideDownCouch) indexField(docID []byte, includeTermVectors bool, fieldIndex uint16, fieldLength int, tokenFreqs analysis.TokenFrequencies, indexRows []index.IndexRow, backIndexTermsEntries []*BackIndexTermsEntry) ([]index.IndexRow, []*BackIndexTermsEntry) {
-	fieldNorm := float32(1.0 / math.Sqrt(float64(fieldLength)))
-
+func (udc *UpsideDownCouch) indexField(docID []byte, includeTermVectors bool, fieldIndex uint16, fieldLength int, boost float32, tokenFreqs analysis.TokenFrequencies, indexRows []index.IndexRow, backIndexTermsEntries []*BackIndexTermsEntry) ([]index.IndexRow, []*BackIndexTermsEntry) {
 	termFreqRows := make([]rows.TermFrequencyRow, len(tokenFreqs))
 	termFreqRowsUsed := 0
 
@@ -496,7 +919,14 @@ func (udc *UpsideDownCouch) indexField(docID []byte, includeTermVectors bool, fi
 		termFreqRow := &termFreqRows[termFreqRowsUsed]
 		termFreqRowsUsed++
 
-		rows.InitTermFrequencyRow(termFreqRow, tf.Term, fieldIndex, docID, float32(frequencyFromTokenFreq(tf))/float32(len(tokenFreqs)), fieldNorm)
+		// the pre score orders the postings of a term best first, the
+		// term scorer for a given field length only grows with the
+		// frequency, the boost is left out and bounded by the highest
+		// boost of the field instead
+		freq := float32(frequencyFromTokenFreq(tf)) / float32(len(tokenFreqs))
+		rows.InitTermFrequencyRow(termFreqRow, tf.Term, fieldIndex, docID, freq, freq)
+		termFreqRow.FieldLength = uint32(fieldLength)
+		termFreqRow.Boost = boost
 
 		if includeTermVectors {
 			termFreqRow.Vectors, indexRows = udc.termVectorsFromTokenFreq(fieldIndex, tf, indexRows)
@@ -507,7 +937,7 @@ func (udc *UpsideDownCouch) indexField(docID []byte, includeTermVectors bool, fi
 
 		indexRows = append(indexRows, termFreqRow)
 	}
-	backIndexTermsEntry := BackIndexTermsEntry{Field: proto.Uint32(uint32(fieldIndex)), Terms: terms}
+	backIndexTermsEntry := BackIndexTermsEntry{Field: proto.Uint32(uint32(fieldIndex)), Terms: terms, FieldLength: proto.Uint32(uint32(fieldLength))}
 	backIndexTermsEntries = append(backIndexTermsEntries, &backIndexTermsEntry)
 
 	return indexRows, backIndexTermsEntries
@@ -536,6 +966,14 @@ func (udc *UpsideDownCouch) Delete(id string) (err error) {
 		return
 	}
 
+	var nestedRows []*BackIndexRow
+	nestedRows, err = staleNestedRows(kvreader, backIndexRow, nil)
+	if err != nil {
+		_ = kvreader.Close()
+		atomic.AddUint64(&udc.stats.errors, 1)
+		return
+	}
+
 	err = kvreader.Close()
 	if err != nil {
 		return
@@ -564,9 +1002,12 @@ func (udc *UpsideDownCouch) Delete(id string) (err error) {
 	if len(deleteRows) > 0 {
 		deleteRowsAll = append(deleteRowsAll, deleteRows)
 	}
+	for _, row := range nestedRows {
+		deleteRowsAll = append(deleteRowsAll, udc.deleteSingle(string(row.doc), row, nil))
+	}
 
-	err = udc.batchRows(kvwriter, nil, nil, deleteRowsAll)
-	if err == nil {
+	err = udc.batchRows(kvwriter, nil, nil, deleteRowsAll, nil)
+	if err == nil && !backIndexRow.isNested() {
 		udc.m.Lock()
 		udc.docCount--
 		udc.m.Unlock()
@@ -594,6 +1035,10 @@ func (udc *UpsideDownCouch) deleteSingle(id string, backIndexRow *BackIndexRow,
 		deleteRows = append(deleteRows, sf)
 	}
 
+	if backIndexRow.expires != 0 {
+		deleteRows = append(deleteRows, NewExpiryRow(idBytes, backIndexRow.expires))
+	}
+
 	// also delete the back entry itself
 	deleteRows = append(deleteRows, backIndexRow)
 	return deleteRows
@@ -671,11 +1116,14 @@ func (udc *UpsideDownCouch) termFieldVectorsFromTermVectors(in []*rows.TermVecto
 func (udc *UpsideDownCouch) Batch(batch *index.Batch) (err error) {
 	analysisStart := time.Now()
 
-	resultChan := make(chan *index.AnalysisResult, len(batch.IndexOps))
+	// the nested documents are indexed along with their document
+	indexOps := withNested(batch.IndexOps)
+
+	resultChan := make(chan *index.AnalysisResult, len(indexOps))
 
 	var numUpdates uint64
 	var numPlainTextBytes uint64
-	for _, doc := range batch.IndexOps {
+	for _, doc := range indexOps {
 		if doc != nil {
 			numUpdates++
 			numPlainTextBytes += doc.NumPlainTextBytes()
@@ -683,7 +1131,7 @@ func (udc *UpsideDownCouch) Batch(batch *index.Batch) (err error) {
 	}
 
 	go func() {
-		for _, doc := range batch.IndexOps {
+		for _, doc := range indexOps {
 			if doc != nil {
 				aw := index.NewAnalysisWork(udc, doc, resultChan)
 				// put the work on the queue
@@ -694,7 +1142,7 @@ func (udc *UpsideDownCouch) Batch(batch *index.Batch) (err error) {
 
 	// retrieve back index rows concurrent with analysis
 	docBackIndexRowErr := error(nil)
-	docBackIndexRowCh := make(chan *docBackIndexRow, len(batch.IndexOps))
+	docBackIndexRowCh := make(chan *docBackIndexRow, len(indexOps))
 
 	udc.writeMutex.Lock()
 	defer udc.writeMutex.Unlock()
@@ -710,7 +1158,7 @@ func (udc *UpsideDownCouch) Batch(batch *index.Batch) (err error) {
 			return
 		}
 
-		for docID, doc := range batch.IndexOps {
+		for docID, doc := range indexOps {
 			backIndexRow, err := backIndexRowForDoc(kvreader, index.IndexInternalID(docID))
 			if err != nil {
 				docBackIndexRowErr = err
@@ -718,6 +1166,16 @@ func (udc *UpsideDownCouch) Batch(batch *index.Batch) (err error) {
 			}
 
 			docBackIndexRowCh <- &docBackIndexRow{docID, doc, backIndexRow}
+
+			// the nested documents no longer there are deleted
+			nestedRows, err := staleNestedRows(kvreader, backIndexRow, indexOps)
+			if err != nil {
+				docBackIndexRowErr = err
+				return
+			}
+			for _, row := range nestedRows {
+				docBackIndexRowCh <- &docBackIndexRow{string(row.doc), nil, row}
+			}
 		}
 
 		err = kvreader.Close()
@@ -771,16 +1229,28 @@ func (udc *UpsideDownCouch) Batch(batch *index.Batch) (err error) {
 		deleteRowsAll = append(deleteRowsAll, deleteRows)
 	}
 
+	var replacedBackIndexRows []*BackIndexRow
+	var versionErr error
+
 	// process back index rows as they arrive
 	for dbir := range docBackIndexRowCh {
+		if err := checkVersion(dbir.docID, dbir.backIndexRow, batch.Versions[dbir.docID]); err != nil {
+			if versionErr == nil {
+				versionErr = err
+			}
+			continue
+		}
 		if dbir.doc == nil && dbir.backIndexRow != nil {
 			// delete
 			deleteRows := udc.deleteSingle(dbir.docID, dbir.backIndexRow, nil)
 			if len(deleteRows) > 0 {
 				deleteRowsAll = append(deleteRowsAll, deleteRows)
 			}
-			docsDeleted++
+			if !dbir.backIndexRow.isNested() {
+				docsDeleted++
+			}
 		} else if dbir.doc != nil {
+			nextVersion(dbir.backIndexRow, newRowsMap[dbir.docID])
 			addRows, updateRows, deleteRows := udc.mergeOldAndNew(dbir.backIndexRow, newRowsMap[dbir.docID])
 			if len(addRows) > 0 {
 				addRowsAll = append(addRowsAll, addRows)
@@ -792,7 +1262,11 @@ func (udc *UpsideDownCouch) Batch(batch *index.Batch) (err error) {
 				deleteRowsAll = append(deleteRowsAll, deleteRows)
 			}
 			if dbir.backIndexRow == nil {
-				docsAdded++
+				if !document.IsNestedID(dbir.docID) {
+					docsAdded++
+				}
+			} else {
+				replacedBackIndexRows = append(replacedBackIndexRows, dbir.backIndexRow)
 			}
 		}
 	}
@@ -800,6 +1274,23 @@ func (udc *UpsideDownCouch) Batch(batch *index.Batch) (err error) {
 	if docBackIndexRowErr != nil {
 		return docBackIndexRowErr
 	}
+	if versionErr != nil {
+		atomic.AddUint64(&udc.stats.errors, 1)
+		return versionErr
+	}
+
+	// merge the partial updates with the documents indexed
+	if len(batch.UpdateFieldsOps) > 0 {
+		err = udc.batchUpdateFields(batch, &addRowsAll, &updateRowsAll, &deleteRowsAll, &replacedBackIndexRows)
+		if err != nil {
+			atomic.AddUint64(&udc.stats.errors, 1)
+			return
+		}
+		for _, doc := range batch.UpdateFieldsOps {
+			numUpdates++
+			numPlainTextBytes += doc.NumPlainTextBytes()
+		}
+	}
 
 	// start a writer for this batch
 	var kvwriter store.KVWriter
@@ -808,7 +1299,7 @@ func (udc *UpsideDownCouch) Batch(batch *index.Batch) (err error) {
 		return
 	}
 
-	err = udc.batchRows(kvwriter, addRowsAll, updateRowsAll, deleteRowsAll)
+	err = udc.batchRows(kvwriter, addRowsAll, updateRowsAll, deleteRowsAll, replacedBackIndexRows)
 	if err != nil {
 		_ = kvwriter.Close()
 		atomic.AddUint64(&udc.stats.errors, 1)
@@ -885,12 +1376,40 @@ func (udc *UpsideDownCouch) Reader() (index.IndexReader, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error opening store reader: %v", err)
 	}
-	udc.m.RLock()
-	defer udc.m.RUnlock()
+	// the stored counter includes the writes of other processes
+	// sharing the store, the local count is only kept until it is set
+	count, err := kvr.GetCounter(VersionTable, DocCountKey)
+	if err != nil {
+		_ = kvr.Close()
+		return nil, fmt.Errorf("error reading document count: %v", err)
+	}
+	expired, err := udc.expiredSet(time.Now())
+	if err != nil {
+		_ = kvr.Close()
+		return nil, fmt.Errorf("error reading expired documents: %v", err)
+	}
+	udc.m.Lock()
+	if count >= 0 {
+		udc.docCount = uint64(count)
+	}
+	docCount := udc.docCount
+	udc.m.Unlock()
+	expiredCount := uint64(0)
+	for id := range expired {
+		if !document.IsNestedID(id) {
+			expiredCount++
+		}
+	}
+	if expiredCount < docCount {
+		docCount -= expiredCount
+	} else {
+		docCount = 0
+	}
 	return &IndexReader{
 		index:    udc,
 		kvreader: kvr,
-		docCount: udc.docCount,
+		docCount: docCount,
+		expired:  expired,
 	}, nil
 }
 
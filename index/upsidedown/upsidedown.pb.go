@@ -29,6 +29,7 @@ var _ = math.Inf
 type BackIndexTermsEntry struct {
 	Field            *uint32  `protobuf:"varint,1,req,name=field" json:"field,omitempty"`
 	Terms            []string `protobuf:"bytes,2,rep,name=terms" json:"terms,omitempty"`
+	FieldLength      *uint32  `protobuf:"varint,3,opt,name=fieldLength" json:"fieldLength,omitempty"`
 	XXX_unrecognized []byte   `json:"-"`
 }
 
@@ -50,6 +51,13 @@ func (m *BackIndexTermsEntry) GetTerms() []string {
 	return nil
 }
 
+func (m *BackIndexTermsEntry) GetFieldLength() uint32 {
+	if m != nil && m.FieldLength != nil {
+		return *m.FieldLength
+	}
+	return 0
+}
+
 type BackIndexStoreEntry struct {
 	Field            *uint32  `protobuf:"varint,1,req,name=field" json:"field,omitempty"`
 	ArrayPositions   []uint64 `protobuf:"varint,2,rep,name=arrayPositions" json:"arrayPositions,omitempty"`
@@ -77,6 +85,10 @@ func (m *BackIndexStoreEntry) GetArrayPositions() []uint64 {
 type BackIndexRowValue struct {
 	TermsEntries     []*BackIndexTermsEntry `protobuf:"bytes,1,rep,name=termsEntries" json:"termsEntries,omitempty"`
 	StoredEntries    []*BackIndexStoreEntry `protobuf:"bytes,2,rep,name=storedEntries" json:"storedEntries,omitempty"`
+	Boost            *float32               `protobuf:"fixed32,3,opt,name=boost" json:"boost,omitempty"`
+	Version          *uint64                `protobuf:"varint,4,opt,name=version" json:"version,omitempty"`
+	Expires          *int64                 `protobuf:"varint,5,opt,name=expires" json:"expires,omitempty"`
+	Nested           []string               `protobuf:"bytes,6,rep,name=nested" json:"nested,omitempty"`
 	XXX_unrecognized []byte                 `json:"-"`
 }
 
@@ -98,6 +110,34 @@ func (m *BackIndexRowValue) GetStoredEntries() []*BackIndexStoreEntry {
 	return nil
 }
 
+func (m *BackIndexRowValue) GetBoost() float32 {
+	if m != nil && m.Boost != nil {
+		return *m.Boost
+	}
+	return 0
+}
+
+func (m *BackIndexRowValue) GetVersion() uint64 {
+	if m != nil && m.Version != nil {
+		return *m.Version
+	}
+	return 0
+}
+
+func (m *BackIndexRowValue) GetExpires() int64 {
+	if m != nil && m.Expires != nil {
+		return *m.Expires
+	}
+	return 0
+}
+
+func (m *BackIndexRowValue) GetNested() []string {
+	if m != nil {
+		return m.Nested
+	}
+	return nil
+}
+
 func (m *BackIndexTermsEntry) Unmarshal(data []byte) error {
 	var hasFields [1]uint64
 	l := len(data)
@@ -158,6 +198,23 @@ func (m *BackIndexTermsEntry) Unmarshal(data []byte) error {
 			}
 			m.Terms = append(m.Terms, string(data[iNdEx:postIndex]))
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FieldLength", wireType)
+			}
+			var v uint32
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.FieldLength = &v
 		default:
 			var sizeOfWire int
 			for {
@@ -348,6 +405,77 @@ func (m *BackIndexRowValue) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 5 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Boost", wireType)
+			}
+			var v uint32
+			if (iNdEx + 4) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += 4
+			v = uint32(data[iNdEx-4])
+			v |= uint32(data[iNdEx-3]) << 8
+			v |= uint32(data[iNdEx-2]) << 16
+			v |= uint32(data[iNdEx-1]) << 24
+			v2 := float32(math.Float32frombits(v))
+			m.Boost = &v2
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			var v uint64
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Version = &v
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Expires", wireType)
+			}
+			var v int64
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Expires = &v
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Nested", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			postIndex := iNdEx + int(stringLen)
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Nested = append(m.Nested, string(data[iNdEx:postIndex]))
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -479,6 +607,9 @@ func (m *BackIndexTermsEntry) Size() (n int) {
 			n += 1 + l + sovUpsidedown(uint64(l))
 		}
 	}
+	if m.FieldLength != nil {
+		n += 1 + sovUpsidedown(uint64(*m.FieldLength))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -517,6 +648,21 @@ func (m *BackIndexRowValue) Size() (n int) {
 			n += 1 + l + sovUpsidedown(uint64(l))
 		}
 	}
+	if m.Boost != nil {
+		n += 5
+	}
+	if m.Version != nil {
+		n += 1 + sovUpsidedown(uint64(*m.Version))
+	}
+	if m.Expires != nil {
+		n += 1 + sovUpsidedown(uint64(*m.Expires))
+	}
+	if len(m.Nested) > 0 {
+		for _, s := range m.Nested {
+			l = len(s)
+			n += 1 + l + sovUpsidedown(uint64(l))
+		}
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -573,6 +719,11 @@ func (m *BackIndexTermsEntry) MarshalTo(data []byte) (n int, err error) {
 			i += copy(data[i:], s)
 		}
 	}
+	if m.FieldLength != nil {
+		data[i] = 0x18
+		i++
+		i = encodeVarintUpsidedown(data, i, uint64(*m.FieldLength))
+	}
 	if m.XXX_unrecognized != nil {
 		i += copy(data[i:], m.XXX_unrecognized)
 	}
@@ -653,6 +804,36 @@ func (m *BackIndexRowValue) MarshalTo(data []byte) (n int, err error) {
 			i += n
 		}
 	}
+	if m.Boost != nil {
+		data[i] = 0x1d
+		i++
+		i = encodeFixed32Upsidedown(data, i, uint32(math.Float32bits(*m.Boost)))
+	}
+	if m.Version != nil {
+		data[i] = 0x20
+		i++
+		i = encodeVarintUpsidedown(data, i, uint64(*m.Version))
+	}
+	if m.Expires != nil {
+		data[i] = 0x28
+		i++
+		i = encodeVarintUpsidedown(data, i, uint64(*m.Expires))
+	}
+	if len(m.Nested) > 0 {
+		for _, s := range m.Nested {
+			data[i] = 0x32
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				data[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			data[i] = uint8(l)
+			i++
+			i += copy(data[i:], s)
+		}
+	}
 	if m.XXX_unrecognized != nil {
 		i += copy(data[i:], m.XXX_unrecognized)
 	}
@@ -29,6 +29,7 @@ import (
 	"github.com/wrble/flock/document"
 	"github.com/wrble/flock/index"
 	"github.com/wrble/flock/index/store/cassandra"
+	"github.com/wrble/flock/index/store/memory"
 	"github.com/wrble/flock/index/store/null"
 	"github.com/wrble/flock/registry"
 )
@@ -1313,3 +1314,478 @@ func TestLargeField(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestIndexDocCountCounter(t *testing.T) {
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx, err := NewUpsideDownCouch(memory.Name, nil, analysisQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Open()
+	if err != nil {
+		t.Fatalf("error opening index: %v", err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	udc := idx.(*UpsideDownCouch)
+
+	expectCount := func(expected uint64) {
+		count, err := udc.DocCount()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != expected {
+			t.Errorf("expected stored count %d, got %d", expected, count)
+		}
+		// readers report the stored count, including the writes of
+		// other processes
+		reader, err := idx.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		count, err = reader.DocCount()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != expected {
+			t.Errorf("expected reader count %d, got %d", expected, count)
+		}
+		err = reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectCount(0)
+
+	for _, id := range []string{"a", "b", "c"} {
+		doc := document.NewDocument(id)
+		doc.AddField(document.NewTextField("name", []uint64{}, []byte("test")))
+		err = idx.Update(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// updating an existing document does not count it again
+	doc := document.NewDocument("a")
+	doc.AddField(document.NewTextField("name", []uint64{}, []byte("updated")))
+	err = idx.Update(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Delete("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := index.NewBatch()
+	batch.Delete("c")
+	batch.Update(document.NewDocument("d"))
+	batch.Update(document.NewDocument("e"))
+	err = idx.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCount(3)
+
+	// let the counter drift, as an interrupted batch would
+	kvwriter, err := udc.store.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wb := kvwriter.NewBatch()
+	err = wb.Increment(VersionTable, DocCountKey, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = kvwriter.ExecuteBatch(wb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = kvwriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCount(8)
+
+	stored, counted, err := udc.ReconcileDocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != 8 || counted != 3 {
+		t.Errorf("expected to correct 8 to 3, got %d to %d", stored, counted)
+	}
+	expectCount(3)
+}
+
+func TestIndexFieldLengths(t *testing.T) {
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx, err := NewUpsideDownCouch(memory.Name, nil, analysisQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Open()
+	if err != nil {
+		t.Fatalf("error opening index: %v", err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// every value of the field is a single token
+	newDoc := func(id string, values ...string) *document.Document {
+		doc := document.NewDocument(id)
+		for i, value := range values {
+			doc.AddField(document.NewTextField("name", []uint64{uint64(i)}, []byte(value)))
+		}
+		return doc
+	}
+	expectLengths := func(expectedTotal, expectedDocs uint64) {
+		indexReader, err := idx.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := indexReader.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		total, docs, err := indexReader.(index.FieldLengthReader).FieldLengths("name")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != expectedTotal || docs != expectedDocs {
+			t.Errorf("expected length %d over %d docs, got %d over %d", expectedTotal, expectedDocs, total, docs)
+		}
+	}
+
+	err = idx.Update(newDoc("a", "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Update(newDoc("b", "x", "y", "z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLengths(4, 2)
+
+	err = idx.Update(newDoc("a", "x", "y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLengths(5, 2)
+
+	err = idx.Delete("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLengths(2, 1)
+
+	batch := index.NewBatch()
+	batch.Update(newDoc("a", "w", "x", "y", "z"))
+	batch.Update(newDoc("c", "x"))
+	err = idx.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLengths(5, 2)
+
+	indexReader, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := indexReader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	reader, err := indexReader.TermFieldReader([]byte("x"), "name", true, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]uint32{"a": 4, "c": 1}
+	tfd, err := reader.Next(nil)
+	for tfd != nil && err == nil {
+		if tfd.FieldLength != expected[string(tfd.ID)] {
+			t.Errorf("expected field length %d for %s, got %d", expected[string(tfd.ID)], tfd.ID, tfd.FieldLength)
+		}
+		delete(expected, string(tfd.ID))
+		tfd, err = reader.Next(nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expected) != 0 {
+		t.Errorf("expected to visit %v", expected)
+	}
+	err = reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexFieldBoosts(t *testing.T) {
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx, err := NewUpsideDownCouch(memory.Name, nil, analysisQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Open()
+	if err != nil {
+		t.Fatalf("error opening index: %v", err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	newDoc := func(id string, boost, nameBoost float64) *document.Document {
+		doc := document.NewDocument(id)
+		doc.AddField(document.NewTextField("name", []uint64{}, []byte("x")))
+		doc.AddField(document.NewTextField("desc", []uint64{}, []byte("x")))
+		doc.Boost = boost
+		doc.SetFieldBoost("name", nameBoost)
+		return doc
+	}
+	expectMaxBoost := func(field string, expected float64) {
+		indexReader, err := idx.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := indexReader.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		boost, err := indexReader.(index.FieldBoostReader).MaxFieldBoost(field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if boost != expected {
+			t.Errorf("expected max boost %f for %s, got %f", expected, field, boost)
+		}
+	}
+
+	err = idx.Update(newDoc("a", 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectMaxBoost("name", 1)
+	expectMaxBoost("desc", 1)
+
+	batch := index.NewBatch()
+	batch.Update(newDoc("b", 2, 1.5))
+	batch.Update(newDoc("c", 0.5, 0))
+	err = idx.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectMaxBoost("name", 3)
+	expectMaxBoost("desc", 2)
+
+	// the bound is not lowered
+	err = idx.Delete("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectMaxBoost("name", 3)
+
+	indexReader, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := indexReader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	reader, err := indexReader.TermFieldReader([]byte("x"), "name", true, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]float32{"a": 1, "c": 0.5}
+	tfd, err := reader.Next(nil)
+	for tfd != nil && err == nil {
+		if tfd.Boost != expected[string(tfd.ID)] {
+			t.Errorf("expected boost %f for %s, got %f", expected[string(tfd.ID)], tfd.ID, tfd.Boost)
+		}
+		delete(expected, string(tfd.ID))
+		tfd, err = reader.Next(nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expected) != 0 {
+		t.Errorf("expected to visit %v", expected)
+	}
+	err = reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexUpdateFields(t *testing.T) {
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx, err := NewUpsideDownCouch(memory.Name, nil, analysisQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Open()
+	if err != nil {
+		t.Fatalf("error opening index: %v", err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	field := func(name, value string) document.Field {
+		return document.NewTextFieldWithIndexingOptions(name, []uint64{}, []byte(value), document.IndexField|document.StoreField)
+	}
+	doc := document.NewDocument("a")
+	doc.AddField(field("name", "alpha"))
+	doc.AddField(field("desc", "one"))
+	doc.AddField(field("desc", "two"))
+	err = idx.Update(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// expectTerm checks the boosts of the documents holding the term
+	expectTerm := func(term, field string, expected map[string]float32) {
+		indexReader, err := idx.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := indexReader.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		reader, err := indexReader.TermFieldReader([]byte(term), field, true, true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := reader.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		actual := make(map[string]float32)
+		tfd, err := reader.Next(nil)
+		for tfd != nil && err == nil {
+			actual[string(tfd.ID)] = tfd.Boost
+			tfd, err = reader.Next(nil)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected %v for %s in %s, got %v", expected, term, field, actual)
+		}
+	}
+	expectLengths := func(field string, expectedTotal, expectedDocs uint64) {
+		indexReader, err := idx.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := indexReader.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		total, docs, err := indexReader.(index.FieldLengthReader).FieldLengths(field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != expectedTotal || docs != expectedDocs {
+			t.Errorf("expected lengths %d in %d docs for %s, got %d in %d", expectedTotal, expectedDocs, field, total, docs)
+		}
+	}
+
+	partial := document.NewDocument("a")
+	partial.AddField(field("name", "gamma"))
+	err = idx.UpdateFields(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTerm("alpha", "name", map[string]float32{})
+	expectTerm("gamma", "name", map[string]float32{"a": 1})
+	expectTerm("one", "desc", map[string]float32{"a": 1})
+	expectTerm("two", "desc", map[string]float32{"a": 1})
+	expectLengths("name", 1, 1)
+	expectLengths("desc", 2, 1)
+
+	// a boost only update rewrites the postings kept
+	partial = document.NewDocument("a")
+	partial.Boost = 2
+	err = idx.UpdateFields(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTerm("gamma", "name", map[string]float32{"a": 2})
+	expectTerm("one", "desc", map[string]float32{"a": 2})
+
+	// the fields of a batch are analyzed with the boost of the document
+	batch := index.NewBatch()
+	partial = document.NewDocument("a")
+	partial.AddField(field("desc", "four"))
+	batch.UpdateFields(partial)
+	err = idx.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTerm("one", "desc", map[string]float32{})
+	expectTerm("two", "desc", map[string]float32{})
+	expectTerm("four", "desc", map[string]float32{"a": 2})
+	expectTerm("gamma", "name", map[string]float32{"a": 2})
+	expectLengths("desc", 1, 1)
+
+	indexReader, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := indexReader.Document("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]string)
+	for _, field := range stored.Fields {
+		values[field.Name()] = string(field.Value())
+	}
+	if !reflect.DeepEqual(values, map[string]string{"name": "gamma", "desc": "four"}) {
+		t.Errorf("unexpected stored fields %v", values)
+	}
+	count, err := indexReader.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 document, got %d", count)
+	}
+	err = indexReader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = idx.UpdateFields(document.NewDocument("b"))
+	if err != index.ErrorDocumentNotFound {
+		t.Errorf("expected document not found, got %v", err)
+	}
+}
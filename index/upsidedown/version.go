@@ -0,0 +1,137 @@
+package upsidedown
+
+import (
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/index/store"
+)
+
+// documentVersion returns the version of the document backIndexRow
+// belongs to, 0 when it is not indexed
+func documentVersion(backIndexRow *BackIndexRow) uint64 {
+	if backIndexRow == nil {
+		return 0
+	}
+	return backIndexRow.version
+}
+
+// checkVersion returns a conflict when the version of the document
+// indexed is not the expected one, 0 expects any version
+func checkVersion(id string, backIndexRow *BackIndexRow, expected uint64) error {
+	actual := documentVersion(backIndexRow)
+	if expected > 0 && expected != actual {
+		return &index.VersionConflictError{
+			ID:       id,
+			Expected: expected,
+			Actual:   actual,
+		}
+	}
+	return nil
+}
+
+// nextVersion sets the version of the back index row among the rows
+// replacing the document backIndexRow belongs to
+func nextVersion(backIndexRow *BackIndexRow, indexRows []index.IndexRow) {
+	for _, row := range indexRows {
+		if row, ok := row.(*BackIndexRow); ok {
+			row.version = documentVersion(backIndexRow) + 1
+		}
+	}
+}
+
+// backIndexClaim is the write of a back index row, old is the value it
+// replaces and value the one it writes, nil when there is none
+type backIndexClaim struct {
+	row      *BackIndexRow
+	old      []byte
+	value    []byte
+	expected uint64
+}
+
+// backIndexClaims returns the writes of the back index rows of the
+// rows, each checked against the value it replaces
+func backIndexClaims(addRowsAll [][]UpsideDownCouchRow, updateRowsAll [][]UpsideDownCouchRow, deleteRowsAll [][]UpsideDownCouchRow, replacedBackIndexRows []*BackIndexRow) []*backIndexClaim {
+	replaced := make(map[string][]byte, len(replacedBackIndexRows))
+	for _, row := range replacedBackIndexRows {
+		replaced[string(row.doc)] = row.stored
+	}
+	var rv []*backIndexClaim
+	for _, addRows := range addRowsAll {
+		for _, row := range addRows {
+			if row, ok := row.(*BackIndexRow); ok {
+				rv = append(rv, &backIndexClaim{row: row, value: row.Value()})
+			}
+		}
+	}
+	for _, updateRows := range updateRowsAll {
+		for _, row := range updateRows {
+			if row, ok := row.(*BackIndexRow); ok {
+				rv = append(rv, &backIndexClaim{row: row, old: replaced[string(row.doc)], value: row.Value(), expected: row.version - 1})
+			}
+		}
+	}
+	for _, deleteRows := range deleteRowsAll {
+		for _, row := range deleteRows {
+			if row, ok := row.(*BackIndexRow); ok {
+				rv = append(rv, &backIndexClaim{row: row, old: row.stored, expected: row.version})
+			}
+		}
+	}
+	return rv
+}
+
+// claimBackIndexRows writes the back index rows one at a time, each
+// only if the row stored is still the one the write replaces.  When
+// one was changed by another writer, the rows already written are
+// restored and a conflict is returned.
+func (udc *UpsideDownCouch) claimBackIndexRows(writer store.KVCompareAndSet, claims []*backIndexClaim) error {
+	for i, claim := range claims {
+		set, err := writer.CompareAndSet(claim.row.Table(), claim.row.Key(), claim.old, claim.value)
+		if err == nil && set {
+			continue
+		}
+		if err == nil {
+			err = udc.versionConflict(claim)
+		}
+		rerr := releaseBackIndexClaims(writer, claims[:i])
+		if rerr != nil {
+			return &index.ReleaseError{Err: err, Release: rerr}
+		}
+		return err
+	}
+	return nil
+}
+
+// releaseBackIndexClaims restores the rows the claims replaced, a row
+// another writer changed since is left alone.  The first error is
+// returned, the rows it left claimed are not restored.
+func releaseBackIndexClaims(writer store.KVCompareAndSet, claims []*backIndexClaim) error {
+	var rv error
+	for _, claim := range claims {
+		_, err := writer.CompareAndSet(claim.row.Table(), claim.row.Key(), claim.value, claim.old)
+		if err != nil && rv == nil {
+			rv = err
+		}
+	}
+	return rv
+}
+
+// versionConflict returns the conflict of a back index row changed by
+// another writer
+func (udc *UpsideDownCouch) versionConflict(claim *backIndexClaim) error {
+	rv := &index.VersionConflictError{
+		ID:       string(claim.row.doc),
+		Expected: claim.expected,
+	}
+	kvreader, err := udc.store.Reader()
+	if err != nil {
+		return rv
+	}
+	defer func() {
+		_ = kvreader.Close()
+	}()
+	backIndexRow, err := backIndexRowForDoc(kvreader, claim.row.doc)
+	if err == nil {
+		rv.Actual = documentVersion(backIndexRow)
+	}
+	return rv
+}
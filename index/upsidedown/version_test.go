@@ -0,0 +1,450 @@
+package upsidedown
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/index/store"
+	"github.com/wrble/flock/index/store/memory"
+	"github.com/wrble/flock/registry"
+)
+
+const sharedMemoryName = "shared_memory_test"
+
+func init() {
+	registry.RegisterKVStore(sharedMemoryName, func(mo store.MergeOperator, config map[string]interface{}) (store.KVStore, error) {
+		s, err := memory.New(mo, config)
+		if err != nil {
+			return nil, err
+		}
+		return &sharedStore{KVStore: s}, nil
+	})
+}
+
+var errBatchFailed = errors.New("batch failed")
+var errReleaseFailed = errors.New("release failed")
+
+// sharedStore is a memory store whose writers compare and set like
+// the stores shared by several processes, beforeClaim runs before the
+// next compare and set to write as another process would, failBatch
+// fails the next batch executed and failRelease the compare and sets
+// after the next failed batch or claim, which release the claims
+type sharedStore struct {
+	store.KVStore
+	beforeClaim func(s store.KVStore)
+	failBatch   bool
+	failRelease bool
+	releasing   bool
+}
+
+func (s *sharedStore) Writer() (store.KVWriter, error) {
+	w, err := s.KVStore.Writer()
+	if err != nil {
+		return nil, err
+	}
+	return &sharedWriter{KVWriter: w, store: s}, nil
+}
+
+type sharedWriter struct {
+	store.KVWriter
+	store *sharedStore
+}
+
+func (w *sharedWriter) ExecuteBatch(batch store.KVBatch) error {
+	if w.store.failBatch {
+		w.store.failBatch = false
+		w.store.releasing = w.store.failRelease
+		return errBatchFailed
+	}
+	return w.KVWriter.ExecuteBatch(batch)
+}
+
+func (w *sharedWriter) CompareAndSet(table string, key, old, value []byte) (bool, error) {
+	if w.store.beforeClaim != nil {
+		beforeClaim := w.store.beforeClaim
+		w.store.beforeClaim = nil
+		beforeClaim(w.store.KVStore)
+	}
+	if w.store.releasing {
+		return false, errReleaseFailed
+	}
+	reader, err := w.store.KVStore.Reader()
+	if err != nil {
+		return false, err
+	}
+	current, err := reader.Get(table, key)
+	_ = reader.Close()
+	if err != nil {
+		return false, err
+	}
+	if (current == nil) != (old == nil) || !bytes.Equal(current, old) {
+		w.store.releasing = w.store.failRelease
+		return false, nil
+	}
+	batch := w.NewBatch()
+	var row index.IndexRow
+	switch {
+	case value == nil:
+		err = batch.Delete(table, key)
+	case table == VersionTable:
+		row, err = NewFieldBoostRowKV(key, value)
+	default:
+		row, err = NewBackIndexRowKV(key, value)
+	}
+	if err == nil && row != nil {
+		err = batch.Set(table, row)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, w.KVWriter.ExecuteBatch(batch)
+}
+
+// bumpVersion raises the version of the back index row of a document
+// behind the back of the index
+func bumpVersion(t *testing.T, id string) func(s store.KVStore) {
+	return func(s store.KVStore) {
+		reader, err := s.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		backIndexRow, err := backIndexRowForDoc(reader, []byte(id))
+		_ = reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		backIndexRow.version++
+		writer, err := s.Writer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch := writer.NewBatch()
+		err = batch.Set(backIndexRow.Table(), backIndexRow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writer.ExecuteBatch(batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func openVersionTestIndex(t *testing.T, storeName string) *UpsideDownCouch {
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx, err := NewUpsideDownCouch(storeName, nil, analysisQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Open()
+	if err != nil {
+		t.Fatalf("error opening index: %v", err)
+	}
+	return idx.(*UpsideDownCouch)
+}
+
+func versionTestDoc(id, name string, version uint64) *document.Document {
+	doc := document.NewDocument(id)
+	doc.AddField(document.NewTextFieldWithIndexingOptions("name", []uint64{}, []byte(name), document.IndexField|document.StoreField))
+	doc.Version = version
+	return doc
+}
+
+func expectVersion(t *testing.T, idx *UpsideDownCouch, id string, expected uint64) {
+	indexReader, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := indexReader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	version, err := indexReader.(index.DocumentVersionReader).DocumentVersion(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != expected {
+		t.Errorf("expected version %d of %s, got %d", expected, id, version)
+	}
+	doc, err := indexReader.Document(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc != nil && doc.Version != expected {
+		t.Errorf("expected document version %d of %s, got %d", expected, id, doc.Version)
+	}
+}
+
+func expectConflict(t *testing.T, err error, id string, expected, actual uint64) {
+	conflict, ok := err.(*index.VersionConflictError)
+	if !ok {
+		t.Fatalf("expected a version conflict on %s, got %v", id, err)
+	}
+	if conflict.ID != id || conflict.Expected != expected || conflict.Actual != actual {
+		t.Errorf("expected conflict on %s expecting %d finding %d, got %v", id, expected, actual, conflict)
+	}
+}
+
+func TestIndexVersions(t *testing.T) {
+	idx := openVersionTestIndex(t, memory.Name)
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	err := idx.Update(versionTestDoc("a", "x", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersion(t, idx, "a", 1)
+	err = idx.Update(versionTestDoc("a", "y", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersion(t, idx, "a", 2)
+
+	// a stale version writes nothing
+	err = idx.Update(versionTestDoc("a", "z", 1))
+	expectConflict(t, err, "a", 1, 2)
+	expectVersion(t, idx, "a", 2)
+	err = idx.Update(versionTestDoc("b", "z", 1))
+	expectConflict(t, err, "b", 1, 0)
+	expectVersion(t, idx, "b", 0)
+
+	partial := versionTestDoc("a", "w", 1)
+	err = idx.UpdateFields(partial)
+	expectConflict(t, err, "a", 1, 2)
+	partial.Version = 2
+	err = idx.UpdateFields(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersion(t, idx, "a", 3)
+
+	// a conflict fails the whole batch
+	batch := index.NewBatch()
+	batch.Update(versionTestDoc("c", "x", 0))
+	batch.DeleteVersion("a", 2)
+	err = idx.Batch(batch)
+	expectConflict(t, err, "a", 2, 3)
+	expectVersion(t, idx, "c", 0)
+	expectVersion(t, idx, "a", 3)
+
+	batch = index.NewBatch()
+	batch.Update(versionTestDoc("c", "x", 0))
+	batch.DeleteVersion("a", 3)
+	err = idx.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersion(t, idx, "c", 1)
+	expectVersion(t, idx, "a", 0)
+}
+
+func TestIndexVersionsSharedStore(t *testing.T) {
+	idx := openVersionTestIndex(t, sharedMemoryName)
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	shared := idx.store.(*sharedStore)
+
+	err := idx.Update(versionTestDoc("a", "x", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Update(versionTestDoc("a", "y", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersion(t, idx, "a", 2)
+
+	// another process writes the document while it is written
+	shared.beforeClaim = bumpVersion(t, "a")
+	err = idx.Update(versionTestDoc("a", "z", 0))
+	expectConflict(t, err, "a", 2, 3)
+	expectVersion(t, idx, "a", 3)
+
+	indexReader, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := indexReader.TermFieldReader([]byte("z"), "name", false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Count() != 0 {
+		t.Errorf("expected the postings of the conflicting write not to be written")
+	}
+	err = reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = indexReader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the back index rows claimed before the conflict are restored
+	shared.beforeClaim = bumpVersion(t, "a")
+	batch := index.NewBatch()
+	batch.Update(versionTestDoc("b", "x", 0))
+	batch.Update(versionTestDoc("a", "z", 0))
+	err = idx.Batch(batch)
+	expectConflict(t, err, "a", 3, 4)
+	expectVersion(t, idx, "b", 0)
+
+	err = idx.Delete("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersion(t, idx, "a", 0)
+
+	// the claims are released when the batch of the other rows fails
+	err = idx.Update(versionTestDoc("c", "x", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared.failBatch = true
+	err = idx.Update(versionTestDoc("c", "y", 0))
+	if err != errBatchFailed {
+		t.Fatalf("expected the batch error, got %v", err)
+	}
+	expectVersion(t, idx, "c", 1)
+	err = idx.Update(versionTestDoc("c", "y", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersion(t, idx, "c", 2)
+}
+
+func TestIndexVersionsReleaseErrors(t *testing.T) {
+	idx := openVersionTestIndex(t, sharedMemoryName)
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	shared := idx.store.(*sharedStore)
+	shared.failRelease = true
+
+	for _, id := range []string{"a", "c"} {
+		err := idx.Update(versionTestDoc(id, "x", 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the batch error is kept along with the release error
+	shared.failBatch = true
+	err := idx.Update(versionTestDoc("c", "y", 0))
+	releaseErr, ok := err.(*index.ReleaseError)
+	if !ok || releaseErr.Err != errBatchFailed || releaseErr.Release != errReleaseFailed {
+		t.Fatalf("expected the batch and release errors, got %v", err)
+	}
+	shared.releasing = false
+
+	// and so is the version conflict
+	shared.beforeClaim = bumpVersion(t, "a")
+	batch := index.NewBatch()
+	batch.Update(versionTestDoc("b", "x", 0))
+	batch.Update(versionTestDoc("a", "z", 0))
+	err = idx.Batch(batch)
+	if _, ok := err.(*index.ReleaseError); !ok {
+		t.Fatalf("expected a release error, got %v", err)
+	}
+	conflict, ok := index.AsVersionConflict(err)
+	if !ok || conflict.ID != "a" || conflict.Expected != 1 || conflict.Actual != 2 {
+		t.Errorf("expected the conflict on a, got %v", err)
+	}
+}
+
+// setFieldBoost sets the highest boost of a field behind the back of
+// the index
+func setFieldBoost(t *testing.T, field uint16, boost float32) func(s store.KVStore) {
+	return func(s store.KVStore) {
+		writer, err := s.Writer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch := writer.NewBatch()
+		row := NewFieldBoostRow(field, boost)
+		err = batch.Set(row.Table(), row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writer.ExecuteBatch(batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIndexFieldBoostsSharedStore(t *testing.T) {
+	idx := openVersionTestIndex(t, sharedMemoryName)
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	shared := idx.store.(*sharedStore)
+
+	boostedDoc := func(id string, boost float64) *document.Document {
+		doc := versionTestDoc(id, "x", 0)
+		doc.SetFieldBoost("name", boost)
+		return doc
+	}
+	expectMaxBoost := func(expected float64) {
+		indexReader, err := idx.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := indexReader.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		boost, err := indexReader.(index.FieldBoostReader).MaxFieldBoost("name")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if boost != expected {
+			t.Errorf("expected max boost %f, got %f", expected, boost)
+		}
+	}
+
+	err := idx.Update(boostedDoc("a", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectMaxBoost(2)
+	fieldIndex, _ := idx.fieldCache.FieldNamed("name", false)
+
+	// another process raises the boost higher while it is written
+	shared.beforeClaim = setFieldBoost(t, uint16(fieldIndex), 5)
+	err = idx.Update(boostedDoc("b", 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectMaxBoost(5)
+
+	// or less high
+	shared.beforeClaim = setFieldBoost(t, uint16(fieldIndex), 6)
+	err = idx.Update(boostedDoc("c", 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectMaxBoost(7)
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/wrble/flock/index/store"
 	"github.com/wrble/flock/mapping"
 	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/query"
 )
 
 type indexAliasImpl struct {
@@ -70,6 +71,54 @@ func (i *indexAliasImpl) Index(id string, data interface{}) error {
 	return i.indexes[0].Index(id, data)
 }
 
+func (i *indexAliasImpl) UpdateFields(id string, fields map[string]interface{}) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return err
+	}
+
+	return i.indexes[0].UpdateFields(id, fields)
+}
+
+func (i *indexAliasImpl) IndexWithVersion(id string, data interface{}, version uint64) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return err
+	}
+
+	return i.indexes[0].IndexWithVersion(id, data, version)
+}
+
+func (i *indexAliasImpl) UpdateFieldsWithVersion(id string, fields map[string]interface{}, version uint64) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return err
+	}
+
+	return i.indexes[0].UpdateFieldsWithVersion(id, fields, version)
+}
+
 func (i *indexAliasImpl) Delete(id string) error {
 	i.mutex.RLock()
 	defer i.mutex.RUnlock()
@@ -86,6 +135,54 @@ func (i *indexAliasImpl) Delete(id string) error {
 	return i.indexes[0].Delete(id)
 }
 
+func (i *indexAliasImpl) DeleteWithVersion(id string, version uint64) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return err
+	}
+
+	return i.indexes[0].DeleteWithVersion(id, version)
+}
+
+func (i *indexAliasImpl) DeleteByQuery(ctx context.Context, q query.Query) (*ByQueryStatus, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return nil, ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return i.indexes[0].DeleteByQuery(ctx, q)
+}
+
+func (i *indexAliasImpl) UpdateByQuery(ctx context.Context, q query.Query, updater Updater) (*ByQueryStatus, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return nil, ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return i.indexes[0].UpdateByQuery(ctx, q, updater)
+}
+
 func (i *indexAliasImpl) Batch(b *Batch) error {
 	i.mutex.RLock()
 	defer i.mutex.RUnlock()
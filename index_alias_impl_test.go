@@ -28,6 +28,7 @@ import (
 	"github.com/wrble/flock/mapping"
 	"github.com/wrble/flock/numeric"
 	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/query"
 )
 
 func TestIndexAliasSingle(t *testing.T) {
@@ -1249,10 +1250,34 @@ func (i *stubIndex) Index(id string, data interface{}) error {
 	return i.err
 }
 
+func (i *stubIndex) UpdateFields(id string, fields map[string]interface{}) error {
+	return i.err
+}
+
+func (i *stubIndex) IndexWithVersion(id string, data interface{}, version uint64) error {
+	return i.err
+}
+
+func (i *stubIndex) UpdateFieldsWithVersion(id string, fields map[string]interface{}, version uint64) error {
+	return i.err
+}
+
 func (i *stubIndex) Delete(id string) error {
 	return i.err
 }
 
+func (i *stubIndex) DeleteWithVersion(id string, version uint64) error {
+	return i.err
+}
+
+func (i *stubIndex) DeleteByQuery(ctx context.Context, q query.Query) (*ByQueryStatus, error) {
+	return nil, i.err
+}
+
+func (i *stubIndex) UpdateByQuery(ctx context.Context, q query.Query, updater Updater) (*ByQueryStatus, error) {
+	return nil, i.err
+}
+
 func (i *stubIndex) Batch(b *Batch) error {
 	return i.err
 }
@@ -0,0 +1,287 @@
+package flock
+
+import (
+	"time"
+
+	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/query"
+	"golang.org/x/net/context"
+)
+
+// ByQueryBatchSize is the number of matching documents DeleteByQuery
+// and UpdateByQuery write per batch
+var ByQueryBatchSize = 100
+
+// ByQueryStatus counts the documents DeleteByQuery and UpdateByQuery
+// went through so far
+type ByQueryStatus struct {
+	// Matched is the number of documents matching the query
+	Matched uint64 `json:"matched"`
+	Deleted uint64 `json:"deleted"`
+	Updated uint64 `json:"updated"`
+	// Noops is the number of documents the Updater left as they were
+	Noops uint64 `json:"noops"`
+	// Conflicts is the number of documents written or deleted by
+	// another writer since they matched, they are left as they are
+	Conflicts uint64        `json:"conflicts"`
+	Batches   uint64        `json:"batches"`
+	Took      time.Duration `json:"took"`
+}
+
+// A ByQueryProgress is called with the counts so far after every batch
+// DeleteByQuery and UpdateByQuery write
+type ByQueryProgress func(status ByQueryStatus)
+
+type byQueryProgressKey struct{}
+
+// WithByQueryProgress returns a context making DeleteByQuery and
+// UpdateByQuery report their progress to progress
+func WithByQueryProgress(ctx context.Context, progress ByQueryProgress) context.Context {
+	return context.WithValue(ctx, byQueryProgressKey{}, progress)
+}
+
+// An Updater returns the fields UpdateByQuery replaces in a matching
+// document, read with its stored fields, as UpdateFields takes them.
+// Returning no fields leaves the document as it is.
+type Updater interface {
+	Update(doc *document.Document) (map[string]interface{}, error)
+}
+
+// UpdaterFunc adapts a function to an Updater
+type UpdaterFunc func(doc *document.Document) (map[string]interface{}, error)
+
+func (f UpdaterFunc) Update(doc *document.Document) (map[string]interface{}, error) {
+	return f(doc)
+}
+
+// DeleteByQuery deletes the documents matching the query, in batches
+// of ByQueryBatchSize.  A document written again since it matched is
+// kept and counted as a conflict.  When the context is done the
+// batches written so far stay deleted.
+func (i *indexImpl) DeleteByQuery(ctx context.Context, q query.Query) (*ByQueryStatus, error) {
+	return i.byQuery(ctx, q, func(batch *index.Batch, indexReader index.IndexReader, id string, status *ByQueryStatus) error {
+		var version uint64
+		if versionReader, ok := indexReader.(index.DocumentVersionReader); ok {
+			var err error
+			version, err = versionReader.DocumentVersion(id)
+			if err != nil {
+				return err
+			}
+		}
+		batch.DeleteVersion(id, version)
+		return nil
+	}, func(status *ByQueryStatus, written uint64) {
+		status.Deleted += written
+	})
+}
+
+// UpdateByQuery replaces the fields the updater returns for each of
+// the documents matching the query, like UpdateFields does, in batches
+// of ByQueryBatchSize.  A document written again since it was read is
+// left as it is and counted as a conflict.  When the context is done
+// the batches written so far stay updated.
+func (i *indexImpl) UpdateByQuery(ctx context.Context, q query.Query, updater Updater) (*ByQueryStatus, error) {
+	return i.byQuery(ctx, q, func(batch *index.Batch, indexReader index.IndexReader, id string, status *ByQueryStatus) error {
+		doc, err := indexReader.Document(id)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			// deleted since it matched
+			status.Conflicts++
+			return nil
+		}
+		fields, err := updater.Update(doc)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			status.Noops++
+			return nil
+		}
+		partial, err := mapFields(i.m, id, fields)
+		if err != nil {
+			return err
+		}
+		partial.Version = doc.Version
+		// a document keeping its source is mapped again whole
+		full, err := sourceDocument(i.m, doc, partial)
+		if err != nil {
+			return err
+		}
+		if full != nil {
+			batch.Update(full)
+			return nil
+		}
+		batch.UpdateFields(partial)
+		return nil
+	}, func(status *ByQueryStatus, written uint64) {
+		status.Updated += written
+	})
+}
+
+// byQuery streams the ids of the documents matching the query, adds
+// the operation of each to a batch with add and writes the batch every
+// ByQueryBatchSize documents, written counts the operations written
+func (i *indexImpl) byQuery(ctx context.Context, q query.Query, add func(batch *index.Batch, indexReader index.IndexReader, id string, status *ByQueryStatus) error, written func(status *ByQueryStatus, written uint64)) (status *ByQueryStatus, err error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return nil, ErrorIndexClosed
+	}
+
+	start := time.Now()
+	status = &ByQueryStatus{}
+	progress, _ := ctx.Value(byQueryProgressKey{}).(ByQueryProgress)
+
+	indexReader, err := i.i.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := indexReader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	searcher, err := q.Searcher(indexReader, i.m, search.SearcherOptions{})
+	if err != nil {
+		return nil, err
+	}
+	searcher = topLevelOnly(searcher, i.m, indexReader)
+	defer func() {
+		if serr := searcher.Close(); err == nil && serr != nil {
+			err = serr
+		}
+	}()
+
+	batch := index.NewBatch()
+	flush := func() error {
+		n, err := i.writeByQuery(batch, status)
+		if err != nil {
+			return err
+		}
+		written(status, n)
+		status.Batches++
+		status.Took = time.Since(start)
+		if progress != nil {
+			progress(*status)
+		}
+		batch.Reset()
+		return nil
+	}
+
+	searchContext := &search.SearchContext{
+		DocumentMatchPool: search.NewDocumentMatchPool(searcher.DocumentMatchPoolSize(), 0),
+	}
+	pending := 0
+	for {
+		select {
+		case <-ctx.Done():
+			status.Took = time.Since(start)
+			return status, ctx.Err()
+		default:
+		}
+
+		var next *search.DocumentMatch
+		next, err = searcher.Next(searchContext)
+		if err != nil {
+			return status, err
+		}
+		if next == nil {
+			break
+		}
+		var id string
+		id, err = indexReader.ExternalID(next.IndexInternalID)
+		searchContext.DocumentMatchPool.Put(next)
+		if err != nil {
+			return status, err
+		}
+		status.Matched++
+		err = add(batch, indexReader, id, status)
+		if err != nil {
+			return status, err
+		}
+		pending++
+		if pending >= ByQueryBatchSize {
+			err = flush()
+			if err != nil {
+				return status, err
+			}
+			pending = 0
+		}
+	}
+	if pending > 0 {
+		err = flush()
+		if err != nil {
+			return status, err
+		}
+	}
+	status.Took = time.Since(start)
+	return status, nil
+}
+
+// writeByQuery writes the batch, the operations conflicting with other
+// writers are dropped and counted.  It returns the number of operations
+// written.
+func (i *indexImpl) writeByQuery(batch *index.Batch, status *ByQueryStatus) (uint64, error) {
+	for {
+		n := uint64(len(batch.IndexOps) + len(batch.UpdateFieldsOps))
+		if n == 0 {
+			return 0, nil
+		}
+		err := i.i.Batch(batch)
+		conflict, ok := index.AsVersionConflict(err)
+		if !ok {
+			if err == index.ErrorDocumentNotFound {
+				err = ErrorDocumentNotFound
+			}
+			return n, err
+		}
+		// the batch stops at the first conflict, the others are looked
+		// up at once so the batch is written again only once
+		conflicts, err := i.versionConflicts(batch)
+		if err != nil {
+			return 0, err
+		}
+		conflicts[conflict.ID] = struct{}{}
+		for id := range conflicts {
+			delete(batch.IndexOps, id)
+			delete(batch.UpdateFieldsOps, id)
+			delete(batch.Versions, id)
+			status.Conflicts++
+		}
+	}
+}
+
+// versionConflicts returns the documents of the batch whose versions
+// the index no longer holds
+func (i *indexImpl) versionConflicts(batch *index.Batch) (rv map[string]struct{}, err error) {
+	rv = make(map[string]struct{})
+	indexReader, err := i.i.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := indexReader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	versionReader, ok := indexReader.(index.DocumentVersionReader)
+	if !ok {
+		return rv, nil
+	}
+	for id, expected := range batch.Versions {
+		actual, err := versionReader.DocumentVersion(id)
+		if err != nil {
+			return nil, err
+		}
+		if actual != expected {
+			rv[id] = struct{}{}
+		}
+	}
+	return rv, nil
+}
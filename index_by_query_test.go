@@ -0,0 +1,180 @@
+package flock
+
+import (
+	"testing"
+
+	"github.com/wrble/flock/document"
+	"golang.org/x/net/context"
+)
+
+func byQueryTestIndex(t *testing.T) Index {
+	index, err := NewTempIndex(NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string]map[string]interface{}{
+		"a": {"kind": "old", "name": "one"},
+		"b": {"kind": "old", "name": "two"},
+		"c": {"kind": "old", "name": "three"},
+		"d": {"kind": "new", "name": "four"},
+		"e": {"kind": "new", "name": "five"},
+	}
+	for id, doc := range docs {
+		err = index.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return index
+}
+
+func TestDeleteByQuery(t *testing.T) {
+	defer func(size int) {
+		ByQueryBatchSize = size
+	}(ByQueryBatchSize)
+	ByQueryBatchSize = 2
+
+	index := byQueryTestIndex(t)
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var progress []ByQueryStatus
+	ctx := WithByQueryProgress(context.Background(), func(status ByQueryStatus) {
+		progress = append(progress, status)
+	})
+	q := NewTermQuery("old")
+	q.SetField("kind")
+	status, err := index.DeleteByQuery(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Matched != 3 || status.Deleted != 3 || status.Batches != 2 || status.Conflicts != 0 {
+		t.Errorf("expected 3 documents deleted in 2 batches, got %+v", status)
+	}
+	if len(progress) != 2 || progress[0].Deleted != 2 || progress[1].Deleted != 3 {
+		t.Errorf("expected progress after each batch, got %+v", progress)
+	}
+	count, err := index.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 documents left, got %d", count)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		doc, err := index.Document(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc != nil {
+			t.Errorf("expected document %s to be deleted", id)
+		}
+	}
+
+	// a cancelled context writes nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q = NewTermQuery("new")
+	q.SetField("kind")
+	status, err = index.DeleteByQuery(ctx, q)
+	if err != context.Canceled {
+		t.Errorf("expected the context error, got %v", err)
+	}
+	if status.Deleted != 0 {
+		t.Errorf("expected nothing deleted, got %+v", status)
+	}
+	count, err = index.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 documents left, got %d", count)
+	}
+}
+
+func TestUpdateByQuery(t *testing.T) {
+	index := byQueryTestIndex(t)
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	q := NewTermQuery("old")
+	q.SetField("kind")
+	status, err := index.UpdateByQuery(context.Background(), q, UpdaterFunc(func(doc *document.Document) (map[string]interface{}, error) {
+		if doc.ID == "b" {
+			return nil, nil
+		}
+		return map[string]interface{}{"kind": "archived"}, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Matched != 3 || status.Updated != 2 || status.Noops != 1 || status.Batches != 1 {
+		t.Errorf("expected 2 documents updated and 1 left, got %+v", status)
+	}
+
+	for kind, expected := range map[string]uint64{"old": 1, "archived": 2, "new": 2} {
+		q = NewTermQuery(kind)
+		q.SetField("kind")
+		res, err := index.Search(NewSearchRequest(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Total != expected {
+			t.Errorf("expected %d documents of kind %s, got %d", expected, kind, res.Total)
+		}
+	}
+	doc, err := index.Document("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != 2 {
+		t.Errorf("expected version 2 of the document updated, got %d", doc.Version)
+	}
+}
+
+func TestUpdateByQueryConflicts(t *testing.T) {
+	index := byQueryTestIndex(t)
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// another writer changes a and c once they were read
+	q := NewTermQuery("old")
+	q.SetField("kind")
+	status, err := index.UpdateByQuery(context.Background(), q, UpdaterFunc(func(doc *document.Document) (map[string]interface{}, error) {
+		if doc.ID != "b" {
+			err := index.Index(doc.ID, map[string]interface{}{"kind": "old", "name": "changed"})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return map[string]interface{}{"kind": "archived"}, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Matched != 3 || status.Updated != 1 || status.Conflicts != 2 {
+		t.Errorf("expected 1 document updated and 2 conflicts, got %+v", status)
+	}
+
+	q = NewTermQuery("archived")
+	q.SetField("kind")
+	res, err := index.Search(NewSearchRequest(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 || res.Hits[0].ID != "b" {
+		t.Errorf("expected only b to be archived, got %v", res.Hits)
+	}
+}
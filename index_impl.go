@@ -34,6 +34,7 @@ import (
 	"github.com/wrble/flock/search/collector"
 	"github.com/wrble/flock/search/facet"
 	"github.com/wrble/flock/search/highlight"
+	"github.com/wrble/flock/search/searcher"
 )
 
 type indexImpl struct {
@@ -63,14 +64,24 @@ func newIndexUsing(path string, mapping mapping.IndexMapping, indexType string,
 		return nil, err
 	}
 
-	if kvconfig == nil {
-		kvconfig = map[string]interface{}{}
+	// copy, as the config is completed below and may be shared,
+	// like Config.DefaultKVConfig
+	config := map[string]interface{}{}
+	for k, v := range kvconfig {
+		config[k] = v
 	}
+	kvconfig = config
 
 	if kvstore == "" {
 		return nil, fmt.Errorf("bleve not configured for file based indexing")
 	}
 
+	// indexes sharing a storage backend, such as a cassandra keyspace,
+	// are kept apart by namespace, recorded in the index meta
+	if _, ok := kvconfig["namespace"]; !ok && path != "" {
+		kvconfig["namespace"] = path
+	}
+
 	rv := indexImpl{
 		path: path,
 		name: path,
@@ -84,12 +95,14 @@ func newIndexUsing(path string, mapping mapping.IndexMapping, indexType string,
 		if err != nil {
 			return nil, err
 		}
-		kvconfig["create_if_missing"] = true
 		kvconfig["error_if_exists"] = true
 		kvconfig["path"] = indexStorePath(path)
 	} else {
 		kvconfig["path"] = ""
 	}
+	// stores without a path, such as cassandra, provision their
+	// keyspace and tables on create
+	kvconfig["create_if_missing"] = true
 
 	// open the index
 	indexTypeConstructor := registry.IndexTypeConstructorByName(rv.meta.IndexType)
@@ -171,6 +184,10 @@ func openIndexUsing(path string, runtimeConfig map[string]interface{}) (rv *inde
 		if err == index.ErrorUnknownStorageType {
 			return nil, ErrorUnknownStorageType
 		}
+		if serr, ok := err.(*store.SchemaVersionError); ok {
+			logger.Printf("cannot open index %s: %v", path, serr)
+			return nil, ErrorIndexSchemaTooNew
+		}
 		return nil, err
 	}
 
@@ -234,6 +251,14 @@ func (i *indexImpl) Mapping() mapping.IndexMapping {
 // The IndexMapping for this index will determine
 // how the object is indexed.
 func (i *indexImpl) Index(id string, data interface{}) (err error) {
+	return i.IndexWithVersion(id, data, 0)
+}
+
+// IndexWithVersion indexes the object only if the
+// index holds the version of the document, 0 accepts
+// any version.  Otherwise an *index.VersionConflictError
+// is returned.
+func (i *indexImpl) IndexWithVersion(id string, data interface{}, version uint64) (err error) {
 	if id == "" {
 		return ErrorEmptyID
 	}
@@ -250,10 +275,62 @@ func (i *indexImpl) Index(id string, data interface{}) (err error) {
 	if err != nil {
 		return
 	}
+	doc.Version = version
 	err = i.i.Update(doc)
 	return
 }
 
+// UpdateFields maps the fields and replaces those of
+// the same name of the document indexed with them.
+// A document keeping its source is mapped again whole
+// from its source with the fields merged in.
+func (i *indexImpl) UpdateFields(id string, fields map[string]interface{}) (err error) {
+	return i.UpdateFieldsWithVersion(id, fields, 0)
+}
+
+// UpdateFieldsWithVersion updates the fields only if
+// the index holds the version of the document, 0
+// accepts any version.
+func (i *indexImpl) UpdateFieldsWithVersion(id string, fields map[string]interface{}, version uint64) (err error) {
+	if id == "" {
+		return ErrorEmptyID
+	}
+
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	doc, err := mapFields(i.m, id, fields)
+	if err != nil {
+		return
+	}
+	doc.Version = version
+	if storesSource(i.m) {
+		err = i.updateFromSource(doc)
+	} else {
+		err = i.i.UpdateFields(doc)
+	}
+	if err == index.ErrorDocumentNotFound {
+		err = ErrorDocumentNotFound
+	}
+	return
+}
+
+// mapFields maps the fields of a partial update, the
+// composite fields are left out
+func mapFields(m mapping.IndexMapping, id string, fields map[string]interface{}) (*document.Document, error) {
+	doc := document.NewDocument(id)
+	err := m.MapDocument(doc, fields)
+	if err != nil {
+		return nil, err
+	}
+	doc.CompositeFields = nil
+	return doc, nil
+}
+
 // IndexAdvanced takes a document.Document object
 // skips the mapping and indexes it.
 func (i *indexImpl) IndexAdvanced(doc *document.Document) (err error) {
@@ -290,6 +367,26 @@ func (i *indexImpl) Delete(id string) (err error) {
 	return
 }
 
+// DeleteWithVersion deletes the document only if the
+// index holds its version, 0 accepts any version.
+func (i *indexImpl) DeleteWithVersion(id string, version uint64) (err error) {
+	if id == "" {
+		return ErrorEmptyID
+	}
+
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	batch := index.NewBatch()
+	batch.DeleteVersion(id, version)
+	err = i.i.Batch(batch)
+	return
+}
+
 // Batch executes multiple Index and Delete
 // operations at the same time.  There are often
 // significant performance benefits when performing
@@ -302,7 +399,11 @@ func (i *indexImpl) Batch(b *Batch) error {
 		return ErrorIndexClosed
 	}
 
-	return i.i.Batch(b.internal)
+	err := i.i.Batch(b.internal)
+	if err == index.ErrorDocumentNotFound {
+		err = ErrorDocumentNotFound
+	}
+	return err
 }
 
 // Document is used to find the values of all the
@@ -343,6 +444,12 @@ func (i *indexImpl) DocCount() (count uint64, err error) {
 		return 0, ErrorIndexClosed
 	}
 
+	// prefer the stored counter, it includes the writes of other
+	// processes sharing the store
+	if counter, ok := i.i.(index.DocCounter); ok {
+		return counter.DocCount()
+	}
+
 	// open a reader for this search
 	indexReader, err := i.i.Reader()
 	if err != nil {
@@ -392,10 +499,14 @@ func (i *indexImpl) SearchInContext(ctx context.Context, req *SearchRequest) (sr
 	searcher, err := req.Query.Searcher(indexReader, i.m, search.SearcherOptions{
 		Explain:            req.Explain,
 		IncludeTermVectors: req.IncludeLocations || req.Highlight != nil,
+		Similarity: func(field string) interface{} {
+			return i.m.SimilarityForPath(field)
+		},
 	})
 	if err != nil {
 		return nil, err
 	}
+	searcher = topLevelOnly(searcher, i.m, indexReader)
 	defer func() {
 		if serr := searcher.Close(); err == nil && serr != nil {
 			err = serr
@@ -456,10 +567,18 @@ func (i *indexImpl) SearchInContext(ctx context.Context, req *SearchRequest) (sr
 		}
 	}
 
+	versionReader, _ := indexReader.(index.DocumentVersionReader)
 	for _, hit := range hits {
-		if len(req.Fields) > 0 || highlighter != nil {
+		if len(req.Fields) > 0 || highlighter != nil || req.Source != nil {
 			doc, err := indexReader.Document(hit.ID)
 			if err == nil && doc != nil {
+				hit.Version = doc.Version
+				if req.Source != nil && len(doc.Source) > 0 {
+					hit.Source, err = req.Source.Filter(doc.Source)
+					if err != nil {
+						return nil, err
+					}
+				}
 				if len(req.Fields) > 0 {
 					for _, f := range req.Fields {
 						for _, docF := range doc.Fields {
@@ -517,6 +636,11 @@ func (i *indexImpl) SearchInContext(ctx context.Context, req *SearchRequest) (sr
 				// was unable to be found during document lookup
 				return nil, ErrorIndexReadInconsistency
 			}
+		} else if versionReader != nil {
+			hit.Version, err = versionReader.DocumentVersion(hit.ID)
+			if err != nil {
+				return nil, err
+			}
 		}
 		if i.name != "" {
 			hit.Index = i.name
@@ -545,6 +669,8 @@ func (i *indexImpl) SearchInContext(ctx context.Context, req *SearchRequest) (sr
 		MaxScore: collector.MaxScore(),
 		Took:     searchDuration,
 		Facets:   collector.FacetResults(),
+
+		TotalIsLowerBound: collector.TotalIsLowerBound(),
 	}, nil
 }
 
@@ -757,3 +883,24 @@ func (f *indexImplFieldDict) Close() error {
 	}
 	return f.indexReader.Close()
 }
+
+// nestedMapping is implemented by index mappings which know whether
+// they map nested documents
+type nestedMapping interface {
+	HasNested() bool
+}
+
+// topLevelOnly keeps the nested documents the searcher matches out of
+// its matches, they are only found through nested queries.  It looks
+// up the external id of every match, so it is skipped when the mapping
+// has no nested mapping.  Nested documents indexed with IndexAdvanced
+// are only kept out when the mapping has one.
+func topLevelOnly(s search.Searcher, m mapping.IndexMapping, indexReader index.IndexReader) search.Searcher {
+	if nm, ok := m.(nestedMapping); ok && !nm.HasNested() {
+		return s
+	}
+	return searcher.NewFilteringSearcher(s, func(d *search.DocumentMatch) bool {
+		id, err := indexReader.ExternalID(d.IndexInternalID)
+		return err != nil || !document.IsNestedID(id)
+	})
+}
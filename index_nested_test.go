@@ -0,0 +1,177 @@
+package flock
+
+import (
+	"testing"
+
+	"github.com/wrble/flock/mapping"
+	"github.com/wrble/flock/search/query"
+)
+
+func nestedTestIndex(t *testing.T) Index {
+	m := NewIndexMapping()
+	m.DefaultMapping.AddSubDocumentMapping("comments", mapping.NewDocumentNestedMapping())
+	index, err := NewTempIndex(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string]map[string]interface{}{
+		"a": {
+			"title": "first",
+			"comments": []interface{}{
+				map[string]interface{}{"author": "bob", "score": 2},
+				map[string]interface{}{"author": "sue", "score": 8},
+			},
+		},
+		"b": {
+			"title": "second",
+			"comments": []interface{}{
+				map[string]interface{}{"author": "bob", "score": 9},
+			},
+		},
+	}
+	for id, doc := range docs {
+		err = index.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return index
+}
+
+func bobsHighScores() *query.NestedQuery {
+	author := NewTermQuery("bob")
+	author.SetField("comments.author")
+	min := 5.0
+	score := NewNumericRangeQuery(&min, nil)
+	score.SetField("comments.score")
+	return NewNestedQuery("comments", NewConjunctionQuery(author, score))
+}
+
+func TestNestedQuery(t *testing.T) {
+	index := nestedTestIndex(t)
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// the conditions hold within one comment only in b
+	q := bobsHighScores()
+	q.SetInnerHits(3)
+	res, err := index.Search(NewSearchRequest(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 || res.Hits[0].ID != "b" {
+		t.Fatalf("expected b only, got %v", res.Hits)
+	}
+	inner := res.Hits[0].InnerHits["comments"]
+	if len(inner) != 1 || inner[0].ID != "b" || inner[0].Nested.Path != "comments" || inner[0].Nested.Offset != 0 {
+		t.Errorf("expected the first comment of b as inner hit, got %v", inner)
+	}
+
+	// flattened, the conditions hold across the comments of a
+	author := NewTermQuery("bob")
+	author.SetField("comments.author")
+	res, err = index.Search(NewSearchRequest(author))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 0 {
+		t.Errorf("expected the nested documents not to be found at the top level, got %v", res.Hits)
+	}
+
+	count, err := index.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 documents, got %d", count)
+	}
+}
+
+func TestNestedQueryScoreModes(t *testing.T) {
+	index := nestedTestIndex(t)
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	scores := make(map[string]float64)
+	for _, mode := range []string{"avg", "max", "min", "sum"} {
+		sue := NewTermQuery("sue")
+		sue.SetField("comments.author")
+		bob := NewTermQuery("bob")
+		bob.SetField("comments.author")
+		bob.SetBoost(2)
+		q := NewNestedQuery("comments", NewDisjunctionQuery(sue, bob))
+		q.SetScoreMode(mode)
+		req := NewSearchRequest(q)
+		req.SortBy([]string{"_id"})
+		res, err := index.Search(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Total != 2 || res.Hits[0].ID != "a" {
+			t.Fatalf("%s: expected a and b, got %v", mode, res.Hits)
+		}
+		scores[mode] = res.Hits[0].Score
+	}
+	if !(scores["min"] < scores["avg"] && scores["avg"] < scores["max"] && scores["max"] < scores["sum"]) {
+		t.Errorf("expected min < avg < max < sum for a, got %v", scores)
+	}
+
+	q := NewNestedQuery("comments", NewTermQuery("bob"))
+	q.SetScoreMode("median")
+	_, err := index.Search(NewSearchRequest(q))
+	if err == nil {
+		t.Errorf("expected an error for an unknown score mode")
+	}
+}
+
+func TestNestedReindex(t *testing.T) {
+	index := nestedTestIndex(t)
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// fewer comments leave no stale nested documents behind
+	err := index.Index("b", map[string]interface{}{"title": "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := index.Search(NewSearchRequest(bobsHighScores()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 0 {
+		t.Errorf("expected the comments of b to be gone, got %v", res.Hits)
+	}
+
+	err = index.Delete("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	author := NewTermQuery("sue")
+	author.SetField("comments.author")
+	res, err = index.Search(NewSearchRequest(NewNestedQuery("comments", author)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 0 {
+		t.Errorf("expected the comments of a to be gone, got %v", res.Hits)
+	}
+	count, err := index.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 document, got %d", count)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"reflect"
 	"time"
 
+	"github.com/wrble/flock/document"
 	"github.com/wrble/flock/registry"
 )
 
@@ -37,9 +38,13 @@ import (
 // If not explicitly mapped, default mapping operations
 // are used.  To disable this automatic handling, set
 // Dynamic to false.
+// A sub-section with Nested set is indexed as nested
+// documents, one for each of its objects, so queries
+// can match the fields of a single object.
 type DocumentMapping struct {
 	Enabled         bool                        `json:"enabled"`
 	Dynamic         bool                        `json:"dynamic"`
+	Nested          bool                        `json:"nested,omitempty"`
 	Properties      map[string]*DocumentMapping `json:"properties,omitempty"`
 	Fields          []*FieldMapping             `json:"fields,omitempty"`
 	DefaultAnalyzer string                      `json:"default_analyzer"`
@@ -75,6 +80,15 @@ func (dm *DocumentMapping) Validate(cache *registry.Cache) error {
 				return err
 			}
 		}
+		if field.Similarity != "" {
+			_, err = cache.SimilarityNamed(field.Similarity)
+			if err != nil {
+				return err
+			}
+		}
+		if field.Boost < 0 {
+			return fmt.Errorf("negative field boost: %f", field.Boost)
+		}
 		switch field.Type {
 		case "text", "datetime", "number", "boolean", "geopoint":
 		default:
@@ -84,6 +98,17 @@ func (dm *DocumentMapping) Validate(cache *registry.Cache) error {
 	return nil
 }
 
+// similarityNameForPath attempts to first find the field
+// described by this path, then returns the similarity
+// configured for that field
+func (dm *DocumentMapping) similarityNameForPath(path string) string {
+	field := dm.fieldDescribedByPath(path)
+	if field != nil {
+		return field.Similarity
+	}
+	return ""
+}
+
 // analyzerNameForPath attempts to first find the field
 // described by this path, then returns the analyzer
 // configured for that field
@@ -201,6 +226,17 @@ func NewDocumentStaticMapping() *DocumentMapping {
 	}
 }
 
+// NewDocumentNestedMapping returns a new document
+// mapping indexing each object of the sub-section it
+// maps as a nested document.
+func NewDocumentNestedMapping() *DocumentMapping {
+	return &DocumentMapping{
+		Enabled: true,
+		Dynamic: true,
+		Nested:  true,
+	}
+}
+
 // NewDocumentDisabledMapping returns a new document
 // mapping that will not perform any indexing.
 func NewDocumentDisabledMapping() *DocumentMapping {
@@ -274,6 +310,11 @@ func (dm *DocumentMapping) UnmarshalJSON(data []byte) error {
 			if err != nil {
 				return err
 			}
+		case "nested":
+			err := json.Unmarshal(v, &dm.Nested)
+			if err != nil {
+				return err
+			}
 		case "default_analyzer":
 			err := json.Unmarshal(v, &dm.DefaultAnalyzer)
 			if err != nil {
@@ -407,6 +448,12 @@ func (dm *DocumentMapping) processProperty(property interface{}, path []string,
 		return
 	}
 
+	// nested objects are indexed as documents of their own
+	if subDocMapping != nil && subDocMapping.Nested && context.nestedPath != pathString {
+		dm.processNested(property, path, context)
+		return
+	}
+
 	propertyValue := reflect.ValueOf(property)
 	if !propertyValue.IsValid() {
 		// cannot do anything with the zero value
@@ -537,3 +584,49 @@ func (dm *DocumentMapping) processProperty(property interface{}, path []string,
 		dm.walkDocument(property, path, indexes, context)
 	}
 }
+
+// hasNested reports whether the mapping or one of its sub-sections is
+// nested
+func (dm *DocumentMapping) hasNested() bool {
+	if dm == nil {
+		return false
+	}
+	if dm.Nested {
+		return true
+	}
+	for _, property := range dm.Properties {
+		if property.hasNested() {
+			return true
+		}
+	}
+	return false
+}
+
+// processNested indexes each object of a nested property as a nested
+// document of the document walked
+func (dm *DocumentMapping) processNested(property interface{}, path []string, context *walkContext) {
+	propertyValue := reflect.ValueOf(property)
+	if !propertyValue.IsValid() {
+		return
+	}
+	switch propertyValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < propertyValue.Len(); i++ {
+			if propertyValue.Index(i).CanInterface() {
+				dm.walkNested(propertyValue.Index(i).Interface(), path, i, context)
+			}
+		}
+	default:
+		dm.walkNested(property, path, 0, context)
+	}
+}
+
+func (dm *DocumentMapping) walkNested(object interface{}, path []string, offset int, context *walkContext) {
+	pathString := encodePath(path)
+	nested := document.NewDocument(document.NestedID(context.doc.ID, pathString, offset))
+	nestedContext := context.im.newWalkContext(nested, context.dm)
+	nestedContext.root = context.root
+	nestedContext.nestedPath = pathString
+	dm.processProperty(object, path, []uint64{}, nestedContext)
+	context.root.AddNested(nested)
+}
@@ -54,6 +54,15 @@ type FieldMapping struct {
 	IncludeTermVectors bool   `json:"include_term_vectors,omitempty"`
 	IncludeInAll       bool   `json:"include_in_all,omitempty"`
 	DateFormat         string `json:"date_format,omitempty"`
+
+	// Similarity specifies the name of the similarity scoring searches
+	// on this field.  If Similarity is empty, the
+	// IndexMapping.DefaultSimilarity is used.
+	Similarity string `json:"similarity,omitempty"`
+
+	// Boost multiplies the score of matches in this field, on top of
+	// the boost of the document.  A Boost of 0 is no boost.
+	Boost float64 `json:"boost,omitempty"`
 }
 
 // NewTextFieldMapping returns a default field mapping for text
@@ -157,6 +166,7 @@ func (fm *FieldMapping) processString(propertyValueString string, pathString str
 		analyzer := fm.analyzerForField(path, context)
 		field := document.NewTextFieldCustom(fieldName, indexes, []byte(propertyValueString), options, analyzer)
 		context.doc.AddField(field)
+		context.doc.SetFieldBoost(fieldName, fm.Boost)
 
 		if !fm.IncludeInAll {
 			context.excludedFromAll = append(context.excludedFromAll, fieldName)
@@ -182,6 +192,7 @@ func (fm *FieldMapping) processFloat64(propertyValFloat float64, pathString stri
 		options := fm.Options()
 		field := document.NewNumericFieldWithIndexingOptions(fieldName, indexes, propertyValFloat, options)
 		context.doc.AddField(field)
+		context.doc.SetFieldBoost(fieldName, fm.Boost)
 
 		if !fm.IncludeInAll {
 			context.excludedFromAll = append(context.excludedFromAll, fieldName)
@@ -196,6 +207,7 @@ func (fm *FieldMapping) processTime(propertyValueTime time.Time, pathString stri
 		field, err := document.NewDateTimeFieldWithIndexingOptions(fieldName, indexes, propertyValueTime, options)
 		if err == nil {
 			context.doc.AddField(field)
+			context.doc.SetFieldBoost(fieldName, fm.Boost)
 		} else {
 			logger.Printf("could not build date %v", err)
 		}
@@ -212,6 +224,7 @@ func (fm *FieldMapping) processBoolean(propertyValueBool bool, pathString string
 		options := fm.Options()
 		field := document.NewBooleanFieldWithIndexingOptions(fieldName, indexes, propertyValueBool, options)
 		context.doc.AddField(field)
+		context.doc.SetFieldBoost(fieldName, fm.Boost)
 
 		if !fm.IncludeInAll {
 			context.excludedFromAll = append(context.excludedFromAll, fieldName)
@@ -226,6 +239,7 @@ func (fm *FieldMapping) processGeoPoint(propertyMightBeGeoPoint interface{}, pat
 		options := fm.Options()
 		field := document.NewGeoPointFieldWithIndexingOptions(fieldName, indexes, lon, lat, options)
 		context.doc.AddField(field)
+		context.doc.SetFieldBoost(fieldName, fm.Boost)
 
 		if !fm.IncludeInAll {
 			context.excludedFromAll = append(context.excludedFromAll, fieldName)
@@ -308,6 +322,16 @@ func (fm *FieldMapping) UnmarshalJSON(data []byte) error {
 			if err != nil {
 				return err
 			}
+		case "similarity":
+			err := json.Unmarshal(v, &fm.Similarity)
+			if err != nil {
+				return err
+			}
+		case "boost":
+			err := json.Unmarshal(v, &fm.Boost)
+			if err != nil {
+				return err
+			}
 		default:
 			invalidKeys = append(invalidKeys, k)
 		}
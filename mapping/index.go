@@ -17,21 +17,26 @@ package mapping
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/wrble/flock/analysis"
 	"github.com/wrble/flock/analysis/analyzer/standard"
 	"github.com/wrble/flock/analysis/datetime/optional"
 	"github.com/wrble/flock/document"
 	"github.com/wrble/flock/registry"
+	"github.com/wrble/flock/search/scorer"
+	"github.com/wrble/flock/search/similarity/bm25"
 )
 
 var MappingJSONStrict = false
 
 const defaultTypeField = "_type"
+const defaultBoostField = "_boost"
 const defaultType = "_default"
 const defaultField = "_all"
 const defaultAnalyzer = standard.Name
 const defaultDateTimeParser = optional.Name
+const defaultSimilarity = bm25.Name
 
 // An IndexMappingImpl controls how objects are placed
 // into an index.
@@ -44,12 +49,16 @@ type IndexMappingImpl struct {
 	TypeMapping           map[string]*DocumentMapping `json:"types,omitempty"`
 	DefaultMapping        *DocumentMapping            `json:"default_mapping"`
 	TypeField             string                      `json:"type_field"`
+	BoostField            string                      `json:"boost_field"`
+	TTLField              string                      `json:"ttl_field"`
 	DefaultType           string                      `json:"default_type"`
 	DefaultAnalyzer       string                      `json:"default_analyzer"`
 	DefaultDateTimeParser string                      `json:"default_datetime_parser"`
+	DefaultSimilarity     string                      `json:"default_similarity"`
 	DefaultField          string                      `json:"default_field"`
 	StoreDynamic          bool                        `json:"store_dynamic"`
 	IndexDynamic          bool                        `json:"index_dynamic"`
+	StoreSource           bool                        `json:"store_source"`
 	CustomAnalysis        *customAnalysis             `json:"analysis,omitempty"`
 	cache                 *registry.Cache
 }
@@ -142,15 +151,28 @@ func (im *IndexMappingImpl) AddCustomDateTimeParser(name string, config map[stri
 	return nil
 }
 
+// AddCustomSimilarity defines a custom similarity for use in this mapping,
+// for instance a bm25 similarity with its own "k1" and "b"
+func (im *IndexMappingImpl) AddCustomSimilarity(name string, config map[string]interface{}) error {
+	_, err := im.cache.DefineSimilarity(name, config)
+	if err != nil {
+		return err
+	}
+	im.CustomAnalysis.Similarities[name] = config
+	return nil
+}
+
 // NewIndexMapping creates a new IndexMapping that will use all the default indexing rules
 func NewIndexMapping() *IndexMappingImpl {
 	return &IndexMappingImpl{
 		TypeMapping:           make(map[string]*DocumentMapping),
 		DefaultMapping:        NewDocumentMapping(),
 		TypeField:             defaultTypeField,
+		BoostField:            defaultBoostField,
 		DefaultType:           defaultType,
 		DefaultAnalyzer:       defaultAnalyzer,
 		DefaultDateTimeParser: defaultDateTimeParser,
+		DefaultSimilarity:     defaultSimilarity,
 		DefaultField:          defaultField,
 		IndexDynamic:          IndexDynamic,
 		StoreDynamic:          StoreDynamic,
@@ -170,6 +192,10 @@ func (im *IndexMappingImpl) Validate() error {
 	if err != nil {
 		return err
 	}
+	_, err = im.cache.SimilarityNamed(im.DefaultSimilarity)
+	if err != nil {
+		return err
+	}
 	err = im.DefaultMapping.Validate(im.cache)
 	if err != nil {
 		return err
@@ -196,6 +222,20 @@ func (im *IndexMappingImpl) mappingForType(docType string) *DocumentMapping {
 	return docMapping
 }
 
+// HasNested reports whether a document mapping has a nested
+// sub-section, documents are mapped without nested documents otherwise
+func (im *IndexMappingImpl) HasNested() bool {
+	if im.DefaultMapping.hasNested() {
+		return true
+	}
+	for _, docMapping := range im.TypeMapping {
+		if docMapping.hasNested() {
+			return true
+		}
+	}
+	return false
+}
+
 // UnmarshalJSON offers custom unmarshaling with optional strict validation
 func (im *IndexMappingImpl) UnmarshalJSON(data []byte) error {
 
@@ -209,9 +249,11 @@ func (im *IndexMappingImpl) UnmarshalJSON(data []byte) error {
 	im.cache = registry.NewCache()
 	im.CustomAnalysis = newCustomAnalysis()
 	im.TypeField = defaultTypeField
+	im.BoostField = defaultBoostField
 	im.DefaultType = defaultType
 	im.DefaultAnalyzer = defaultAnalyzer
 	im.DefaultDateTimeParser = defaultDateTimeParser
+	im.DefaultSimilarity = defaultSimilarity
 	im.DefaultField = defaultField
 	im.DefaultMapping = NewDocumentMapping()
 	im.TypeMapping = make(map[string]*DocumentMapping)
@@ -231,6 +273,21 @@ func (im *IndexMappingImpl) UnmarshalJSON(data []byte) error {
 			if err != nil {
 				return err
 			}
+		case "boost_field":
+			err := json.Unmarshal(v, &im.BoostField)
+			if err != nil {
+				return err
+			}
+		case "ttl_field":
+			err := json.Unmarshal(v, &im.TTLField)
+			if err != nil {
+				return err
+			}
+		case "store_source":
+			err := json.Unmarshal(v, &im.StoreSource)
+			if err != nil {
+				return err
+			}
 		case "default_type":
 			err := json.Unmarshal(v, &im.DefaultType)
 			if err != nil {
@@ -246,6 +303,11 @@ func (im *IndexMappingImpl) UnmarshalJSON(data []byte) error {
 			if err != nil {
 				return err
 			}
+		case "default_similarity":
+			err := json.Unmarshal(v, &im.DefaultSimilarity)
+			if err != nil {
+				return err
+			}
 		case "default_field":
 			err := json.Unmarshal(v, &im.DefaultField)
 			if err != nil {
@@ -309,7 +371,74 @@ func (im *IndexMappingImpl) determineType(data interface{}) string {
 	return im.DefaultType
 }
 
+// determineBoost returns the index time boost of the object, 0 when it
+// has none
+func (im *IndexMappingImpl) determineBoost(data interface{}) float64 {
+	// first see if the object implements Booster
+	booster, ok := data.(Booster)
+	if ok {
+		return booster.BleveBoost()
+	}
+
+	// now see if we can find a boost using the mapping
+	if im.BoostField != "" {
+		boost, ok := mustFloat(lookupPropertyPath(data, im.BoostField))
+		if ok {
+			return boost
+		}
+	}
+
+	return 0
+}
+
+// determineExpiry returns when the object expires, the zero time when
+// it does not.  The TTL field, none unless set, holds either the number
+// of seconds or the duration the object lives for from now, or the time
+// it expires at.
+func (im *IndexMappingImpl) determineExpiry(data interface{}) time.Time {
+	// first see if the object implements Expirer
+	expirer, ok := data.(Expirer)
+	if ok {
+		return expirer.BleveExpires()
+	}
+
+	// now see if we can find an expiry using the mapping
+	if im.TTLField != "" {
+		ttl := lookupPropertyPath(data, im.TTLField)
+		if seconds, ok := mustFloat(ttl); ok && seconds > 0 {
+			return time.Now().Add(time.Duration(seconds * float64(time.Second)))
+		}
+		switch ttl := ttl.(type) {
+		case time.Time:
+			return ttl
+		case string:
+			if d, err := time.ParseDuration(ttl); err == nil && d > 0 {
+				return time.Now().Add(d)
+			}
+			if t, err := time.Parse(time.RFC3339, ttl); err == nil {
+				return t
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
 func (im *IndexMappingImpl) MapDocument(doc *document.Document, data interface{}) error {
+	if boost := im.determineBoost(data); boost > 0 {
+		doc.Boost = boost
+	}
+	if expires := im.determineExpiry(data); !expires.IsZero() {
+		doc.Expires = expires
+	}
+	if im.StoreSource {
+		// the source is kept as JSON, compressed by the index
+		source, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		doc.Source = source
+	}
 	docType := im.determineType(data)
 	docMapping := im.mappingForType(docType)
 	walkContext := im.newWalkContext(doc, docMapping)
@@ -332,6 +461,10 @@ type walkContext struct {
 	im              *IndexMappingImpl
 	dm              *DocumentMapping
 	excludedFromAll []string
+	// root is the top level document the nested documents are added
+	// to, nestedPath the path of the nested document walked
+	root       *document.Document
+	nestedPath string
 }
 
 func (im *IndexMappingImpl) newWalkContext(doc *document.Document, dm *DocumentMapping) *walkContext {
@@ -340,6 +473,7 @@ func (im *IndexMappingImpl) newWalkContext(doc *document.Document, dm *DocumentM
 		im:              im,
 		dm:              dm,
 		excludedFromAll: []string{},
+		root:            doc,
 	}
 }
 
@@ -386,6 +520,35 @@ func (im *IndexMappingImpl) AnalyzerNamed(name string) *analysis.Analyzer {
 	return analyzer
 }
 
+// SimilarityNameForPath returns the name of the similarity explicitly
+// mapped on a field at the provided path in any document type, or the
+// DefaultSimilarity
+func (im *IndexMappingImpl) SimilarityNameForPath(path string) string {
+	for _, docMapping := range im.TypeMapping {
+		similarityName := docMapping.similarityNameForPath(path)
+		if similarityName != "" {
+			return similarityName
+		}
+	}
+	similarityName := im.DefaultMapping.similarityNameForPath(path)
+	if similarityName != "" {
+		return similarityName
+	}
+	return im.DefaultSimilarity
+}
+
+// SimilarityForPath returns the similarity scoring searches on the
+// field at the provided path
+func (im *IndexMappingImpl) SimilarityForPath(path string) scorer.Similarity {
+	name := im.SimilarityNameForPath(path)
+	similarity, err := im.cache.SimilarityNamed(name)
+	if err != nil {
+		logger.Printf("error using similarity named: %s", name)
+		return nil
+	}
+	return similarity
+}
+
 func (im *IndexMappingImpl) DateTimeParserNamed(name string) analysis.DateTimeParser {
 	if name == "" {
 		name = im.DefaultDateTimeParser
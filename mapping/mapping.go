@@ -17,9 +17,11 @@ package mapping
 import (
 	"io/ioutil"
 	"log"
+	"time"
 
 	"github.com/wrble/flock/analysis"
 	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/search/scorer"
 )
 
 // A Classifier is an interface describing any object which knows how to
@@ -37,6 +39,19 @@ type bleveClassifier interface {
 	BleveType() string
 }
 
+// A Booster is an interface describing any object which knows its own
+// index time boost, it multiplies the score of all its fields.
+type Booster interface {
+	BleveBoost() float64
+}
+
+// An Expirer is an interface describing any object which knows when it
+// expires, searches no longer find it from then on.  The zero time
+// never expires.
+type Expirer interface {
+	BleveExpires() time.Time
+}
+
 var logger = log.New(ioutil.Discard, "bleve mapping ", log.LstdFlags)
 
 // SetLog sets the logger used for logging
@@ -55,4 +70,6 @@ type IndexMapping interface {
 
 	AnalyzerNameForPath(path string) string
 	AnalyzerNamed(name string) *analysis.Analyzer
+
+	SimilarityForPath(path string) scorer.Similarity
 }
@@ -25,6 +25,8 @@ import (
 	"github.com/wrble/flock/analysis/tokenizer/regexp"
 	"github.com/wrble/flock/document"
 	"github.com/wrble/flock/numeric"
+	"github.com/wrble/flock/search/scorer"
+	"github.com/wrble/flock/search/similarity/classic"
 )
 
 var mappingSource = []byte(`{
@@ -349,6 +351,114 @@ func TestMappingForPath(t *testing.T) {
 
 }
 
+func TestMappingSimilarityForPath(t *testing.T) {
+	mappingBytes := []byte(`{
+		"default_similarity": "` + classic.Name + `",
+		"analysis": {
+			"similarities": {
+				"short_bm25": {
+					"type": "bm25",
+					"k1": 2.0,
+					"b": 0.3
+				}
+			}
+		},
+		"types": {
+			"beer": {
+				"properties": {
+					"title": {
+						"fields": [
+							{
+								"name": "title",
+								"type": "text",
+								"similarity": "short_bm25"
+							}
+						]
+					}
+				}
+			}
+		}
+	}`)
+	var im IndexMappingImpl
+	err := json.Unmarshal(mappingBytes, &im)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = im.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := im.SimilarityNameForPath("title"); name != "short_bm25" {
+		t.Errorf("expected 'short_bm25' got '%s'", name)
+	}
+	bm25, ok := im.SimilarityForPath("title").(*scorer.BM25Similarity)
+	if !ok || bm25.K1 != 2.0 || bm25.B != 0.3 {
+		t.Errorf("expected bm25 with k1 2 and b 0.3, got %#v", im.SimilarityForPath("title"))
+	}
+	if _, ok := im.SimilarityForPath("desc").(*scorer.ClassicSimilarity); !ok {
+		t.Errorf("expected the default classic similarity, got %#v", im.SimilarityForPath("desc"))
+	}
+
+	// an unknown similarity does not validate
+	im.TypeMapping["beer"].Properties["title"].Fields[0].Similarity = "unknown"
+	err = im.Validate()
+	if err == nil {
+		t.Errorf("expected an unknown similarity to fail validation")
+	}
+}
+
+type boostedDoc struct {
+	Name string `json:"name"`
+}
+
+func (d *boostedDoc) BleveBoost() float64 {
+	return 4.0
+}
+
+func TestMappingBoosts(t *testing.T) {
+	titleMapping := NewTextFieldMapping()
+	titleMapping.Boost = 3.0
+	docMapping := NewDocumentMapping()
+	docMapping.AddFieldMappingsAt("title", titleMapping)
+	m := NewIndexMapping()
+	m.DefaultMapping = docMapping
+
+	doc := document.NewDocument("1")
+	err := m.MapDocument(doc, map[string]interface{}{
+		"_boost": 2,
+		"title":  "hello",
+		"desc":   "world",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Boost != 2.0 {
+		t.Errorf("expected document boost 2, got %f", doc.Boost)
+	}
+	if doc.FieldBoost("title") != 6.0 {
+		t.Errorf("expected title boost 6, got %f", doc.FieldBoost("title"))
+	}
+	if doc.FieldBoost("desc") != 2.0 {
+		t.Errorf("expected desc boost 2, got %f", doc.FieldBoost("desc"))
+	}
+
+	doc = document.NewDocument("2")
+	err = m.MapDocument(doc, &boostedDoc{Name: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Boost != 4.0 {
+		t.Errorf("expected document boost 4, got %f", doc.Boost)
+	}
+
+	titleMapping.Boost = -1.0
+	err = m.Validate()
+	if err == nil {
+		t.Errorf("expected a negative boost to fail validation")
+	}
+}
+
 func TestMappingWithTokenizerDeps(t *testing.T) {
 
 	tokNoDeps := map[string]interface{}{
@@ -991,3 +1101,134 @@ func TestMappingForNilTextMarshaler(t *testing.T) {
 	}
 
 }
+
+type expiringDoc struct {
+	Name    string `json:"name"`
+	expires time.Time
+}
+
+func (d *expiringDoc) BleveExpires() time.Time {
+	return d.expires
+}
+
+func TestMappingExpiry(t *testing.T) {
+	m := NewIndexMapping()
+	m.TTLField = "_ttl"
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		data    interface{}
+		min     time.Time
+		max     time.Time
+		expires bool
+	}{
+		{data: map[string]interface{}{"name": "a"}},
+		{data: map[string]interface{}{"_ttl": 60}, min: time.Now().Add(time.Minute), max: time.Now().Add(2 * time.Minute), expires: true},
+		{data: map[string]interface{}{"_ttl": "1h"}, min: time.Now().Add(time.Hour), max: time.Now().Add(time.Hour + time.Minute), expires: true},
+		{data: map[string]interface{}{"_ttl": "2030-01-01T00:00:00Z"}, min: expires, max: expires, expires: true},
+		{data: map[string]interface{}{"_ttl": "never"}},
+		{data: &expiringDoc{Name: "a", expires: expires}, min: expires, max: expires, expires: true},
+	}
+	for i, test := range tests {
+		doc := document.NewDocument("1")
+		err := m.MapDocument(doc, test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !test.expires {
+			if !doc.Expires.IsZero() {
+				t.Errorf("%d: expected no expiry, got %v", i, doc.Expires)
+			}
+			continue
+		}
+		if doc.Expires.Before(test.min) || doc.Expires.After(test.max) {
+			t.Errorf("%d: expected an expiry between %v and %v, got %v", i, test.min, test.max, doc.Expires)
+		}
+	}
+
+	// the ttl field is opt in
+	doc := document.NewDocument("1")
+	err := NewIndexMapping().MapDocument(doc, map[string]interface{}{"_ttl": 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !doc.Expires.IsZero() {
+		t.Errorf("expected no expiry without a ttl field, got %v", doc.Expires)
+	}
+}
+
+func TestMappingNested(t *testing.T) {
+	m := NewIndexMapping()
+	if m.HasNested() {
+		t.Errorf("expected the default mapping to have no nested mapping")
+	}
+	m.DefaultMapping.AddSubDocumentMapping("comments", NewDocumentNestedMapping())
+	if !m.HasNested() {
+		t.Errorf("expected the mapping to have a nested mapping")
+	}
+
+	doc := document.NewDocument("1")
+	err := m.MapDocument(doc, map[string]interface{}{
+		"title": "a",
+		"comments": []interface{}{
+			map[string]interface{}{"author": "bob", "score": 3},
+			map[string]interface{}{"author": "sue", "score": 7},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the nested objects are not indexed with the parent
+	for _, field := range doc.Fields {
+		if field.Name() != "title" && field.Name() != "_all" {
+			t.Errorf("expected only the title in the parent, got %s", field.Name())
+		}
+	}
+	if len(doc.Nested) != 2 {
+		t.Fatalf("expected 2 nested documents, got %d", len(doc.Nested))
+	}
+	for i, nested := range doc.Nested {
+		parentID, path, offset, ok := document.ParseNestedID(nested.ID)
+		if !ok || parentID != "1" || path != "comments" || offset != i {
+			t.Errorf("expected nested document %d of comments of 1, got %q", i, nested.ID)
+		}
+		var author string
+		for _, field := range nested.Fields {
+			if field.Name() == "comments.author" {
+				author = string(field.Value())
+			}
+			if len(field.ArrayPositions()) != 0 {
+				t.Errorf("expected no array positions in nested documents, got %v", field.ArrayPositions())
+			}
+		}
+		if expected := []string{"bob", "sue"}[i]; author != expected {
+			t.Errorf("expected author %s of nested document %d, got %s", expected, i, author)
+		}
+	}
+}
+
+func TestMappingStoreSource(t *testing.T) {
+	m := NewIndexMapping()
+	data := map[string]interface{}{"name": "a"}
+	doc := document.NewDocument("1")
+	err := m.MapDocument(doc, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Source != nil {
+		t.Errorf("expected no source by default, got %s", doc.Source)
+	}
+
+	err = json.Unmarshal([]byte(`{"store_source":true}`), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.MapDocument(doc, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(doc.Source) != `{"name":"a"}` {
+		t.Errorf("expected the source kept, got %s", doc.Source)
+	}
+}
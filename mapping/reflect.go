@@ -80,6 +80,21 @@ func mustString(data interface{}) (string, bool) {
 	return "", false
 }
 
+func mustFloat(data interface{}) (float64, bool) {
+	if data != nil {
+		val := reflect.ValueOf(data)
+		switch val.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return val.Float(), true
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(val.Int()), true
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(val.Uint()), true
+		}
+	}
+	return 0, false
+}
+
 // parseTagName extracts the field name from a struct tag
 func parseTagName(tag string) string {
 	if idx := strings.Index(tag, ","); idx != -1 {
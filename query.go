@@ -70,6 +70,14 @@ func NewDisjunctionQuery(disjuncts ...query.Query) *query.DisjunctionQuery {
 	return query.NewDisjunctionQuery(disjuncts)
 }
 
+// NewDisMaxQuery creates a new compound Query.
+// Result documents satisfy at least one Query, and
+// are scored by the Query they match best, plus the
+// tie breaker times the scores of the others.
+func NewDisMaxQuery(disjuncts ...query.Query) *query.DisMaxQuery {
+	return query.NewDisMaxQuery(disjuncts)
+}
+
 // NewDocIDQuery creates a new Query object returning indexed documents among
 // the specified set. Combine it with ConjunctionQuery to restrict the scope of
 // other queries output.
@@ -77,6 +85,23 @@ func NewDocIDQuery(ids []string) *query.DocIDQuery {
 	return query.NewDocIDQuery(ids)
 }
 
+// NewExistsQuery creates a new Query for finding
+// documents having a value in the field, or any
+// field of the object or nested documents at the
+// path, with the same score.
+func NewExistsQuery(field string) *query.ExistsQuery {
+	return query.NewExistsQuery(field)
+}
+
+// NewFunctionScoreQuery creates a new Query matching
+// the documents the query matches, and scoring them
+// again with the functions: field value factors, decay
+// functions of numeric, date and geo point fields,
+// weights and random scores.
+func NewFunctionScoreQuery(q query.Query, functions ...*query.ScoreFunction) *query.FunctionScoreQuery {
+	return query.NewFunctionScoreQuery(q, functions...)
+}
+
 // NewFuzzyQuery creates a new Query which finds
 // documents containing terms within a specific
 // fuzziness of the specified term.
@@ -88,6 +113,13 @@ func NewFuzzyQuery(term string) *query.FuzzyQuery {
 	return query.NewFuzzyQuery(term)
 }
 
+// NewMatchAllQuery creates a Query which will
+// match all documents in the index, with the
+// same score, in the order of their ids.
+func NewMatchAllQuery() *query.MatchAllQuery {
+	return query.NewMatchAllQuery()
+}
+
 // NewMatchNoneQuery creates a Query which will not
 // match any documents in the index.
 func NewMatchNoneQuery() *query.MatchNoneQuery {
@@ -116,6 +148,23 @@ func NewMatchQuery(match string) *query.MatchQuery {
 	return query.NewMatchQuery(match)
 }
 
+// NewMultiMatchQuery creates a Query for matching text
+// in several fields, each field may end with ^ and a
+// boost for the matches in that field, as in "title^2".
+// The text is matched in each field like a MatchQuery
+// and a document is scored by its best field.
+func NewMultiMatchQuery(match string, fields ...string) *query.MultiMatchQuery {
+	return query.NewMultiMatchQuery(match, fields...)
+}
+
+// NewNestedQuery creates a new Query matching the documents
+// with nested documents at the path matching the query, it is
+// evaluated within a single nested document.  The scores of
+// the nested documents matching are averaged by default.
+func NewNestedQuery(path string, q query.Query) *query.NestedQuery {
+	return query.NewNestedQuery(path, q)
+}
+
 // NewNumericRangeQuery creates a new Query for ranges
 // of numeric values.
 // Either, but not both endpoints can be nil.
@@ -19,6 +19,7 @@ import (
 
 	"github.com/wrble/flock/analysis"
 	"github.com/wrble/flock/search/highlight"
+	"github.com/wrble/flock/search/scorer"
 )
 
 var stores = make(KVStoreRegistry, 0)
@@ -29,6 +30,9 @@ var fragmentFormatters = make(FragmentFormatterRegistry, 0)
 var fragmenters = make(FragmenterRegistry, 0)
 var highlighters = make(HighlighterRegistry, 0)
 
+// scoring
+var similarities = make(SimilarityRegistry, 0)
+
 // analysis
 var charFilters = make(CharFilterRegistry, 0)
 var tokenizers = make(TokenizerRegistry, 0)
@@ -47,6 +51,7 @@ type Cache struct {
 	FragmentFormatters *FragmentFormatterCache
 	Fragmenters        *FragmenterCache
 	Highlighters       *HighlighterCache
+	Similarities       *SimilarityCache
 }
 
 func NewCache() *Cache {
@@ -60,6 +65,7 @@ func NewCache() *Cache {
 		FragmentFormatters: NewFragmentFormatterCache(),
 		Fragmenters:        NewFragmenterCache(),
 		Highlighters:       NewHighlighterCache(),
+		Similarities:       NewSimilarityCache(),
 	}
 }
 
@@ -182,3 +188,15 @@ func (c *Cache) DefineHighlighter(name string, config map[string]interface{}) (h
 	}
 	return c.Highlighters.DefineHighlighter(name, typ, config, c)
 }
+
+func (c *Cache) SimilarityNamed(name string) (scorer.Similarity, error) {
+	return c.Similarities.SimilarityNamed(name, c)
+}
+
+func (c *Cache) DefineSimilarity(name string, config map[string]interface{}) (scorer.Similarity, error) {
+	typ, err := typeFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return c.Similarities.DefineSimilarity(name, typ, config, c)
+}
@@ -0,0 +1,75 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/wrble/flock/search/scorer"
+)
+
+func RegisterSimilarity(name string, constructor SimilarityConstructor) {
+	_, exists := similarities[name]
+	if exists {
+		panic(fmt.Errorf("attempted to register duplicate similarity named '%s'", name))
+	}
+	similarities[name] = constructor
+}
+
+type SimilarityConstructor func(config map[string]interface{}, cache *Cache) (scorer.Similarity, error)
+type SimilarityRegistry map[string]SimilarityConstructor
+
+type SimilarityCache struct {
+	*ConcurrentCache
+}
+
+func NewSimilarityCache() *SimilarityCache {
+	return &SimilarityCache{
+		NewConcurrentCache(),
+	}
+}
+
+func SimilarityBuild(name string, config map[string]interface{}, cache *Cache) (interface{}, error) {
+	cons, registered := similarities[name]
+	if !registered {
+		return nil, fmt.Errorf("no similarity with name or type '%s' registered", name)
+	}
+	similarity, err := cons(config, cache)
+	if err != nil {
+		return nil, fmt.Errorf("error building similarity: %v", err)
+	}
+	return similarity, nil
+}
+
+func (c *SimilarityCache) SimilarityNamed(name string, cache *Cache) (scorer.Similarity, error) {
+	item, err := c.ItemNamed(name, cache, SimilarityBuild)
+	if err != nil {
+		return nil, err
+	}
+	return item.(scorer.Similarity), nil
+}
+
+func (c *SimilarityCache) DefineSimilarity(name string, typ string, config map[string]interface{}, cache *Cache) (scorer.Similarity, error) {
+	item, err := c.DefineItem(name, typ, config, cache, SimilarityBuild)
+	if err != nil {
+		if err == ErrAlreadyDefined {
+			return nil, fmt.Errorf("similarity named '%s' already defined", name)
+		}
+		return nil, err
+	}
+	return item.(scorer.Similarity), nil
+}
+
+func SimilarityTypesAndInstances() ([]string, []string) {
+	emptyConfig := map[string]interface{}{}
+	emptyCache := NewCache()
+	var types []string
+	var instances []string
+	for name, cons := range similarities {
+		_, err := cons(emptyConfig, emptyCache)
+		if err == nil {
+			instances = append(instances, name)
+		} else {
+			types = append(types, name)
+		}
+	}
+	return types, instances
+}
@@ -0,0 +1,380 @@
+package flock
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/index"
+	"golang.org/x/net/context"
+)
+
+// ReindexBatchSize is the number of documents Reindex writes per batch
+// unless ReindexOptions sets it
+var ReindexBatchSize = 100
+
+// A ReindexTransform changes the data of a document before it is
+// indexed again, returning nil data leaves the document out.
+type ReindexTransform func(id string, data interface{}) (interface{}, error)
+
+// ReindexOptions tune Reindex, the zero value writes one batch of
+// ReindexBatchSize documents at a time without a checkpoint.
+type ReindexOptions struct {
+	BatchSize int
+	// Concurrency is the number of batches written at the same time
+	Concurrency int
+	Transform   ReindexTransform
+	// Checkpoint is the internal key of the destination index the id
+	// of the last document written is kept under while reindexing.  A
+	// reindex interrupted resumes after it, it is removed once done.
+	Checkpoint []byte
+	// Progress is called with the counts so far after every batch
+	// written
+	Progress func(status ReindexStatus)
+}
+
+// ReindexStatus counts the documents Reindex went through so far
+type ReindexStatus struct {
+	Read    uint64 `json:"read"`
+	Indexed uint64 `json:"indexed"`
+	// Skipped is the number of documents the transform left out
+	Skipped uint64        `json:"skipped"`
+	Batches uint64        `json:"batches"`
+	Took    time.Duration `json:"took"`
+	// Resumed is the id of the document the reindex resumed after
+	Resumed string `json:"resumed,omitempty"`
+}
+
+// Reindex indexes the documents of src again into dst, mapped by the
+// mapping of dst.  The data of each document is its source when the
+// mapping of src stores sources, otherwise it is rebuilt from its
+// stored fields, the fields which are not stored are lost.  Documents
+// are read in id order and written in batches, see ReindexOptions.
+// Together with IndexAlias.Swap it migrates an index to a new mapping
+// while searches go on.
+func Reindex(src, dst Index, opts *ReindexOptions) (*ReindexStatus, error) {
+	return ReindexInContext(context.Background(), src, dst, opts)
+}
+
+// ReindexInContext reindexes like Reindex, until the context is done.
+// The batches written so far stay written.
+func ReindexInContext(ctx context.Context, src, dst Index, opts *ReindexOptions) (status *ReindexStatus, err error) {
+	if opts == nil {
+		opts = &ReindexOptions{}
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = ReindexBatchSize
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	start := time.Now()
+	status = &ReindexStatus{}
+
+	var resume []byte
+	if len(opts.Checkpoint) > 0 {
+		resume, err = dst.GetInternal(opts.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		status.Resumed = string(resume)
+	}
+
+	srcIndex, _, err := src.Advanced()
+	if err != nil {
+		return nil, err
+	}
+	indexReader, err := srcIndex.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := indexReader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	docIDReader, ok := indexReader.(index.DocIDIndexReader)
+	if !ok {
+		return nil, fmt.Errorf("index cannot list its documents")
+	}
+	docIDs, err := docIDReader.DocIDReaderAll()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := docIDs.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := &reindexWriter{
+		dst:        dst,
+		opts:       opts,
+		status:     status,
+		start:      start,
+		cancel:     cancel,
+		done:       make(map[uint64]string),
+		checkpoint: string(resume),
+	}
+	batches := make(chan *reindexBatch)
+	var wg sync.WaitGroup
+	for n := 0; n < concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				w.write(batch)
+			}
+		}()
+	}
+
+	err = w.read(ctx, indexReader, docIDs, resume, batchSize, batches)
+	close(batches)
+	wg.Wait()
+
+	status.Took = time.Since(start)
+	if w.err != nil {
+		return status, w.err
+	}
+	if err != nil {
+		return status, err
+	}
+	if len(opts.Checkpoint) > 0 {
+		err = dst.DeleteInternal(opts.Checkpoint)
+		if err != nil {
+			return status, err
+		}
+	}
+	return status, nil
+}
+
+type reindexBatch struct {
+	seq   uint64
+	batch *Batch
+	// last is the id of the last document read for the batch
+	last string
+}
+
+// reindexWriter writes the batches read, the checkpoint moves to the
+// last document of a batch once every batch before it is written
+type reindexWriter struct {
+	dst    Index
+	opts   *ReindexOptions
+	start  time.Time
+	cancel context.CancelFunc
+
+	m          sync.Mutex
+	status     *ReindexStatus
+	err        error
+	done       map[uint64]string
+	next       uint64
+	checkpoint string
+}
+
+// read sends the batches of the documents after resume, it stops when
+// the context is done
+func (w *reindexWriter) read(ctx context.Context, indexReader index.IndexReader, docIDs index.DocIDReader, resume []byte, batchSize int, batches chan<- *reindexBatch) error {
+	var seq uint64
+	current := &reindexBatch{batch: w.dst.NewBatch()}
+	send := func() error {
+		select {
+		case batches <- current:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		seq++
+		current = &reindexBatch{seq: seq, batch: w.dst.NewBatch()}
+		return nil
+	}
+
+	var next index.IndexInternalID
+	var err error
+	if len(resume) > 0 {
+		next, err = docIDs.Advance(index.IndexInternalID(resume))
+		if err == nil && next != nil && next.Equals(index.IndexInternalID(resume)) {
+			next, err = docIDs.Next()
+		}
+	} else {
+		next, err = docIDs.Next()
+	}
+	for ; next != nil || err != nil; next, err = docIDs.Next() {
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		var id string
+		id, err = indexReader.ExternalID(next)
+		if err != nil {
+			return err
+		}
+		var doc *document.Document
+		doc, err = indexReader.Document(id)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			// expired since it was listed
+			continue
+		}
+		err = w.add(current, doc)
+		if err != nil {
+			return err
+		}
+		current.last = id
+		if current.batch.Size() >= batchSize {
+			err = send()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if current.last != "" {
+		return send()
+	}
+	return nil
+}
+
+// add adds the document to the batch, transformed
+func (w *reindexWriter) add(current *reindexBatch, doc *document.Document) error {
+	var data interface{}
+	if len(doc.Source) > 0 {
+		err := json.Unmarshal(doc.Source, &data)
+		if err != nil {
+			return err
+		}
+	} else {
+		data = storedFieldsData(doc)
+	}
+	if w.opts.Transform != nil {
+		var err error
+		data, err = w.opts.Transform(doc.ID, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.m.Lock()
+	w.status.Read++
+	if data == nil {
+		w.status.Skipped++
+	}
+	w.m.Unlock()
+	if data == nil {
+		return nil
+	}
+	if !doc.Expires.IsZero() {
+		ttl := doc.Expires.Sub(time.Now())
+		if ttl <= 0 {
+			// expired since it was read
+			return nil
+		}
+		return current.batch.IndexWithTTL(doc.ID, data, ttl)
+	}
+	return current.batch.Index(doc.ID, data)
+}
+
+func (w *reindexWriter) write(batch *reindexBatch) {
+	var err error
+	if batch.batch.Size() > 0 {
+		err = w.dst.Batch(batch.batch)
+	}
+
+	w.m.Lock()
+	defer w.m.Unlock()
+	if err != nil {
+		if w.err == nil {
+			w.err = err
+		}
+		w.cancel()
+		return
+	}
+	w.status.Indexed += uint64(batch.batch.Size())
+	w.status.Batches++
+	w.status.Took = time.Since(w.start)
+
+	// move the checkpoint over the batches written in a row
+	w.done[batch.seq] = batch.last
+	last, ok := w.done[w.next]
+	for ok {
+		delete(w.done, w.next)
+		w.checkpoint = last
+		w.next++
+		last, ok = w.done[w.next]
+	}
+	if len(w.opts.Checkpoint) > 0 && w.err == nil {
+		err = w.dst.SetInternal(w.opts.Checkpoint, []byte(w.checkpoint))
+		if err != nil {
+			w.err = err
+			w.cancel()
+			return
+		}
+	}
+	if w.opts.Progress != nil {
+		w.opts.Progress(*w.status)
+	}
+}
+
+// storedFieldsData rebuilds the data of a document from its stored
+// fields, the values of a field named more than once make an array
+func storedFieldsData(doc *document.Document) map[string]interface{} {
+	rv := make(map[string]interface{})
+	for _, field := range doc.Fields {
+		var value interface{}
+		var err error
+		switch field := field.(type) {
+		case *document.TextField:
+			value = string(field.Value())
+		case *document.NumericField:
+			value, err = field.Number()
+		case *document.DateTimeField:
+			value, err = field.DateTime()
+		case *document.BooleanField:
+			value, err = field.Boolean()
+		case *document.GeoPointField:
+			var lon, lat float64
+			lon, err = field.Lon()
+			if err == nil {
+				lat, err = field.Lat()
+				value = []float64{lon, lat}
+			}
+		}
+		if err != nil || value == nil {
+			continue
+		}
+		setPropertyPath(rv, strings.Split(field.Name(), "."), value)
+	}
+	return rv
+}
+
+func setPropertyPath(data map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := data[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			data[key] = next
+		}
+		data = next
+	}
+	key := path[len(path)-1]
+	switch existing := data[key].(type) {
+	case nil:
+		data[key] = value
+	case []interface{}:
+		data[key] = append(existing, value)
+	default:
+		data[key] = []interface{}{existing, value}
+	}
+}
@@ -0,0 +1,190 @@
+package flock
+
+import (
+	"testing"
+
+	"github.com/wrble/flock/mapping"
+)
+
+func reindexTestIndexes(t *testing.T, storeSource bool) (Index, Index) {
+	m := NewIndexMapping()
+	m.StoreSource = storeSource
+	src, err := NewTempIndex(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string]map[string]interface{}{
+		"a": {"name": "one", "rating": 1, "address": map[string]interface{}{"city": "paris"}, "tags": []interface{}{"x", "y"}},
+		"b": {"name": "two", "rating": 2, "address": map[string]interface{}{"city": "rome"}},
+		"c": {"name": "three", "rating": 3},
+		"d": {"name": "four", "rating": 4},
+		"e": {"name": "five", "rating": 5},
+	}
+	for id, doc := range docs {
+		err = src.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the city is no longer analyzed in the new mapping
+	dm := mapping.NewDocumentMapping()
+	city := mapping.NewTextFieldMapping()
+	city.Analyzer = "keyword"
+	dm.AddFieldMappingsAt("city", city)
+	dstMapping := NewIndexMapping()
+	dstMapping.DefaultMapping.AddSubDocumentMapping("address", dm)
+	dst, err := NewTempIndex(dstMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src, dst
+}
+
+func closeIndexes(t *testing.T, indexes ...Index) {
+	for _, index := range indexes {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func expectTotal(t *testing.T, index Index, field, term string, expected uint64) {
+	q := NewTermQuery(term)
+	q.SetField(field)
+	res, err := index.Search(NewSearchRequest(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != expected {
+		t.Errorf("expected %d documents with %s %s, got %d", expected, field, term, res.Total)
+	}
+}
+
+func TestReindexStoredFields(t *testing.T) {
+	src, dst := reindexTestIndexes(t, false)
+	defer closeIndexes(t, src, dst)
+
+	var progress []ReindexStatus
+	status, err := Reindex(src, dst, &ReindexOptions{
+		BatchSize:   2,
+		Concurrency: 2,
+		Progress: func(status ReindexStatus) {
+			progress = append(progress, status)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Read != 5 || status.Indexed != 5 || status.Batches != 3 {
+		t.Errorf("expected 5 documents indexed in 3 batches, got %+v", status)
+	}
+	if len(progress) != 3 {
+		t.Errorf("expected progress after each batch, got %+v", progress)
+	}
+	count, err := dst.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 {
+		t.Errorf("expected 5 documents, got %d", count)
+	}
+	expectTotal(t, dst, "address.city", "paris", 1)
+	expectTotal(t, dst, "tags", "y", 1)
+	expectTotal(t, dst, "name", "three", 1)
+	min := 4.0
+	q := NewNumericRangeQuery(&min, nil)
+	q.SetField("rating")
+	res, err := dst.Search(NewSearchRequest(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 2 {
+		t.Errorf("expected 2 documents rated 4 or more, got %d", res.Total)
+	}
+}
+
+func TestReindexSourceTransform(t *testing.T) {
+	src, dst := reindexTestIndexes(t, true)
+	defer closeIndexes(t, src, dst)
+
+	status, err := Reindex(src, dst, &ReindexOptions{
+		Transform: func(id string, data interface{}) (interface{}, error) {
+			if id == "c" {
+				return nil, nil
+			}
+			data.(map[string]interface{})["reindexed"] = true
+			return data, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Read != 5 || status.Indexed != 4 || status.Skipped != 1 {
+		t.Errorf("expected 4 documents indexed and 1 skipped, got %+v", status)
+	}
+	doc, err := dst.Document("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc != nil {
+		t.Errorf("expected document c left out")
+	}
+	expectTotal(t, dst, "address.city", "rome", 1)
+	q := NewBoolFieldQuery(true)
+	q.SetField("reindexed")
+	res, err := dst.Search(NewSearchRequest(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 4 {
+		t.Errorf("expected 4 documents transformed, got %d", res.Total)
+	}
+}
+
+func TestReindexResume(t *testing.T) {
+	src, dst := reindexTestIndexes(t, false)
+	defer closeIndexes(t, src, dst)
+
+	checkpoint := []byte("_reindex")
+	err := dst.SetInternal(checkpoint, []byte("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := Reindex(src, dst, &ReindexOptions{
+		BatchSize:  1,
+		Checkpoint: checkpoint,
+		Progress: func(status ReindexStatus) {
+			val, err := dst.GetInternal(checkpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := string(rune('b' + status.Batches)); string(val) != expected {
+				t.Errorf("expected checkpoint %s, got %s", expected, val)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Resumed != "b" || status.Indexed != 3 {
+		t.Errorf("expected 3 documents indexed after b, got %+v", status)
+	}
+	for _, id := range []string{"a", "b", "c", "e"} {
+		doc, err := dst.Document(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (doc != nil) != (id > "b") {
+			t.Errorf("expected document %s reindexed only after b", id)
+		}
+	}
+	val, err := dst.GetInternal(checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != nil {
+		t.Errorf("expected the checkpoint removed, got %s", val)
+	}
+}
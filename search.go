@@ -247,6 +247,8 @@ func (h *HighlightRequest) AddField(field string) {
 // Explain triggers inclusion of additional search
 // result score explanations.
 // Sort describes the desired order for the results to be returned.
+// Source asks for the sources of the result documents, filtered,
+// provided the mapping stored them.
 //
 // A special field named "*" can be used to return all fields.
 type SearchRequest struct {
@@ -259,6 +261,7 @@ type SearchRequest struct {
 	Explain          bool              `json:"explain"`
 	Sort             search.SortOrder  `json:"sort"`
 	IncludeLocations bool              `json:"includeLocations"`
+	Source           *SourceFilter     `json:"_source,omitempty"`
 }
 
 func (r *SearchRequest) Validate() error {
@@ -308,6 +311,7 @@ func (r *SearchRequest) UnmarshalJSON(input []byte) error {
 		Explain          bool              `json:"explain"`
 		Sort             []json.RawMessage `json:"sort"`
 		IncludeLocations bool              `json:"includeLocations"`
+		Source           json.RawMessage   `json:"_source"`
 	}
 
 	err := json.Unmarshal(input, &temp)
@@ -334,6 +338,10 @@ func (r *SearchRequest) UnmarshalJSON(input []byte) error {
 	r.Fields = temp.Fields
 	r.Facets = temp.Facets
 	r.IncludeLocations = temp.IncludeLocations
+	r.Source, err = ParseSourceFilter(temp.Source)
+	if err != nil {
+		return err
+	}
 	r.Query, err = query.ParseQuery(temp.Q)
 	if err != nil {
 		return err
@@ -430,6 +438,10 @@ type SearchResult struct {
 	MaxScore float64                        `json:"max_score"`
 	Took     time.Duration                  `json:"took"`
 	Facets   search.FacetResults            `json:"facets"`
+
+	// TotalIsLowerBound is set when the search stopped early once the
+	// top hits were known, Total then only counts the hits visited
+	TotalIsLowerBound bool `json:"total_hits_is_lower_bound,omitempty"`
 }
 
 func (sr *SearchResult) String() string {
@@ -478,6 +490,7 @@ func (sr *SearchResult) Merge(other *SearchResult) {
 	sr.Status.Merge(other.Status)
 	sr.Hits = append(sr.Hits, other.Hits...)
 	sr.Total += other.Total
+	sr.TotalIsLowerBound = sr.TotalIsLowerBound || other.TotalIsLowerBound
 	if other.MaxScore > sr.MaxScore {
 		sr.MaxScore = other.MaxScore
 	}
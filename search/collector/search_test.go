@@ -15,6 +15,8 @@
 package collector
 
 import (
+	"sort"
+
 	"github.com/wrble/flock/document"
 	"github.com/wrble/flock/index"
 	"github.com/wrble/flock/search"
@@ -74,6 +76,45 @@ func (ss *stubSearcher) DocumentMatchPoolSize() int {
 	return 0
 }
 
+// stubBestFirstSearcher returns its matches by descending score once
+// switched to best first, stopping at the minimum score
+type stubBestFirstSearcher struct {
+	stubSearcher
+	bestFirst bool
+	minScore  float64
+	truncated bool
+}
+
+func (ss *stubBestFirstSearcher) BestFirst() (bool, error) {
+	sort.Stable(ss)
+	ss.bestFirst = true
+	return true, nil
+}
+
+func (ss *stubBestFirstSearcher) Len() int { return len(ss.matches) }
+func (ss *stubBestFirstSearcher) Less(i, j int) bool {
+	return ss.matches[i].Score > ss.matches[j].Score
+}
+func (ss *stubBestFirstSearcher) Swap(i, j int) {
+	ss.matches[i], ss.matches[j] = ss.matches[j], ss.matches[i]
+}
+
+func (ss *stubBestFirstSearcher) Next(ctx *search.SearchContext) (*search.DocumentMatch, error) {
+	if ss.bestFirst && ss.index < len(ss.matches) && ss.matches[ss.index].Score <= ss.minScore {
+		ss.truncated = true
+		return nil, nil
+	}
+	return ss.stubSearcher.Next(ctx)
+}
+
+func (ss *stubBestFirstSearcher) SetMinScore(min float64) {
+	ss.minScore = min
+}
+
+func (ss *stubBestFirstSearcher) Truncated() bool {
+	return ss.truncated
+}
+
 type stubReader struct{}
 
 func (sr *stubReader) TermFieldReader(term []byte, field string, includeFreq, includeNorm, includeTermVectors bool) (index.TermFieldReader, error) {
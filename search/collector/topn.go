@@ -58,6 +58,8 @@ type TopNCollector struct {
 	cachedDesc    []bool
 
 	lowestMatchOutsideResults *search.DocumentMatch
+
+	totalIsLowerBound bool
 }
 
 // CheckDoneEvery controls how frequently we check the context deadline
@@ -115,6 +117,21 @@ func (hc *TopNCollector) Collect(ctx context.Context, searcher search.Searcher,
 		DocumentMatchPool: search.NewDocumentMatchPool(backingSize+searcher.DocumentMatchPoolSize(), len(hc.sort)),
 	}
 
+	// when ranking by score alone the searcher may return its best
+	// matches first and stop once the results can no longer change
+	var bestFirst search.BestFirstSearcher
+	if hc.size > 0 && hc.facetsBuilder == nil && len(hc.sort) == 1 && hc.cachedScoring[0] && hc.cachedDesc[0] {
+		if bfs, ok := searcher.(search.BestFirstSearcher); ok {
+			ok, err = bfs.BestFirst()
+			if err != nil {
+				return err
+			}
+			if ok {
+				bestFirst = bfs
+			}
+		}
+	}
+
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -134,9 +151,15 @@ func (hc *TopNCollector) Collect(ctx context.Context, searcher search.Searcher,
 		if err != nil {
 			break
 		}
+		if bestFirst != nil && hc.lowestMatchOutsideResults != nil {
+			bestFirst.SetMinScore(hc.lowestMatchOutsideResults.Score)
+		}
 
 		next, err = searcher.Next(searchContext)
 	}
+	if bestFirst != nil {
+		hc.totalIsLowerBound = bestFirst.Truncated()
+	}
 	// compute search duration
 	hc.took = time.Since(startTime)
 	if err != nil {
@@ -273,6 +296,12 @@ func (hc *TopNCollector) Total() uint64 {
 	return hc.total
 }
 
+// TotalIsLowerBound returns whether the collection stopped before
+// visiting every hit, Total then only counts the hits visited
+func (hc *TopNCollector) TotalIsLowerBound() bool {
+	return hc.totalIsLowerBound
+}
+
 // MaxScore returns the maximum score seen across all the hits
 func (hc *TopNCollector) MaxScore() float64 {
 	return hc.maxScore
@@ -575,3 +575,45 @@ func BenchmarkTop10000of1000000Scores(b *testing.B) {
 		return NewTopNCollector(10000, 0, search.SortOrder{&search.SortScore{Desc: true}})
 	}, b)
 }
+
+func TestTop2BestFirstStopsEarly(t *testing.T) {
+	matches := func() []*search.DocumentMatch {
+		return []*search.DocumentMatch{
+			{IndexInternalID: index.IndexInternalID("a"), Score: 1},
+			{IndexInternalID: index.IndexInternalID("b"), Score: 7},
+			{IndexInternalID: index.IndexInternalID("c"), Score: 3},
+			{IndexInternalID: index.IndexInternalID("d"), Score: 9},
+			{IndexInternalID: index.IndexInternalID("e"), Score: 7},
+			{IndexInternalID: index.IndexInternalID("f"), Score: 2},
+		}
+	}
+
+	searcher := &stubBestFirstSearcher{stubSearcher: stubSearcher{matches: matches()}}
+	collector := NewTopNCollector(2, 0, search.SortOrder{&search.SortScore{Desc: true}})
+	err := collector.Collect(context.Background(), searcher, &stubReader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := collector.Results()
+	if len(results) != 2 || results[0].ID != "d" || results[1].ID != "b" {
+		t.Fatalf("expected hits d and b, got %v", results)
+	}
+	// d, b and e are visited, e can not beat b so c ends the collection
+	if collector.Total() != 3 {
+		t.Errorf("expected 3 hits visited, got %d", collector.Total())
+	}
+	if !collector.TotalIsLowerBound() {
+		t.Errorf("expected total to be a lower bound")
+	}
+
+	// sorting by anything but descending score visits every hit
+	searcher = &stubBestFirstSearcher{stubSearcher: stubSearcher{matches: matches()}}
+	collector = NewTopNCollector(2, 0, search.SortOrder{&search.SortScore{Desc: false}})
+	err = collector.Collect(context.Background(), searcher, &stubReader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if searcher.bestFirst || collector.Total() != 6 || collector.TotalIsLowerBound() {
+		t.Errorf("expected all 6 hits in index order, got %d (best first %t)", collector.Total(), searcher.bestFirst)
+	}
+}
@@ -37,7 +37,8 @@ type BooleanQuery struct {
 // Result documents must satisfy ALL of the
 // must Queries.
 // Result documents must satisfy NONE of the must not
-// Queries.
+// Queries, with must not Queries only they are all the
+// other documents.
 // Result documents that ALSO satisfy any of the should
 // Queries will score higher.
 func NewBooleanQuery(must []Query, should []Query, mustNot []Query) *BooleanQuery {
@@ -161,6 +162,15 @@ func (q *BooleanQuery) Searcher(i index.IndexReader, m mapping.IndexMapping, opt
 		return shouldSearcher, nil
 	}
 
+	// if only must not searcher, exclude its matches from all documents
+	if mustSearcher == nil && shouldSearcher == nil && mustNotSearcher != nil {
+		mustSearcher, err = searcher.NewMatchAllSearcher(i, 1.0, options)
+		if err != nil {
+			_ = mustNotSearcher.Close()
+			return nil, err
+		}
+	}
+
 	return searcher.NewBooleanSearcher(i, mustSearcher, shouldSearcher, mustNotSearcher, options)
 }
 
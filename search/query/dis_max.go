@@ -0,0 +1,128 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/mapping"
+	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/searcher"
+)
+
+type DisMaxQuery struct {
+	Disjuncts  []Query `json:"dis_max"`
+	TieBreaker float64 `json:"tie_breaker,omitempty"`
+	BoostVal   *Boost  `json:"boost,omitempty"`
+}
+
+// NewDisMaxQuery creates a new compound Query.
+// Result documents satisfy at least one Query, and
+// are scored by the Query they match best, plus the
+// tie breaker times the scores of the others.
+func NewDisMaxQuery(disjuncts []Query) *DisMaxQuery {
+	return &DisMaxQuery{
+		Disjuncts: disjuncts,
+	}
+}
+
+func (q *DisMaxQuery) SetBoost(b float64) {
+	boost := Boost(b)
+	q.BoostVal = &boost
+}
+
+func (q *DisMaxQuery) Boost() float64 {
+	return q.BoostVal.Value()
+}
+
+func (q *DisMaxQuery) AddQuery(aq ...Query) {
+	for _, aaq := range aq {
+		q.Disjuncts = append(q.Disjuncts, aaq)
+	}
+}
+
+// SetTieBreaker sets how much the Queries other than the
+// best one add to the score, from 0 for none of them to
+// 1 for all of them.
+func (q *DisMaxQuery) SetTieBreaker(tieBreaker float64) {
+	q.TieBreaker = tieBreaker
+}
+
+func (q *DisMaxQuery) Searcher(i index.IndexReader, m mapping.IndexMapping, options search.SearcherOptions) (search.Searcher, error) {
+	ss := make([]search.Searcher, 0, len(q.Disjuncts))
+	for _, disjunct := range q.Disjuncts {
+		sr, err := disjunct.Searcher(i, m, options)
+		if err != nil {
+			for _, searcher := range ss {
+				if searcher != nil {
+					_ = searcher.Close()
+				}
+			}
+			return nil, err
+		}
+		if _, ok := sr.(*searcher.MatchNoneSearcher); ok {
+			_ = sr.Close()
+			continue
+		}
+		ss = append(ss, sr)
+	}
+	if len(ss) < 1 {
+		return searcher.NewMatchNoneSearcher(i)
+	}
+	return searcher.NewDisjunctionMaxSearcher(i, ss, q.TieBreaker, options)
+}
+
+func (q *DisMaxQuery) Validate() error {
+	if len(q.Disjuncts) == 0 {
+		return fmt.Errorf("dis max query must have at least one clause")
+	}
+	if q.TieBreaker < 0 || q.TieBreaker > 1 {
+		return fmt.Errorf("dis max query tie breaker must be between 0 and 1")
+	}
+	for _, q := range q.Disjuncts {
+		if q, ok := q.(ValidatableQuery); ok {
+			err := q.Validate()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (q *DisMaxQuery) UnmarshalJSON(data []byte) error {
+	tmp := struct {
+		Disjuncts  []json.RawMessage `json:"dis_max"`
+		TieBreaker float64           `json:"tie_breaker"`
+		Boost      *Boost            `json:"boost,omitempty"`
+	}{}
+	err := json.Unmarshal(data, &tmp)
+	if err != nil {
+		return err
+	}
+	q.Disjuncts = make([]Query, len(tmp.Disjuncts))
+	for i, term := range tmp.Disjuncts {
+		query, err := ParseQuery(term)
+		if err != nil {
+			return err
+		}
+		q.Disjuncts[i] = query
+	}
+	q.TieBreaker = tmp.TieBreaker
+	q.BoostVal = tmp.Boost
+	return nil
+}
@@ -0,0 +1,96 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/mapping"
+	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/searcher"
+)
+
+type ExistsQuery struct {
+	FieldVal string `json:"field"`
+	BoostVal *Boost `json:"boost,omitempty"`
+}
+
+// NewExistsQuery creates a new Query for finding
+// documents having a value in the field, or any
+// field of the object or nested documents at the
+// path, with the same score.  Field presence is
+// recorded at index time, documents indexed before
+// it was are found once indexed again.
+func NewExistsQuery(field string) *ExistsQuery {
+	return &ExistsQuery{
+		FieldVal: field,
+	}
+}
+
+func (q *ExistsQuery) SetBoost(b float64) {
+	boost := Boost(b)
+	q.BoostVal = &boost
+}
+
+func (q *ExistsQuery) Boost() float64 {
+	return q.BoostVal.Value()
+}
+
+func (q *ExistsQuery) SetField(f string) {
+	q.FieldVal = f
+}
+
+func (q *ExistsQuery) Field() string {
+	return q.FieldVal
+}
+
+func (q *ExistsQuery) Searcher(i index.IndexReader, m mapping.IndexMapping, options search.SearcherOptions) (search.Searcher, error) {
+	return searcher.NewExistsSearcher(i, q.FieldVal, q.BoostVal.Value(), options)
+}
+
+func (q *ExistsQuery) Validate() error {
+	if q.FieldVal == "" {
+		return fmt.Errorf("exists query must have a field")
+	}
+	return nil
+}
+
+func (q *ExistsQuery) MarshalJSON() ([]byte, error) {
+	tmp := map[string]interface{}{
+		"boost": q.BoostVal,
+		"exists": map[string]interface{}{
+			"field": q.FieldVal,
+		},
+	}
+	return json.Marshal(tmp)
+}
+
+func (q *ExistsQuery) UnmarshalJSON(data []byte) error {
+	tmp := struct {
+		Exists struct {
+			Field string `json:"field"`
+		} `json:"exists"`
+		Boost *Boost `json:"boost,omitempty"`
+	}{}
+	err := json.Unmarshal(data, &tmp)
+	if err != nil {
+		return err
+	}
+	q.FieldVal = tmp.Exists.Field
+	q.BoostVal = tmp.Boost
+	return nil
+}
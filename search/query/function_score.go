@@ -0,0 +1,567 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wrble/flock/geo"
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/mapping"
+	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/scorer"
+	"github.com/wrble/flock/search/searcher"
+)
+
+type FunctionScoreQuery struct {
+	Query     Query            `json:"function_score"`
+	Functions []*ScoreFunction `json:"functions,omitempty"`
+	ScoreMode string           `json:"score_mode,omitempty"`
+	BoostMode string           `json:"boost_mode,omitempty"`
+	MaxBoost  *float64         `json:"max_boost,omitempty"`
+	BoostVal  *Boost           `json:"boost,omitempty"`
+}
+
+// NewFunctionScoreQuery creates a new Query matching
+// the documents the query matches, and scoring them
+// again with the functions.  The values of the
+// functions applying to a document are multiplied
+// together, then with the score of the document, see
+// SetScoreMode and SetBoostMode for the other ways.
+func NewFunctionScoreQuery(query Query, functions ...*ScoreFunction) *FunctionScoreQuery {
+	return &FunctionScoreQuery{
+		Query:     query,
+		Functions: functions,
+	}
+}
+
+func (q *FunctionScoreQuery) SetBoost(b float64) {
+	boost := Boost(b)
+	q.BoostVal = &boost
+}
+
+func (q *FunctionScoreQuery) Boost() float64 {
+	return q.BoostVal.Value()
+}
+
+func (q *FunctionScoreQuery) AddFunction(functions ...*ScoreFunction) {
+	q.Functions = append(q.Functions, functions...)
+}
+
+// SetScoreMode sets how the values of the functions
+// applying to a document are combined: "multiply",
+// "sum", "avg", "first", "max" or "min".
+func (q *FunctionScoreQuery) SetScoreMode(scoreMode string) {
+	q.ScoreMode = scoreMode
+}
+
+// SetBoostMode sets how the value of the functions is
+// combined with the score of the document: "multiply",
+// "replace", "sum", "avg", "max" or "min".
+func (q *FunctionScoreQuery) SetBoostMode(boostMode string) {
+	q.BoostMode = boostMode
+}
+
+// SetMaxBoost caps the value of the functions.
+func (q *FunctionScoreQuery) SetMaxBoost(maxBoost float64) {
+	q.MaxBoost = &maxBoost
+}
+
+func (q *FunctionScoreQuery) Searcher(i index.IndexReader, m mapping.IndexMapping, options search.SearcherOptions) (search.Searcher, error) {
+	functions := make([]scorer.ScoreFunction, len(q.Functions))
+	weights := make([]float64, len(q.Functions))
+	for j, function := range q.Functions {
+		var err error
+		functions[j], err = function.scoreFunction()
+		if err != nil {
+			return nil, err
+		}
+		weights[j] = 1.0
+		if function.Weight != nil {
+			weights[j] = *function.Weight
+		}
+	}
+	maxBoost := math.Inf(1)
+	if q.MaxBoost != nil {
+		maxBoost = *q.MaxBoost
+	}
+
+	s, err := q.Query.Searcher(i, m, options)
+	if err != nil {
+		return nil, err
+	}
+	filters := make([]search.Searcher, len(q.Functions))
+	for j, function := range q.Functions {
+		if function.Filter == nil {
+			continue
+		}
+		// the filters only tell which documents they match
+		filters[j], err = function.Filter.Searcher(i, m, search.SearcherOptions{})
+		if err != nil {
+			_ = s.Close()
+			closeSearchers(filters[:j])
+			return nil, err
+		}
+	}
+	rv, err := searcher.NewFunctionScoreSearcher(i, s, functions, weights, filters,
+		q.ScoreMode, q.BoostMode, maxBoost, q.BoostVal.Value(), options)
+	if err != nil {
+		_ = s.Close()
+		closeSearchers(filters)
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (q *FunctionScoreQuery) Validate() error {
+	if q.Query == nil {
+		return fmt.Errorf("function score query must have a query")
+	}
+	switch q.ScoreMode {
+	case "", scorer.FunctionScoreModeMultiply, scorer.FunctionScoreModeSum,
+		scorer.FunctionScoreModeAvg, scorer.FunctionScoreModeFirst,
+		scorer.FunctionScoreModeMax, scorer.FunctionScoreModeMin:
+	default:
+		return fmt.Errorf("unknown function score mode: '%s'", q.ScoreMode)
+	}
+	switch q.BoostMode {
+	case "", scorer.FunctionBoostModeMultiply, scorer.FunctionBoostModeReplace,
+		scorer.FunctionBoostModeSum, scorer.FunctionBoostModeAvg,
+		scorer.FunctionBoostModeMax, scorer.FunctionBoostModeMin:
+	default:
+		return fmt.Errorf("unknown function boost mode: '%s'", q.BoostMode)
+	}
+	if vq, ok := q.Query.(ValidatableQuery); ok {
+		err := vq.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	for _, function := range q.Functions {
+		_, err := function.scoreFunction()
+		if err != nil {
+			return err
+		}
+		if vq, ok := function.Filter.(ValidatableQuery); ok {
+			err = vq.Validate()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (q *FunctionScoreQuery) UnmarshalJSON(data []byte) error {
+	tmp := struct {
+		Query     json.RawMessage  `json:"function_score"`
+		Functions []*ScoreFunction `json:"functions"`
+		ScoreMode string           `json:"score_mode"`
+		BoostMode string           `json:"boost_mode"`
+		MaxBoost  *float64         `json:"max_boost"`
+		Boost     *Boost           `json:"boost,omitempty"`
+	}{}
+	err := json.Unmarshal(data, &tmp)
+	if err != nil {
+		return err
+	}
+	q.Query, err = ParseQuery(tmp.Query)
+	if err != nil {
+		return err
+	}
+	q.Functions = tmp.Functions
+	q.ScoreMode = tmp.ScoreMode
+	q.BoostMode = tmp.BoostMode
+	q.MaxBoost = tmp.MaxBoost
+	q.BoostVal = tmp.Boost
+	return nil
+}
+
+// ScoreFunction is a function of a FunctionScoreQuery, one of a
+// field value factor, a decay or a random score, or its weight only.
+// It applies to the documents its filter matches, to all of them
+// without a filter.
+type ScoreFunction struct {
+	Filter           Query
+	Weight           *float64
+	FieldValueFactor *FieldValueFactor
+	Decay            *DecayFunction
+	RandomScore      *RandomScore
+}
+
+// NewWeightFunction creates a function of the weight
+// only, to score the documents its filter matches.
+func NewWeightFunction(weight float64) *ScoreFunction {
+	return &ScoreFunction{
+		Weight: &weight,
+	}
+}
+
+// NewFieldValueFactorFunction creates a function of
+// the value of a numeric field.
+func NewFieldValueFactorFunction(field string) *ScoreFunction {
+	return &ScoreFunction{
+		FieldValueFactor: &FieldValueFactor{
+			Field:  field,
+			Factor: 1.0,
+		},
+	}
+}
+
+// NewDecayFunction creates a function decaying with
+// the distance of the value of the field from the
+// origin, "gauss", "exp" or "linear", from 1 at the
+// origin to 0.5 at scale from it.
+// A numeric field takes a number origin and scale.
+// A date field takes a time.Time or date string
+// origin, now without one, and a duration string
+// scale such as "10d" or "12h".
+// A geo point field takes a geo point origin and a
+// distance string scale such as "2km".
+func NewDecayFunction(decayType, field string, origin, scale interface{}) *ScoreFunction {
+	return &ScoreFunction{
+		Decay: &DecayFunction{
+			Type:   decayType,
+			Field:  field,
+			Origin: origin,
+			Scale:  scale,
+			Decay:  0.5,
+		},
+	}
+}
+
+// NewRandomScoreFunction creates a function of random
+// values from 0 to 1, the same for the same seed and
+// document.
+func NewRandomScoreFunction(seed int64) *ScoreFunction {
+	return &ScoreFunction{
+		RandomScore: &RandomScore{
+			Seed: seed,
+		},
+	}
+}
+
+// SetFilter applies the function to the documents the
+// filter matches only.
+func (f *ScoreFunction) SetFilter(filter Query) {
+	f.Filter = filter
+}
+
+// SetWeight multiplies the value of the function by
+// the weight.
+func (f *ScoreFunction) SetWeight(weight float64) {
+	f.Weight = &weight
+}
+
+// scoreFunction returns the function computing the values
+func (f *ScoreFunction) scoreFunction() (scorer.ScoreFunction, error) {
+	var rv scorer.ScoreFunction
+	var err error
+	count := 0
+	if f.FieldValueFactor != nil {
+		rv, err = f.FieldValueFactor.scoreFunction()
+		count++
+	}
+	if f.Decay != nil {
+		rv, err = f.Decay.scoreFunction()
+		count++
+	}
+	if f.RandomScore != nil {
+		rv = scorer.NewRandomScoreFunction(f.RandomScore.Seed, f.RandomScore.Field)
+		count++
+	}
+	switch {
+	case count > 1:
+		return nil, fmt.Errorf("score function must have one of field_value_factor, gauss, exp, linear and random_score")
+	case count == 0 && f.Weight == nil:
+		return nil, fmt.Errorf("score function must have a function or a weight")
+	case count == 0:
+		return scorer.NewWeightFunction(), nil
+	}
+	return rv, err
+}
+
+func (f *ScoreFunction) MarshalJSON() ([]byte, error) {
+	rv := make(map[string]interface{})
+	if f.Filter != nil {
+		rv["filter"] = f.Filter
+	}
+	if f.Weight != nil {
+		rv["weight"] = *f.Weight
+	}
+	if f.FieldValueFactor != nil {
+		rv["field_value_factor"] = f.FieldValueFactor
+	}
+	if f.Decay != nil {
+		rv[f.Decay.Type] = f.Decay
+	}
+	if f.RandomScore != nil {
+		rv["random_score"] = f.RandomScore
+	}
+	return json.Marshal(rv)
+}
+
+func (f *ScoreFunction) UnmarshalJSON(data []byte) error {
+	tmp := struct {
+		Filter           json.RawMessage `json:"filter"`
+		Weight           *float64        `json:"weight"`
+		FieldValueFactor json.RawMessage `json:"field_value_factor"`
+		Gauss            json.RawMessage `json:"gauss"`
+		Exp              json.RawMessage `json:"exp"`
+		Linear           json.RawMessage `json:"linear"`
+		RandomScore      *RandomScore    `json:"random_score"`
+	}{}
+	err := json.Unmarshal(data, &tmp)
+	if err != nil {
+		return err
+	}
+	*f = ScoreFunction{
+		Weight:      tmp.Weight,
+		RandomScore: tmp.RandomScore,
+	}
+	if tmp.Filter != nil {
+		f.Filter, err = ParseQuery(tmp.Filter)
+		if err != nil {
+			return err
+		}
+	}
+	if tmp.FieldValueFactor != nil {
+		f.FieldValueFactor = &FieldValueFactor{Factor: 1.0}
+		err = json.Unmarshal(tmp.FieldValueFactor, f.FieldValueFactor)
+		if err != nil {
+			return err
+		}
+	}
+	for _, decay := range []struct {
+		decayType string
+		data      json.RawMessage
+	}{
+		{scorer.DecayGauss, tmp.Gauss},
+		{scorer.DecayExp, tmp.Exp},
+		{scorer.DecayLinear, tmp.Linear},
+	} {
+		if decay.data == nil {
+			continue
+		}
+		if f.Decay != nil {
+			return fmt.Errorf("score function must have one of gauss, exp and linear")
+		}
+		f.Decay = &DecayFunction{Decay: 0.5}
+		err = json.Unmarshal(decay.data, f.Decay)
+		if err != nil {
+			return err
+		}
+		f.Decay.Type = decay.decayType
+	}
+	return nil
+}
+
+// FieldValueFactor scores a document by the value of a
+// numeric field times the factor, through the modifier:
+// "none", "log", "log1p", "log2p", "ln", "ln1p", "ln2p",
+// "square", "sqrt" or "reciprocal".
+type FieldValueFactor struct {
+	Field    string   `json:"field"`
+	Factor   float64  `json:"factor"`
+	Modifier string   `json:"modifier,omitempty"`
+	Missing  *float64 `json:"missing,omitempty"`
+}
+
+func (f *FieldValueFactor) SetFactor(factor float64) {
+	f.Factor = factor
+}
+
+func (f *FieldValueFactor) SetModifier(modifier string) {
+	f.Modifier = modifier
+}
+
+// SetMissing sets the value of the documents without a
+// value, which are an error otherwise.
+func (f *FieldValueFactor) SetMissing(missing float64) {
+	f.Missing = &missing
+}
+
+func (f *FieldValueFactor) scoreFunction() (scorer.ScoreFunction, error) {
+	if f.Field == "" {
+		return nil, fmt.Errorf("field value factor must have a field")
+	}
+	return scorer.NewFieldValueFactorFunction(f.Field, f.Factor, f.Modifier, f.Missing)
+}
+
+// DecayFunction scores a document by the distance of
+// the value of a numeric, date or geo point field from
+// the origin, see NewDecayFunction.
+type DecayFunction struct {
+	Type   string      `json:"-"`
+	Field  string      `json:"field"`
+	Origin interface{} `json:"origin,omitempty"`
+	Scale  interface{} `json:"scale"`
+	Offset interface{} `json:"offset,omitempty"`
+	Decay  float64     `json:"decay"`
+}
+
+// SetOffset sets the distance from the origin the
+// value starts to decay at, in the units of scale.
+func (d *DecayFunction) SetOffset(offset interface{}) {
+	d.Offset = offset
+}
+
+// SetDecay sets the value at scale from the origin,
+// between 0 and 1.
+func (d *DecayFunction) SetDecay(decay float64) {
+	d.Decay = decay
+}
+
+func (d *DecayFunction) scoreFunction() (scorer.ScoreFunction, error) {
+	if d.Field == "" {
+		return nil, fmt.Errorf("decay function must have a field")
+	}
+	switch origin := d.Origin.(type) {
+	case nil:
+		return d.dateFunction(time.Now())
+	case time.Time:
+		return d.dateFunction(origin)
+	case string:
+		t, err := queryTimeFromString(origin)
+		if err != nil {
+			return nil, fmt.Errorf("decay function origin '%s' is not a date: %v", origin, err)
+		}
+		return d.dateFunction(t)
+	}
+
+	if origin, ok := numberValue(d.Origin); ok {
+		scale, ok := numberValue(d.Scale)
+		if !ok {
+			return nil, fmt.Errorf("numeric decay function scale must be a number")
+		}
+		var offset float64
+		if d.Offset != nil {
+			offset, ok = numberValue(d.Offset)
+			if !ok {
+				return nil, fmt.Errorf("numeric decay function offset must be a number")
+			}
+		}
+		return scorer.NewNumericDecayFunction(d.Type, d.Field, origin, scale, offset, d.Decay)
+	}
+
+	lon, lat, ok := geo.ExtractGeoPoint(d.Origin)
+	if !ok {
+		return nil, fmt.Errorf("decay function origin must be a number, a date or a geo point")
+	}
+	scale, err := decayDistance(d.Scale)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := decayDistance(d.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return scorer.NewGeoDecayFunction(d.Type, d.Field, lon, lat, scale, offset, d.Decay)
+}
+
+func (d *DecayFunction) dateFunction(origin time.Time) (scorer.ScoreFunction, error) {
+	scale, err := decayDuration(d.Scale)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := decayDuration(d.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return scorer.NewDateDecayFunction(d.Type, d.Field, origin, scale, offset, d.Decay)
+}
+
+// decayDuration returns the duration of a date decay function scale
+// or offset, a duration or a duration string with the d and w units
+// for days and weeks, numbers are in milliseconds
+func decayDuration(v interface{}) (time.Duration, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case time.Duration:
+		return v, nil
+	case string:
+		for _, unit := range []struct {
+			suffix   string
+			duration time.Duration
+		}{
+			{"d", 24 * time.Hour},
+			{"w", 7 * 24 * time.Hour},
+		} {
+			if strings.HasSuffix(v, unit.suffix) {
+				n, err := strconv.ParseFloat(strings.TrimSuffix(v, unit.suffix), 64)
+				if err != nil {
+					return 0, fmt.Errorf("invalid decay function duration '%s': %v", v, err)
+				}
+				return time.Duration(n * float64(unit.duration)), nil
+			}
+		}
+		rv, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid decay function duration '%s': %v", v, err)
+		}
+		return rv, nil
+	}
+	if n, ok := numberValue(v); ok {
+		return time.Duration(n * float64(time.Millisecond)), nil
+	}
+	return 0, fmt.Errorf("date decay function scale and offset must be durations")
+}
+
+// decayDistance returns the distance in meters of a geo point decay
+// function scale or offset, a distance string or a number of meters
+func decayDistance(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return geo.ParseDistance(v)
+	}
+	if n, ok := numberValue(v); ok {
+		return n, nil
+	}
+	return 0, fmt.Errorf("geo point decay function scale and offset must be distances")
+}
+
+func numberValue(v interface{}) (float64, bool) {
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(val.Uint()), true
+	}
+	return 0, false
+}
+
+// RandomScore scores a document with a random value
+// from 0 to 1 drawn from the seed and the id of the
+// document, or the value of the field when it has one.
+type RandomScore struct {
+	Seed  int64  `json:"seed"`
+	Field string `json:"field,omitempty"`
+}
+
+func (r *RandomScore) SetField(field string) {
+	r.Field = field
+}
@@ -0,0 +1,96 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"encoding/json"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/mapping"
+	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/searcher"
+)
+
+type MatchAllQuery struct {
+	// After resumes a listing after the document of this id
+	After    string `json:"after,omitempty"`
+	BoostVal *Boost `json:"boost,omitempty"`
+}
+
+// NewMatchAllQuery creates a Query which will
+// match all documents in the index, with the
+// same score, in the order of their ids.
+func NewMatchAllQuery() *MatchAllQuery {
+	return &MatchAllQuery{}
+}
+
+func (q *MatchAllQuery) SetBoost(b float64) {
+	boost := Boost(b)
+	q.BoostVal = &boost
+}
+
+func (q *MatchAllQuery) Boost() float64 {
+	return q.BoostVal.Value()
+}
+
+// SetAfter makes the query match the documents
+// following the document of the id only.  Hits
+// of the same score keep the order of the ids,
+// so the id of the last hit of a page of all
+// documents resumes the listing with the next
+// page.
+func (q *MatchAllQuery) SetAfter(id string) {
+	q.After = id
+}
+
+func (q *MatchAllQuery) Searcher(i index.IndexReader, m mapping.IndexMapping, options search.SearcherOptions) (search.Searcher, error) {
+	var after index.IndexInternalID
+	if q.After != "" {
+		var err error
+		after, err = i.InternalID(q.After)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return searcher.NewMatchAllSearcherAfter(i, after, q.BoostVal.Value(), options)
+}
+
+func (q *MatchAllQuery) MarshalJSON() ([]byte, error) {
+	matchAll := map[string]interface{}{}
+	if q.After != "" {
+		matchAll["after"] = q.After
+	}
+	tmp := map[string]interface{}{
+		"boost":     q.BoostVal,
+		"match_all": matchAll,
+	}
+	return json.Marshal(tmp)
+}
+
+func (q *MatchAllQuery) UnmarshalJSON(data []byte) error {
+	tmp := struct {
+		MatchAll struct {
+			After string `json:"after"`
+		} `json:"match_all"`
+		Boost *Boost `json:"boost,omitempty"`
+	}{}
+	err := json.Unmarshal(data, &tmp)
+	if err != nil {
+		return err
+	}
+	q.After = tmp.MatchAll.After
+	q.BoostVal = tmp.Boost
+	return nil
+}
@@ -27,6 +27,7 @@ type MatchPhraseQuery struct {
 	MatchPhrase string `json:"match_phrase"`
 	FieldVal    string `json:"field,omitempty"`
 	Analyzer    string `json:"analyzer,omitempty"`
+	Slop        int    `json:"slop,omitempty"`
 	BoostVal    *Boost `json:"boost,omitempty"`
 }
 
@@ -53,6 +54,20 @@ func (q *MatchPhraseQuery) Boost() float64 {
 	return q.BoostVal.Value()
 }
 
+// SetSlop lets the tokens match out of order or apart
+// by up to slop moves of the tokens, matches needing
+// more moves score lower.
+func (q *MatchPhraseQuery) SetSlop(slop int) {
+	q.Slop = slop
+}
+
+func (q *MatchPhraseQuery) Validate() error {
+	if q.Slop < 0 {
+		return fmt.Errorf("phrase query slop cannot be negative")
+	}
+	return nil
+}
+
 func (q *MatchPhraseQuery) SetField(f string) {
 	q.FieldVal = f
 }
@@ -82,6 +97,7 @@ func (q *MatchPhraseQuery) Searcher(i index.IndexReader, m mapping.IndexMapping,
 	if len(tokens) > 0 {
 		phrase := tokenStreamToPhrase(tokens)
 		phraseQuery := NewMultiPhraseQuery(phrase, field)
+		phraseQuery.SetSlop(q.Slop)
 		phraseQuery.SetBoost(q.BoostVal.Value())
 		return phraseQuery.Searcher(i, m, options)
 	}
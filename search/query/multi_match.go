@@ -0,0 +1,331 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/mapping"
+	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/searcher"
+)
+
+// The types of a MultiMatchQuery, how the text is searched in its
+// fields and how the scores of the fields are combined
+const (
+	// MultiMatchBestFields matches the text in each field like a
+	// MatchQuery and scores a document by its best field, plus the
+	// tie breaker times the scores of its other fields
+	MultiMatchBestFields = "best_fields"
+	// MultiMatchMostFields matches the text in each field like a
+	// MatchQuery and sums the scores of the fields
+	MultiMatchMostFields = "most_fields"
+	// MultiMatchCrossFields searches each token of the text in all the
+	// fields as if they were one, a token is scored with the same
+	// document frequency in each of the fields
+	MultiMatchCrossFields = "cross_fields"
+	// MultiMatchPhrase matches the text as a phrase in each field like
+	// a MatchPhraseQuery and scores a document by its best field
+	MultiMatchPhrase = "phrase"
+)
+
+type MultiMatchQuery struct {
+	Match      string             `json:"multi_match"`
+	Fields     []string           `json:"fields,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	TieBreaker float64            `json:"tie_breaker,omitempty"`
+	Analyzer   string             `json:"analyzer,omitempty"`
+	Operator   MatchQueryOperator `json:"operator,omitempty"`
+	Prefix     int                `json:"prefix_length,omitempty"`
+	Fuzziness  int                `json:"fuzziness,omitempty"`
+	Slop       int                `json:"slop,omitempty"`
+	BoostVal   *Boost             `json:"boost,omitempty"`
+}
+
+// NewMultiMatchQuery creates a Query for matching text
+// in several fields, each field may end with ^ and a
+// boost for the matches in that field, as in "title^2".
+// Without fields the default search field is used.
+// The text is matched in each field like a MatchQuery
+// and a document is scored by its best field, see
+// SetType for the other ways.
+func NewMultiMatchQuery(match string, fields ...string) *MultiMatchQuery {
+	return &MultiMatchQuery{
+		Match:    match,
+		Fields:   fields,
+		Operator: MatchQueryOperatorOr,
+	}
+}
+
+func (q *MultiMatchQuery) SetBoost(b float64) {
+	boost := Boost(b)
+	q.BoostVal = &boost
+}
+
+func (q *MultiMatchQuery) Boost() float64 {
+	return q.BoostVal.Value()
+}
+
+func (q *MultiMatchQuery) SetFields(fields ...string) {
+	q.Fields = fields
+}
+
+// SetType sets how the text is searched in the fields, one
+// of MultiMatchBestFields, MultiMatchMostFields,
+// MultiMatchCrossFields and MultiMatchPhrase.
+func (q *MultiMatchQuery) SetType(t string) {
+	q.Type = t
+}
+
+// SetTieBreaker sets how much the fields other than the
+// best one add to the score, from 0 for none of them to
+// 1 for all of them.
+func (q *MultiMatchQuery) SetTieBreaker(tieBreaker float64) {
+	q.TieBreaker = tieBreaker
+}
+
+func (q *MultiMatchQuery) SetFuzziness(f int) {
+	q.Fuzziness = f
+}
+
+func (q *MultiMatchQuery) SetPrefix(p int) {
+	q.Prefix = p
+}
+
+func (q *MultiMatchQuery) SetOperator(operator MatchQueryOperator) {
+	q.Operator = operator
+}
+
+func (q *MultiMatchQuery) SetSlop(slop int) {
+	q.Slop = slop
+}
+
+func (q *MultiMatchQuery) Validate() error {
+	switch q.Type {
+	case "", MultiMatchBestFields, MultiMatchMostFields:
+	case MultiMatchCrossFields, MultiMatchPhrase:
+		if q.Fuzziness != 0 {
+			return fmt.Errorf("multi match query of type %s cannot be fuzzy", q.Type)
+		}
+	default:
+		return fmt.Errorf("unknown multi match query type: '%s'", q.Type)
+	}
+	if q.TieBreaker < 0 || q.TieBreaker > 1 {
+		return fmt.Errorf("multi match query tie breaker must be between 0 and 1")
+	}
+	if q.Slop < 0 {
+		return fmt.Errorf("phrase query slop cannot be negative")
+	}
+	for _, field := range q.Fields {
+		_, _, err := parseFieldBoost(field)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseFieldBoost splits the boost off a field name ending
+// with ^ and the boost
+func parseFieldBoost(field string) (string, float64, error) {
+	i := strings.LastIndex(field, "^")
+	if i < 0 {
+		return field, 1.0, nil
+	}
+	boost, err := strconv.ParseFloat(field[i+1:], 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid boost of field '%s': %v", field, err)
+	}
+	return field[:i], boost, nil
+}
+
+// fieldBoosts returns the fields searched and their boosts
+func (q *MultiMatchQuery) fieldBoosts(m mapping.IndexMapping) ([]string, []float64, error) {
+	if len(q.Fields) == 0 {
+		return []string{m.DefaultSearchField()}, []float64{1.0}, nil
+	}
+	fields := make([]string, len(q.Fields))
+	boosts := make([]float64, len(q.Fields))
+	for i, field := range q.Fields {
+		var err error
+		fields[i], boosts[i], err = parseFieldBoost(field)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return fields, boosts, nil
+}
+
+func (q *MultiMatchQuery) Searcher(i index.IndexReader, m mapping.IndexMapping, options search.SearcherOptions) (search.Searcher, error) {
+	fields, boosts, err := q.fieldBoosts(m)
+	if err != nil {
+		return nil, err
+	}
+	boost := q.BoostVal.Value()
+	for j := range boosts {
+		boosts[j] *= boost
+	}
+
+	switch q.Type {
+	case "", MultiMatchBestFields, MultiMatchMostFields:
+		ss, err := fieldSearchers(i, m, options, fields, func(field string, j int) Query {
+			mq := NewMatchQuery(q.Match)
+			mq.SetField(field)
+			mq.Analyzer = q.Analyzer
+			mq.SetOperator(q.Operator)
+			mq.SetFuzziness(q.Fuzziness)
+			mq.SetPrefix(q.Prefix)
+			mq.SetBoost(boosts[j])
+			return mq
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(ss) == 0 {
+			return searcher.NewMatchNoneSearcher(i)
+		}
+		if q.Type == MultiMatchMostFields {
+			return searcher.NewDisjunctionSearcher(i, ss, 1, options)
+		}
+		return searcher.NewDisjunctionMaxSearcher(i, ss, q.TieBreaker, options)
+
+	case MultiMatchPhrase:
+		ss, err := fieldSearchers(i, m, options, fields, func(field string, j int) Query {
+			pq := NewMatchPhraseQuery(q.Match)
+			pq.SetField(field)
+			pq.Analyzer = q.Analyzer
+			pq.SetSlop(q.Slop)
+			pq.SetBoost(boosts[j])
+			return pq
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(ss) == 0 {
+			return searcher.NewMatchNoneSearcher(i)
+		}
+		return searcher.NewDisjunctionMaxSearcher(i, ss, q.TieBreaker, options)
+
+	case MultiMatchCrossFields:
+		return q.crossFieldsSearcher(i, m, options, fields, boosts)
+
+	default:
+		return nil, fmt.Errorf("unknown multi match query type: '%s'", q.Type)
+	}
+}
+
+// crossFieldsSearcher searches each token in all the fields analyzed
+// alike, fields analyzed differently are searched apart and the best
+// of them scores
+func (q *MultiMatchQuery) crossFieldsSearcher(i index.IndexReader, m mapping.IndexMapping, options search.SearcherOptions, fields []string, boosts []float64) (search.Searcher, error) {
+	groups := make(map[string][]int)
+	for j, field := range fields {
+		analyzerName := q.Analyzer
+		if analyzerName == "" {
+			analyzerName = m.AnalyzerNameForPath(field)
+		}
+		groups[analyzerName] = append(groups[analyzerName], j)
+	}
+	analyzerNames := make([]string, 0, len(groups))
+	for analyzerName := range groups {
+		analyzerNames = append(analyzerNames, analyzerName)
+	}
+	sort.Strings(analyzerNames)
+
+	gs := make([]search.Searcher, 0, len(groups))
+	for _, analyzerName := range analyzerNames {
+		analyzer := m.AnalyzerNamed(analyzerName)
+		if analyzer == nil {
+			closeSearchers(gs)
+			return nil, fmt.Errorf("no analyzer named '%s' registered", analyzerName)
+		}
+		groupFields := make([]string, len(groups[analyzerName]))
+		groupBoosts := make([]float64, len(groups[analyzerName]))
+		for k, j := range groups[analyzerName] {
+			groupFields[k] = fields[j]
+			groupBoosts[k] = boosts[j]
+		}
+
+		tokens := analyzer.Analyze([]byte(q.Match))
+		if len(tokens) == 0 {
+			continue
+		}
+		ts := make([]search.Searcher, 0, len(tokens))
+		for _, token := range tokens {
+			s, err := searcher.NewBlendedTermSearcher(i, string(token.Term), groupFields, groupBoosts, q.TieBreaker, options)
+			if err != nil {
+				closeSearchers(ts)
+				closeSearchers(gs)
+				return nil, err
+			}
+			ts = append(ts, s)
+		}
+
+		var gsr search.Searcher
+		var err error
+		switch q.Operator {
+		case MatchQueryOperatorOr:
+			gsr, err = searcher.NewDisjunctionSearcher(i, ts, 1, options)
+		case MatchQueryOperatorAnd:
+			gsr, err = searcher.NewConjunctionSearcher(i, ts, options)
+		default:
+			err = fmt.Errorf("unhandled operator %d", q.Operator)
+		}
+		if err != nil {
+			closeSearchers(ts)
+			closeSearchers(gs)
+			return nil, err
+		}
+		gs = append(gs, gsr)
+	}
+	switch len(gs) {
+	case 0:
+		return searcher.NewMatchNoneSearcher(i)
+	case 1:
+		return gs[0], nil
+	}
+	return searcher.NewDisjunctionMaxSearcher(i, gs, q.TieBreaker, options)
+}
+
+// fieldSearchers returns the searchers of the queries fieldQuery
+// returns for each of the fields, those matching nothing are left out
+func fieldSearchers(i index.IndexReader, m mapping.IndexMapping, options search.SearcherOptions, fields []string, fieldQuery func(field string, j int) Query) ([]search.Searcher, error) {
+	ss := make([]search.Searcher, 0, len(fields))
+	for j, field := range fields {
+		sr, err := fieldQuery(field, j).Searcher(i, m, options)
+		if err != nil {
+			closeSearchers(ss)
+			return nil, err
+		}
+		if _, ok := sr.(*searcher.MatchNoneSearcher); ok {
+			_ = sr.Close()
+			continue
+		}
+		ss = append(ss, sr)
+	}
+	return ss, nil
+}
+
+func closeSearchers(ss []search.Searcher) {
+	for _, s := range ss {
+		if s != nil {
+			_ = s.Close()
+		}
+	}
+}
@@ -27,6 +27,7 @@ import (
 type MultiPhraseQuery struct {
 	Terms    [][]string `json:"terms"`
 	Field    string     `json:"field,omitempty"`
+	Slop     int        `json:"slop,omitempty"`
 	BoostVal *Boost     `json:"boost,omitempty"`
 }
 
@@ -55,14 +56,24 @@ func (q *MultiPhraseQuery) Boost() float64 {
 	return q.BoostVal.Value()
 }
 
+// SetSlop lets the terms match out of order or apart
+// by up to slop moves of the terms, matches needing
+// more moves score lower.
+func (q *MultiPhraseQuery) SetSlop(slop int) {
+	q.Slop = slop
+}
+
 func (q *MultiPhraseQuery) Searcher(i index.IndexReader, m mapping.IndexMapping, options search.SearcherOptions) (search.Searcher, error) {
-	return searcher.NewMultiPhraseSearcher(i, q.Terms, q.Field, options)
+	return searcher.NewSloppyMultiPhraseSearcher(i, q.Terms, q.Field, q.Slop, options)
 }
 
 func (q *MultiPhraseQuery) Validate() error {
 	if len(q.Terms) < 1 {
 		return fmt.Errorf("phrase query must contain at least one term")
 	}
+	if q.Slop < 0 {
+		return fmt.Errorf("phrase query slop cannot be negative")
+	}
 	return nil
 }
 
@@ -75,6 +86,7 @@ func (q *MultiPhraseQuery) UnmarshalJSON(data []byte) error {
 	}
 	q.Terms = tmp.Terms
 	q.Field = tmp.Field
+	q.Slop = tmp.Slop
 	q.BoostVal = tmp.BoostVal
 	return nil
 }
@@ -0,0 +1,112 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/mapping"
+	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/searcher"
+)
+
+type NestedQuery struct {
+	Path      string `json:"path"`
+	Query     Query  `json:"query"`
+	ScoreMode string `json:"score_mode,omitempty"`
+	InnerHits int    `json:"inner_hits,omitempty"`
+	BoostVal  *Boost `json:"boost,omitempty"`
+}
+
+// NewNestedQuery creates a new Query matching the documents with
+// nested documents at the path matching the query.  The query is
+// evaluated within a single nested document, its fields are named with
+// the full path.
+func NewNestedQuery(path string, query Query) *NestedQuery {
+	return &NestedQuery{
+		Path:  path,
+		Query: query,
+	}
+}
+
+func (q *NestedQuery) SetBoost(b float64) {
+	boost := Boost(b)
+	q.BoostVal = &boost
+}
+
+func (q *NestedQuery) Boost() float64 {
+	return q.BoostVal.Value()
+}
+
+// SetScoreMode sets how the scores of the nested documents matching are
+// combined into the score of the document: "avg", the default, "max",
+// "min" or "sum"
+func (q *NestedQuery) SetScoreMode(mode string) {
+	q.ScoreMode = mode
+}
+
+// SetInnerHits returns up to n of the best nested documents matching
+// along with each document
+func (q *NestedQuery) SetInnerHits(n int) {
+	q.InnerHits = n
+}
+
+func (q *NestedQuery) Searcher(i index.IndexReader, m mapping.IndexMapping, options search.SearcherOptions) (search.Searcher, error) {
+	s, err := q.Query.Searcher(i, m, options)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := searcher.NewNestedSearcher(i, s, q.Path, q.ScoreMode, q.BoostVal.Value(), q.InnerHits, options)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (q *NestedQuery) Validate() error {
+	if q.Path == "" {
+		return fmt.Errorf("nested query must have a path")
+	}
+	if q.Query == nil {
+		return fmt.Errorf("nested query must have a query")
+	}
+	switch q.ScoreMode {
+	case "", searcher.NestedScoreAvg, searcher.NestedScoreMax, searcher.NestedScoreMin, searcher.NestedScoreSum:
+	default:
+		return fmt.Errorf("unknown nested score mode: '%s'", q.ScoreMode)
+	}
+	if q.InnerHits < 0 {
+		return fmt.Errorf("nested query inner hits must not be negative")
+	}
+	if q, ok := q.Query.(ValidatableQuery); ok {
+		return q.Validate()
+	}
+	return nil
+}
+
+func (q *NestedQuery) UnmarshalJSON(data []byte) error {
+	tmp := struct {
+		Path      string          `json:"path"`
+		Query     json.RawMessage `json:"query"`
+		ScoreMode string          `json:"score_mode"`
+		InnerHits int             `json:"inner_hits"`
+		Boost     *Boost          `json:"boost,omitempty"`
+	}{}
+	err := json.Unmarshal(data, &tmp)
+	if err != nil {
+		return err
+	}
+	q.Path = tmp.Path
+	q.Query = nil
+	if tmp.Query != nil {
+		q.Query, err = ParseQuery(tmp.Query)
+		if err != nil {
+			return err
+		}
+	}
+	q.ScoreMode = tmp.ScoreMode
+	q.InnerHits = tmp.InnerHits
+	q.BoostVal = tmp.Boost
+	return nil
+}
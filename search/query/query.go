@@ -72,8 +72,9 @@ func ParseQuery(input []byte) (Query, error) {
 		return nil, err
 	}
 	_, isMatchQuery := tmp["match"]
+	_, isMultiMatchQuery := tmp["multi_match"]
 	_, hasFuzziness := tmp["fuzziness"]
-	if hasFuzziness && !isMatchQuery {
+	if hasFuzziness && !isMatchQuery && !isMultiMatchQuery {
 		var rv FuzzyQuery
 		err := json.Unmarshal(input, &rv)
 		if err != nil {
@@ -98,6 +99,14 @@ func ParseQuery(input []byte) (Query, error) {
 		}
 		return &rv, nil
 	}
+	if isMultiMatchQuery {
+		var rv MultiMatchQuery
+		err := json.Unmarshal(input, &rv)
+		if err != nil {
+			return nil, err
+		}
+		return &rv, nil
+	}
 	_, isMatchPhraseQuery := tmp["match_phrase"]
 	if isMatchPhraseQuery {
 		var rv MatchPhraseQuery
@@ -151,7 +160,34 @@ func ParseQuery(input []byte) (Query, error) {
 		}
 		return &rv, nil
 	}
+	_, hasFunctionScore := tmp["function_score"]
+	if hasFunctionScore {
+		var rv FunctionScoreQuery
+		err := json.Unmarshal(input, &rv)
+		if err != nil {
+			return nil, err
+		}
+		return &rv, nil
+	}
+	_, hasDisMax := tmp["dis_max"]
+	if hasDisMax {
+		var rv DisMaxQuery
+		err := json.Unmarshal(input, &rv)
+		if err != nil {
+			return nil, err
+		}
+		return &rv, nil
+	}
 
+	_, hasPath := tmp["path"]
+	if hasPath {
+		var rv NestedQuery
+		err := json.Unmarshal(input, &rv)
+		if err != nil {
+			return nil, err
+		}
+		return &rv, nil
+	}
 	_, hasSyntaxQuery := tmp["query"]
 	if hasSyntaxQuery {
 		var rv QueryStringQuery
@@ -218,6 +254,24 @@ func ParseQuery(input []byte) (Query, error) {
 		}
 		return &rv, nil
 	}
+	_, hasMatchAll := tmp["match_all"]
+	if hasMatchAll {
+		var rv MatchAllQuery
+		err := json.Unmarshal(input, &rv)
+		if err != nil {
+			return nil, err
+		}
+		return &rv, nil
+	}
+	_, hasExists := tmp["exists"]
+	if hasExists {
+		var rv ExistsQuery
+		err := json.Unmarshal(input, &rv)
+		if err != nil {
+			return nil, err
+		}
+		return &rv, nil
+	}
 	_, hasMatchNone := tmp["match_none"]
 	if hasMatchNone {
 		var rv MatchNoneQuery
@@ -290,7 +344,7 @@ func expandQuery(m mapping.IndexMapping, query Query) (Query, error) {
 		switch query.(type) {
 		case *QueryStringQuery:
 			q := query.(*QueryStringQuery)
-			parsed, err := parseQuerySyntax(q.Query)
+			parsed, err := q.Parse()
 			if err != nil {
 				return nil, fmt.Errorf("could not parse '%s': %s", q.Query, err)
 			}
@@ -311,6 +365,14 @@ func expandQuery(m mapping.IndexMapping, query Query) (Query, error) {
 			}
 			q.Disjuncts = children
 			return &q, nil
+		case *DisMaxQuery:
+			q := *query.(*DisMaxQuery)
+			children, err := expandSlice(q.Disjuncts)
+			if err != nil {
+				return nil, err
+			}
+			q.Disjuncts = children
+			return &q, nil
 		case *BooleanQuery:
 			q := *query.(*BooleanQuery)
 			var err error
@@ -327,6 +389,34 @@ func expandQuery(m mapping.IndexMapping, query Query) (Query, error) {
 				return nil, err
 			}
 			return &q, nil
+		case *FunctionScoreQuery:
+			q := *query.(*FunctionScoreQuery)
+			var err error
+			q.Query, err = expand(q.Query)
+			if err != nil {
+				return nil, err
+			}
+			functions := make([]*ScoreFunction, len(q.Functions))
+			for i, function := range q.Functions {
+				f := *function
+				if f.Filter != nil {
+					f.Filter, err = expand(f.Filter)
+					if err != nil {
+						return nil, err
+					}
+				}
+				functions[i] = &f
+			}
+			q.Functions = functions
+			return &q, nil
+		case *NestedQuery:
+			q := *query.(*NestedQuery)
+			var err error
+			q.Query, err = expand(q.Query)
+			if err != nil {
+				return nil, err
+			}
+			return &q, nil
 		default:
 			return query, nil
 		}
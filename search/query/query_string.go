@@ -21,8 +21,9 @@ import (
 )
 
 type QueryStringQuery struct {
-	Query    string `json:"query"`
-	BoostVal *Boost `json:"boost,omitempty"`
+	Query    string   `json:"query"`
+	Fields   []string `json:"fields,omitempty"`
+	BoostVal *Boost   `json:"boost,omitempty"`
 }
 
 // NewQueryStringQuery creates a new Query used for
@@ -43,12 +44,23 @@ func (q *QueryStringQuery) Boost() float64 {
 	return q.BoostVal.Value()
 }
 
+// SetFields makes the terms and phrases of the query string
+// given no field match in the fields, like a MultiMatchQuery
+// of them, instead of the default search field.
+func (q *QueryStringQuery) SetFields(fields ...string) {
+	q.Fields = fields
+}
+
 func (q *QueryStringQuery) Parse() (Query, error) {
-	return parseQuerySyntax(q.Query)
+	newQuery, err := parseQuerySyntax(q.Query)
+	if err != nil || len(q.Fields) == 0 {
+		return newQuery, err
+	}
+	return multiMatchFields(newQuery, q.Fields), nil
 }
 
 func (q *QueryStringQuery) Searcher(i index.IndexReader, m mapping.IndexMapping, options search.SearcherOptions) (search.Searcher, error) {
-	newQuery, err := parseQuerySyntax(q.Query)
+	newQuery, err := q.Parse()
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +68,7 @@ func (q *QueryStringQuery) Searcher(i index.IndexReader, m mapping.IndexMapping,
 }
 
 func (q *QueryStringQuery) Validate() error {
-	newQuery, err := parseQuerySyntax(q.Query)
+	newQuery, err := q.Parse()
 	if err != nil {
 		return err
 	}
@@ -65,3 +77,39 @@ func (q *QueryStringQuery) Validate() error {
 	}
 	return nil
 }
+
+// multiMatchFields replaces the match and match phrase queries given no
+// field in the parsed query with multi match queries of the fields
+func multiMatchFields(query Query, fields []string) Query {
+	switch q := query.(type) {
+	case *BooleanQuery:
+		q.Must = multiMatchFields(q.Must, fields)
+		q.Should = multiMatchFields(q.Should, fields)
+		q.MustNot = multiMatchFields(q.MustNot, fields)
+	case *ConjunctionQuery:
+		for i, conjunct := range q.Conjuncts {
+			q.Conjuncts[i] = multiMatchFields(conjunct, fields)
+		}
+	case *DisjunctionQuery:
+		for i, disjunct := range q.Disjuncts {
+			q.Disjuncts[i] = multiMatchFields(disjunct, fields)
+		}
+	case *MatchQuery:
+		if q.FieldVal == "" {
+			rv := NewMultiMatchQuery(q.Match, fields...)
+			rv.SetFuzziness(q.Fuzziness)
+			rv.SetPrefix(q.Prefix)
+			rv.BoostVal = q.BoostVal
+			return rv
+		}
+	case *MatchPhraseQuery:
+		if q.FieldVal == "" {
+			rv := NewMultiMatchQuery(q.MatchPhrase, fields...)
+			rv.SetType(MultiMatchPhrase)
+			rv.SetSlop(q.Slop)
+			rv.BoostVal = q.BoostVal
+			return rv
+		}
+	}
+	return query
+}
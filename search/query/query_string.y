@@ -147,6 +147,18 @@ tPHRASE {
 	$$ = q
 }
 |
+tPHRASE tTILDE {
+	phrase := $1
+	slop, err := strconv.ParseFloat($2, 64)
+	if err != nil {
+	  yylex.(*lexerWrapper).lex.Error(fmt.Sprintf("invalid slop value: %v", err))
+	}
+	logDebugGrammar("SLOPPY PHRASE - %s %f", phrase, slop)
+	q := NewMatchPhraseQuery(phrase)
+	q.SetSlop(int(slop))
+	$$ = q
+}
+|
 tSTRING tCOLON tSTRING {
 	field := $1
 	str := $3
@@ -190,6 +202,20 @@ tSTRING tCOLON tPHRASE {
 	$$ = q
 }
 |
+tSTRING tCOLON tPHRASE tTILDE {
+	field := $1
+	phrase := $3
+	slop, err := strconv.ParseFloat($4, 64)
+	if err != nil {
+		yylex.(*lexerWrapper).lex.Error(fmt.Sprintf("invalid slop value: %v", err))
+	}
+	logDebugGrammar("FIELD - %s SLOPPY PHRASE - %s %f", field, phrase, slop)
+	q := NewMatchPhraseQuery(phrase)
+	q.SetSlop(int(slop))
+	q.SetField(field)
+	$$ = q
+}
+|
 tSTRING tCOLON tGREATER posOrNegNumber {
 	field := $1
 	min, err := strconv.ParseFloat($4, 64)
@@ -1,3 +1,4 @@
+//line query_string.y:2
 package query
 
 import __yyfmt__ "fmt"
@@ -54,6 +55,7 @@ var yyToknames = [...]string{
 	"tEQUAL",
 	"tTILDE",
 }
+
 var yyStatenames = [...]string{}
 
 const yyEofCode = 1
@@ -61,7 +63,7 @@ const yyErrCode = 2
 const yyInitialStackSize = 16
 
 //line yacctab:1
-var yyExca = [...]int{
+var yyExca = [...]int8{
 	-1, 1,
 	1, -1,
 	-2, 0,
@@ -70,69 +72,65 @@ var yyExca = [...]int{
 	-2, 5,
 }
 
-const yyNprod = 28
 const yyPrivate = 57344
 
-var yyTokenNames []string
-var yyStates []string
-
-const yyLast = 42
-
-var yyAct = [...]int{
+const yyLast = 44
 
-	17, 16, 18, 23, 22, 30, 3, 21, 19, 20,
-	29, 26, 22, 22, 1, 21, 21, 15, 28, 25,
-	24, 27, 34, 14, 22, 13, 31, 21, 32, 33,
-	22, 9, 11, 21, 5, 6, 2, 10, 4, 12,
-	7, 8,
+var yyAct = [...]int8{
+	18, 17, 19, 25, 23, 24, 16, 22, 20, 21,
+	31, 28, 23, 23, 32, 22, 22, 15, 30, 27,
+	13, 26, 29, 14, 36, 34, 23, 23, 33, 22,
+	22, 35, 9, 11, 3, 5, 6, 2, 10, 1,
+	4, 7, 12, 8,
 }
-var yyPact = [...]int{
 
-	28, -1000, -1000, 28, 27, -1000, -1000, -1000, 16, 9,
-	-1000, -1000, -1000, -1000, -1000, -3, -11, -1000, -1000, 6,
-	5, -1000, -5, -1000, -1000, 23, -1000, -1000, 17, -1000,
-	-1000, -1000, -1000, -1000, -1000,
+var yyPact = [...]int16{
+	29, -32768, -32768, 29, 28, -32768, -32768, -32768, 11, 9,
+	-32768, -8, -32768, -32768, -32768, -3, -32768, -9, -32768, -11,
+	6, 5, -32768, 4, -32768, -32768, -32768, 20, -32768, -32768,
+	19, -32768, -32768, -32768, -32768, -32768, -32768,
 }
-var yyPgo = [...]int{
 
-	0, 0, 41, 39, 38, 14, 36, 6,
+var yyPgo = [...]int8{
+	0, 0, 43, 42, 40, 39, 37, 34,
 }
-var yyR1 = [...]int{
 
+var yyR1 = [...]int8{
 	0, 5, 6, 6, 7, 4, 4, 4, 2, 2,
 	2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 3, 3, 1, 1,
+	2, 2, 2, 2, 2, 2, 3, 3, 1, 1,
 }
-var yyR2 = [...]int{
 
+var yyR2 = [...]int8{
 	0, 1, 2, 1, 3, 0, 1, 1, 1, 2,
-	4, 1, 1, 3, 3, 3, 4, 5, 4, 5,
-	4, 5, 4, 5, 0, 1, 1, 2,
+	4, 1, 1, 2, 3, 3, 3, 4, 4, 5,
+	4, 5, 4, 5, 4, 5, 0, 1, 1, 2,
 }
-var yyChk = [...]int{
 
-	-1000, -5, -6, -7, -4, 6, 7, -6, -2, 4,
-	10, 5, -3, 9, 14, 8, 4, -1, 5, 11,
-	12, 10, 7, 14, -1, 13, 5, -1, 13, 5,
-	10, -1, 5, -1, 5,
+var yyChk = [...]int16{
+	-32768, -5, -6, -7, -4, 6, 7, -6, -2, 4,
+	10, 5, -3, 9, 14, 8, 14, 4, -1, 5,
+	11, 12, 10, 7, 14, 14, -1, 13, 5, -1,
+	13, 5, 10, -1, 5, -1, 5,
 }
-var yyDef = [...]int{
 
-	5, -2, 1, -2, 0, 6, 7, 2, 24, 8,
-	11, 12, 4, 25, 9, 0, 13, 14, 15, 0,
-	0, 26, 0, 10, 16, 0, 20, 18, 0, 22,
-	27, 17, 21, 19, 23,
+var yyDef = [...]int8{
+	5, -2, 1, -2, 0, 6, 7, 2, 26, 8,
+	11, 12, 4, 27, 9, 0, 13, 14, 15, 16,
+	0, 0, 28, 0, 10, 17, 18, 0, 22, 20,
+	0, 24, 29, 19, 23, 21, 25,
 }
-var yyTok1 = [...]int{
 
+var yyTok1 = [...]int8{
 	1,
 }
-var yyTok2 = [...]int{
 
+var yyTok2 = [...]int8{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
 	12, 13, 14,
 }
-var yyTok3 = [...]int{
+
+var yyTok3 = [...]int8{
 	0,
 }
 
@@ -175,7 +173,7 @@ func yyNewParser() yyParser {
 	return &yyParserImpl{}
 }
 
-const yyFlag = -1000
+const yyFlag = -32768
 
 func yyTokname(c int) string {
 	if c >= 1 && c-1 < len(yyToknames) {
@@ -214,9 +212,9 @@ func yyErrorMessage(state, lookAhead int) string {
 	expected := make([]int, 0, 4)
 
 	// Look for shiftable tokens.
-	base := yyPact[state]
+	base := int(yyPact[state])
 	for tok := TOKSTART; tok-1 < len(yyToknames); tok++ {
-		if n := base + tok; n >= 0 && n < yyLast && yyChk[yyAct[n]] == tok {
+		if n := base + tok; n >= 0 && n < yyLast && int(yyChk[int(yyAct[n])]) == tok {
 			if len(expected) == cap(expected) {
 				return res
 			}
@@ -226,13 +224,13 @@ func yyErrorMessage(state, lookAhead int) string {
 
 	if yyDef[state] == -2 {
 		i := 0
-		for yyExca[i] != -1 || yyExca[i+1] != state {
+		for yyExca[i] != -1 || int(yyExca[i+1]) != state {
 			i += 2
 		}
 
 		// Look for tokens that we accept or reduce.
 		for i += 2; yyExca[i] >= 0; i += 2 {
-			tok := yyExca[i]
+			tok := int(yyExca[i])
 			if tok < TOKSTART || yyExca[i+1] == 0 {
 				continue
 			}
@@ -263,30 +261,30 @@ func yylex1(lex yyLexer, lval *yySymType) (char, token int) {
 	token = 0
 	char = lex.Lex(lval)
 	if char <= 0 {
-		token = yyTok1[0]
+		token = int(yyTok1[0])
 		goto out
 	}
 	if char < len(yyTok1) {
-		token = yyTok1[char]
+		token = int(yyTok1[char])
 		goto out
 	}
 	if char >= yyPrivate {
 		if char < yyPrivate+len(yyTok2) {
-			token = yyTok2[char-yyPrivate]
+			token = int(yyTok2[char-yyPrivate])
 			goto out
 		}
 	}
 	for i := 0; i < len(yyTok3); i += 2 {
-		token = yyTok3[i+0]
+		token = int(yyTok3[i+0])
 		if token == char {
-			token = yyTok3[i+1]
+			token = int(yyTok3[i+1])
 			goto out
 		}
 	}
 
 out:
 	if token == 0 {
-		token = yyTok2[1] /* unknown char */
+		token = int(yyTok2[1]) /* unknown char */
 	}
 	if yyDebug >= 3 {
 		__yyfmt__.Printf("lex %s(%d)\n", yyTokname(token), uint(char))
@@ -341,7 +339,7 @@ yystack:
 	yyS[yyp].yys = yystate
 
 yynewstate:
-	yyn = yyPact[yystate]
+	yyn = int(yyPact[yystate])
 	if yyn <= yyFlag {
 		goto yydefault /* simple state */
 	}
@@ -352,8 +350,8 @@ yynewstate:
 	if yyn < 0 || yyn >= yyLast {
 		goto yydefault
 	}
-	yyn = yyAct[yyn]
-	if yyChk[yyn] == yytoken { /* valid shift */
+	yyn = int(yyAct[yyn])
+	if int(yyChk[yyn]) == yytoken { /* valid shift */
 		yyrcvr.char = -1
 		yytoken = -1
 		yyVAL = yyrcvr.lval
@@ -366,7 +364,7 @@ yynewstate:
 
 yydefault:
 	/* default state action */
-	yyn = yyDef[yystate]
+	yyn = int(yyDef[yystate])
 	if yyn == -2 {
 		if yyrcvr.char < 0 {
 			yyrcvr.char, yytoken = yylex1(yylex, &yyrcvr.lval)
@@ -375,18 +373,18 @@ yydefault:
 		/* look through exception table */
 		xi := 0
 		for {
-			if yyExca[xi+0] == -1 && yyExca[xi+1] == yystate {
+			if yyExca[xi+0] == -1 && int(yyExca[xi+1]) == yystate {
 				break
 			}
 			xi += 2
 		}
 		for xi += 2; ; xi += 2 {
-			yyn = yyExca[xi+0]
+			yyn = int(yyExca[xi+0])
 			if yyn < 0 || yyn == yytoken {
 				break
 			}
 		}
-		yyn = yyExca[xi+1]
+		yyn = int(yyExca[xi+1])
 		if yyn < 0 {
 			goto ret0
 		}
@@ -408,10 +406,10 @@ yydefault:
 
 			/* find a state where "error" is a legal shift action */
 			for yyp >= 0 {
-				yyn = yyPact[yyS[yyp].yys] + yyErrCode
+				yyn = int(yyPact[yyS[yyp].yys]) + yyErrCode
 				if yyn >= 0 && yyn < yyLast {
-					yystate = yyAct[yyn] /* simulate a shift of "error" */
-					if yyChk[yystate] == yyErrCode {
+					yystate = int(yyAct[yyn]) /* simulate a shift of "error" */
+					if int(yyChk[yystate]) == yyErrCode {
 						goto yystack
 					}
 				}
@@ -447,7 +445,7 @@ yydefault:
 	yypt := yyp
 	_ = yypt // guard against "declared and not used"
 
-	yyp -= yyR2[yyn]
+	yyp -= int(yyR2[yyn])
 	// yyp is now the index of $0. Perform the default action. Iff the
 	// reduced production is ε, $1 is possibly out of range.
 	if yyp+1 >= len(yyS) {
@@ -458,16 +456,16 @@ yydefault:
 	yyVAL = yyS[yyp+1]
 
 	/* consult goto table to find next state */
-	yyn = yyR1[yyn]
-	yyg := yyPgo[yyn]
+	yyn = int(yyR1[yyn])
+	yyg := int(yyPgo[yyn])
 	yyj := yyg + yyS[yyp].yys + 1
 
 	if yyj >= yyLast {
-		yystate = yyAct[yyg]
+		yystate = int(yyAct[yyg])
 	} else {
-		yystate = yyAct[yyj]
-		if yyChk[yystate] != -yyn {
-			yystate = yyAct[yyg]
+		yystate = int(yyAct[yyj])
+		if int(yyChk[yystate]) != -yyn {
+			yystate = int(yyAct[yyg])
 		}
 	}
 	// dummy call; replaced with literal code
@@ -475,25 +473,25 @@ yydefault:
 
 	case 1:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line query_string.y:40
+//line query_string.y:40
 		{
 			logDebugGrammar("INPUT")
 		}
 	case 2:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line query_string.y:45
+//line query_string.y:45
 		{
 			logDebugGrammar("SEARCH PARTS")
 		}
 	case 3:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line query_string.y:49
+//line query_string.y:49
 		{
 			logDebugGrammar("SEARCH PART")
 		}
 	case 4:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line query_string.y:54
+//line query_string.y:54
 		{
 			query := yyDollar[2].q
 			if yyDollar[3].pf != nil {
@@ -512,27 +510,27 @@ yydefault:
 		}
 	case 5:
 		yyDollar = yyS[yypt-0 : yypt+1]
-		//line query_string.y:73
+//line query_string.y:73
 		{
 			yyVAL.n = queryShould
 		}
 	case 6:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line query_string.y:77
+//line query_string.y:77
 		{
 			logDebugGrammar("PLUS")
 			yyVAL.n = queryMust
 		}
 	case 7:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line query_string.y:82
+//line query_string.y:82
 		{
 			logDebugGrammar("MINUS")
 			yyVAL.n = queryMustNot
 		}
 	case 8:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line query_string.y:88
+//line query_string.y:88
 		{
 			str := yyDollar[1].s
 			logDebugGrammar("STRING - %s", str)
@@ -548,7 +546,7 @@ yydefault:
 		}
 	case 9:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line query_string.y:102
+//line query_string.y:102
 		{
 			str := yyDollar[1].s
 			fuzziness, err := strconv.ParseFloat(yyDollar[2].s, 64)
@@ -562,7 +560,7 @@ yydefault:
 		}
 	case 10:
 		yyDollar = yyS[yypt-4 : yypt+1]
-		//line query_string.y:114
+//line query_string.y:114
 		{
 			field := yyDollar[1].s
 			str := yyDollar[3].s
@@ -578,7 +576,7 @@ yydefault:
 		}
 	case 11:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line query_string.y:128
+//line query_string.y:128
 		{
 			str := yyDollar[1].s
 			logDebugGrammar("STRING - %s", str)
@@ -595,7 +593,7 @@ yydefault:
 		}
 	case 12:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line query_string.y:143
+//line query_string.y:143
 		{
 			phrase := yyDollar[1].s
 			logDebugGrammar("PHRASE - %s", phrase)
@@ -603,8 +601,22 @@ yydefault:
 			yyVAL.q = q
 		}
 	case 13:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line query_string.y:150
+		{
+			phrase := yyDollar[1].s
+			slop, err := strconv.ParseFloat(yyDollar[2].s, 64)
+			if err != nil {
+				yylex.(*lexerWrapper).lex.Error(fmt.Sprintf("invalid slop value: %v", err))
+			}
+			logDebugGrammar("SLOPPY PHRASE - %s %f", phrase, slop)
+			q := NewMatchPhraseQuery(phrase)
+			q.SetSlop(int(slop))
+			yyVAL.q = q
+		}
+	case 14:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line query_string.y:150
+//line query_string.y:162
 		{
 			field := yyDollar[1].s
 			str := yyDollar[3].s
@@ -620,9 +632,9 @@ yydefault:
 			q.SetField(field)
 			yyVAL.q = q
 		}
-	case 14:
+	case 15:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line query_string.y:166
+//line query_string.y:178
 		{
 			field := yyDollar[1].s
 			str := yyDollar[3].s
@@ -640,9 +652,9 @@ yydefault:
 			q.queryStringMode = true
 			yyVAL.q = q
 		}
-	case 15:
+	case 16:
 		yyDollar = yyS[yypt-3 : yypt+1]
-		//line query_string.y:184
+//line query_string.y:196
 		{
 			field := yyDollar[1].s
 			phrase := yyDollar[3].s
@@ -651,9 +663,25 @@ yydefault:
 			q.SetField(field)
 			yyVAL.q = q
 		}
-	case 16:
+	case 17:
 		yyDollar = yyS[yypt-4 : yypt+1]
-		//line query_string.y:193
+//line query_string.y:205
+		{
+			field := yyDollar[1].s
+			phrase := yyDollar[3].s
+			slop, err := strconv.ParseFloat(yyDollar[4].s, 64)
+			if err != nil {
+				yylex.(*lexerWrapper).lex.Error(fmt.Sprintf("invalid slop value: %v", err))
+			}
+			logDebugGrammar("FIELD - %s SLOPPY PHRASE - %s %f", field, phrase, slop)
+			q := NewMatchPhraseQuery(phrase)
+			q.SetSlop(int(slop))
+			q.SetField(field)
+			yyVAL.q = q
+		}
+	case 18:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line query_string.y:219
 		{
 			field := yyDollar[1].s
 			min, err := strconv.ParseFloat(yyDollar[4].s, 64)
@@ -666,9 +694,9 @@ yydefault:
 			q.SetField(field)
 			yyVAL.q = q
 		}
-	case 17:
+	case 19:
 		yyDollar = yyS[yypt-5 : yypt+1]
-		//line query_string.y:206
+//line query_string.y:232
 		{
 			field := yyDollar[1].s
 			min, err := strconv.ParseFloat(yyDollar[5].s, 64)
@@ -681,9 +709,9 @@ yydefault:
 			q.SetField(field)
 			yyVAL.q = q
 		}
-	case 18:
+	case 20:
 		yyDollar = yyS[yypt-4 : yypt+1]
-		//line query_string.y:219
+//line query_string.y:245
 		{
 			field := yyDollar[1].s
 			max, err := strconv.ParseFloat(yyDollar[4].s, 64)
@@ -696,9 +724,9 @@ yydefault:
 			q.SetField(field)
 			yyVAL.q = q
 		}
-	case 19:
+	case 21:
 		yyDollar = yyS[yypt-5 : yypt+1]
-		//line query_string.y:232
+//line query_string.y:258
 		{
 			field := yyDollar[1].s
 			max, err := strconv.ParseFloat(yyDollar[5].s, 64)
@@ -711,9 +739,9 @@ yydefault:
 			q.SetField(field)
 			yyVAL.q = q
 		}
-	case 20:
+	case 22:
 		yyDollar = yyS[yypt-4 : yypt+1]
-		//line query_string.y:245
+//line query_string.y:271
 		{
 			field := yyDollar[1].s
 			minInclusive := false
@@ -728,9 +756,9 @@ yydefault:
 			q.SetField(field)
 			yyVAL.q = q
 		}
-	case 21:
+	case 23:
 		yyDollar = yyS[yypt-5 : yypt+1]
-		//line query_string.y:260
+//line query_string.y:286
 		{
 			field := yyDollar[1].s
 			minInclusive := true
@@ -745,9 +773,9 @@ yydefault:
 			q.SetField(field)
 			yyVAL.q = q
 		}
-	case 22:
+	case 24:
 		yyDollar = yyS[yypt-4 : yypt+1]
-		//line query_string.y:275
+//line query_string.y:301
 		{
 			field := yyDollar[1].s
 			maxInclusive := false
@@ -762,9 +790,9 @@ yydefault:
 			q.SetField(field)
 			yyVAL.q = q
 		}
-	case 23:
+	case 25:
 		yyDollar = yyS[yypt-5 : yypt+1]
-		//line query_string.y:290
+//line query_string.y:316
 		{
 			field := yyDollar[1].s
 			maxInclusive := true
@@ -779,15 +807,15 @@ yydefault:
 			q.SetField(field)
 			yyVAL.q = q
 		}
-	case 24:
+	case 26:
 		yyDollar = yyS[yypt-0 : yypt+1]
-		//line query_string.y:306
+//line query_string.y:332
 		{
 			yyVAL.pf = nil
 		}
-	case 25:
+	case 27:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line query_string.y:310
+//line query_string.y:336
 		{
 			yyVAL.pf = nil
 			boost, err := strconv.ParseFloat(yyDollar[1].s, 64)
@@ -798,15 +826,15 @@ yydefault:
 			}
 			logDebugGrammar("BOOST %f", boost)
 		}
-	case 26:
+	case 28:
 		yyDollar = yyS[yypt-1 : yypt+1]
-		//line query_string.y:322
+//line query_string.y:348
 		{
 			yyVAL.s = yyDollar[1].s
 		}
-	case 27:
+	case 29:
 		yyDollar = yyS[yypt-2 : yypt+1]
-		//line query_string.y:326
+//line query_string.y:352
 		{
 			yyVAL.s = "-" + yyDollar[2].s
 		}
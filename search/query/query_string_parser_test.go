@@ -190,6 +190,35 @@ func TestQuerySyntaxParserValid(t *testing.T) {
 				},
 				nil),
 		},
+		{
+			input:   `"test phrase 1"~2`,
+			mapping: mapping.NewIndexMapping(),
+			result: NewBooleanQueryForQueryString(
+				nil,
+				[]Query{
+					func() Query {
+						q := NewMatchPhraseQuery("test phrase 1")
+						q.SetSlop(2)
+						return q
+					}(),
+				},
+				nil),
+		},
+		{
+			input:   `field3:"test phrase 2"~1`,
+			mapping: mapping.NewIndexMapping(),
+			result: NewBooleanQueryForQueryString(
+				nil,
+				[]Query{
+					func() Query {
+						q := NewMatchPhraseQuery("test phrase 2")
+						q.SetField("field3")
+						q.SetSlop(1)
+						return q
+					}(),
+				},
+				nil),
+		},
 		{
 			input:   `+field4:"test phrase 1"`,
 			mapping: mapping.NewIndexMapping(),
@@ -860,6 +889,30 @@ func TestQuerySyntaxParserInvalid(t *testing.T) {
 	}
 }
 
+func TestQueryStringFields(t *testing.T) {
+	q := NewQueryStringQuery(`+beer^3 "light beer"~1 name:devon`)
+	q.SetFields("name^2", "desc")
+	parsed, err := q.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	beer := NewMultiMatchQuery("beer", "name^2", "desc")
+	beer.SetBoost(3)
+	light := NewMultiMatchQuery("light beer", "name^2", "desc")
+	light.SetType(MultiMatchPhrase)
+	light.SetSlop(1)
+	devon := NewMatchQuery("devon")
+	devon.SetField("name")
+	expected := NewBooleanQueryForQueryString(
+		[]Query{beer},
+		[]Query{light, devon},
+		nil)
+	if !reflect.DeepEqual(parsed, expected) {
+		t.Errorf("expected %#v, got %#v", expected, parsed)
+	}
+}
+
 func BenchmarkLexer(b *testing.B) {
 
 	for n := 0; n < b.N; n++ {
@@ -132,10 +132,85 @@ func TestParseQuery(t *testing.T) {
 			input:  []byte(`{"terms":["watered","down"],"field":"desc"}`),
 			output: NewPhraseQuery([]string{"watered", "down"}, "desc"),
 		},
+		{
+			input: []byte(`{"terms":["watered","down"],"field":"desc","slop":2}`),
+			output: func() Query {
+				q := NewPhraseQuery([]string{"watered", "down"}, "desc")
+				q.SetSlop(2)
+				return q
+			}(),
+		},
+		{
+			input: []byte(`{"match_phrase":"light beer","field":"desc","slop":1}`),
+			output: func() Query {
+				q := NewMatchPhraseQuery("light beer")
+				q.SetField("desc")
+				q.SetSlop(1)
+				return q
+			}(),
+		},
+		{
+			input: []byte(`{"function_score":{"match":"beer","field":"desc"},"functions":[{"filter":{"term":"light","field":"desc"},"weight":2},{"field_value_factor":{"field":"abv","modifier":"log1p"}},{"gauss":{"field":"brewed","origin":"2017-01-01T00:00:00Z","scale":"10d","offset":"1d"}},{"random_score":{"seed":7}}],"score_mode":"sum","boost_mode":"replace","max_boost":3}`),
+			output: func() Query {
+				mq := NewMatchQuery("beer")
+				mq.SetField("desc")
+				light := NewTermQuery("light")
+				light.SetField("desc")
+				wf := NewWeightFunction(2)
+				wf.SetFilter(light)
+				ff := NewFieldValueFactorFunction("abv")
+				ff.FieldValueFactor.SetModifier("log1p")
+				df := NewDecayFunction("gauss", "brewed", "2017-01-01T00:00:00Z", "10d")
+				df.Decay.SetOffset("1d")
+				q := NewFunctionScoreQuery(mq, wf, ff, df, NewRandomScoreFunction(7))
+				q.SetScoreMode("sum")
+				q.SetBoostMode("replace")
+				q.SetMaxBoost(3)
+				return q
+			}(),
+		},
+		{
+			input: []byte(`{"dis_max":[{"match":"beer","field":"name"},{"match":"beer","field":"desc"}],"tie_breaker":0.3}`),
+			output: func() Query {
+				name := NewMatchQuery("beer")
+				name.SetField("name")
+				desc := NewMatchQuery("beer")
+				desc.SetField("desc")
+				q := NewDisMaxQuery([]Query{name, desc})
+				q.SetTieBreaker(0.3)
+				return q
+			}(),
+		},
+		{
+			input: []byte(`{"multi_match":"light beer","fields":["name^2","desc"],"type":"cross_fields","tie_breaker":0.3,"operator":"and"}`),
+			output: func() Query {
+				q := NewMultiMatchQuery("light beer", "name^2", "desc")
+				q.SetType(MultiMatchCrossFields)
+				q.SetTieBreaker(0.3)
+				q.SetOperator(MatchQueryOperatorAnd)
+				return q
+			}(),
+		},
+		{
+			input: []byte(`{"multi_match":"beer","fields":["name","desc"],"fuzziness":1}`),
+			output: func() Query {
+				q := NewMultiMatchQuery("beer", "name", "desc")
+				q.SetFuzziness(1)
+				return q
+			}(),
+		},
 		{
 			input:  []byte(`{"query":"+beer \"light beer\" -devon"}`),
 			output: NewQueryStringQuery(`+beer "light beer" -devon`),
 		},
+		{
+			input: []byte(`{"query":"beer","fields":["name","desc"]}`),
+			output: func() Query {
+				q := NewQueryStringQuery("beer")
+				q.SetFields("name", "desc")
+				return q
+			}(),
+		},
 		{
 			input: []byte(`{"min":5.1,"max":7.1,"field":"desc"}`),
 			output: func() Query {
@@ -172,6 +247,23 @@ func TestParseQuery(t *testing.T) {
 			input:  []byte(`{"match_none":{}}`),
 			output: NewMatchNoneQuery(),
 		},
+		{
+			input:  []byte(`{"match_all":{}}`),
+			output: NewMatchAllQuery(),
+		},
+		{
+			input: []byte(`{"match_all":{"after":"b"},"boost":2}`),
+			output: func() Query {
+				q := NewMatchAllQuery()
+				q.SetAfter("b")
+				q.SetBoost(2)
+				return q
+			}(),
+		},
+		{
+			input:  []byte(`{"exists":{"field":"desc"}}`),
+			output: NewExistsQuery("desc"),
+		},
 		{
 			input:  []byte(`{"ids":["a","b","c"]}`),
 			output: NewDocIDQuery([]string{"a", "b", "c"}),
@@ -180,6 +272,17 @@ func TestParseQuery(t *testing.T) {
 			input:  []byte(`{"bool": true}`),
 			output: NewBoolFieldQuery(true),
 		},
+		{
+			input: []byte(`{"path":"comments","query":{"term":"bob","field":"comments.author"},"score_mode":"max","inner_hits":3}`),
+			output: func() Query {
+				tq := NewTermQuery("bob")
+				tq.SetField("comments.author")
+				q := NewNestedQuery("comments", tq)
+				q.SetScoreMode("max")
+				q.SetInnerHits(3)
+				return q
+			}(),
+		},
 		{
 			input:  []byte(`{"madeitup":"queryhere"}`),
 			output: nil,
@@ -264,6 +367,14 @@ func TestQueryValidate(t *testing.T) {
 			query: NewPhraseQuery([]string{}, "field"),
 			err:   true,
 		},
+		{
+			query: func() Query {
+				q := NewPhraseQuery([]string{"watered", "down"}, "desc")
+				q.SetSlop(-1)
+				return q
+			}(),
+			err: true,
+		},
 		{
 			query: func() Query {
 				q := NewMatchNoneQuery()
@@ -271,6 +382,112 @@ func TestQueryValidate(t *testing.T) {
 				return q
 			}(),
 		},
+		{
+			query: NewExistsQuery(""),
+			err:   true,
+		},
+		{
+			query: NewMultiMatchQuery("light beer", "name^2", "desc"),
+		},
+		{
+			query: NewDisMaxQuery([]Query{NewMatchQuery("beer")}),
+		},
+		{
+			query: NewDisMaxQuery(nil),
+			err:   true,
+		},
+		{
+			query: NewFunctionScoreQuery(NewMatchQuery("beer"),
+				NewFieldValueFactorFunction("abv"),
+				NewDecayFunction("exp", "brewed", "2017-01-01T00:00:00Z", "10d"),
+				NewDecayFunction("linear", "abv", 5, 2),
+				NewDecayFunction("gauss", "brewery", []float64{-0.12, 51.5}, "2km"),
+				NewRandomScoreFunction(7)),
+		},
+		{
+			query: NewFunctionScoreQuery(nil, NewRandomScoreFunction(7)),
+			err:   true,
+		},
+		{
+			query: func() Query {
+				q := NewFunctionScoreQuery(NewMatchQuery("beer"), NewRandomScoreFunction(7))
+				q.SetBoostMode("divide")
+				return q
+			}(),
+			err: true,
+		},
+		{
+			query: NewFunctionScoreQuery(NewMatchQuery("beer"), &ScoreFunction{}),
+			err:   true,
+		},
+		{
+			query: func() Query {
+				f := NewRandomScoreFunction(7)
+				f.FieldValueFactor = &FieldValueFactor{Field: "abv", Factor: 1}
+				return NewFunctionScoreQuery(NewMatchQuery("beer"), f)
+			}(),
+			err: true,
+		},
+		{
+			query: func() Query {
+				f := NewFieldValueFactorFunction("abv")
+				f.FieldValueFactor.SetModifier("cube")
+				return NewFunctionScoreQuery(NewMatchQuery("beer"), f)
+			}(),
+			err: true,
+		},
+		{
+			query: NewFunctionScoreQuery(NewMatchQuery("beer"),
+				NewDecayFunction("gauss", "brewed", "2017-01-01T00:00:00Z", "ten days")),
+			err: true,
+		},
+		{
+			query: NewFunctionScoreQuery(NewMatchQuery("beer"),
+				NewDecayFunction("gauss", "abv", 5, -2)),
+			err: true,
+		},
+		{
+			query: NewFunctionScoreQuery(NewMatchQuery("beer"),
+				NewDecayFunction("cosine", "abv", 5, 2)),
+			err: true,
+		},
+		{
+			query: func() Query {
+				q := NewDisMaxQuery([]Query{NewMatchQuery("beer")})
+				q.SetTieBreaker(-0.5)
+				return q
+			}(),
+			err: true,
+		},
+		{
+			query: NewMultiMatchQuery("light beer", "name^x"),
+			err:   true,
+		},
+		{
+			query: func() Query {
+				q := NewMultiMatchQuery("light beer", "name", "desc")
+				q.SetType("some_fields")
+				return q
+			}(),
+			err: true,
+		},
+		{
+			query: func() Query {
+				q := NewMultiMatchQuery("light beer", "name", "desc")
+				q.SetType(MultiMatchCrossFields)
+				q.SetFuzziness(1)
+				return q
+			}(),
+			err: true,
+		},
+		{
+			query: func() Query {
+				q := NewMultiMatchQuery("light beer", "name", "desc")
+				q.SetTieBreaker(1.5)
+				return q
+			}(),
+			err: true,
+		},
 		{
 			query: NewBooleanQuery(
 				[]Query{func() Query {
@@ -0,0 +1,140 @@
+package scorer
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/wrble/flock/geo"
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/numeric"
+	"github.com/wrble/flock/search"
+)
+
+// The types of a DecayFunction, how its value falls with the distance
+// of the value of the field from the origin
+const (
+	DecayGauss  = "gauss"
+	DecayExp    = "exp"
+	DecayLinear = "linear"
+)
+
+// DecayFunction scores a document by the distance of the value of one
+// of its fields from an origin.  The value is 1 up to offset from the
+// origin and decay at scale further, then keeps falling along a
+// normal, exponential or linear curve.  The closest value of a field
+// holding several is used, documents without a value score 1.
+type DecayFunction struct {
+	decayType string
+	field     string
+	origin    string
+	scale     float64
+	offset    float64
+	decay     float64
+	// distance returns the distance of a value of the field from the
+	// origin, in the units of scale and offset
+	distance func(i64 int64) float64
+}
+
+// NewNumericDecayFunction returns a decay function of the distance of
+// the value of a numeric field from the origin
+func NewNumericDecayFunction(decayType, field string, origin, scale, offset, decay float64) (*DecayFunction, error) {
+	return newDecayFunction(decayType, field, fmt.Sprintf("%g", origin), scale, offset, decay, func(i64 int64) float64 {
+		return math.Abs(numeric.Int64ToFloat64(i64) - origin)
+	})
+}
+
+// NewDateDecayFunction returns a decay function of the time between
+// the value of a date field and the origin
+func NewDateDecayFunction(decayType, field string, origin time.Time, scale, offset time.Duration, decay float64) (*DecayFunction, error) {
+	originNanos := origin.UnixNano()
+	return newDecayFunction(decayType, field, origin.Format(time.RFC3339), float64(scale), float64(offset), decay, func(i64 int64) float64 {
+		return math.Abs(float64(i64 - originNanos))
+	})
+}
+
+// NewGeoDecayFunction returns a decay function of the distance in
+// meters between the point of a geo point field and the origin, scale
+// and offset are in meters
+func NewGeoDecayFunction(decayType, field string, lon, lat, scale, offset, decay float64) (*DecayFunction, error) {
+	return newDecayFunction(decayType, field, fmt.Sprintf("[%g, %g]", lon, lat), scale, offset, decay, func(i64 int64) float64 {
+		docLon := geo.MortonUnhashLon(uint64(i64))
+		docLat := geo.MortonUnhashLat(uint64(i64))
+		// Haversin returns km
+		return geo.Haversin(lon, lat, docLon, docLat) * 1000
+	})
+}
+
+func newDecayFunction(decayType, field, origin string, scale, offset, decay float64, distance func(i64 int64) float64) (*DecayFunction, error) {
+	switch decayType {
+	case DecayGauss, DecayExp, DecayLinear:
+	default:
+		return nil, fmt.Errorf("unknown decay function type: '%s'", decayType)
+	}
+	if scale <= 0 {
+		return nil, fmt.Errorf("decay function scale must be positive")
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("decay function offset cannot be negative")
+	}
+	if decay <= 0 || decay >= 1 {
+		return nil, fmt.Errorf("decay function decay must be between 0 and 1")
+	}
+	return &DecayFunction{
+		decayType: decayType,
+		field:     field,
+		origin:    origin,
+		scale:     scale,
+		offset:    offset,
+		decay:     decay,
+		distance:  distance,
+	}, nil
+}
+
+func (f *DecayFunction) Score(indexReader index.IndexReader, d *search.DocumentMatch, explain bool) (float64, *search.Explanation, error) {
+	values, err := numericFieldValues(indexReader, d.IndexInternalID, f.field)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(values) == 0 {
+		var expl *search.Explanation
+		if explain {
+			expl = &search.Explanation{
+				Value:   1,
+				Message: fmt.Sprintf("%s decay of field %s, no value", f.decayType, f.field),
+			}
+		}
+		return 1, expl, nil
+	}
+
+	dist := f.distance(values[0])
+	for _, v := range values[1:] {
+		dist = math.Min(dist, f.distance(v))
+	}
+	rv := f.value(math.Max(0, dist-f.offset))
+
+	var expl *search.Explanation
+	if explain {
+		expl = &search.Explanation{
+			Value: rv,
+			Message: fmt.Sprintf("%s decay of field %s, distance %g from origin %s, offset %g, scale %g, decay %g",
+				f.decayType, f.field, dist, f.origin, f.offset, f.scale, f.decay),
+		}
+	}
+	return rv, expl, nil
+}
+
+// value returns the value of the function at dist past the offset
+func (f *DecayFunction) value(dist float64) float64 {
+	switch f.decayType {
+	case DecayExp:
+		lambda := math.Log(f.decay) / f.scale
+		return math.Exp(lambda * dist)
+	case DecayLinear:
+		s := f.scale / (1 - f.decay)
+		return math.Max(0, (s-dist)/s)
+	}
+	// the variance making the value decay at scale
+	variance := -f.scale * f.scale / (2 * math.Log(f.decay))
+	return math.Exp(-dist * dist / (2 * variance))
+}
@@ -0,0 +1,113 @@
+package scorer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/numeric"
+	"github.com/wrble/flock/search"
+)
+
+// The modifiers a FieldValueFactorFunction applies to the value of the
+// field times the factor
+const (
+	FieldValueModifierNone       = "none"
+	FieldValueModifierLog        = "log"
+	FieldValueModifierLog1p      = "log1p"
+	FieldValueModifierLog2p      = "log2p"
+	FieldValueModifierLn         = "ln"
+	FieldValueModifierLn1p       = "ln1p"
+	FieldValueModifierLn2p       = "ln2p"
+	FieldValueModifierSquare     = "square"
+	FieldValueModifierSqrt       = "sqrt"
+	FieldValueModifierReciprocal = "reciprocal"
+)
+
+// FieldValueFactorFunction scores a document by the numeric value of
+// one of its fields times a factor, through a modifier.  The lowest
+// value of a field holding several is used.
+type FieldValueFactorFunction struct {
+	field    string
+	factor   float64
+	modifier string
+	missing  *float64
+}
+
+// NewFieldValueFactorFunction returns a function of the value of the
+// numeric field, or of missing for the documents without a value.
+// Without a missing value a document without a value is an error.
+func NewFieldValueFactorFunction(field string, factor float64, modifier string, missing *float64) (*FieldValueFactorFunction, error) {
+	switch modifier {
+	case "":
+		modifier = FieldValueModifierNone
+	case FieldValueModifierNone, FieldValueModifierLog, FieldValueModifierLog1p,
+		FieldValueModifierLog2p, FieldValueModifierLn, FieldValueModifierLn1p,
+		FieldValueModifierLn2p, FieldValueModifierSquare, FieldValueModifierSqrt,
+		FieldValueModifierReciprocal:
+	default:
+		return nil, fmt.Errorf("unknown field value factor modifier: '%s'", modifier)
+	}
+	return &FieldValueFactorFunction{
+		field:    field,
+		factor:   factor,
+		modifier: modifier,
+		missing:  missing,
+	}, nil
+}
+
+func (f *FieldValueFactorFunction) Score(indexReader index.IndexReader, d *search.DocumentMatch, explain bool) (float64, *search.Explanation, error) {
+	values, err := numericFieldValues(indexReader, d.IndexInternalID, f.field)
+	if err != nil {
+		return 0, nil, err
+	}
+	var value float64
+	if len(values) > 0 {
+		value = numeric.Int64ToFloat64(values[0])
+		for _, v := range values[1:] {
+			value = math.Min(value, numeric.Int64ToFloat64(v))
+		}
+	} else if f.missing != nil {
+		value = *f.missing
+	} else {
+		return 0, nil, fmt.Errorf("document has no value for field value factor field '%s'", f.field)
+	}
+
+	rv := f.modify(value * f.factor)
+	if math.IsNaN(rv) || math.IsInf(rv, 0) {
+		return 0, nil, fmt.Errorf("field value factor %s(%f * %f) of field '%s' is not a number", f.modifier, value, f.factor, f.field)
+	}
+
+	var expl *search.Explanation
+	if explain {
+		expl = &search.Explanation{
+			Value:   rv,
+			Message: fmt.Sprintf("field value factor %s(%f * factor %f) of field %s", f.modifier, value, f.factor, f.field),
+		}
+	}
+	return rv, expl, nil
+}
+
+func (f *FieldValueFactorFunction) modify(v float64) float64 {
+	switch f.modifier {
+	case FieldValueModifierLog:
+		return math.Log10(v)
+	case FieldValueModifierLog1p:
+		return math.Log10(v + 1)
+	case FieldValueModifierLog2p:
+		return math.Log10(v + 2)
+	case FieldValueModifierLn:
+		return math.Log(v)
+	case FieldValueModifierLn1p:
+		return math.Log1p(v)
+	case FieldValueModifierLn2p:
+		return math.Log(v + 2)
+	case FieldValueModifierSquare:
+		return v * v
+	case FieldValueModifierSqrt:
+		return math.Sqrt(v)
+	case FieldValueModifierReciprocal:
+		return 1 / v
+	}
+	return v
+}
@@ -0,0 +1,65 @@
+package scorer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/search"
+)
+
+// RandomScoreFunction scores a document with a random value from 0 to
+// 1, the same for the same seed and document.  The value is drawn from
+// the id of the document, or from the lowest term of a field when it
+// has one.
+type RandomScoreFunction struct {
+	seed  int64
+	field string
+}
+
+func NewRandomScoreFunction(seed int64, field string) *RandomScoreFunction {
+	return &RandomScoreFunction{
+		seed:  seed,
+		field: field,
+	}
+}
+
+func (f *RandomScoreFunction) Score(indexReader index.IndexReader, d *search.DocumentMatch, explain bool) (float64, *search.Explanation, error) {
+	var key []byte
+	if f.field != "" {
+		err := indexReader.DocumentVisitFieldTerms(d.IndexInternalID, []string{f.field}, func(field string, term []byte) {
+			if key == nil || string(term) < string(key) {
+				key = append(key[:0], term...)
+			}
+		})
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+	if key == nil {
+		id, err := indexReader.ExternalID(d.IndexInternalID)
+		if err != nil {
+			return 0, nil, err
+		}
+		key = []byte(id)
+	}
+
+	h := fnv.New64a()
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], uint64(f.seed))
+	_, _ = h.Write(seed[:])
+	_, _ = h.Write(key)
+	// the top 53 bits, as many as a float64 holds exactly
+	rv := float64(h.Sum64()>>11) / (1 << 53)
+
+	var expl *search.Explanation
+	if explain {
+		message := fmt.Sprintf("random score (seed %d)", f.seed)
+		if f.field != "" {
+			message = fmt.Sprintf("random score (seed %d, field %s)", f.seed, f.field)
+		}
+		expl = &search.Explanation{Value: rv, Message: message}
+	}
+	return rv, expl, nil
+}
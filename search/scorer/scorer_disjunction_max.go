@@ -0,0 +1,95 @@
+package scorer
+
+import (
+	"fmt"
+
+	"github.com/wrble/flock/search"
+)
+
+// DisjunctionMaxQueryScorer scores a match with the highest score of
+// the clauses matching, plus the tie breaker times the sum of the
+// scores of the others.  A tie breaker of 0 keeps the best clause only,
+// 1 sums them all.
+type DisjunctionMaxQueryScorer struct {
+	tieBreaker float64
+	options    search.SearcherOptions
+}
+
+func NewDisjunctionMaxQueryScorer(tieBreaker float64, options search.SearcherOptions) *DisjunctionMaxQueryScorer {
+	return &DisjunctionMaxQueryScorer{
+		tieBreaker: tieBreaker,
+		options:    options,
+	}
+}
+
+// Weight combines the weights of the clauses like the scores
+func (s *DisjunctionMaxQueryScorer) Weight(weights []float64) float64 {
+	var sum, max float64
+	for _, weight := range weights {
+		sum += weight
+		if weight > max {
+			max = weight
+		}
+	}
+	return max + s.tieBreaker*(sum-max)
+}
+
+func (s *DisjunctionMaxQueryScorer) Score(ctx *search.SearchContext, constituents []*search.DocumentMatch) *search.DocumentMatch {
+	var sum, max float64
+	best := 0
+	var locations []search.FieldTermLocationMap
+	for i, docMatch := range constituents {
+		sum += docMatch.Score
+		if i == 0 || docMatch.Score > max {
+			max = docMatch.Score
+			best = i
+		}
+		if docMatch.Locations != nil {
+			locations = append(locations, docMatch.Locations)
+		}
+	}
+	others := sum - max
+	newScore := max + s.tieBreaker*others
+
+	var newExpl *search.Explanation
+	if s.options.Explain {
+		// the clause which won, then the others
+		newExpl = &search.Explanation{
+			Value:    max,
+			Message:  fmt.Sprintf("best of %d clauses matching:", len(constituents)),
+			Children: []*search.Explanation{constituents[best].Expl},
+		}
+		if len(constituents) > 1 && s.tieBreaker != 0 {
+			otherExpls := make([]*search.Explanation, 0, len(constituents)-1)
+			for i, docMatch := range constituents {
+				if i != best {
+					otherExpls = append(otherExpls, docMatch.Expl)
+				}
+			}
+			newExpl = &search.Explanation{
+				Value:   newScore,
+				Message: "sum of:",
+				Children: []*search.Explanation{
+					newExpl,
+					{
+						Value:    s.tieBreaker * others,
+						Message:  fmt.Sprintf("tie breaker %f times sum of the others:", s.tieBreaker),
+						Children: otherExpls,
+					},
+				},
+			}
+		}
+	}
+
+	// reuse constituents[0] as the return value
+	rv := constituents[0]
+	rv.Score = newScore
+	rv.Expl = newExpl
+	if len(locations) == 1 {
+		rv.Locations = locations[0]
+	} else if len(locations) > 1 {
+		rv.Locations = search.MergeLocations(locations)
+	}
+
+	return rv
+}
@@ -0,0 +1,103 @@
+package scorer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/search"
+)
+
+func TestDisjunctionMaxQueryScorer(t *testing.T) {
+	title := &search.Explanation{Value: 1.0, Message: "title"}
+	body := &search.Explanation{Value: 3.0, Message: "body"}
+	tags := &search.Explanation{Value: 2.0, Message: "tags"}
+
+	tests := []struct {
+		tieBreaker   float64
+		constituents []*search.DocumentMatch
+		result       *search.DocumentMatch
+	}{
+		// the best clause only
+		{
+			tieBreaker: 0,
+			constituents: []*search.DocumentMatch{
+				{IndexInternalID: index.IndexInternalID("one"), Score: 1.0, Expl: title},
+				{IndexInternalID: index.IndexInternalID("one"), Score: 3.0, Expl: body},
+			},
+			result: &search.DocumentMatch{
+				IndexInternalID: index.IndexInternalID("one"),
+				Score:           3.0,
+				Expl: &search.Explanation{
+					Value:    3.0,
+					Message:  "best of 2 clauses matching:",
+					Children: []*search.Explanation{body},
+				},
+			},
+		},
+		// the best clause plus half the others
+		{
+			tieBreaker: 0.5,
+			constituents: []*search.DocumentMatch{
+				{IndexInternalID: index.IndexInternalID("one"), Score: 1.0, Expl: title},
+				{IndexInternalID: index.IndexInternalID("one"), Score: 3.0, Expl: body},
+				{IndexInternalID: index.IndexInternalID("one"), Score: 2.0, Expl: tags},
+			},
+			result: &search.DocumentMatch{
+				IndexInternalID: index.IndexInternalID("one"),
+				Score:           4.5,
+				Expl: &search.Explanation{
+					Value:   4.5,
+					Message: "sum of:",
+					Children: []*search.Explanation{
+						{
+							Value:    3.0,
+							Message:  "best of 3 clauses matching:",
+							Children: []*search.Explanation{body},
+						},
+						{
+							Value:    1.5,
+							Message:  "tie breaker 0.500000 times sum of the others:",
+							Children: []*search.Explanation{title, tags},
+						},
+					},
+				},
+			},
+		},
+		{
+			tieBreaker: 0.5,
+			constituents: []*search.DocumentMatch{
+				{IndexInternalID: index.IndexInternalID("one"), Score: 2.0, Expl: tags},
+			},
+			result: &search.DocumentMatch{
+				IndexInternalID: index.IndexInternalID("one"),
+				Score:           2.0,
+				Expl: &search.Explanation{
+					Value:    2.0,
+					Message:  "best of 1 clauses matching:",
+					Children: []*search.Explanation{tags},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		scorer := NewDisjunctionMaxQueryScorer(test.tieBreaker, search.SearcherOptions{Explain: true})
+		ctx := &search.SearchContext{
+			DocumentMatchPool: search.NewDocumentMatchPool(1, 0),
+		}
+		actual := scorer.Score(ctx, test.constituents)
+
+		if !reflect.DeepEqual(actual, test.result) {
+			t.Errorf("expected %#v got %#v with tie breaker %f", test.result, actual, test.tieBreaker)
+		}
+	}
+}
+
+func TestDisjunctionMaxQueryScorerWeight(t *testing.T) {
+	scorer := NewDisjunctionMaxQueryScorer(0.5, search.SearcherOptions{})
+	weight := scorer.Weight([]float64{1.0, 3.0, 2.0})
+	if weight != 4.5 {
+		t.Errorf("expected weight 4.5, got %f", weight)
+	}
+}
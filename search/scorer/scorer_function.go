@@ -0,0 +1,257 @@
+package scorer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/numeric"
+	"github.com/wrble/flock/search"
+)
+
+// The score modes of a FunctionQueryScorer, how the values of the
+// functions applying to a match are combined
+const (
+	FunctionScoreModeMultiply = "multiply"
+	FunctionScoreModeSum      = "sum"
+	// FunctionScoreModeAvg averages the values weighted by the weights
+	// of the functions
+	FunctionScoreModeAvg = "avg"
+	// FunctionScoreModeFirst keeps the value of the first function
+	// applying to the match
+	FunctionScoreModeFirst = "first"
+	FunctionScoreModeMax   = "max"
+	FunctionScoreModeMin   = "min"
+)
+
+// The boost modes of a FunctionQueryScorer, how the combined value of
+// the functions is combined with the score of the match
+const (
+	FunctionBoostModeMultiply = "multiply"
+	// FunctionBoostModeReplace scores the match with the value of the
+	// functions only
+	FunctionBoostModeReplace = "replace"
+	FunctionBoostModeSum     = "sum"
+	FunctionBoostModeAvg     = "avg"
+	FunctionBoostModeMax     = "max"
+	FunctionBoostModeMin     = "min"
+)
+
+// A ScoreFunction computes a value of a document matched, which a
+// FunctionQueryScorer combines with its score
+type ScoreFunction interface {
+	// Score returns the value of the function for the match, and its
+	// explanation when explain is set
+	Score(indexReader index.IndexReader, d *search.DocumentMatch, explain bool) (float64, *search.Explanation, error)
+}
+
+// FunctionQueryScorer scores a match by combining its score with the
+// values of the functions applying to it, each multiplied by its
+// weight.  The value of the functions is 1 when none of them applies.
+type FunctionQueryScorer struct {
+	functions []ScoreFunction
+	weights   []float64
+	scoreMode string
+	boostMode string
+	maxBoost  float64
+	boost     float64
+	options   search.SearcherOptions
+}
+
+// NewFunctionQueryScorer returns a scorer combining the values of the
+// functions by the score mode, then with the score of the match by the
+// boost mode.  The combined value of the functions is capped at
+// maxBoost, +Inf for no cap, and the final score multiplied by boost.
+func NewFunctionQueryScorer(functions []ScoreFunction, weights []float64, scoreMode, boostMode string, maxBoost, boost float64, options search.SearcherOptions) (*FunctionQueryScorer, error) {
+	switch scoreMode {
+	case "":
+		scoreMode = FunctionScoreModeMultiply
+	case FunctionScoreModeMultiply, FunctionScoreModeSum, FunctionScoreModeAvg,
+		FunctionScoreModeFirst, FunctionScoreModeMax, FunctionScoreModeMin:
+	default:
+		return nil, fmt.Errorf("unknown function score mode: '%s'", scoreMode)
+	}
+	switch boostMode {
+	case "":
+		boostMode = FunctionBoostModeMultiply
+	case FunctionBoostModeMultiply, FunctionBoostModeReplace, FunctionBoostModeSum,
+		FunctionBoostModeAvg, FunctionBoostModeMax, FunctionBoostModeMin:
+	default:
+		return nil, fmt.Errorf("unknown function boost mode: '%s'", boostMode)
+	}
+	if len(weights) != len(functions) {
+		return nil, fmt.Errorf("expected %d function weights, got %d", len(functions), len(weights))
+	}
+	return &FunctionQueryScorer{
+		functions: functions,
+		weights:   weights,
+		scoreMode: scoreMode,
+		boostMode: boostMode,
+		maxBoost:  maxBoost,
+		boost:     boost,
+		options:   options,
+	}, nil
+}
+
+// Score replaces the score of the match, the functions whose applies
+// entry is false are left out
+func (s *FunctionQueryScorer) Score(indexReader index.IndexReader, d *search.DocumentMatch, applies []bool) error {
+	var value, weights float64
+	var count int
+	var expls []*search.Explanation
+	for i, function := range s.functions {
+		if !applies[i] || (s.scoreMode == FunctionScoreModeFirst && count > 0) {
+			continue
+		}
+		v, expl, err := function.Score(indexReader, d, s.options.Explain)
+		if err != nil {
+			return err
+		}
+		weight := s.weights[i]
+		if weight != 1 {
+			v *= weight
+			if s.options.Explain {
+				expl = &search.Explanation{
+					Value:   v,
+					Message: "product of:",
+					Children: []*search.Explanation{
+						expl,
+						{Value: weight, Message: "weight"},
+					},
+				}
+			}
+		}
+		count++
+		weights += weight
+		if s.options.Explain {
+			expls = append(expls, expl)
+		}
+
+		switch {
+		case count == 1:
+			value = v
+		case s.scoreMode == FunctionScoreModeMultiply:
+			value *= v
+		case s.scoreMode == FunctionScoreModeSum, s.scoreMode == FunctionScoreModeAvg:
+			value += v
+		case s.scoreMode == FunctionScoreModeMax:
+			value = math.Max(value, v)
+		case s.scoreMode == FunctionScoreModeMin:
+			value = math.Min(value, v)
+		}
+	}
+	if count == 0 {
+		value = 1
+	} else if s.scoreMode == FunctionScoreModeAvg {
+		value /= weights
+	}
+
+	var functionsExpl *search.Explanation
+	if s.options.Explain {
+		if count == 0 {
+			functionsExpl = &search.Explanation{
+				Value:   value,
+				Message: "no function applies",
+			}
+		} else {
+			functionsExpl = &search.Explanation{
+				Value:    value,
+				Message:  fmt.Sprintf("functions, score mode [%s], of:", s.scoreMode),
+				Children: expls,
+			}
+		}
+	}
+	if value > s.maxBoost {
+		value = s.maxBoost
+		if s.options.Explain {
+			functionsExpl = &search.Explanation{
+				Value:   value,
+				Message: "min of:",
+				Children: []*search.Explanation{
+					functionsExpl,
+					{Value: s.maxBoost, Message: "max boost"},
+				},
+			}
+		}
+	}
+
+	var score float64
+	switch s.boostMode {
+	case FunctionBoostModeMultiply:
+		score = d.Score * value
+	case FunctionBoostModeReplace:
+		score = value
+	case FunctionBoostModeSum:
+		score = d.Score + value
+	case FunctionBoostModeAvg:
+		score = (d.Score + value) / 2
+	case FunctionBoostModeMax:
+		score = math.Max(d.Score, value)
+	case FunctionBoostModeMin:
+		score = math.Min(d.Score, value)
+	}
+
+	var newExpl *search.Explanation
+	if s.options.Explain {
+		children := []*search.Explanation{functionsExpl}
+		if s.boostMode != FunctionBoostModeReplace {
+			children = []*search.Explanation{d.Expl, functionsExpl}
+		}
+		newExpl = &search.Explanation{
+			Value:    score,
+			Message:  fmt.Sprintf("function score, boost mode [%s], of:", s.boostMode),
+			Children: children,
+		}
+	}
+	if s.boost != 1 {
+		score *= s.boost
+		if s.options.Explain {
+			newExpl = &search.Explanation{
+				Value:   score,
+				Message: "product of:",
+				Children: []*search.Explanation{
+					{Value: s.boost, Message: "boost"},
+					newExpl,
+				},
+			}
+		}
+	}
+
+	d.Score = score
+	d.Expl = newExpl
+	return nil
+}
+
+// WeightFunction is the function of value 1, to score the documents a
+// filter matches with the weight of the function only
+type WeightFunction struct{}
+
+func NewWeightFunction() *WeightFunction {
+	return &WeightFunction{}
+}
+
+func (f *WeightFunction) Score(indexReader index.IndexReader, d *search.DocumentMatch, explain bool) (float64, *search.Explanation, error) {
+	var expl *search.Explanation
+	if explain {
+		expl = &search.Explanation{Value: 1, Message: "constant score 1"}
+	}
+	return 1, expl, nil
+}
+
+// numericFieldValues returns the numeric values the document has in the
+// field, decoded from their full precision terms
+func numericFieldValues(indexReader index.IndexReader, id index.IndexInternalID, field string) ([]int64, error) {
+	var rv []int64
+	err := indexReader.DocumentVisitFieldTerms(id, []string{field}, func(field string, term []byte) {
+		prefixCoded := numeric.PrefixCoded(term)
+		shift, err := prefixCoded.Shift()
+		if err != nil || shift != 0 {
+			return
+		}
+		i64, err := prefixCoded.Int64()
+		if err == nil {
+			rv = append(rv, i64)
+		}
+	})
+	return rv, err
+}
@@ -0,0 +1,153 @@
+package scorer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/search"
+)
+
+// constantFunction is a score function of a constant value
+type constantFunction float64
+
+func (f constantFunction) Score(indexReader index.IndexReader, d *search.DocumentMatch, explain bool) (float64, *search.Explanation, error) {
+	return float64(f), &search.Explanation{Value: float64(f), Message: "constant"}, nil
+}
+
+func TestFunctionQueryScorer(t *testing.T) {
+	functions := []ScoreFunction{constantFunction(2), constantFunction(3), constantFunction(5)}
+
+	tests := []struct {
+		scoreMode string
+		boostMode string
+		weights   []float64
+		applies   []bool
+		maxBoost  float64
+		boost     float64
+		score     float64
+	}{
+		{
+			score: 4 * 30,
+		},
+		{
+			scoreMode: FunctionScoreModeSum,
+			score:     4 * 10,
+		},
+		{
+			scoreMode: FunctionScoreModeAvg,
+			weights:   []float64{1, 1, 2},
+			// (2 + 3 + 2 * 5) / (1 + 1 + 2)
+			score: 4 * 3.75,
+		},
+		{
+			scoreMode: FunctionScoreModeFirst,
+			applies:   []bool{false, true, true},
+			score:     4 * 3,
+		},
+		{
+			scoreMode: FunctionScoreModeMax,
+			score:     4 * 5,
+		},
+		{
+			scoreMode: FunctionScoreModeMin,
+			weights:   []float64{3, 1, 1},
+			score:     4 * 3,
+		},
+		// none of the functions applies
+		{
+			applies: []bool{false, false, false},
+			score:   4,
+		},
+		{
+			boostMode: FunctionBoostModeReplace,
+			score:     30,
+		},
+		{
+			boostMode: FunctionBoostModeSum,
+			score:     34,
+		},
+		{
+			boostMode: FunctionBoostModeAvg,
+			score:     17,
+		},
+		{
+			boostMode: FunctionBoostModeMax,
+			score:     30,
+		},
+		{
+			boostMode: FunctionBoostModeMin,
+			score:     4,
+		},
+		{
+			maxBoost: 10,
+			boost:    2,
+			score:    2 * 4 * 10,
+		},
+	}
+
+	for i, test := range tests {
+		weights := test.weights
+		if weights == nil {
+			weights = []float64{1, 1, 1}
+		}
+		applies := test.applies
+		if applies == nil {
+			applies = []bool{true, true, true}
+		}
+		maxBoost := test.maxBoost
+		if maxBoost == 0 {
+			maxBoost = math.Inf(1)
+		}
+		boost := test.boost
+		if boost == 0 {
+			boost = 1
+		}
+		scorer, err := NewFunctionQueryScorer(functions, weights, test.scoreMode, test.boostMode, maxBoost, boost, search.SearcherOptions{Explain: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &search.DocumentMatch{
+			Score: 4,
+			Expl:  &search.Explanation{Value: 4, Message: "query"},
+		}
+		err = scorer.Score(nil, d, applies)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Score != test.score {
+			t.Errorf("test %d: expected score %f, got %f", i, test.score, d.Score)
+		}
+		if d.Expl == nil || d.Expl.Value != d.Score {
+			t.Errorf("test %d: expected the explanation to add up to the score %f, got %v", i, d.Score, d.Expl)
+		}
+	}
+
+	_, err := NewFunctionQueryScorer(functions, []float64{1, 1, 1}, "median", "", math.Inf(1), 1, search.SearcherOptions{})
+	if err == nil {
+		t.Errorf("expected an error for an unknown score mode")
+	}
+}
+
+func TestDecayFunctionValue(t *testing.T) {
+	for _, decayType := range []string{DecayGauss, DecayExp, DecayLinear} {
+		f, err := NewNumericDecayFunction(decayType, "price", 10, 5, 0, 0.25)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := f.value(0); v != 1 {
+			t.Errorf("expected %s decay 1 at the origin, got %f", decayType, v)
+		}
+		if v := f.value(5); math.Abs(v-0.25) > 1e-9 {
+			t.Errorf("expected %s decay 0.25 at scale, got %f", decayType, v)
+		}
+		if v := f.value(10); v >= 0.25 {
+			t.Errorf("expected %s decay below 0.25 past scale, got %f", decayType, v)
+		}
+	}
+
+	_, err := NewNumericDecayFunction(DecayGauss, "price", 10, 5, 0, 1)
+	if err == nil {
+		t.Errorf("expected an error for a decay of 1")
+	}
+}
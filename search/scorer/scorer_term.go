@@ -16,7 +16,6 @@ package scorer
 
 import (
 	"fmt"
-	"math"
 
 	"github.com/wrble/flock/index"
 	"github.com/wrble/flock/search"
@@ -34,18 +33,30 @@ type TermQueryScorer struct {
 	queryNorm              float64
 	queryWeight            float64
 	queryWeightExplanation *search.Explanation
+	similarity             Similarity
+	stats                  TermStats
+	maxFieldBoost          float64
 }
 
+// NewTermQueryScorer returns a scorer using the similarity the options
+// select for the query field
 func NewTermQueryScorer(queryTerm []byte, queryField string, queryBoost float64, docTotal, docTerm uint64, options search.SearcherOptions) *TermQueryScorer {
+	similarity := SimilarityForField(options, queryField)
 	rv := TermQueryScorer{
-		queryTerm:   queryTerm,
-		queryField:  queryField,
-		queryBoost:  queryBoost,
-		docTerm:     docTerm,
-		docTotal:    docTotal,
-		idf:         1.0 + math.Log(float64(docTotal)/float64(docTerm+1.0)),
-		options:     options,
-		queryWeight: 1.0,
+		queryTerm:     queryTerm,
+		queryField:    queryField,
+		queryBoost:    queryBoost,
+		docTerm:       docTerm,
+		docTotal:      docTotal,
+		idf:           similarity.Idf(docTotal, docTerm),
+		options:       options,
+		queryWeight:   1.0,
+		similarity:    similarity,
+		maxFieldBoost: 1.0,
+		stats: TermStats{
+			DocTotal: docTotal,
+			DocTerm:  docTerm,
+		},
 	}
 
 	if options.Explain {
@@ -88,47 +99,66 @@ func (s *TermQueryScorer) SetQueryNorm(qnorm float64) {
 	}
 }
 
-// BM 25 ranking function
-func (s *TermQueryScorer) Score(ctx *search.SearchContext, termMatch *index.TermFieldDoc) *search.DocumentMatch {
-	var scoreExplanation *search.Explanation
+// SetDocumentFrequency replaces the number of documents having the
+// term the idf is computed from, terms searched across several fields
+// blend their frequencies so they weigh the same in each
+func (s *TermQueryScorer) SetDocumentFrequency(docTerm uint64) {
+	s.docTerm = docTerm
+	s.stats.DocTerm = docTerm
+	s.idf = s.similarity.Idf(s.docTotal, docTerm)
+	if s.options.Explain {
+		s.idfExplanation = &search.Explanation{
+			Value:   s.idf,
+			Message: fmt.Sprintf("idf(docFreq=%d, maxDocs=%d)", docTerm, s.docTotal),
+		}
+	}
+}
+
+// SetAverageFieldLength sets the average length of the query field
+// over the documents, 0 when it is unknown
+func (s *TermQueryScorer) SetAverageFieldLength(adl float64) {
+	s.stats.AvgFieldLength = adl
+}
 
-	// Constants
-	k := 1.2 // or 2.0
-	b := .75
+// SetMaxFieldBoost sets the highest index time boost of the query
+// field, at least 1
+func (s *TermQueryScorer) SetMaxFieldBoost(boost float64) {
+	if boost < 1.0 {
+		boost = 1.0
+	}
+	s.maxFieldBoost = boost
+}
+
+// UpperBound returns the highest score a document with the term
+// frequency freq can get, whatever the length and the boost of its
+// field
+func (s *TermQueryScorer) UpperBound(freq float32) float64 {
+	return s.similarity.MaxScore(&s.stats, s.idf, freq) * s.maxFieldBoost * s.queryWeight
+}
 
-	// calculated
-	idf := s.idf
-	tf := ((k + 1.0) * float64(termMatch.Freq)) / (k + float64(termMatch.Freq))
+// indexBoost returns the index time boost of the match, 1 when it has
+// none
+func indexBoost(termMatch *index.TermFieldDoc) float64 {
+	if termMatch.Boost > 0 {
+		return float64(termMatch.Boost)
+	}
+	return 1.0
+}
 
-	dl := 1024.0 // TODO: this doc length
-	adl := 512.0 // TODO: avg doc length
+func (s *TermQueryScorer) Score(ctx *search.SearchContext, termMatch *index.TermFieldDoc) *search.DocumentMatch {
+	var scoreExplanation *search.Explanation
 
-	// From https://en.wikipedia.org/wiki/Okapi_BM25
-	score := idf * ((tf * (k + 1.0)) / (tf + k*(1.0-b+b*(dl/adl))))
+	boost := indexBoost(termMatch)
+	score := s.similarity.Score(&s.stats, s.idf, termMatch.Freq, termMatch.FieldLength) * boost
 
 	if s.options.Explain {
-		childrenExplanations := []*search.Explanation{
-			{
-				Value:   tf,
-				Message: fmt.Sprintf("tf(termFreq(%s:%s)=%d", s.queryField, string(s.queryTerm), termMatch.Freq),
-			},
-			{
-				Value:   float64(termMatch.Score),
-				Message: fmt.Sprintf("fieldScore(field=%s, doc=%s)", s.queryField, termMatch.ID),
-			},
-			{
-				Value:   idf,
-				Message: fmt.Sprintf("idf(inverseDocFreq=%d)", s.idf),
-			},
-			{
-				Value:   dl,
-				Message: fmt.Sprintf("dl(docLength=%d)", dl),
-			},
-			{
-				Value:   adl,
-				Message: fmt.Sprintf("adl(averageDocLength=%d)", adl),
-			},
-			s.idfExplanation,
+		childrenExplanations := s.similarity.Explain(&s.stats, s.idf, termMatch.Freq, termMatch.FieldLength)
+		childrenExplanations = append(childrenExplanations, s.idfExplanation)
+		if boost != 1.0 {
+			childrenExplanations = append(childrenExplanations, &search.Explanation{
+				Value:   boost,
+				Message: "indexBoost",
+			})
 		}
 		scoreExplanation = &search.Explanation{
 			Value:    score,
@@ -137,10 +167,10 @@ func (s *TermQueryScorer) Score(ctx *search.SearchContext, termMatch *index.Term
 		}
 	}
 
-	return processMatch(s, tf, termMatch, scoreExplanation, score, ctx)
+	return processMatch(s, termMatch, scoreExplanation, score, ctx)
 }
 
-func processMatch(s *TermQueryScorer, tf float64, termMatch *index.TermFieldDoc, scoreExplanation *search.Explanation, score float64, ctx *search.SearchContext) *search.DocumentMatch {
+func processMatch(s *TermQueryScorer, termMatch *index.TermFieldDoc, scoreExplanation *search.Explanation, score float64, ctx *search.SearchContext) *search.DocumentMatch {
 
 	// if the query weight isn't 1, multiply
 	if s.queryWeight != 1.0 {
@@ -15,6 +15,7 @@
 package scorer
 
 import (
+	"fmt"
 	"math"
 	"reflect"
 	"testing"
@@ -23,6 +24,17 @@ import (
 	"github.com/wrble/flock/search"
 )
 
+// bm25 is the expected field score for a field of length dl when the
+// average length is adl
+func bm25(idf float64, freq float64, dl, adl float64) (tf, score float64) {
+	tf = (2.2 * freq) / (1.2 + freq)
+	norm := 1.0
+	if dl > 0 && adl > 0 {
+		norm = 0.25 + 0.75*(dl/adl)
+	}
+	return tf, idf * ((tf * 2.2) / (tf + 1.2*norm))
+}
+
 func TestTermScorer(t *testing.T) {
 
 	var docTotal uint64 = 100
@@ -31,18 +43,49 @@ func TestTermScorer(t *testing.T) {
 	var queryField = "desc"
 	var queryBoost = 1.0
 	scorer := NewTermQueryScorer(queryTerm, queryField, queryBoost, docTotal, docTerm, search.SearcherOptions{Explain: true})
+	scorer.SetAverageFieldLength(4)
 	idf := 1.0 + math.Log(float64(docTotal)/float64(docTerm+1.0))
 
+	explain := func(freq float64, dl uint32) (float64, *search.Explanation) {
+		tf, score := bm25(idf, freq, float64(dl), 4)
+		return score, &search.Explanation{
+			Value:   score,
+			Message: "fieldWeight(desc:beer in one), product of:",
+			Children: []*search.Explanation{
+				{
+					Value:   tf,
+					Message: fmt.Sprintf("tf(termFreq=%f)", freq),
+				},
+				{
+					Value:   float64(dl),
+					Message: fmt.Sprintf("dl(fieldLength=%d)", dl),
+				},
+				{
+					Value:   4,
+					Message: "adl(averageFieldLength=4.000000)",
+				},
+				{
+					Value:   idf,
+					Message: "idf(docFreq=9, maxDocs=100)",
+				},
+			},
+		}
+	}
+	averageScore, averageExpl := explain(1, 4)
+	unknownScore, unknownExpl := explain(1, 0)
+	longScore, longExpl := explain(65, 8)
+
 	tests := []struct {
 		termMatch *index.TermFieldDoc
 		result    *search.DocumentMatch
 	}{
-		// test some simple math
+		// a field of average length
 		{
 			termMatch: &index.TermFieldDoc{
-				ID:    index.IndexInternalID("one"),
-				Freq:  1,
-				Score: 1.0,
+				ID:          index.IndexInternalID("one"),
+				Freq:        1,
+				Score:       1.0,
+				FieldLength: 4,
 				Vectors: []*index.TermFieldVector{
 					{
 						Field: "desc",
@@ -54,26 +97,9 @@ func TestTermScorer(t *testing.T) {
 			},
 			result: &search.DocumentMatch{
 				IndexInternalID: index.IndexInternalID("one"),
-				Score:           math.Sqrt(1.0) * idf,
+				Score:           averageScore,
 				Sort:            []string{},
-				Expl: &search.Explanation{
-					Value:   math.Sqrt(1.0) * idf,
-					Message: "fieldWeight(desc:beer in one), product of:",
-					Children: []*search.Explanation{
-						{
-							Value:   1,
-							Message: "tf(termFreq(desc:beer)=1",
-						},
-						{
-							Value:   1,
-							Message: "fieldNorm(field=desc, doc=one)",
-						},
-						{
-							Value:   idf,
-							Message: "idf(docFreq=9, maxDocs=100)",
-						},
-					},
-				},
+				Expl:            averageExpl,
 				Locations: search.FieldTermLocationMap{
 					"desc": search.TermLocationMap{
 						"beer": []*search.Location{
@@ -87,7 +113,7 @@ func TestTermScorer(t *testing.T) {
 				},
 			},
 		},
-		// test the same thing again (score should be cached this time)
+		// an unknown field length is not normalized
 		{
 			termMatch: &index.TermFieldDoc{
 				ID:    index.IndexInternalID("one"),
@@ -96,57 +122,24 @@ func TestTermScorer(t *testing.T) {
 			},
 			result: &search.DocumentMatch{
 				IndexInternalID: index.IndexInternalID("one"),
-				Score:           math.Sqrt(1.0) * idf,
+				Score:           unknownScore,
 				Sort:            []string{},
-				Expl: &search.Explanation{
-					Value:   math.Sqrt(1.0) * idf,
-					Message: "fieldWeight(desc:beer in one), product of:",
-					Children: []*search.Explanation{
-						{
-							Value:   1,
-							Message: "tf(termFreq(desc:beer)=1",
-						},
-						{
-							Value:   1,
-							Message: "fieldNorm(field=desc, doc=one)",
-						},
-						{
-							Value:   idf,
-							Message: "idf(docFreq=9, maxDocs=100)",
-						},
-					},
-				},
+				Expl:            unknownExpl,
 			},
 		},
-		// test a case where the sqrt isn't precalculated
+		// a field twice the average length
 		{
 			termMatch: &index.TermFieldDoc{
-				ID:    index.IndexInternalID("one"),
-				Freq:  65,
-				Score: 1.0,
+				ID:          index.IndexInternalID("one"),
+				Freq:        65,
+				Score:       1.0,
+				FieldLength: 8,
 			},
 			result: &search.DocumentMatch{
 				IndexInternalID: index.IndexInternalID("one"),
-				Score:           math.Sqrt(65) * idf,
+				Score:           longScore,
 				Sort:            []string{},
-				Expl: &search.Explanation{
-					Value:   math.Sqrt(65) * idf,
-					Message: "fieldWeight(desc:beer in one), product of:",
-					Children: []*search.Explanation{
-						{
-							Value:   math.Sqrt(65),
-							Message: "tf(termFreq(desc:beer)=65",
-						},
-						{
-							Value:   1,
-							Message: "fieldNorm(field=desc, doc=one)",
-						},
-						{
-							Value:   idf,
-							Message: "idf(docFreq=9, maxDocs=100)",
-						},
-					},
-				},
+				Expl:            longExpl,
 			},
 		},
 	}
@@ -162,6 +155,76 @@ func TestTermScorer(t *testing.T) {
 		}
 	}
 
+	if averageScore != unknownScore {
+		t.Errorf("expected a field of average length to score as an unknown length, got %f and %f", averageScore, unknownScore)
+	}
+	_, shortScore := bm25(idf, 1, 1, 4)
+	if shortScore <= averageScore {
+		t.Errorf("expected a short field to score higher, got %f <= %f", shortScore, averageScore)
+	}
+}
+
+func TestTermScorerUpperBound(t *testing.T) {
+	scorer := NewTermQueryScorer([]byte("beer"), "desc", 3.0, 100, 9, search.SearcherOptions{})
+	scorer.SetQueryNorm(2.0)
+	scorer.SetAverageFieldLength(4)
+
+	ctx := &search.SearchContext{
+		DocumentMatchPool: search.NewDocumentMatchPool(1, 0),
+	}
+	for _, freq := range []float32{0.25, 1, 65} {
+		bound := scorer.UpperBound(freq)
+		for _, dl := range []uint32{0, 1, 4, 100} {
+			actual := scorer.Score(ctx, &index.TermFieldDoc{
+				ID:          index.IndexInternalID("one"),
+				Freq:        freq,
+				FieldLength: dl,
+			})
+			if actual.Score > bound {
+				t.Errorf("expected score %f for freq %f, length %d to be at most %f", actual.Score, freq, dl, bound)
+			}
+			ctx.DocumentMatchPool.Put(actual)
+		}
+	}
+}
+
+func TestTermScorerIndexBoost(t *testing.T) {
+	scorer := NewTermQueryScorer([]byte("beer"), "desc", 1.0, 100, 9, search.SearcherOptions{Explain: true})
+	scorer.SetAverageFieldLength(4)
+
+	ctx := &search.SearchContext{
+		DocumentMatchPool: search.NewDocumentMatchPool(2, 0),
+	}
+	plain := scorer.Score(ctx, &index.TermFieldDoc{
+		ID:          index.IndexInternalID("one"),
+		Freq:        1,
+		FieldLength: 2,
+	})
+	boosted := scorer.Score(ctx, &index.TermFieldDoc{
+		ID:          index.IndexInternalID("two"),
+		Freq:        1,
+		FieldLength: 2,
+		Boost:       2.5,
+	})
+	if boosted.Score != plain.Score*2.5 {
+		t.Errorf("expected boosted score %f, got %f", plain.Score*2.5, boosted.Score)
+	}
+	if len(plain.Expl.Children) != len(boosted.Expl.Children)-1 {
+		t.Fatalf("expected an explanation of the boost, got %v", boosted.Expl)
+	}
+	boostExplanation := boosted.Expl.Children[len(boosted.Expl.Children)-1]
+	if boostExplanation.Message != "indexBoost" || boostExplanation.Value != 2.5 {
+		t.Errorf("expected indexBoost 2.5, got %v", boostExplanation)
+	}
+
+	// the bound only holds for boosts up to the highest of the field
+	if scorer.UpperBound(1) >= boosted.Score {
+		t.Errorf("expected the unboosted bound %f below the boosted score %f", scorer.UpperBound(1), boosted.Score)
+	}
+	scorer.SetMaxFieldBoost(2.5)
+	if scorer.UpperBound(1) < boosted.Score {
+		t.Errorf("expected bound %f to be at least %f", scorer.UpperBound(1), boosted.Score)
+	}
 }
 
 func TestTermScorerWithQueryNorm(t *testing.T) {
@@ -182,6 +245,8 @@ func TestTermScorerWithQueryNorm(t *testing.T) {
 		t.Errorf("expected query weight %f, got %f", expectedQueryWeight, actualQueryWeight)
 	}
 
+	tf, fieldScore := bm25(idf, 1, 0, 0)
+
 	tests := []struct {
 		termMatch *index.TermFieldDoc
 		result    *search.DocumentMatch
@@ -194,10 +259,10 @@ func TestTermScorerWithQueryNorm(t *testing.T) {
 			},
 			result: &search.DocumentMatch{
 				IndexInternalID: index.IndexInternalID("one"),
-				Score:           math.Sqrt(1.0) * idf * 3.0 * idf * 2.0,
+				Score:           fieldScore * 3.0 * idf * 2.0,
 				Sort:            []string{},
 				Expl: &search.Explanation{
-					Value:   math.Sqrt(1.0) * idf * 3.0 * idf * 2.0,
+					Value:   fieldScore * 3.0 * idf * 2.0,
 					Message: "weight(desc:beer^3.000000 in one), product of:",
 					Children: []*search.Explanation{
 						{
@@ -219,16 +284,20 @@ func TestTermScorerWithQueryNorm(t *testing.T) {
 							},
 						},
 						{
-							Value:   math.Sqrt(1.0) * idf,
+							Value:   fieldScore,
 							Message: "fieldWeight(desc:beer in one), product of:",
 							Children: []*search.Explanation{
 								{
-									Value:   1,
-									Message: "tf(termFreq(desc:beer)=1",
+									Value:   tf,
+									Message: "tf(termFreq=1.000000)",
+								},
+								{
+									Value:   0,
+									Message: "dl(fieldLength=0)",
 								},
 								{
-									Value:   1,
-									Message: "fieldNorm(field=desc, doc=one)",
+									Value:   0,
+									Message: "adl(averageFieldLength=0.000000)",
 								},
 								{
 									Value:   idf,
@@ -0,0 +1,51 @@
+package scorer
+
+import (
+	"github.com/wrble/flock/search"
+)
+
+// TermStats are the statistics of a query term in its field
+type TermStats struct {
+	// DocTotal is the number of documents in the index
+	DocTotal uint64
+	// DocTerm is the number of documents with the term in the field
+	DocTerm uint64
+	// AvgFieldLength is the average length of the field, 0 when unknown
+	AvgFieldLength float64
+}
+
+// Similarity is a scoring model, it computes the score of a term in
+// the field of a document.  The frequency is TermFieldDoc.Freq, the
+// field length TermFieldDoc.FieldLength which is 0 when unknown.
+type Similarity interface {
+	// Idf returns the weight of a term found in docTerm of the
+	// docTotal documents, the query weight is its product with the
+	// boost and the query norm
+	Idf(docTotal, docTerm uint64) float64
+
+	// Score returns the score of a document where the term has the
+	// frequency freq in a field of length dl, it must not decrease as
+	// freq grows
+	Score(stats *TermStats, idf float64, freq float32, dl uint32) float64
+
+	// MaxScore returns the highest score a document with the frequency
+	// freq can get, whatever the length of its field
+	MaxScore(stats *TermStats, idf float64, freq float32) float64
+
+	// Explain returns the factors of Score
+	Explain(stats *TermStats, idf float64, freq float32, dl uint32) []*search.Explanation
+}
+
+// DefaultSimilarity scores the fields which do not select a similarity
+var DefaultSimilarity Similarity = NewBM25Similarity(DefaultBM25K1, DefaultBM25B)
+
+// SimilarityForField returns the similarity the options select for the
+// field, DefaultSimilarity when they select none
+func SimilarityForField(options search.SearcherOptions, field string) Similarity {
+	if options.Similarity != nil {
+		if similarity, ok := options.Similarity(field).(Similarity); ok && similarity != nil {
+			return similarity
+		}
+	}
+	return DefaultSimilarity
+}
@@ -0,0 +1,80 @@
+package scorer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/wrble/flock/search"
+)
+
+// BM 25 defaults
+const (
+	DefaultBM25K1 = 1.2 // or 2.0
+	DefaultBM25B  = .75
+)
+
+// BM25Similarity is the Okapi BM 25 ranking function, K1 controls the
+// term frequency saturation and B the length normalization
+type BM25Similarity struct {
+	K1 float64
+	B  float64
+}
+
+func NewBM25Similarity(k1, b float64) *BM25Similarity {
+	return &BM25Similarity{
+		K1: k1,
+		B:  b,
+	}
+}
+
+func (s *BM25Similarity) Idf(docTotal, docTerm uint64) float64 {
+	return 1.0 + math.Log(float64(docTotal)/float64(docTerm+1.0))
+}
+
+// lengthNorm is the length normalization of a field of length dl, it
+// is left out when either length is unknown
+func (s *BM25Similarity) lengthNorm(stats *TermStats, dl uint32) float64 {
+	if dl == 0 || stats.AvgFieldLength <= 0 {
+		return 1.0
+	}
+	return 1.0 - s.B + s.B*(float64(dl)/stats.AvgFieldLength)
+}
+
+func (s *BM25Similarity) tf(freq float32) float64 {
+	return ((s.K1 + 1.0) * float64(freq)) / (s.K1 + float64(freq))
+}
+
+func (s *BM25Similarity) score(idf, tf, lengthNorm float64) float64 {
+	// From https://en.wikipedia.org/wiki/Okapi_BM25
+	return idf * ((tf * (s.K1 + 1.0)) / (tf + s.K1*lengthNorm))
+}
+
+func (s *BM25Similarity) Score(stats *TermStats, idf float64, freq float32, dl uint32) float64 {
+	return s.score(idf, s.tf(freq), s.lengthNorm(stats, dl))
+}
+
+func (s *BM25Similarity) MaxScore(stats *TermStats, idf float64, freq float32) float64 {
+	lengthNorm := 1.0
+	if stats.AvgFieldLength > 0 {
+		// the norm of an empty field
+		lengthNorm = 1.0 - s.B
+	}
+	return s.score(idf, s.tf(freq), lengthNorm)
+}
+
+func (s *BM25Similarity) Explain(stats *TermStats, idf float64, freq float32, dl uint32) []*search.Explanation {
+	return []*search.Explanation{
+		{
+			Value:   s.tf(freq),
+			Message: fmt.Sprintf("tf(termFreq=%f)", freq),
+		},
+		{
+			Value:   float64(dl),
+			Message: fmt.Sprintf("dl(fieldLength=%d)", dl),
+		},
+		{
+			Value:   stats.AvgFieldLength,
+			Message: fmt.Sprintf("adl(averageFieldLength=%f)", stats.AvgFieldLength),
+		},
+	}
+}
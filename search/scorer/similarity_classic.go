@@ -0,0 +1,52 @@
+package scorer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/wrble/flock/search"
+)
+
+// ClassicSimilarity is the classic TF-IDF model, the square root of
+// the term frequency times the idf, normalized by the inverse square
+// root of the field length
+type ClassicSimilarity struct{}
+
+func NewClassicSimilarity() *ClassicSimilarity {
+	return &ClassicSimilarity{}
+}
+
+func (s *ClassicSimilarity) Idf(docTotal, docTerm uint64) float64 {
+	return 1.0 + math.Log(float64(docTotal)/float64(docTerm+1.0))
+}
+
+// fieldNorm is left out when the field length is unknown
+func (s *ClassicSimilarity) fieldNorm(dl uint32) float64 {
+	if dl == 0 {
+		return 1.0
+	}
+	return 1.0 / math.Sqrt(float64(dl))
+}
+
+func (s *ClassicSimilarity) Score(stats *TermStats, idf float64, freq float32, dl uint32) float64 {
+	return math.Sqrt(float64(freq)) * idf * s.fieldNorm(dl)
+}
+
+// MaxScore is the score in a field of a single token, the shortest a
+// field containing the term can be
+func (s *ClassicSimilarity) MaxScore(stats *TermStats, idf float64, freq float32) float64 {
+	return math.Sqrt(float64(freq)) * idf
+}
+
+func (s *ClassicSimilarity) Explain(stats *TermStats, idf float64, freq float32, dl uint32) []*search.Explanation {
+	return []*search.Explanation{
+		{
+			Value:   math.Sqrt(float64(freq)),
+			Message: fmt.Sprintf("tf(termFreq=%f)", freq),
+		},
+		{
+			Value:   s.fieldNorm(dl),
+			Message: fmt.Sprintf("fieldNorm(fieldLength=%d)", dl),
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package scorer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/wrble/flock/search"
+)
+
+// DefaultDFRC is the default length normalization parameter of DFR
+const DefaultDFRC = 1.0
+
+// DFRSimilarity is the divergence from randomness model In-L-H2: the
+// inverse document frequency basic model, the Laplace after effect and
+// the logarithmic length normalization H2 controlled by C
+type DFRSimilarity struct {
+	C float64
+}
+
+func NewDFRSimilarity(c float64) *DFRSimilarity {
+	return &DFRSimilarity{
+		C: c,
+	}
+}
+
+func (s *DFRSimilarity) Idf(docTotal, docTerm uint64) float64 {
+	return math.Log2((float64(docTotal) + 1.0) / (float64(docTerm) + 0.5))
+}
+
+// tfn is the term frequency normalized to the average field length, it
+// is left as is when either length is unknown
+func (s *DFRSimilarity) tfn(stats *TermStats, freq float32, dl uint32) float64 {
+	if dl == 0 || stats.AvgFieldLength <= 0 {
+		return float64(freq)
+	}
+	return float64(freq) * math.Log2(1.0+s.C*stats.AvgFieldLength/float64(dl))
+}
+
+func (s *DFRSimilarity) Score(stats *TermStats, idf float64, freq float32, dl uint32) float64 {
+	tfn := s.tfn(stats, freq, dl)
+	return idf * (tfn / (tfn + 1.0))
+}
+
+// MaxScore is the limit of the score as the field gets shorter, the
+// normalized frequency grows without bound
+func (s *DFRSimilarity) MaxScore(stats *TermStats, idf float64, freq float32) float64 {
+	if stats.AvgFieldLength > 0 {
+		return idf
+	}
+	return s.Score(stats, idf, freq, 0)
+}
+
+func (s *DFRSimilarity) Explain(stats *TermStats, idf float64, freq float32, dl uint32) []*search.Explanation {
+	tfn := s.tfn(stats, freq, dl)
+	return []*search.Explanation{
+		{
+			Value:   tfn,
+			Message: fmt.Sprintf("tfn(termFreq=%f, c=%f, dl(fieldLength=%d), adl(averageFieldLength=%f))", freq, s.C, dl, stats.AvgFieldLength),
+		},
+		{
+			Value:   1.0 / (tfn + 1.0),
+			Message: "afterEffectL, 1/(tfn+1)",
+		},
+	}
+}
@@ -0,0 +1,134 @@
+package scorer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/wrble/flock/search"
+)
+
+// language model defaults
+const (
+	DefaultLMDirichletMu         = 2000.0
+	DefaultLMJelinekMercerLambda = 0.1
+)
+
+// lmIdf leaves the term weight to the collection probability
+func lmIdf(docTotal, docTerm uint64) float64 {
+	return 1.0
+}
+
+// collectionProbability estimates the probability of the term in the
+// collection of fields.  The index does not count the occurrences of a
+// term over the collection, every document containing it is assumed to
+// hold it once.
+func collectionProbability(stats *TermStats) float64 {
+	adl := stats.AvgFieldLength
+	if adl <= 0 {
+		adl = 1.0
+	}
+	return (float64(stats.DocTerm) + 1.0) / (float64(stats.DocTotal)*adl + 1.0)
+}
+
+// lmFieldLength is the length of the field, the average one when it
+// is unknown, 0 when that is unknown too
+func lmFieldLength(stats *TermStats, dl uint32) float64 {
+	if dl > 0 {
+		return float64(dl)
+	}
+	return stats.AvgFieldLength
+}
+
+// LMDirichletSimilarity is the language model with Dirichlet smoothing,
+// Mu is the weight of the collection model.  Negative scores are
+// clamped to 0.
+type LMDirichletSimilarity struct {
+	Mu float64
+}
+
+func NewLMDirichletSimilarity(mu float64) *LMDirichletSimilarity {
+	return &LMDirichletSimilarity{
+		Mu: mu,
+	}
+}
+
+func (s *LMDirichletSimilarity) Idf(docTotal, docTerm uint64) float64 {
+	return lmIdf(docTotal, docTerm)
+}
+
+func (s *LMDirichletSimilarity) termWeight(stats *TermStats, freq float32) float64 {
+	return math.Log(1.0 + float64(freq)/(s.Mu*collectionProbability(stats)))
+}
+
+func (s *LMDirichletSimilarity) lengthWeight(stats *TermStats, dl uint32) float64 {
+	return math.Log(s.Mu / (lmFieldLength(stats, dl) + s.Mu))
+}
+
+func (s *LMDirichletSimilarity) Score(stats *TermStats, idf float64, freq float32, dl uint32) float64 {
+	score := s.termWeight(stats, freq) + s.lengthWeight(stats, dl)
+	if score < 0 {
+		return 0
+	}
+	return idf * score
+}
+
+// MaxScore is the score of an empty field, which has no length weight
+func (s *LMDirichletSimilarity) MaxScore(stats *TermStats, idf float64, freq float32) float64 {
+	return idf * s.termWeight(stats, freq)
+}
+
+func (s *LMDirichletSimilarity) Explain(stats *TermStats, idf float64, freq float32, dl uint32) []*search.Explanation {
+	return []*search.Explanation{
+		{
+			Value:   s.termWeight(stats, freq),
+			Message: fmt.Sprintf("termWeight(termFreq=%f, mu=%f, collectionProbability=%f)", freq, s.Mu, collectionProbability(stats)),
+		},
+		{
+			Value:   s.lengthWeight(stats, dl),
+			Message: fmt.Sprintf("lengthWeight(dl(fieldLength=%d), adl(averageFieldLength=%f))", dl, stats.AvgFieldLength),
+		},
+	}
+}
+
+// LMJelinekMercerSimilarity is the language model with Jelinek-Mercer
+// smoothing, Lambda is the share of the collection model
+type LMJelinekMercerSimilarity struct {
+	Lambda float64
+}
+
+func NewLMJelinekMercerSimilarity(lambda float64) *LMJelinekMercerSimilarity {
+	return &LMJelinekMercerSimilarity{
+		Lambda: lambda,
+	}
+}
+
+func (s *LMJelinekMercerSimilarity) Idf(docTotal, docTerm uint64) float64 {
+	return lmIdf(docTotal, docTerm)
+}
+
+func (s *LMJelinekMercerSimilarity) score(stats *TermStats, freq float32, length float64) float64 {
+	if length < 1.0 {
+		length = 1.0
+	}
+	documentModel := (1.0 - s.Lambda) * float64(freq) / length
+	return math.Log(1.0 + documentModel/(s.Lambda*collectionProbability(stats)))
+}
+
+func (s *LMJelinekMercerSimilarity) Score(stats *TermStats, idf float64, freq float32, dl uint32) float64 {
+	return idf * s.score(stats, freq, lmFieldLength(stats, dl))
+}
+
+// MaxScore is the score in a field of a single token, the shortest a
+// field containing the term can be
+func (s *LMJelinekMercerSimilarity) MaxScore(stats *TermStats, idf float64, freq float32) float64 {
+	return idf * s.score(stats, freq, 1.0)
+}
+
+func (s *LMJelinekMercerSimilarity) Explain(stats *TermStats, idf float64, freq float32, dl uint32) []*search.Explanation {
+	return []*search.Explanation{
+		{
+			Value:   s.score(stats, freq, lmFieldLength(stats, dl)),
+			Message: fmt.Sprintf("termWeight(termFreq=%f, lambda=%f, collectionProbability=%f, dl(fieldLength=%d), adl(averageFieldLength=%f))", freq, s.Lambda, collectionProbability(stats), dl, stats.AvgFieldLength),
+		},
+	}
+}
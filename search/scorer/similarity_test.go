@@ -0,0 +1,67 @@
+package scorer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimilarityMaxScore(t *testing.T) {
+	similarities := map[string]Similarity{
+		"bm25":              NewBM25Similarity(DefaultBM25K1, DefaultBM25B),
+		"classic":           NewClassicSimilarity(),
+		"dfr":               NewDFRSimilarity(DefaultDFRC),
+		"lm_dirichlet":      NewLMDirichletSimilarity(DefaultLMDirichletMu),
+		"lm_jelinek_mercer": NewLMJelinekMercerSimilarity(DefaultLMJelinekMercerLambda),
+	}
+	freqs := []float32{0.1, 0.5, 1, 3, 65}
+	lengths := []uint32{0, 1, 2, 10, 1000}
+
+	for name, similarity := range similarities {
+		for _, adl := range []float64{0, 1.5, 40} {
+			stats := &TermStats{
+				DocTotal:       100,
+				DocTerm:        9,
+				AvgFieldLength: adl,
+			}
+			idf := similarity.Idf(stats.DocTotal, stats.DocTerm)
+			if idf <= 0 || math.IsInf(idf, 0) || math.IsNaN(idf) {
+				t.Errorf("%s: expected a positive idf, got %f", name, idf)
+			}
+			lastMax := 0.0
+			for _, freq := range freqs {
+				max := similarity.MaxScore(stats, idf, freq)
+				if max < lastMax {
+					t.Errorf("%s: expected the max score to grow with freq, got %f after %f", name, max, lastMax)
+				}
+				lastMax = max
+				for _, dl := range lengths {
+					score := similarity.Score(stats, idf, freq, dl)
+					if math.IsNaN(score) || score < 0 || score > max {
+						t.Errorf("%s: expected score for freq %f, length %d, adl %f between 0 and %f, got %f", name, freq, dl, adl, max, score)
+					}
+					if len(similarity.Explain(stats, idf, freq, dl)) == 0 {
+						t.Errorf("%s: expected an explanation", name)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestBM25SimilarityLengthNorm(t *testing.T) {
+	stats := &TermStats{DocTotal: 100, DocTerm: 9, AvgFieldLength: 10}
+	similarity := NewBM25Similarity(DefaultBM25K1, DefaultBM25B)
+	idf := similarity.Idf(stats.DocTotal, stats.DocTerm)
+	short := similarity.Score(stats, idf, 1, 5)
+	average := similarity.Score(stats, idf, 1, 10)
+	long := similarity.Score(stats, idf, 1, 20)
+	if !(short > average && average > long) {
+		t.Errorf("expected shorter fields to score higher, got %f, %f, %f", short, average, long)
+	}
+
+	// without length normalization the length does not matter
+	similarity = NewBM25Similarity(DefaultBM25K1, 0)
+	if similarity.Score(stats, idf, 1, 5) != similarity.Score(stats, idf, 1, 20) {
+		t.Errorf("expected b 0 to ignore the field length")
+	}
+}
@@ -15,6 +15,7 @@
 package search
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/wrble/flock/document"
@@ -59,6 +60,12 @@ type FieldTermLocationMap map[string]TermLocationMap
 
 type FieldFragmentMap map[string][]string
 
+// NestedIdentity is the path and the offset in it of a nested document
+type NestedIdentity struct {
+	Path   string `json:"path"`
+	Offset int    `json:"offset"`
+}
+
 type DocumentMatch struct {
 	Index           string                `json:"index,omitempty"`
 	ID              string                `json:"id"`
@@ -68,12 +75,25 @@ type DocumentMatch struct {
 	Locations       FieldTermLocationMap  `json:"locations,omitempty"`
 	Fragments       FieldFragmentMap      `json:"fragments,omitempty"`
 	Sort            []string              `json:"sort,omitempty"`
+	Version         uint64                `json:"version,omitempty"`
+
+	// Nested locates the nested document an inner hit is, ID is then
+	// that of the document it is nested in
+	Nested *NestedIdentity `json:"nested,omitempty"`
+
+	// InnerHits are the best nested documents matching a nested query,
+	// by nested path
+	InnerHits map[string]DocumentMatchCollection `json:"inner_hits,omitempty"`
 
 	// Fields contains the values for document fields listed in
 	// SearchRequest.Fields. Text fields are returned as strings, numeric
 	// fields as float64s and date fields as time.RFC3339 formatted strings.
 	Fields map[string]interface{} `json:"fields,omitempty"`
 
+	// Source is the source of the document, filtered as
+	// SearchRequest.Source asks
+	Source json.RawMessage `json:"_source,omitempty"`
+
 	// if we load the document for this hit, remember it so we dont load again
 	Document *document.Document `json:"-"`
 
@@ -139,9 +159,32 @@ type Searcher interface {
 	DocumentMatchPoolSize() int
 }
 
+// BestFirstSearcher is implemented by searchers which can return their
+// matches by descending score and stop early.
+type BestFirstSearcher interface {
+	Searcher
+
+	// BestFirst switches the searcher to descending score order, it
+	// must be called before the first Next and reports whether the
+	// switch was possible.  Advance is not supported afterwards.
+	BestFirst() (bool, error)
+
+	// SetMinScore tells the searcher that matches scoring at or below
+	// min are no longer of interest, in best first order it stops there
+	SetMinScore(min float64)
+
+	// Truncated reports whether matches were left unvisited
+	Truncated() bool
+}
+
 type SearcherOptions struct {
 	Explain            bool
 	IncludeTermVectors bool
+
+	// Similarity returns the scorer.Similarity of a field, nil for the
+	// default one.  It is loosely typed as the scorer package depends
+	// on this one.
+	Similarity func(field string) interface{}
 }
 
 // SearchContext represents the context around a single search
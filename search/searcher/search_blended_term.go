@@ -0,0 +1,38 @@
+package searcher
+
+import (
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/search"
+)
+
+// NewBlendedTermSearcher returns a searcher matching the term in any of
+// the fields, each with its boost, scored by the best field plus the
+// tie breaker times the others.  The fields are scored as if the term
+// were as frequent in each as in the field it is the most frequent in,
+// so a term rare in one field only does not outweigh the others.
+func NewBlendedTermSearcher(indexReader index.IndexReader, term string, fields []string, boosts []float64, tieBreaker float64, options search.SearcherOptions) (*DisjunctionSearcher, error) {
+	if tooManyClauses(len(fields)) {
+		return nil, tooManyClausesErr()
+	}
+	termSearchers := make([]*TermSearcher, 0, len(fields))
+	searchers := make([]search.Searcher, 0, len(fields))
+	var docTerm uint64
+	for i, field := range fields {
+		ts, err := NewTermSearcher(indexReader, term, field, boosts[i], options)
+		if err != nil {
+			for _, searcher := range searchers {
+				_ = searcher.Close()
+			}
+			return nil, err
+		}
+		if ts.Count() > docTerm {
+			docTerm = ts.Count()
+		}
+		termSearchers = append(termSearchers, ts)
+		searchers = append(searchers, ts)
+	}
+	for _, ts := range termSearchers {
+		ts.scorer.SetDocumentFrequency(docTerm)
+	}
+	return NewDisjunctionMaxSearcher(indexReader, searchers, tieBreaker, options)
+}
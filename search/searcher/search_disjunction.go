@@ -36,6 +36,7 @@ type DisjunctionSearcher struct {
 	queryNorm    float64
 	currs        []*search.DocumentMatch
 	scorer       *scorer.DisjunctionQueryScorer
+	maxScorer    *scorer.DisjunctionMaxQueryScorer
 	min          int
 	matching     []*search.DocumentMatch
 	matchingIdxs []int
@@ -90,12 +91,37 @@ func newDisjunctionSearcher(indexReader index.IndexReader,
 	return &rv, nil
 }
 
+// NewDisjunctionMaxSearcher returns a searcher matching the documents
+// any of the searchers match, scored by the best of them plus the tie
+// breaker times the sum of the others, see
+// scorer.DisjunctionMaxQueryScorer
+func NewDisjunctionMaxSearcher(indexReader index.IndexReader,
+	qsearchers []search.Searcher, tieBreaker float64,
+	options search.SearcherOptions) (*DisjunctionSearcher, error) {
+	if tooManyClauses(len(qsearchers)) {
+		return nil, tooManyClausesErr()
+	}
+	searchers := make(OrderedSearcherList, len(qsearchers))
+	for i, searcher := range qsearchers {
+		searchers[i] = searcher
+	}
+	sort.Sort(sort.Reverse(searchers))
+	rv := DisjunctionSearcher{
+		indexReader:  indexReader,
+		searchers:    searchers,
+		numSearchers: len(searchers),
+		currs:        make([]*search.DocumentMatch, len(searchers)),
+		maxScorer:    scorer.NewDisjunctionMaxQueryScorer(tieBreaker, options),
+		matching:     make([]*search.DocumentMatch, len(searchers)),
+		matchingIdxs: make([]int, len(searchers)),
+	}
+	rv.computeQueryNorm()
+	return &rv, nil
+}
+
 func (s *DisjunctionSearcher) computeQueryNorm() {
 	// first calculate sum of squared weights
-	sumOfSquaredWeights := 0.0
-	for _, termSearcher := range s.searchers {
-		sumOfSquaredWeights += termSearcher.Weight()
-	}
+	sumOfSquaredWeights := s.Weight()
 	// now compute query norm from this
 	s.queryNorm = 1.0 / math.Sqrt(sumOfSquaredWeights)
 	// finally tell all the downstream searchers the norm
@@ -159,6 +185,13 @@ func (s *DisjunctionSearcher) updateMatches() error {
 }
 
 func (s *DisjunctionSearcher) Weight() float64 {
+	if s.maxScorer != nil {
+		weights := make([]float64, len(s.searchers))
+		for i, searcher := range s.searchers {
+			weights[i] = searcher.Weight()
+		}
+		return s.maxScorer.Weight(weights)
+	}
 	var rv float64
 	for _, searcher := range s.searchers {
 		rv += searcher.Weight()
@@ -188,7 +221,11 @@ func (s *DisjunctionSearcher) Next(ctx *search.SearchContext) (
 		if len(s.matching) >= s.min {
 			found = true
 			// score this match
-			rv = s.scorer.Score(ctx, s.matching, len(s.matching), s.numSearchers)
+			if s.maxScorer != nil {
+				rv = s.maxScorer.Score(ctx, s.matching)
+			} else {
+				rv = s.scorer.Score(ctx, s.matching, len(s.matching), s.numSearchers)
+			}
 		}
 
 		// invoke next on all the matching searchers
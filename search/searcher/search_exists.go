@@ -0,0 +1,73 @@
+package searcher
+
+import (
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/scorer"
+)
+
+// ExistsSearcher matches the documents having a field, or an object
+// or nested documents at the path, with a constant score.  It reads the
+// postings of the name of the field in index.FieldNamesField, where the
+// index records the fields of every document.
+type ExistsSearcher struct {
+	reader index.TermFieldReader
+	scorer *scorer.ConstantScorer
+}
+
+func NewExistsSearcher(indexReader index.IndexReader, field string, boost float64, options search.SearcherOptions) (*ExistsSearcher, error) {
+	reader, err := indexReader.TermFieldReader([]byte(field), index.FieldNamesField, false, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return &ExistsSearcher{
+		reader: reader,
+		scorer: scorer.NewConstantScorer(1.0, boost, options),
+	}, nil
+}
+
+func (s *ExistsSearcher) Count() uint64 {
+	return s.reader.Count()
+}
+
+func (s *ExistsSearcher) Weight() float64 {
+	return s.scorer.Weight()
+}
+
+func (s *ExistsSearcher) SetQueryNorm(qnorm float64) {
+	s.scorer.SetQueryNorm(qnorm)
+}
+
+func (s *ExistsSearcher) Next(ctx *search.SearchContext) (*search.DocumentMatch, error) {
+	tfd, err := s.reader.Next(nil)
+	if err != nil {
+		return nil, err
+	}
+	if tfd == nil {
+		return nil, nil
+	}
+	return s.scorer.Score(ctx, tfd.ID), nil
+}
+
+func (s *ExistsSearcher) Advance(ctx *search.SearchContext, ID index.IndexInternalID) (*search.DocumentMatch, error) {
+	tfd, err := s.reader.Advance(ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if tfd == nil {
+		return nil, nil
+	}
+	return s.scorer.Score(ctx, tfd.ID), nil
+}
+
+func (s *ExistsSearcher) Close() error {
+	return s.reader.Close()
+}
+
+func (s *ExistsSearcher) Min() int {
+	return 0
+}
+
+func (s *ExistsSearcher) DocumentMatchPoolSize() int {
+	return 1
+}
@@ -86,3 +86,25 @@ func (f *FilteringSearcher) Min() int {
 func (f *FilteringSearcher) DocumentMatchPoolSize() int {
 	return f.child.DocumentMatchPoolSize()
 }
+
+// BestFirst switches the searcher filtered to descending score order
+// when it can
+func (f *FilteringSearcher) BestFirst() (bool, error) {
+	if bfs, ok := f.child.(search.BestFirstSearcher); ok {
+		return bfs.BestFirst()
+	}
+	return false, nil
+}
+
+func (f *FilteringSearcher) SetMinScore(min float64) {
+	if bfs, ok := f.child.(search.BestFirstSearcher); ok {
+		bfs.SetMinScore(min)
+	}
+}
+
+func (f *FilteringSearcher) Truncated() bool {
+	if bfs, ok := f.child.(search.BestFirstSearcher); ok {
+		return bfs.Truncated()
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package searcher
+
+import (
+	"fmt"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/scorer"
+)
+
+// FunctionScoreSearcher matches the documents another searcher matches
+// and scores them again with score functions, see
+// scorer.FunctionQueryScorer.  A function applies to the documents its
+// filter matches, or to all of them when it has no filter.
+type FunctionScoreSearcher struct {
+	indexReader index.IndexReader
+	searcher    search.Searcher
+	filters     []search.Searcher
+	// filterIDs holds the last document each filter matched, nil
+	// before the first one
+	filterIDs  []index.IndexInternalID
+	filterDone []bool
+	applies    []bool
+	scorer     *scorer.FunctionQueryScorer
+}
+
+// NewFunctionScoreSearcher returns a searcher scoring the matches of
+// the searcher with the functions, weights and filters of the same
+// index, a nil filter applies the function to every match.  The
+// functions are combined by the score mode, then with the score of the
+// match by the boost mode, and capped at maxBoost.
+func NewFunctionScoreSearcher(indexReader index.IndexReader, searcher search.Searcher, functions []scorer.ScoreFunction, weights []float64, filters []search.Searcher, scoreMode, boostMode string, maxBoost, boost float64, options search.SearcherOptions) (*FunctionScoreSearcher, error) {
+	if len(filters) != len(functions) {
+		return nil, fmt.Errorf("expected %d function filters, got %d", len(functions), len(filters))
+	}
+	functionScorer, err := scorer.NewFunctionQueryScorer(functions, weights, scoreMode, boostMode, maxBoost, boost, options)
+	if err != nil {
+		return nil, err
+	}
+	return &FunctionScoreSearcher{
+		indexReader: indexReader,
+		searcher:    searcher,
+		filters:     filters,
+		filterIDs:   make([]index.IndexInternalID, len(filters)),
+		filterDone:  make([]bool, len(filters)),
+		applies:     make([]bool, len(filters)),
+		scorer:      functionScorer,
+	}, nil
+}
+
+func (s *FunctionScoreSearcher) Next(ctx *search.SearchContext) (*search.DocumentMatch, error) {
+	next, err := s.searcher.Next(ctx)
+	if err != nil || next == nil {
+		return nil, err
+	}
+	return s.score(ctx, next)
+}
+
+func (s *FunctionScoreSearcher) Advance(ctx *search.SearchContext, ID index.IndexInternalID) (*search.DocumentMatch, error) {
+	next, err := s.searcher.Advance(ctx, ID)
+	if err != nil || next == nil {
+		return nil, err
+	}
+	return s.score(ctx, next)
+}
+
+// score finds the functions applying to the match and scores it
+func (s *FunctionScoreSearcher) score(ctx *search.SearchContext, d *search.DocumentMatch) (*search.DocumentMatch, error) {
+	for i := range s.filters {
+		applies, err := s.filterMatches(ctx, i, d.IndexInternalID)
+		if err != nil {
+			ctx.DocumentMatchPool.Put(d)
+			return nil, err
+		}
+		s.applies[i] = applies
+	}
+	err := s.scorer.Score(s.indexReader, d, s.applies)
+	if err != nil {
+		ctx.DocumentMatchPool.Put(d)
+		return nil, err
+	}
+	return d, nil
+}
+
+// filterMatches returns whether the filter of function i matches the
+// document, the filters are advanced along the matches
+func (s *FunctionScoreSearcher) filterMatches(ctx *search.SearchContext, i int, ID index.IndexInternalID) (bool, error) {
+	filter := s.filters[i]
+	if filter == nil {
+		return true, nil
+	}
+	if s.filterDone[i] {
+		return false, nil
+	}
+	if s.filterIDs[i] == nil || s.filterIDs[i].Compare(ID) < 0 {
+		next, err := filter.Advance(ctx, ID)
+		if err != nil {
+			return false, err
+		}
+		if next == nil {
+			s.filterDone[i] = true
+			return false, nil
+		}
+		s.filterIDs[i] = append(s.filterIDs[i][:0], next.IndexInternalID...)
+		ctx.DocumentMatchPool.Put(next)
+	}
+	return s.filterIDs[i].Equals(ID), nil
+}
+
+func (s *FunctionScoreSearcher) Close() error {
+	err := s.searcher.Close()
+	for _, filter := range s.filters {
+		if filter == nil {
+			continue
+		}
+		ferr := filter.Close()
+		if err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
+func (s *FunctionScoreSearcher) Weight() float64 {
+	return s.searcher.Weight()
+}
+
+func (s *FunctionScoreSearcher) SetQueryNorm(qnorm float64) {
+	s.searcher.SetQueryNorm(qnorm)
+}
+
+func (s *FunctionScoreSearcher) Count() uint64 {
+	return s.searcher.Count()
+}
+
+func (s *FunctionScoreSearcher) Min() int {
+	return 0
+}
+
+func (s *FunctionScoreSearcher) DocumentMatchPoolSize() int {
+	rv := s.searcher.DocumentMatchPoolSize()
+	for _, filter := range s.filters {
+		if filter != nil {
+			rv += filter.DocumentMatchPoolSize()
+		}
+	}
+	return rv
+}
@@ -0,0 +1,110 @@
+package searcher
+
+import (
+	"fmt"
+
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/scorer"
+)
+
+// MatchAllSearcher matches every document of the index with a constant
+// score, in the order of their ids.  It walks the back index, which the
+// stores read page by page, resuming each page after the last id read,
+// so listing a large index holds no more than a page at a time.
+type MatchAllSearcher struct {
+	indexReader index.IndexReader
+	reader      index.DocIDReader
+	scorer      *scorer.ConstantScorer
+	count       uint64
+	after       index.IndexInternalID
+	started     bool
+}
+
+func NewMatchAllSearcher(indexReader index.IndexReader, boost float64, options search.SearcherOptions) (*MatchAllSearcher, error) {
+	return NewMatchAllSearcherAfter(indexReader, nil, boost, options)
+}
+
+// NewMatchAllSearcherAfter returns a MatchAllSearcher resuming after
+// the document after, the last one of a previous listing, nil to start
+// from the first document
+func NewMatchAllSearcherAfter(indexReader index.IndexReader, after index.IndexInternalID, boost float64, options search.SearcherOptions) (*MatchAllSearcher, error) {
+	docIDIndexReader, ok := indexReader.(index.DocIDIndexReader)
+	if !ok {
+		return nil, fmt.Errorf("match all searcher requires an index reader enumerating its documents")
+	}
+	reader, err := docIDIndexReader.DocIDReaderAll()
+	if err != nil {
+		return nil, err
+	}
+	count, err := indexReader.DocCount()
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	rv := &MatchAllSearcher{
+		indexReader: indexReader,
+		reader:      reader,
+		scorer:      scorer.NewConstantScorer(1.0, boost, options),
+		count:       count,
+	}
+	if len(after) > 0 {
+		// the smallest id following after
+		rv.after = append(append(index.IndexInternalID(nil), after...), 0)
+	}
+	return rv, nil
+}
+
+func (s *MatchAllSearcher) Count() uint64 {
+	return s.count
+}
+
+func (s *MatchAllSearcher) Weight() float64 {
+	return s.scorer.Weight()
+}
+
+func (s *MatchAllSearcher) SetQueryNorm(qnorm float64) {
+	s.scorer.SetQueryNorm(qnorm)
+}
+
+func (s *MatchAllSearcher) Next(ctx *search.SearchContext) (*search.DocumentMatch, error) {
+	if !s.started && s.after != nil {
+		return s.Advance(ctx, s.after)
+	}
+	s.started = true
+	id, err := s.reader.Next()
+	if err != nil {
+		return nil, err
+	}
+	if id == nil {
+		return nil, nil
+	}
+	return s.scorer.Score(ctx, id), nil
+}
+
+func (s *MatchAllSearcher) Advance(ctx *search.SearchContext, ID index.IndexInternalID) (*search.DocumentMatch, error) {
+	if s.after != nil && ID.Compare(s.after) < 0 {
+		ID = s.after
+	}
+	s.started = true
+	id, err := s.reader.Advance(ID)
+	if err != nil {
+		return nil, err
+	}
+	if id == nil {
+		return nil, nil
+	}
+	return s.scorer.Score(ctx, id), nil
+}
+
+func (s *MatchAllSearcher) Close() error {
+	return s.reader.Close()
+}
+
+func (s *MatchAllSearcher) Min() int {
+	return 0
+}
+
+func (s *MatchAllSearcher) DocumentMatchPoolSize() int {
+	return 1
+}
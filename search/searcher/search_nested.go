@@ -0,0 +1,232 @@
+package searcher
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/search"
+)
+
+// The score modes of a NestedSearcher, how the scores of the nested
+// documents matching are combined into the score of their parent
+const (
+	NestedScoreAvg = "avg"
+	NestedScoreMax = "max"
+	NestedScoreMin = "min"
+	NestedScoreSum = "sum"
+)
+
+// NestedSearcher joins the nested documents of a path matched by
+// another searcher back to their parent document.  Nested documents
+// sort right after their parent, so the matches of the nested
+// documents of a parent are read in a row.
+type NestedSearcher struct {
+	indexReader index.IndexReader
+	searcher    search.Searcher
+	path        string
+	scoreMode   string
+	boost       float64
+	innerHits   int
+	options     search.SearcherOptions
+	// pending is the first match read of the next parent
+	pending *search.DocumentMatch
+}
+
+// NewNestedSearcher returns a searcher matching the parents of the
+// nested documents of the path the searcher matches, scored from their
+// scores by the score mode.  Up to innerHits of the best nested
+// documents matching are returned as inner hits of each parent.
+func NewNestedSearcher(indexReader index.IndexReader, searcher search.Searcher, path, scoreMode string, boost float64, innerHits int, options search.SearcherOptions) (*NestedSearcher, error) {
+	switch scoreMode {
+	case "":
+		scoreMode = NestedScoreAvg
+	case NestedScoreAvg, NestedScoreMax, NestedScoreMin, NestedScoreSum:
+	default:
+		return nil, fmt.Errorf("unknown nested score mode: '%s'", scoreMode)
+	}
+	return &NestedSearcher{
+		indexReader: indexReader,
+		searcher:    searcher,
+		path:        path,
+		scoreMode:   scoreMode,
+		boost:       boost,
+		innerHits:   innerHits,
+		options:     options,
+	}, nil
+}
+
+func (s *NestedSearcher) Next(ctx *search.SearchContext) (*search.DocumentMatch, error) {
+	return s.join(ctx, nil)
+}
+
+func (s *NestedSearcher) Advance(ctx *search.SearchContext, ID index.IndexInternalID) (*search.DocumentMatch, error) {
+	return s.join(ctx, ID)
+}
+
+// nextNested returns the next match of a nested document at or after
+// min, nil for no minimum
+func (s *NestedSearcher) nextNested(ctx *search.SearchContext, min index.IndexInternalID) (*search.DocumentMatch, error) {
+	if s.pending != nil {
+		next := s.pending
+		s.pending = nil
+		if min == nil || next.IndexInternalID.Compare(min) >= 0 {
+			return next, nil
+		}
+		ctx.DocumentMatchPool.Put(next)
+	}
+	if min != nil {
+		return s.searcher.Advance(ctx, min)
+	}
+	return s.searcher.Next(ctx)
+}
+
+// join reads the matches of the nested documents of the next parent at
+// or after min and returns the match of the parent
+func (s *NestedSearcher) join(ctx *search.SearchContext, min index.IndexInternalID) (*search.DocumentMatch, error) {
+	target := min
+	var parent index.IndexInternalID
+	var parentID string
+	var count int
+	var sum, max, low float64
+	var expls []*search.Explanation
+	var innerHits search.DocumentMatchCollection
+
+	for {
+		next, err := s.nextNested(ctx, min)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			break
+		}
+		// the nested documents are all at or after min from now on
+		min = nil
+
+		id, err := s.indexReader.ExternalID(next.IndexInternalID)
+		if err != nil {
+			ctx.DocumentMatchPool.Put(next)
+			return nil, err
+		}
+		nextParentID, path, offset, ok := document.ParseNestedID(id)
+		if !ok || path != s.path {
+			ctx.DocumentMatchPool.Put(next)
+			continue
+		}
+		if parent == nil {
+			nextParent, err := s.indexReader.InternalID(nextParentID)
+			if err != nil {
+				ctx.DocumentMatchPool.Put(next)
+				return nil, err
+			}
+			if target != nil && nextParent.Compare(target) < 0 {
+				// a nested document after the parent advanced to
+				ctx.DocumentMatchPool.Put(next)
+				continue
+			}
+			parent = nextParent
+			parentID = nextParentID
+		} else if nextParentID != parentID {
+			s.pending = next
+			break
+		}
+
+		count++
+		sum += next.Score
+		if count == 1 || next.Score > max {
+			max = next.Score
+		}
+		if count == 1 || next.Score < low {
+			low = next.Score
+		}
+		if s.options.Explain {
+			expls = append(expls, next.Expl)
+		}
+		if s.innerHits > 0 {
+			innerHits = append(innerHits, &search.DocumentMatch{
+				ID:        parentID,
+				Score:     next.Score,
+				Expl:      next.Expl,
+				Locations: next.Locations,
+				InnerHits: next.InnerHits,
+				Nested: &search.NestedIdentity{
+					Path:   path,
+					Offset: offset,
+				},
+			})
+		}
+		ctx.DocumentMatchPool.Put(next)
+	}
+	if parent == nil {
+		return nil, nil
+	}
+
+	var score float64
+	switch s.scoreMode {
+	case NestedScoreMax:
+		score = max
+	case NestedScoreMin:
+		score = low
+	case NestedScoreSum:
+		score = sum
+	default:
+		score = sum / float64(count)
+	}
+
+	rv := ctx.DocumentMatchPool.Get()
+	rv.IndexInternalID = append(rv.IndexInternalID, parent...)
+	rv.Score = score * s.boost
+	if s.options.Explain {
+		rv.Expl = &search.Explanation{
+			Value:    score,
+			Message:  fmt.Sprintf("%s of %d nested documents of %s, of:", s.scoreMode, count, s.path),
+			Children: expls,
+		}
+		if s.boost != 1 {
+			rv.Expl = &search.Explanation{
+				Value:   rv.Score,
+				Message: "product of:",
+				Children: []*search.Explanation{
+					{Value: s.boost, Message: "boost"},
+					rv.Expl,
+				},
+			}
+		}
+	}
+	if len(innerHits) > 0 {
+		sort.Stable(innerHits)
+		if len(innerHits) > s.innerHits {
+			innerHits = innerHits[:s.innerHits]
+		}
+		rv.InnerHits = map[string]search.DocumentMatchCollection{
+			s.path: innerHits,
+		}
+	}
+	return rv, nil
+}
+
+func (s *NestedSearcher) Close() error {
+	return s.searcher.Close()
+}
+
+func (s *NestedSearcher) Weight() float64 {
+	return s.searcher.Weight()
+}
+
+func (s *NestedSearcher) SetQueryNorm(qnorm float64) {
+	s.searcher.SetQueryNorm(qnorm)
+}
+
+func (s *NestedSearcher) Count() uint64 {
+	return s.searcher.Count()
+}
+
+func (s *NestedSearcher) Min() int {
+	return 0
+}
+
+func (s *NestedSearcher) DocumentMatchPoolSize() int {
+	// the match of the parent and the first of the next one
+	return s.searcher.DocumentMatchPoolSize() + 2
+}
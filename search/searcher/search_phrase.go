@@ -30,18 +30,36 @@ type PhraseSearcher struct {
 	slop         int
 	terms        [][]string
 	initialized  bool
+	options      search.SearcherOptions
 }
 
 func NewPhraseSearcher(indexReader index.IndexReader, terms []string, field string, options search.SearcherOptions) (*PhraseSearcher, error) {
+	return NewSloppyPhraseSearcher(indexReader, terms, field, 0, options)
+}
+
+// NewSloppyPhraseSearcher returns a searcher matching the terms in
+// order, or out of order and apart by up to slop moves of the terms.
+// The score of a match is divided by one more than the moves of its
+// closest phrase.
+func NewSloppyPhraseSearcher(indexReader index.IndexReader, terms []string, field string, slop int, options search.SearcherOptions) (*PhraseSearcher, error) {
 	// turn flat terms []string into [][]string
 	mterms := make([][]string, len(terms))
 	for i, term := range terms {
 		mterms[i] = []string{term}
 	}
-	return NewMultiPhraseSearcher(indexReader, mterms, field, options)
+	return NewSloppyMultiPhraseSearcher(indexReader, mterms, field, slop, options)
 }
 
 func NewMultiPhraseSearcher(indexReader index.IndexReader, terms [][]string, field string, options search.SearcherOptions) (*PhraseSearcher, error) {
+	return NewSloppyMultiPhraseSearcher(indexReader, terms, field, 0, options)
+}
+
+// NewSloppyMultiPhraseSearcher is NewSloppyPhraseSearcher where each
+// position in the phrase may be satisfied by any of a list of terms
+func NewSloppyMultiPhraseSearcher(indexReader index.IndexReader, terms [][]string, field string, slop int, options search.SearcherOptions) (*PhraseSearcher, error) {
+	if slop < 0 {
+		return nil, fmt.Errorf("phrase searcher slop cannot be negative: %d", slop)
+	}
 	options.IncludeTermVectors = true
 	var termPositionSearchers []search.Searcher
 	for _, termPos := range terms {
@@ -98,7 +116,9 @@ func NewMultiPhraseSearcher(indexReader index.IndexReader, terms [][]string, fie
 	rv := PhraseSearcher{
 		indexReader:  indexReader,
 		mustSearcher: mustSearcher,
+		slop:         slop,
 		terms:        terms,
+		options:      options,
 	}
 	rv.computeQueryNorm()
 	return &rv, nil
@@ -184,16 +204,20 @@ func (s *PhraseSearcher) Next(ctx *search.SearchContext) (*search.DocumentMatch,
 func (s *PhraseSearcher) checkCurrMustMatch(ctx *search.SearchContext) *search.DocumentMatch {
 	rvftlm := make(search.FieldTermLocationMap, 0)
 	freq := 0
+	minSlop := -1
 	// typically we would expect there to only actually be results in
 	// one field, but we allow for this to not be the case
 	// but, we note that phrase constraints can only be satisfied within
 	// a single field, so we can check them each independently
 	for field, tlm := range s.currMust.Locations {
 
-		f, rvtlm := s.checkCurrMustMatchField(ctx, tlm)
+		f, rvtlm, fieldSlop := s.checkCurrMustMatchField(ctx, tlm)
 		if f > 0 {
 			freq += f
 			rvftlm[field] = rvtlm
+			if minSlop < 0 || fieldSlop < minSlop {
+				minSlop = fieldSlop
+			}
 		}
 	}
 
@@ -201,6 +225,9 @@ func (s *PhraseSearcher) checkCurrMustMatch(ctx *search.SearchContext) *search.D
 		// return match
 		rv := s.currMust
 		rv.Locations = rvftlm
+		if minSlop > 0 {
+			s.decayScore(rv, minSlop)
+		}
 		return rv
 	}
 
@@ -211,14 +238,37 @@ func (s *PhraseSearcher) checkCurrMustMatch(ctx *search.SearchContext) *search.D
 // field within the currMust DocumentMatch Locations satisfies the phase
 // constraints (possibly more than once).  if so, the number of times it was
 // satisfied, and these locations are returned.  otherwise 0 and either
-// a nil or empty TermLocationMap
-func (s *PhraseSearcher) checkCurrMustMatchField(ctx *search.SearchContext, tlm search.TermLocationMap) (int, search.TermLocationMap) {
-	paths := findPhrasePaths(0, nil, s.terms, tlm, nil, 0)
+// a nil or empty TermLocationMap.  the least slop used by the paths
+// found is returned too
+func (s *PhraseSearcher) checkCurrMustMatchField(ctx *search.SearchContext, tlm search.TermLocationMap) (int, search.TermLocationMap, int) {
+	paths := findPhrasePaths(0, nil, s.terms, tlm, nil, s.slop)
 	rv := make(search.TermLocationMap, len(s.terms))
-	for _, p := range paths {
+	minSlop := 0
+	for i, p := range paths {
 		p.MergeInto(rv)
+		pathSlop := phrasePathSlop(p, s.terms)
+		if i == 0 || pathSlop < minSlop {
+			minSlop = pathSlop
+		}
+	}
+	return len(paths), rv, minSlop
+}
+
+// decayScore divides the score of a match whose closest phrase used
+// slop by one more than that slop
+func (s *PhraseSearcher) decayScore(dm *search.DocumentMatch, slop int) {
+	factor := 1.0 / float64(1+slop)
+	dm.Score *= factor
+	if s.options.Explain {
+		dm.Expl = &search.Explanation{
+			Value:   dm.Score,
+			Message: "product of:",
+			Children: []*search.Explanation{
+				{Value: factor, Message: fmt.Sprintf("sloppy phrase, slop %d", slop)},
+				dm.Expl,
+			},
+		}
 	}
-	return len(paths), rv
 }
 
 type phrasePart struct {
@@ -298,6 +348,30 @@ func findPhrasePaths(prevPos uint64, ap search.ArrayPositions, phraseTerms [][]s
 	return rv
 }
 
+// phrasePathSlop returns the slop the path used to match the phrase
+// terms, measured like findPhrasePaths does
+func phrasePathSlop(p phrasePath, phraseTerms [][]string) int {
+	slop := 0
+	var prevPos uint64
+	for _, car := range phraseTerms {
+		if len(car) == 0 || (len(car) == 1 && car[0] == "") {
+			if prevPos != 0 {
+				prevPos++
+			}
+			continue
+		}
+		if len(p) < 1 {
+			break
+		}
+		if prevPos != 0 {
+			slop += editDistance(prevPos+1, p[0].loc.Pos)
+		}
+		prevPos = p[0].loc.Pos
+		p = p[1:]
+	}
+	return slop
+}
+
 func editDistance(p1, p2 uint64) int {
 	dist := int(p1 - p2)
 	if dist < 0 {
@@ -464,6 +464,58 @@ func TestFindPhrasePathsSloppy(t *testing.T) {
 	}
 }
 
+func TestPhrasePathSlop(t *testing.T) {
+	tests := []struct {
+		phrase [][]string
+		path   phrasePath
+		slop   int
+	}{
+		// exact
+		{
+			phrase: [][]string{[]string{"one"}, []string{"two"}},
+			path: phrasePath{
+				&phrasePart{"one", &search.Location{Pos: 1}},
+				&phrasePart{"two", &search.Location{Pos: 2}},
+			},
+			slop: 0,
+		},
+		// one apart
+		{
+			phrase: [][]string{[]string{"one"}, []string{"three"}},
+			path: phrasePath{
+				&phrasePart{"one", &search.Location{Pos: 1}},
+				&phrasePart{"three", &search.Location{Pos: 3}},
+			},
+			slop: 1,
+		},
+		// reversed
+		{
+			phrase: [][]string{[]string{"two"}, []string{"one"}},
+			path: phrasePath{
+				&phrasePart{"two", &search.Location{Pos: 2}},
+				&phrasePart{"one", &search.Location{Pos: 1}},
+			},
+			slop: 2,
+		},
+		// placeholder in between
+		{
+			phrase: [][]string{[]string{"one"}, []string{""}, []string{"four"}},
+			path: phrasePath{
+				&phrasePart{"one", &search.Location{Pos: 1}},
+				&phrasePart{"four", &search.Location{Pos: 4}},
+			},
+			slop: 1,
+		},
+	}
+
+	for i, test := range tests {
+		actual := phrasePathSlop(test.path, test.phrase)
+		if actual != test.slop {
+			t.Errorf("expected slop %d got %d for test %d", test.slop, actual, i)
+		}
+	}
+}
+
 func TestFindPhrasePathsSloppyPalyndrome(t *testing.T) {
 	tlm := search.TermLocationMap{
 		"one": search.Locations{
@@ -15,6 +15,8 @@
 package searcher
 
 import (
+	"math"
+
 	"github.com/wrble/flock/index"
 	"github.com/wrble/flock/search"
 	"github.com/wrble/flock/search/scorer"
@@ -25,10 +27,21 @@ type TermSearcher struct {
 	reader      index.TermFieldReader
 	scorer      *scorer.TermQueryScorer
 	tfd         index.TermFieldDoc
+	term        []byte
+	field       string
+	boost       float64
+	options     search.SearcherOptions
+	bestFirst   bool
+	minScore    float64
+	truncated   bool
 }
 
 func NewTermSearcher(indexReader index.IndexReader, term string, field string, boost float64, options search.SearcherOptions) (*TermSearcher, error) {
-	reader, err := indexReader.TermFieldReader([]byte(term), field, true, true, options.IncludeTermVectors)
+	return NewTermSearcherBytes(indexReader, []byte(term), field, boost, options)
+}
+
+func NewTermSearcherBytes(indexReader index.IndexReader, term []byte, field string, boost float64, options search.SearcherOptions) (*TermSearcher, error) {
+	reader, err := indexReader.TermFieldReader(term, field, true, true, options.IncludeTermVectors)
 	if err != nil {
 		return nil, err
 	}
@@ -37,32 +50,54 @@ func NewTermSearcher(indexReader index.IndexReader, term string, field string, b
 		_ = reader.Close()
 		return nil, err
 	}
-	scorer := scorer.NewTermQueryScorer([]byte(term), field, boost, count, reader.Count(), options)
-	return &TermSearcher{
-		indexReader: indexReader,
-		reader:      reader,
-		scorer:      scorer,
-	}, nil
-}
-
-func NewTermSearcherBytes(indexReader index.IndexReader, term []byte, field string, boost float64, options search.SearcherOptions) (*TermSearcher, error) {
-	reader, err := indexReader.TermFieldReader(term, field, true, true, options.IncludeTermVectors)
+	adl, err := averageFieldLength(indexReader, field)
 	if err != nil {
+		_ = reader.Close()
 		return nil, err
 	}
-	count, err := indexReader.DocCount()
+	maxBoost, err := maxFieldBoost(indexReader, field)
 	if err != nil {
 		_ = reader.Close()
 		return nil, err
 	}
 	scorer := scorer.NewTermQueryScorer(term, field, boost, count, reader.Count(), options)
+	scorer.SetAverageFieldLength(adl)
+	scorer.SetMaxFieldBoost(maxBoost)
 	return &TermSearcher{
 		indexReader: indexReader,
 		reader:      reader,
 		scorer:      scorer,
+		term:        term,
+		field:       field,
+		boost:       boost,
+		options:     options,
 	}, nil
 }
 
+// averageFieldLength returns the average length of the field, 0 when
+// the index does not keep field lengths
+func averageFieldLength(indexReader index.IndexReader, field string) (float64, error) {
+	lengthReader, ok := indexReader.(index.FieldLengthReader)
+	if !ok {
+		return 0, nil
+	}
+	total, docs, err := lengthReader.FieldLengths(field)
+	if err != nil || docs == 0 {
+		return 0, err
+	}
+	return float64(total) / float64(docs), nil
+}
+
+// maxFieldBoost returns the highest index time boost of the field, 1
+// when the index does not keep boosts
+func maxFieldBoost(indexReader index.IndexReader, field string) (float64, error) {
+	boostReader, ok := indexReader.(index.FieldBoostReader)
+	if !ok {
+		return 1.0, nil
+	}
+	return boostReader.MaxFieldBoost(field)
+}
+
 func (s *TermSearcher) Count() uint64 {
 	return s.reader.Count()
 }
@@ -76,6 +111,9 @@ func (s *TermSearcher) SetQueryNorm(qnorm float64) {
 }
 
 func (s *TermSearcher) Next(ctx *search.SearchContext) (*search.DocumentMatch, error) {
+	if s.truncated {
+		return nil, nil
+	}
 	termMatch, err := s.reader.Next(s.tfd.Reset())
 	if err != nil {
 		return nil, err
@@ -85,8 +123,16 @@ func (s *TermSearcher) Next(ctx *search.SearchContext) (*search.DocumentMatch, e
 		return nil, nil
 	}
 
+	// in best first order no later match scores higher than the
+	// bound of this one
+	if s.bestFirst && s.scorer.UpperBound(termMatch.Freq) <= s.minScore {
+		s.truncated = true
+		return nil, nil
+	}
+
 	// score match
 	docMatch := s.scorer.Score(ctx, termMatch)
+
 	// return doc match
 	return docMatch, nil
 
@@ -109,6 +155,43 @@ func (s *TermSearcher) Advance(ctx *search.SearchContext, ID index.IndexInternal
 	return docMatch, nil
 }
 
+// BestFirst swaps the term field reader for one walking the postings
+// by descending pre score, which the upper bound of the term scorer
+// does not decrease with as long as the boost is positive
+func (s *TermSearcher) BestFirst() (bool, error) {
+	if s.bestFirst {
+		return true, nil
+	}
+	indexReader, ok := s.indexReader.(index.ScoreOrderedIndexReader)
+	if !ok || s.boost <= 0 {
+		return false, nil
+	}
+	reader, err := indexReader.ScoreOrderedTermFieldReader(s.term, s.field, true, true, s.options.IncludeTermVectors)
+	if err == index.ErrorScoreOrderUnsupported {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	err = s.reader.Close()
+	if err != nil {
+		_ = reader.Close()
+		return false, err
+	}
+	s.reader = reader
+	s.bestFirst = true
+	s.minScore = math.Inf(-1)
+	return true, nil
+}
+
+func (s *TermSearcher) SetMinScore(min float64) {
+	s.minScore = min
+}
+
+func (s *TermSearcher) Truncated() bool {
+	return s.truncated
+}
+
 func (s *TermSearcher) Close() error {
 	return s.reader.Close()
 }
@@ -21,6 +21,7 @@ import (
 	"github.com/wrble/flock/document"
 	"github.com/wrble/flock/index"
 	"github.com/wrble/flock/index/store/cassandra"
+	"github.com/wrble/flock/index/store/memory"
 	"github.com/wrble/flock/index/upsidedown"
 	"github.com/wrble/flock/search"
 )
@@ -213,3 +214,99 @@ func TestTermSearcher(t *testing.T) {
 		t.Errorf("expected nil, got %v", docMatch)
 	}
 }
+
+func TestTermSearcherBestFirst(t *testing.T) {
+	analysisQueue := index.NewAnalysisQueue(1)
+	i, err := upsidedown.NewUpsideDownCouch(memory.Name, nil, analysisQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = i.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := i.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// the pre score of beer is its share of the distinct terms
+	docs := map[string][]string{
+		"a": {"beer", "wine", "water", "juice"},
+		"b": {"beer"},
+		"c": {"beer", "beer", "wine"},
+		"d": {"wine"},
+		"e": {"beer", "wine"},
+	}
+	for id, desc := range docs {
+		doc := document.NewDocument(id)
+		for pos, term := range desc {
+			doc.AddField(document.NewTextField("desc", []uint64{uint64(pos)}, []byte(term)))
+		}
+		err = i.Update(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	indexReader, err := i.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := indexReader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	searcher, err := NewTermSearcher(indexReader, "beer", "desc", 1.0, search.SearcherOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := searcher.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ok, err := searcher.BestFirst()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected the memory store to support best first")
+	}
+
+	ctx := &search.SearchContext{
+		DocumentMatchPool: search.NewDocumentMatchPool(searcher.DocumentMatchPoolSize(), 0),
+	}
+	var ids []string
+	var scores []float64
+	next, err := searcher.Next(ctx)
+	for err == nil && next != nil {
+		id, err := indexReader.ExternalID(next.IndexInternalID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+		scores = append(scores, next.Score)
+		if len(ids) == 1 {
+			searcher.SetMinScore(next.Score)
+		}
+		ctx.DocumentMatchPool.Put(next)
+		next, err = searcher.Next(ctx)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	// c shares the pre score of b but its longer field scores lower, e
+	// could still beat b in a short field, a could not in any
+	if len(ids) != 3 || ids[0] != "b" || ids[1] != "c" || ids[2] != "e" {
+		t.Fatalf("expected b, c then e, got %v", ids)
+	}
+	if scores[1] >= scores[0] {
+		t.Errorf("expected c to score lower than b, got %v", scores)
+	}
+	if !searcher.Truncated() {
+		t.Errorf("expected the search to stop before a")
+	}
+}
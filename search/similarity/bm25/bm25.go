@@ -0,0 +1,34 @@
+package bm25
+
+import (
+	"fmt"
+
+	"github.com/wrble/flock/registry"
+	"github.com/wrble/flock/search/scorer"
+)
+
+const Name = "bm25"
+
+// Constructor builds a BM 25 similarity, the optional "k1" and "b"
+// settings default to scorer.DefaultBM25K1 and scorer.DefaultBM25B
+func Constructor(config map[string]interface{}, cache *registry.Cache) (scorer.Similarity, error) {
+	k1 := scorer.DefaultBM25K1
+	if val, ok := config["k1"].(float64); ok {
+		k1 = val
+	}
+	if k1 < 0 {
+		return nil, fmt.Errorf("bm25 k1 must not be negative, got %f", k1)
+	}
+	b := scorer.DefaultBM25B
+	if val, ok := config["b"].(float64); ok {
+		b = val
+	}
+	if b < 0 || b > 1 {
+		return nil, fmt.Errorf("bm25 b must be between 0 and 1, got %f", b)
+	}
+	return scorer.NewBM25Similarity(k1, b), nil
+}
+
+func init() {
+	registry.RegisterSimilarity(Name, Constructor)
+}
@@ -0,0 +1,16 @@
+package classic
+
+import (
+	"github.com/wrble/flock/registry"
+	"github.com/wrble/flock/search/scorer"
+)
+
+const Name = "classic"
+
+func Constructor(config map[string]interface{}, cache *registry.Cache) (scorer.Similarity, error) {
+	return scorer.NewClassicSimilarity(), nil
+}
+
+func init() {
+	registry.RegisterSimilarity(Name, Constructor)
+}
@@ -0,0 +1,27 @@
+package dfr
+
+import (
+	"fmt"
+
+	"github.com/wrble/flock/registry"
+	"github.com/wrble/flock/search/scorer"
+)
+
+const Name = "dfr"
+
+// Constructor builds a DFR similarity, the optional "c" setting
+// defaults to scorer.DefaultDFRC
+func Constructor(config map[string]interface{}, cache *registry.Cache) (scorer.Similarity, error) {
+	c := scorer.DefaultDFRC
+	if val, ok := config["c"].(float64); ok {
+		c = val
+	}
+	if c <= 0 {
+		return nil, fmt.Errorf("dfr c must be positive, got %f", c)
+	}
+	return scorer.NewDFRSimilarity(c), nil
+}
+
+func init() {
+	registry.RegisterSimilarity(Name, Constructor)
+}
@@ -0,0 +1,27 @@
+package lmdirichlet
+
+import (
+	"fmt"
+
+	"github.com/wrble/flock/registry"
+	"github.com/wrble/flock/search/scorer"
+)
+
+const Name = "lm_dirichlet"
+
+// Constructor builds a Dirichlet language model similarity, the
+// optional "mu" setting defaults to scorer.DefaultLMDirichletMu
+func Constructor(config map[string]interface{}, cache *registry.Cache) (scorer.Similarity, error) {
+	mu := scorer.DefaultLMDirichletMu
+	if val, ok := config["mu"].(float64); ok {
+		mu = val
+	}
+	if mu <= 0 {
+		return nil, fmt.Errorf("lm_dirichlet mu must be positive, got %f", mu)
+	}
+	return scorer.NewLMDirichletSimilarity(mu), nil
+}
+
+func init() {
+	registry.RegisterSimilarity(Name, Constructor)
+}
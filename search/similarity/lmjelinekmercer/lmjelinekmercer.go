@@ -0,0 +1,28 @@
+package lmjelinekmercer
+
+import (
+	"fmt"
+
+	"github.com/wrble/flock/registry"
+	"github.com/wrble/flock/search/scorer"
+)
+
+const Name = "lm_jelinek_mercer"
+
+// Constructor builds a Jelinek-Mercer language model similarity, the
+// optional "lambda" setting defaults to
+// scorer.DefaultLMJelinekMercerLambda
+func Constructor(config map[string]interface{}, cache *registry.Cache) (scorer.Similarity, error) {
+	lambda := scorer.DefaultLMJelinekMercerLambda
+	if val, ok := config["lambda"].(float64); ok {
+		lambda = val
+	}
+	if lambda <= 0 || lambda > 1 {
+		return nil, fmt.Errorf("lm_jelinek_mercer lambda must be in (0, 1], got %f", lambda)
+	}
+	return scorer.NewLMJelinekMercerSimilarity(lambda), nil
+}
+
+func init() {
+	registry.RegisterSimilarity(Name, Constructor)
+}
@@ -18,11 +18,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/wrble/flock/search"
+	"github.com/wrble/flock/search/query"
+	"github.com/wrble/flock/search/scorer"
 )
 
 func TestSearchResultString(t *testing.T) {
@@ -326,3 +329,612 @@ func TestFacetNumericDateRangeRequests(t *testing.T) {
 	}
 
 }
+
+func TestSloppyPhraseSearch(t *testing.T) {
+	index, err := NewTempIndex(NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	docs := map[string]string{
+		"a": "quick brown fox",
+		"b": "brown quick fox",
+		"c": "quick red brown fox",
+		"d": "brown fox",
+	}
+	for id, desc := range docs {
+		err = index.Index(id, map[string]interface{}{"desc": desc})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query    query.Query
+		expected []string
+	}{
+		{
+			query: func() query.Query {
+				q := NewMatchPhraseQuery("quick brown")
+				q.SetField("desc")
+				return q
+			}(),
+			expected: []string{"a"},
+		},
+		{
+			query: func() query.Query {
+				q := NewMatchPhraseQuery("quick brown")
+				q.SetField("desc")
+				q.SetSlop(1)
+				return q
+			}(),
+			expected: []string{"a", "c"},
+		},
+		// the swapped terms take two moves, and the more moves the lower
+		// the score
+		{
+			query: func() query.Query {
+				q := NewPhraseQuery([]string{"quick", "brown"}, "desc")
+				q.SetSlop(2)
+				return q
+			}(),
+			expected: []string{"a", "c", "b"},
+		},
+		{
+			query:    NewQueryStringQuery(`desc:"quick brown"~2`),
+			expected: []string{"a", "c", "b"},
+		},
+	}
+
+	for i, test := range tests {
+		req := NewSearchRequest(test.query)
+		req.Explain = true
+		res, err := index.Search(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, hit := range res.Hits {
+			ids = append(ids, hit.ID)
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("test %d: expected %v, got %v", i, test.expected, ids)
+		}
+		for _, hit := range res.Hits {
+			if hit.Expl == nil || hit.Expl.Value != hit.Score {
+				t.Errorf("test %d: expected the explanation of %s to add up to its score %f, got %v", i, hit.ID, hit.Score, hit.Expl)
+			}
+		}
+	}
+}
+
+func matchAllTestIndex(t *testing.T) Index {
+	index, err := NewTempIndex(NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string]map[string]interface{}{
+		"a": {"status": "open", "address": map[string]interface{}{"city": "paris"}},
+		"b": {"status": "closed"},
+		"c": {"status": "open", "email": ""},
+		"d": {"name": "no status"},
+		"e": {"status": "open"},
+	}
+	for id, doc := range docs {
+		err = index.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return index
+}
+
+func searchIDs(t *testing.T, index Index, req *SearchRequest) []string {
+	res, err := index.Search(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, hit := range res.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestMatchAllSearch(t *testing.T) {
+	index := matchAllTestIndex(t)
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// pages of all documents, each resuming after the last hit of the
+	// previous one
+	var pages [][]string
+	after := ""
+	for {
+		q := NewMatchAllQuery()
+		q.SetAfter(after)
+		ids := searchIDs(t, index, NewSearchRequestOptions(q, 2, 0, false))
+		if len(ids) == 0 {
+			break
+		}
+		pages = append(pages, ids)
+		after = ids[len(ids)-1]
+	}
+	expectedPages := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+	if !reflect.DeepEqual(pages, expectedPages) {
+		t.Errorf("expected pages %v, got %v", expectedPages, pages)
+	}
+
+	q := NewMatchAllQuery()
+	res, err := index.Search(NewSearchRequest(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 5 {
+		t.Errorf("expected 5 documents, got %d", res.Total)
+	}
+
+	// must not clauses only exclude their matches from all documents
+	open := NewTermQuery("open")
+	open.SetField("status")
+	tests := []struct {
+		query    query.Query
+		expected []string
+	}{
+		{
+			query: func() query.Query {
+				q := NewBooleanQuery()
+				q.AddMustNot(open)
+				return q
+			}(),
+			expected: []string{"b", "d"},
+		},
+		{
+			query:    NewQueryStringQuery("-status:open"),
+			expected: []string{"b", "d"},
+		},
+		// a filter without a scoring clause
+		{
+			query: func() query.Query {
+				q := NewBooleanQuery()
+				q.AddMust(NewMatchAllQuery(), open)
+				return q
+			}(),
+			expected: []string{"a", "c", "e"},
+		},
+	}
+	for i, test := range tests {
+		req := NewSearchRequest(test.query)
+		req.SortBy([]string{"_id"})
+		ids := searchIDs(t, index, req)
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("test %d: expected %v, got %v", i, test.expected, ids)
+		}
+	}
+}
+
+func TestExistsSearch(t *testing.T) {
+	index := matchAllTestIndex(t)
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	tests := []struct {
+		field    string
+		expected []string
+	}{
+		{
+			field:    "status",
+			expected: []string{"a", "b", "c", "e"},
+		},
+		{
+			field:    "email",
+			expected: []string{"c"},
+		},
+		{
+			field:    "address",
+			expected: []string{"a"},
+		},
+		{
+			field:    "address.city",
+			expected: []string{"a"},
+		},
+		{
+			field:    "missing",
+			expected: []string{},
+		},
+	}
+	for _, test := range tests {
+		req := NewSearchRequest(NewExistsQuery(test.field))
+		req.SortBy([]string{"_id"})
+		ids := searchIDs(t, index, req)
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("field %s: expected %v, got %v", test.field, test.expected, ids)
+		}
+	}
+
+	// a partial update records its fields
+	err := index.UpdateFields("d", map[string]interface{}{"email": "d@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewBooleanQuery()
+	q.AddMustNot(NewExistsQuery("email"))
+	req := NewSearchRequest(q)
+	req.SortBy([]string{"_id"})
+	ids := searchIDs(t, index, req)
+	expected := []string{"a", "b", "e"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v without email, got %v", expected, ids)
+	}
+}
+
+func TestMultiMatchSearch(t *testing.T) {
+	index, err := NewTempIndex(NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	docs := map[string]map[string]interface{}{
+		"ws":   {"first": "will", "last": "smith"},
+		"wj":   {"first": "will", "last": "jones"},
+		"sa":   {"first": "smith", "last": "adams"},
+		"pair": {"first": "anna", "last": "will smith"},
+		"mary": {"first": "mary", "last": "brown"},
+	}
+	for id, doc := range docs {
+		err = index.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query    query.Query
+		expected []string
+		sorted   bool
+	}{
+		// all the tokens in one of the fields
+		{
+			query: func() query.Query {
+				q := NewMultiMatchQuery("will smith", "first", "last")
+				q.SetOperator(query.MatchQueryOperatorAnd)
+				return q
+			}(),
+			expected: []string{"pair"},
+		},
+		// all the tokens in any of the fields
+		{
+			query: func() query.Query {
+				q := NewMultiMatchQuery("will smith", "first", "last")
+				q.SetType(query.MultiMatchCrossFields)
+				q.SetOperator(query.MatchQueryOperatorAnd)
+				return q
+			}(),
+			expected: []string{"pair", "ws"},
+			sorted:   true,
+		},
+		{
+			query: func() query.Query {
+				q := NewMultiMatchQuery("will smith", "first", "last")
+				q.SetType(query.MultiMatchMostFields)
+				return q
+			}(),
+			expected: []string{"pair", "sa", "wj", "ws"},
+			sorted:   true,
+		},
+		// the boost of the field decides
+		{
+			query:    NewMultiMatchQuery("smith", "first^10", "last"),
+			expected: []string{"sa", "ws", "pair"},
+		},
+		{
+			query:    NewMultiMatchQuery("smith", "first", "last^10"),
+			expected: []string{"ws", "pair", "sa"},
+		},
+		{
+			query: func() query.Query {
+				q := NewMultiMatchQuery("smith will", "first", "last")
+				q.SetType(query.MultiMatchPhrase)
+				q.SetSlop(2)
+				return q
+			}(),
+			expected: []string{"pair"},
+		},
+		{
+			query: func() query.Query {
+				q := NewQueryStringQuery(`+will +smith`)
+				q.SetFields("first", "last")
+				return q
+			}(),
+			expected: []string{"pair", "ws"},
+			sorted:   true,
+		},
+		{
+			query: func() query.Query {
+				q := NewQueryStringQuery(`"will smith" last:jones`)
+				q.SetFields("first", "last")
+				return q
+			}(),
+			expected: []string{"pair", "wj"},
+			sorted:   true,
+		},
+	}
+
+	for i, test := range tests {
+		req := NewSearchRequest(test.query)
+		req.Explain = true
+		res, err := index.Search(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, hit := range res.Hits {
+			ids = append(ids, hit.ID)
+			if hit.Expl == nil || hit.Expl.Value != hit.Score {
+				t.Errorf("test %d: expected the explanation of %s to add up to its score %f, got %v", i, hit.ID, hit.Score, hit.Expl)
+			}
+		}
+		if test.sorted {
+			sort.Strings(ids)
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("test %d: expected %v, got %v", i, test.expected, ids)
+		}
+	}
+}
+
+func TestDisMaxSearch(t *testing.T) {
+	index, err := NewTempIndex(NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	docs := map[string]map[string]interface{}{
+		"best":   {"title": "brown fox", "body": "a cat", "tags": "cat"},
+		"spread": {"title": "brown dog", "body": "red fox", "tags": "brown bear"},
+		"none":   {"title": "red dog", "body": "a cat", "tags": "bear"},
+	}
+	for id, doc := range docs {
+		err = index.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fieldQueries := func() []query.Query {
+		var rv []query.Query
+		for _, field := range []string{"title", "body", "tags"} {
+			q := NewMatchQuery("brown fox")
+			q.SetField(field)
+			rv = append(rv, q)
+		}
+		return rv
+	}
+
+	// summing the fields, the document spreading the terms over all
+	// of them wins
+	ids := searchIDs(t, index, NewSearchRequest(NewDisjunctionQuery(fieldQueries()...)))
+	expected := []string{"spread", "best"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v, got %v", expected, ids)
+	}
+
+	// the best field decides
+	q := NewDisMaxQuery(fieldQueries()...)
+	req := NewSearchRequest(q)
+	req.Explain = true
+	res, err := index.Search(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids = []string{}
+	for _, hit := range res.Hits {
+		ids = append(ids, hit.ID)
+		if hit.Expl == nil || hit.Expl.Value != hit.Score {
+			t.Errorf("expected the explanation of %s to add up to its score %f, got %v", hit.ID, hit.Score, hit.Expl)
+		}
+	}
+	expected = []string{"best", "spread"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v, got %v", expected, ids)
+	}
+	if res.Hits[0].Expl.Message != "best of 1 clauses matching:" {
+		t.Errorf("expected the best clause explained, got %v", res.Hits[0].Expl)
+	}
+
+	// the others count for the tie breaker times their scores
+	spread := res.Hits[1].Score
+	q.SetTieBreaker(0.5)
+	res, err = index.Search(NewSearchRequest(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hit := range res.Hits {
+		if hit.ID == "spread" && hit.Score <= spread {
+			t.Errorf("expected the tie breaker to raise the score of spread above %f, got %f", spread, hit.Score)
+		}
+	}
+}
+
+func functionScoreTestIndex(t *testing.T) Index {
+	m := NewIndexMapping()
+	m.DefaultMapping.AddFieldMappingsAt("location", NewGeoPointFieldMapping())
+	index, err := NewTempIndex(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := 24 * time.Hour
+	now := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	docs := map[string]map[string]interface{}{
+		"a": {"kind": "pub", "likes": 10, "opened": now.Add(-30 * day), "location": map[string]interface{}{"lon": -0.12, "lat": 51.5}},
+		"b": {"kind": "pub", "likes": 100, "opened": now.Add(-300 * day), "location": map[string]interface{}{"lon": 2.35, "lat": 48.86}},
+		"c": {"kind": "pub", "likes": 1, "opened": now.Add(-3 * day), "location": map[string]interface{}{"lon": -0.13, "lat": 51.51}},
+		"d": {"kind": "brewery", "likes": 50, "opened": now.Add(-100 * day), "location": map[string]interface{}{"lon": 13.4, "lat": 52.52}},
+	}
+	for id, doc := range docs {
+		err = index.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return index
+}
+
+func TestFunctionScoreSearch(t *testing.T) {
+	index := functionScoreTestIndex(t)
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	pubs := func() query.Query {
+		q := NewTermQuery("pub")
+		q.SetField("kind")
+		return q
+	}
+
+	tests := []struct {
+		query    query.Query
+		expected []string
+	}{
+		// the most liked first
+		{
+			query: func() query.Query {
+				f := query.NewFieldValueFactorFunction("likes")
+				f.FieldValueFactor.SetModifier(scorer.FieldValueModifierLog1p)
+				return NewFunctionScoreQuery(pubs(), f)
+			}(),
+			expected: []string{"b", "a", "c"},
+		},
+		// the most recently opened first
+		{
+			query: NewFunctionScoreQuery(pubs(),
+				query.NewDecayFunction(scorer.DecayGauss, "opened", "2017-06-01T00:00:00Z", "30d")),
+			expected: []string{"c", "a", "b"},
+		},
+		{
+			query: NewFunctionScoreQuery(NewMatchAllQuery(),
+				query.NewDecayFunction(scorer.DecayLinear, "likes", 40, 20)),
+			expected: []string{"d", "a", "c", "b"},
+		},
+		// the closest to london first, the pubs further than 500km
+		// scoring 0
+		{
+			query: func() query.Query {
+				f := query.NewDecayFunction(scorer.DecayExp, "location", []float64{-0.12, 51.5}, "100km")
+				f.Decay.SetOffset("1km")
+				return NewFunctionScoreQuery(NewMatchAllQuery(), f)
+			}(),
+			expected: []string{"a", "c", "b", "d"},
+		},
+		// the weight of the filter matching replaces the score
+		{
+			query: func() query.Query {
+				brewery := NewTermQuery("brewery")
+				brewery.SetField("kind")
+				bf := query.NewWeightFunction(5)
+				bf.SetFilter(brewery)
+				lf := query.NewFieldValueFactorFunction("likes")
+				q := NewFunctionScoreQuery(NewMatchAllQuery(), bf, lf)
+				q.SetScoreMode(scorer.FunctionScoreModeFirst)
+				q.SetBoostMode(scorer.FunctionBoostModeReplace)
+				return q
+			}(),
+			expected: []string{"b", "a", "d", "c"},
+		},
+		{
+			query: func() query.Query {
+				lf := query.NewFieldValueFactorFunction("likes")
+				q := NewFunctionScoreQuery(NewMatchAllQuery(), lf)
+				q.SetMaxBoost(20)
+				q.SetBoostMode(scorer.FunctionBoostModeReplace)
+				return q
+			}(),
+			// b and d capped at 20 keep the order of the documents
+			expected: []string{"b", "d", "a", "c"},
+		},
+	}
+
+	for i, test := range tests {
+		req := NewSearchRequest(test.query)
+		req.Explain = true
+		res, err := index.Search(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, hit := range res.Hits {
+			ids = append(ids, hit.ID)
+			if hit.Expl == nil || hit.Expl.Value != hit.Score {
+				t.Errorf("test %d: expected the explanation of %s to add up to its score %f, got %v", i, hit.ID, hit.Score, hit.Expl)
+			}
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("test %d: expected %v, got %v", i, test.expected, ids)
+		}
+	}
+}
+
+func TestFunctionScoreRandomSearch(t *testing.T) {
+	index := functionScoreTestIndex(t)
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	scores := func(seed int64) map[string]float64 {
+		q := NewFunctionScoreQuery(NewMatchAllQuery(), query.NewRandomScoreFunction(seed))
+		res, err := index.Search(NewSearchRequest(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rv := make(map[string]float64)
+		for _, hit := range res.Hits {
+			if hit.Score < 0 || hit.Score >= 1 {
+				t.Errorf("expected a random score from 0 to 1, got %f", hit.Score)
+			}
+			rv[hit.ID] = hit.Score
+		}
+		return rv
+	}
+	first := scores(42)
+	if len(first) != 4 {
+		t.Fatalf("expected 4 hits, got %v", first)
+	}
+	if again := scores(42); !reflect.DeepEqual(first, again) {
+		t.Errorf("expected the same scores for the same seed, got %v and %v", first, again)
+	}
+	if other := scores(43); reflect.DeepEqual(first, other) {
+		t.Errorf("expected other scores for another seed, got %v", other)
+	}
+}
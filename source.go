@@ -0,0 +1,210 @@
+package flock
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"strings"
+
+	"github.com/wrble/flock/document"
+	"github.com/wrble/flock/index"
+	"github.com/wrble/flock/mapping"
+)
+
+// A SourceFilter selects the properties of the sources of documents
+// returned.  Includes and Excludes are dotted property paths, each part
+// of which can use the wildcards of path.Match.  A property an include
+// matches is kept with all it holds, less what the excludes match.
+// Without includes every property is kept.
+type SourceFilter struct {
+	Includes []string `json:"includes,omitempty"`
+	Excludes []string `json:"excludes,omitempty"`
+}
+
+// NewSourceFilter creates a new SourceFilter keeping
+// the properties included, all of them when none is.
+func NewSourceFilter(includes ...string) *SourceFilter {
+	return &SourceFilter{
+		Includes: includes,
+	}
+}
+
+// ParseSourceFilter parses the source filter of a request, true asks
+// for the whole source, a list of paths for the properties included and
+// an object for a SourceFilter.  No filter, null or false, returns nil.
+func ParseSourceFilter(input json.RawMessage) (*SourceFilter, error) {
+	input = bytes.TrimSpace(input)
+	if len(input) == 0 {
+		return nil, nil
+	}
+	var rv *SourceFilter
+	switch input[0] {
+	case '[':
+		var includes []string
+		err := json.Unmarshal(input, &includes)
+		if err != nil {
+			return nil, err
+		}
+		rv = NewSourceFilter(includes...)
+	case '{':
+		err := json.Unmarshal(input, &rv)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		var enabled bool
+		err := json.Unmarshal(input, &enabled)
+		if err != nil {
+			return nil, err
+		}
+		if enabled {
+			rv = NewSourceFilter()
+		}
+	}
+	return rv, nil
+}
+
+// Filter returns the parts of the source the filter keeps.
+func (f *SourceFilter) Filter(source json.RawMessage) (json.RawMessage, error) {
+	if len(f.Includes) == 0 && len(f.Excludes) == 0 {
+		return source, nil
+	}
+	data, err := document.DecodeSource(source)
+	if err != nil {
+		return nil, err
+	}
+	rv, ok := f.filter(nil, data, len(f.Includes) == 0)
+	if !ok {
+		return json.RawMessage("{}"), nil
+	}
+	return json.Marshal(rv)
+}
+
+// filter returns what the filter keeps of the value at the path, false
+// for nothing, included tells whether an include matched the path or
+// the path of a property holding it
+func (f *SourceFilter) filter(keys []string, value interface{}, included bool) (interface{}, bool) {
+	if len(keys) > 0 {
+		if matchesPath(f.Excludes, keys) {
+			return nil, false
+		}
+		included = included || matchesPath(f.Includes, keys)
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		rv := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			if v, ok := f.filter(append(keys[:len(keys):len(keys)], k), v, included); ok {
+				rv[k] = v
+			}
+		}
+		return rv, included || len(rv) > 0
+	case []interface{}:
+		// the values of arrays are at the path of the array
+		rv := make([]interface{}, 0, len(value))
+		for _, v := range value {
+			if v, ok := f.filter(keys, v, included); ok {
+				rv = append(rv, v)
+			}
+		}
+		return rv, included || len(rv) > 0
+	}
+	return value, included
+}
+
+// matchesPath tells whether one of the patterns matches the path
+func matchesPath(patterns []string, keys []string) bool {
+	for _, pattern := range patterns {
+		parts := strings.Split(pattern, ".")
+		if len(parts) != len(keys) {
+			continue
+		}
+		matched := true
+		for i, part := range parts {
+			if ok, _ := path.Match(part, keys[i]); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// storesSource tells whether the mapping keeps the sources of the
+// documents
+func storesSource(m mapping.IndexMapping) bool {
+	im, ok := m.(*mapping.IndexMappingImpl)
+	return ok && im.StoreSource
+}
+
+// sourceDocument maps the document read again whole from its source,
+// with the source of the partial update merged in, nil if it keeps no
+// source.  It keeps its expiry unless the update sets one.
+func sourceDocument(m mapping.IndexMapping, doc, partial *document.Document) (*document.Document, error) {
+	if len(doc.Source) == 0 || len(partial.Source) == 0 {
+		return nil, nil
+	}
+	source, err := document.MergeSource(doc.Source, partial.Source)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	err = json.Unmarshal(source, &data)
+	if err != nil {
+		return nil, err
+	}
+	rv := document.NewDocument(doc.ID)
+	err = m.MapDocument(rv, data)
+	if err != nil {
+		return nil, err
+	}
+	rv.Source = source
+	rv.Version = doc.Version
+	if partial.Version != 0 {
+		rv.Version = partial.Version
+	}
+	rv.Expires = doc.Expires
+	if !partial.Expires.IsZero() {
+		rv.Expires = partial.Expires
+	}
+	return rv, nil
+}
+
+// updateFromSource replaces the fields of the document named like those
+// of partial by mapping it again whole from its source, so that its
+// composite fields and nested documents follow.  A document keeping no
+// source is updated partially.  Without a version to check, the update
+// is tried again when another writer gets in between.
+func (i *indexImpl) updateFromSource(partial *document.Document) error {
+	for {
+		indexReader, err := i.i.Reader()
+		if err != nil {
+			return err
+		}
+		doc, err := indexReader.Document(partial.ID)
+		if cerr := indexReader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			return index.ErrorDocumentNotFound
+		}
+		full, err := sourceDocument(i.m, doc, partial)
+		if err != nil {
+			return err
+		}
+		if full == nil {
+			return i.i.UpdateFields(partial)
+		}
+		err = i.i.Update(full)
+		if _, conflict := index.AsVersionConflict(err); conflict && partial.Version == 0 {
+			continue
+		}
+		return err
+	}
+}
@@ -0,0 +1,220 @@
+package flock
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/wrble/flock/mapping"
+)
+
+func TestSourceFilter(t *testing.T) {
+	source := json.RawMessage(`{"name":"a","address":{"city":"b","zip":"c"},"comments":[{"author":"d","text":"e"},{"author":"f"}]}`)
+	tests := []struct {
+		filter   *SourceFilter
+		expected string
+	}{
+		{
+			filter:   NewSourceFilter(),
+			expected: string(source),
+		},
+		{
+			filter:   NewSourceFilter("name", "address.city"),
+			expected: `{"address":{"city":"b"},"name":"a"}`,
+		},
+		{
+			filter:   NewSourceFilter("address"),
+			expected: `{"address":{"city":"b","zip":"c"}}`,
+		},
+		{
+			filter:   NewSourceFilter("comments.author"),
+			expected: `{"comments":[{"author":"d"},{"author":"f"}]}`,
+		},
+		{
+			filter:   NewSourceFilter("*.c*"),
+			expected: `{"address":{"city":"b"}}`,
+		},
+		{
+			filter:   &SourceFilter{Excludes: []string{"address.zip", "comments"}},
+			expected: `{"address":{"city":"b"},"name":"a"}`,
+		},
+		{
+			filter:   &SourceFilter{Includes: []string{"address"}, Excludes: []string{"address.*"}},
+			expected: `{"address":{}}`,
+		},
+		{
+			filter:   NewSourceFilter("missing"),
+			expected: `{}`,
+		},
+	}
+	for i, test := range tests {
+		actual, err := test.filter.Filter(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != test.expected {
+			t.Errorf("%d: expected %s, got %s", i, test.expected, actual)
+		}
+	}
+}
+
+func TestParseSourceFilter(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected *SourceFilter
+	}{
+		{input: ``},
+		{input: `null`},
+		{input: `false`},
+		{input: `true`, expected: &SourceFilter{}},
+		{input: `["a","b.*"]`, expected: &SourceFilter{Includes: []string{"a", "b.*"}}},
+		{input: `{"excludes":["c"]}`, expected: &SourceFilter{Excludes: []string{"c"}}},
+	}
+	for _, test := range tests {
+		actual, err := ParseSourceFilter(json.RawMessage(test.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (actual == nil) != (test.expected == nil) {
+			t.Errorf("expected %v for %s, got %v", test.expected, test.input, actual)
+			continue
+		}
+		if actual != nil && (len(actual.Includes) != len(test.expected.Includes) || len(actual.Excludes) != len(test.expected.Excludes)) {
+			t.Errorf("expected %v for %s, got %v", test.expected, test.input, actual)
+		}
+	}
+
+	_, err := ParseSourceFilter(json.RawMessage(`"a"`))
+	if err == nil {
+		t.Errorf("expected an error for a string")
+	}
+}
+
+func sourceTestIndex(t *testing.T) Index {
+	m := NewIndexMapping()
+	m.StoreSource = true
+	m.DefaultMapping.AddSubDocumentMapping("comments", mapping.NewDocumentNestedMapping())
+	index, err := NewTempIndex(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Index("a", map[string]interface{}{
+		"name":     "marty",
+		"city":     "hill valley",
+		"year":     1985,
+		"comments": []interface{}{map[string]interface{}{"author": "doc"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return index
+}
+
+func TestSource(t *testing.T) {
+	index := sourceTestIndex(t)
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	doc, err := index.Document("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"city":"hill valley","comments":[{"author":"doc"}],"name":"marty","year":1985}`
+	if string(doc.Source) != expected {
+		t.Errorf("expected source %s, got %s", expected, doc.Source)
+	}
+
+	req := NewSearchRequest(NewMatchQuery("marty"))
+	req.Source = NewSourceFilter("name", "year")
+	res, err := index.Search(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 || string(res.Hits[0].Source) != `{"name":"marty","year":1985}` {
+		t.Errorf("expected the filtered source of a, got %v", res.Hits)
+	}
+	req.Source = nil
+	res, err = index.Search(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 || res.Hits[0].Source != nil {
+		t.Errorf("expected no source unless asked, got %s", res.Hits[0].Source)
+	}
+}
+
+func TestSourceUpdateFields(t *testing.T) {
+	index := sourceTestIndex(t)
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// the document is mapped again from its source, the _all field and
+	// the nested documents follow
+	err := index.UpdateFields("a", map[string]interface{}{
+		"city":     "twin pines",
+		"comments": []interface{}{map[string]interface{}{"author": "biff"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := index.Document("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"city":"twin pines","comments":[{"author":"biff"}],"name":"marty","year":1985}`
+	if string(doc.Source) != expected {
+		t.Errorf("expected source %s, got %s", expected, doc.Source)
+	}
+	if doc.Version != 2 {
+		t.Errorf("expected version 2, got %d", doc.Version)
+	}
+	for q, expected := range map[string]uint64{"pines": 1, "valley": 0, "marty": 1} {
+		res, err := index.Search(NewSearchRequest(NewMatchQuery(q)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Total != expected {
+			t.Errorf("expected %d documents for %s in _all, got %d", expected, q, res.Total)
+		}
+	}
+	author := NewTermQuery("biff")
+	author.SetField("comments.author")
+	res, err := index.Search(NewSearchRequest(NewNestedQuery("comments", author)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 {
+		t.Errorf("expected the nested documents updated, got %v", res.Hits)
+	}
+
+	// partial updates of a batch merge into the source too
+	batch := index.NewBatch()
+	err = batch.UpdateFields("a", map[string]interface{}{"name": "emmett"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err = index.Document("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `{"city":"twin pines","comments":[{"author":"biff"}],"name":"emmett","year":1985}`
+	if string(doc.Source) != expected {
+		t.Errorf("expected source %s, got %s", expected, doc.Source)
+	}
+
+	err = index.UpdateFields("b", map[string]interface{}{"name": "biff"})
+	if err != ErrorDocumentNotFound {
+		t.Errorf("expected document not found, got %v", err)
+	}
+}
@@ -10,14 +10,23 @@ import (
 )
 
 func main() {
-	store, err := cassandra.New(nil, flock.Config.DefaultKVConfig)
+	config := map[string]interface{}{
+		"create_if_missing": true,
+	}
+	for k, v := range flock.Config.DefaultKVConfig {
+		config[k] = v
+	}
+
+	// creates the keyspace and runs the schema migrations
+	store, err := cassandra.New(nil, config)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer store.Close()
 
-	err = cassandra.CreateTables(store.(*cassandra.Store).Session)
+	version, err := cassandra.ReadSchemaVersion(store.(*cassandra.Store).Session)
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Println("Tables created!")
+	fmt.Println("Tables created! Schema version", version)
 }